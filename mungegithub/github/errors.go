@@ -0,0 +1,83 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/google/go-github/github"
+)
+
+// APIError is a failed github API call, classified by whether trying again
+// later might work: server errors, rate limiting and network trouble are
+// Retryable, a 404 or 422 isn't.
+type APIError struct {
+	Err       error
+	Retryable bool
+}
+
+func (e *APIError) Error() string {
+	return e.Err.Error()
+}
+
+// classifyError wraps err, which is from the github client, in an APIError.
+func classifyError(err error) *APIError {
+	return &APIError{Err: err, Retryable: isRetryable(err)}
+}
+
+func isRetryable(err error) bool {
+	switch e := err.(type) {
+	case *github.RateLimitError:
+		return true
+	case *github.ErrorResponse:
+		if e.Response == nil {
+			return false
+		}
+		code := e.Response.StatusCode
+		return code >= 500 || code == http.StatusTooManyRequests
+	case *url.Error:
+		return true
+	case net.Error:
+		return true
+	}
+	return false
+}
+
+// IsRetryable returns true if err is an APIError worth retrying.
+func IsRetryable(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.Retryable
+}
+
+// recordError remembers err, from the github client, as the object's
+// LastError.
+func (obj *MungeObject) recordError(err error) {
+	obj.lastError = classifyError(err)
+}
+
+// LastError returns the failure of the last of the calls, like GetCommits
+// and GetEvents, which only say whether they worked, or nil if it worked.
+// Each of those calls, and Refresh, starts by clearing it. Callers can check
+// IsRetryable(obj.LastError()) to tell a blip from a real problem.
+func (obj *MungeObject) LastError() error {
+	if obj.lastError == nil {
+		return nil
+	}
+	return obj.lastError
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"net/http"
+	"testing"
+
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+)
+
+func TestErrorClassification(t *testing.T) {
+	rateLimited := func(w http.ResponseWriter) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "API rate limit exceeded for k8s-merge-robot."}`))
+	}
+	tests := []struct {
+		name      string
+		respond   func(w http.ResponseWriter)
+		retryable bool
+	}{
+		{"server error", func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) }, true},
+		{"too many requests", func(w http.ResponseWriter) { w.WriteHeader(http.StatusTooManyRequests) }, true},
+		{"rate limited", rateLimited, true},
+		{"not found", func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) }, false},
+		{"unprocessable", func(w http.ResponseWriter) { w.WriteHeader(http.StatusUnprocessableEntity) }, false},
+	}
+	calls := map[string]func(obj *MungeObject) bool{
+		"GetCommits": func(obj *MungeObject) bool { _, ok := obj.GetCommits(); return ok },
+		"GetEvents":  func(obj *MungeObject) bool { _, ok := obj.GetEvents(); return ok },
+		"GetStatus":  func(obj *MungeObject) bool { _, ok := obj.getCombinedStatus(); return ok },
+	}
+	for _, test := range tests {
+		for name, call := range calls {
+			issue := github_test.Issue("bob", 1, nil, true)
+			pr := github_test.PullRequest("bob", false, true, true)
+			client, server, mux := github_test.InitServer(t, issue, pr, nil, nil, nil, nil, nil)
+			for _, path := range []string{"/repos/o/r/pulls/1/commits", "/repos/o/r/issues/1/events", "/repos/o/r/commits/" + *pr.Head.SHA + "/status"} {
+				mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) { test.respond(w) })
+			}
+			config := &Config{}
+			config.Org = "o"
+			config.Project = "r"
+			config.SetClient(client)
+			obj := TestObject(config, issue, pr, nil, nil)
+
+			if obj.LastError() != nil {
+				t.Errorf("%s %s: expected no error before any calls, got %v", test.name, name, obj.LastError())
+			}
+			if call(obj) {
+				t.Errorf("%s %s: expected the call to fail", test.name, name)
+			}
+			if _, ok := obj.LastError().(*APIError); !ok {
+				t.Errorf("%s %s: expected an *APIError, got %#v", test.name, name, obj.LastError())
+			}
+			if retryable := IsRetryable(obj.LastError()); retryable != test.retryable {
+				t.Errorf("%s %s: expected retryable=%v, got %v: %v", test.name, name, test.retryable, retryable, obj.LastError())
+			}
+			server.Close()
+		}
+	}
+
+	// With the server gone it is a network error
+	issue := github_test.Issue("bob", 1, nil, true)
+	client, server, _ := github_test.InitServer(t, issue, nil, nil, nil, nil, nil, nil)
+	server.Close()
+	config := &Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+	obj := TestObject(config, issue, nil, nil, nil)
+	if _, ok := obj.GetEvents(); ok || !IsRetryable(obj.LastError()) {
+		t.Errorf("expected a network error to be retryable, got %v", obj.LastError())
+	}
+}
+
+func TestLastErrorCleared(t *testing.T) {
+	issue := github_test.Issue("bob", 1, nil, true)
+	pr := github_test.PullRequest("bob", false, true, true)
+	client, server, mux := github_test.InitServer(t, issue, pr, nil, nil, nil, nil, nil)
+	defer server.Close()
+	fail := true
+	mux.HandleFunc("/repos/o/r/issues/1/events", func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("[]"))
+	})
+	config := &Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+	obj := TestObject(config, issue, pr, nil, nil)
+
+	if _, ok := obj.GetEvents(); ok || !IsRetryable(obj.LastError()) {
+		t.Fatalf("expected a retryable failure, got %v", obj.LastError())
+	}
+	if !obj.Refresh() {
+		t.Fatalf("unexpected failure to refresh")
+	}
+	if obj.LastError() != nil {
+		t.Errorf("expected Refresh to clear the error, got %v", obj.LastError())
+	}
+
+	if _, ok := obj.GetEvents(); ok {
+		t.Fatalf("expected the call to fail")
+	}
+	fail = false
+	if _, ok := obj.GetEvents(); !ok {
+		t.Fatalf("expected the call to work")
+	}
+	if obj.LastError() != nil {
+		t.Errorf("expected a success to clear the error, got %v", obj.LastError())
+	}
+}
@@ -19,6 +19,7 @@ package github
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	goflag "flag"
 	"fmt"
 	"io/ioutil"
@@ -32,6 +33,7 @@ import (
 	"text/tabwriter"
 	"time"
 
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
 	"k8s.io/kubernetes/pkg/util/sets"
 
 	"github.com/golang/glog"
@@ -56,6 +58,12 @@ const (
 	headerRateReset     = "X-RateLimit-Reset"
 
 	maxCommentLen = 65535
+
+	// PRs whose mergeability is unknown are refetched this many times by
+	// default, sleeping 2^attempt BaseWaitTimes in between but never more
+	// than 2^maxMergeableBackoff.
+	defaultMergeableAttempts = 5
+	maxMergeableBackoff      = 5
 )
 
 var (
@@ -80,8 +88,20 @@ type callLimitRoundTripper struct {
 	delegate  http.RoundTripper
 	remaining int
 	resetTime time.Time
+	// warnBelow is how few remaining calls are worth a warning
+	warnBelow int
+}
+
+// postedComment is the last comment the bot wrote on an issue
+type postedComment struct {
+	body string
+	at   time.Time
 }
 
+// commentLock protects the lastComments of every Config. It isn't part of
+// Config so the configs for extra repos can be copied from the main one.
+var commentLock sync.Mutex
+
 func (c *callLimitRoundTripper) getTokenExcept(remaining int) {
 	c.Lock()
 	if c.remaining > remaining {
@@ -110,12 +130,18 @@ func (c *callLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, er
 		c.delegate = http.DefaultTransport
 	}
 	c.getToken()
+	apiMetrics.Calls.WithLabelValues(apiCategory(req.URL.Path)).Inc()
 	resp, err := c.delegate.RoundTrip(req)
 	c.Lock()
 	defer c.Unlock()
 	if resp != nil {
 		if remaining := resp.Header.Get(headerRateRemaining); remaining != "" {
+			last := c.remaining
 			c.remaining, _ = strconv.Atoi(remaining)
+			apiMetrics.RateRemaining.Set(float64(c.remaining))
+			if c.remaining < c.warnBelow && last >= c.warnBelow {
+				glog.Warningf("Only %d github API calls are left until %v", c.remaining, c.resetTime)
+			}
 		}
 		if reset := resp.Header.Get(headerRateReset); reset != "" {
 			if v, _ := strconv.ParseInt(reset, 10, 64); v != 0 {
@@ -127,7 +153,9 @@ func (c *callLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, er
 }
 
 // By default github responds to PR requests with:
-//    Cache-Control:[private, max-age=60, s-maxage=60]
+//
+//	Cache-Control:[private, max-age=60, s-maxage=60]
+//
 // Which means the httpcache would not consider anything stale for 60 seconds.
 // However, when we re-check 'PR.mergeable' we need to skip the cache.
 // I considered checking the req.URL.Path and only setting max-age=0 when
@@ -156,7 +184,17 @@ type Config struct {
 	apiLimit *callLimitRoundTripper
 	Org      string
 	Project  string
-	Url      string
+
+	// EnterpriseBaseURL and EnterpriseUploadURL point the client at a
+	// GitHub Enterprise server rather than github.com. If only the base
+	// URL is given it is used for uploads too.
+	EnterpriseBaseURL   string
+	EnterpriseUploadURL string
+
+	// ExtraRepos are "org/project" repos whose PRs the submit queue handles
+	// in addition to Org/Project's. See Repos().
+	ExtraRepos  []string
+	repoConfigs []*Config
 
 	State  string
 	Labels []string
@@ -165,6 +203,12 @@ type Config struct {
 	token     string
 	TokenFile string
 
+	// mergeToken, if set, is used for merging PRs so the merges are done
+	// by a separate "merge bot" account. Everything else uses token.
+	mergeToken     string
+	MergeTokenFile string
+	mergeClient    *github.Client
+
 	Address string // if a munger runs a web server, where it should live
 	WWWRoot string
 
@@ -178,9 +222,32 @@ type Config struct {
 	// If true, don't make any mutating API calls
 	DryRun bool
 
+	// A warning is logged when fewer than this many github API calls are
+	// left before the rate limit resets.
+	RateLimitWarning int
+
 	// Base sleep time for retry loops. Defaults to 1 second.
 	BaseWaitTime time.Duration
 
+	// How many times to refetch a PR whose mergeability github hasn't
+	// computed yet, backing off between attempts, before giving up.
+	// Defaults to defaultMergeableAttempts.
+	MergeableAttempts int
+
+	// An identical comment on the same issue within this window is not
+	// posted again by WriteComment. Zero disables the check.
+	CommentDedupWindow time.Duration
+	lastComments       map[int]postedComment
+	clock              utilclock.Clock
+
+	// IgnoredStatusContexts are left out of every PR's combined status, so
+	// they affect neither the overall state nor any required contexts.
+	IgnoredStatusContexts []string
+
+	// If true, fetch each PR's issue, commits, events and status with one
+	// GraphQL query instead of a REST call for each.
+	UseGraphQL bool
+
 	// When we clear analytics we store the last values here
 	lastAnalytics analytics
 	analytics     analytics
@@ -222,6 +289,8 @@ type analytics struct {
 	ListFiles            analytic
 	GetCombinedStatus    analytic
 	SetStatus            analytic
+	ListCheckRuns        analytic
+	SetCheckRun          analytic
 	GetPR                analytic
 	AssignPR             analytic
 	UnassignPR           analytic
@@ -230,6 +299,9 @@ type analytics struct {
 	GetContents          analytic
 	ListComments         analytic
 	ListReviewComments   analytic
+	ListReviews          analytic
+	ListReviewRequests   analytic
+	RequestReviews       analytic
 	CreateComment        analytic
 	DeleteComment        analytic
 	EditComment          analytic
@@ -238,7 +310,16 @@ type analytics struct {
 	SetMilestone         analytic
 	ListMilestones       analytic
 	GetBranch            analytic
+	GetRepo              analytic
 	EditBranch           analytic
+	CreateRef            analytic
+	CreateDeployment     analytic
+	DeleteRef            analytic
+	MergeBranch          analytic
+	SearchIssues         analytic
+	CompareCommits       analytic
+	UpdateBranch         analytic
+	GraphQL              analytic
 }
 
 func (a analytics) print() {
@@ -262,12 +343,17 @@ func (a analytics) print() {
 	fmt.Fprintf(w, "ListFiles\t%d\t\n", a.ListFiles.Count)
 	fmt.Fprintf(w, "GetCombinedStatus\t%d\t\n", a.GetCombinedStatus.Count)
 	fmt.Fprintf(w, "SetStatus\t%d\t\n", a.SetStatus.Count)
+	fmt.Fprintf(w, "ListCheckRuns\t%d\t\n", a.ListCheckRuns.Count)
+	fmt.Fprintf(w, "SetCheckRun\t%d\t\n", a.SetCheckRun.Count)
 	fmt.Fprintf(w, "GetPR\t%d\t\n", a.GetPR.Count)
 	fmt.Fprintf(w, "AssignPR\t%d\t\n", a.AssignPR.Count)
 	fmt.Fprintf(w, "ClosePR\t%d\t\n", a.ClosePR.Count)
 	fmt.Fprintf(w, "OpenPR\t%d\t\n", a.OpenPR.Count)
 	fmt.Fprintf(w, "GetContents\t%d\t\n", a.GetContents.Count)
 	fmt.Fprintf(w, "ListReviewComments\t%d\t\n", a.ListReviewComments.Count)
+	fmt.Fprintf(w, "ListReviews\t%d\t\n", a.ListReviews.Count)
+	fmt.Fprintf(w, "ListReviewRequests\t%d\t\n", a.ListReviewRequests.Count)
+	fmt.Fprintf(w, "RequestReviews\t%d\t\n", a.RequestReviews.Count)
 	fmt.Fprintf(w, "ListComments\t%d\t\n", a.ListComments.Count)
 	fmt.Fprintf(w, "CreateComment\t%d\t\n", a.CreateComment.Count)
 	fmt.Fprintf(w, "DeleteComment\t%d\t\n", a.DeleteComment.Count)
@@ -276,7 +362,16 @@ func (a analytics) print() {
 	fmt.Fprintf(w, "SetMilestone\t%d\t\n", a.SetMilestone.Count)
 	fmt.Fprintf(w, "ListMilestones\t%d\t\n", a.ListMilestones.Count)
 	fmt.Fprintf(w, "GetBranch\t%d\t\n", a.GetBranch.Count)
+	fmt.Fprintf(w, "GetRepo\t%d\t\n", a.GetRepo.Count)
 	fmt.Fprintf(w, "EditBranch\t%d\t\n", a.EditBranch.Count)
+	fmt.Fprintf(w, "CreateRef\t%d\t\n", a.CreateRef.Count)
+	fmt.Fprintf(w, "CreateDeployment\t%d\t\n", a.CreateDeployment.Count)
+	fmt.Fprintf(w, "DeleteRef\t%d\t\n", a.DeleteRef.Count)
+	fmt.Fprintf(w, "MergeBranch\t%d\t\n", a.MergeBranch.Count)
+	fmt.Fprintf(w, "SearchIssues\t%d\t\n", a.SearchIssues.Count)
+	fmt.Fprintf(w, "CompareCommits\t%d\t\n", a.CompareCommits.Count)
+	fmt.Fprintf(w, "UpdateBranch\t%d\t\n", a.UpdateBranch.Count)
+	fmt.Fprintf(w, "GraphQL\t%d\t\n", a.GraphQL.Count)
 	w.Flush()
 	glog.V(2).Infof("\n%v", buf)
 }
@@ -287,6 +382,7 @@ type MungeObject struct {
 	config      *Config
 	Issue       *github.Issue
 	pr          *github.PullRequest
+	draft       *bool
 	commits     []*github.RepositoryCommit
 	events      []*github.IssueEvent
 	comments    []*github.IssueComment
@@ -297,9 +393,29 @@ type MungeObject struct {
 	combinedStatus     *github.CombinedStatus
 	combinedStatusTime time.Time
 
+	// and the check runs of the head commit for as long.
+	checkRuns     []*CheckRun
+	checkRunsSHA  string
+	checkRunsTime time.Time
+
+	// prefetchedEvents is true if events came from the GraphQL query and
+	// are still current, so GetEvents can return them.
+	prefetchedEvents bool
+
+	// lastError is the most recent failed call, see LastError.
+	lastError *APIError
+
+	// mergeSHA is the commit github made when it merged the PR.
+	mergeSHA string
+
 	Annotations map[string]string //annotations are things you can set yourself.
 }
 
+// Repo returns the "org/project" the object belongs to.
+func (obj *MungeObject) Repo() string {
+	return obj.config.FullName()
+}
+
 // Number is short for *obj.Issue.Number.
 func (obj *MungeObject) Number() int {
 	return *obj.Issue.Number
@@ -354,18 +470,27 @@ func SetCombinedStatusLifetime(lifetime time.Duration) {
 func (config *Config) AddRootFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVar(&config.token, "token", "", "The OAuth Token to use for requests.")
 	cmd.PersistentFlags().StringVar(&config.TokenFile, "token-file", "", "The file containing the OAuth token to use for requests.")
+	cmd.PersistentFlags().StringVar(&config.mergeToken, "merge-token", "", "The OAuth Token to use for merging PRs. If unset --token is used.")
+	cmd.PersistentFlags().StringVar(&config.MergeTokenFile, "merge-token-file", "", "The file containing the OAuth token to use for merging PRs.")
 	cmd.PersistentFlags().IntVar(&config.MinPRNumber, "min-pr-number", 0, "The minimum PR to start with")
 	cmd.PersistentFlags().IntVar(&config.MaxPRNumber, "max-pr-number", maxInt, "The maximum PR to start with")
 	cmd.PersistentFlags().BoolVar(&config.DryRun, "dry-run", true, "If true, don't actually merge anything")
 	cmd.PersistentFlags().StringVar(&config.Org, "organization", "", "The github organization to scan")
 	cmd.PersistentFlags().StringVar(&config.Project, "project", "", "The github project to scan")
+	cmd.PersistentFlags().StringSliceVar(&config.ExtraRepos, "extra-repos", []string{}, "CSV list of org/project repos whose PRs the submit queue also merges, with the same settings. Other mungers only handle --organization/--project.")
 	cmd.PersistentFlags().StringVar(&config.State, "state", "", "State of PRs to process: 'open', 'all', etc")
 	cmd.PersistentFlags().StringSliceVar(&config.Labels, "labels", []string{}, "CSV list of label which should be set on processed PRs. Unset is all labels.")
 	cmd.PersistentFlags().StringVar(&config.Address, "address", ":8080", "The address to listen on for HTTP Status")
 	cmd.PersistentFlags().StringVar(&config.WWWRoot, "www", "www", "Path to static web files to serve from the webserver")
 	cmd.PersistentFlags().StringVar(&config.HTTPCacheDir, "http-cache-dir", "", "Path to directory where github data can be cached across restarts, if unset use in memory cache")
 	cmd.PersistentFlags().Uint64Var(&config.HTTPCacheSize, "http-cache-size", 1000, "Maximum size for the HTTP cache (in MB)")
-	cmd.PersistentFlags().StringVar(&config.Url, "url", "", "The GitHub Enterprise server url (default: https://api.github.com/)")
+	cmd.PersistentFlags().StringVar(&config.EnterpriseBaseURL, "url", "", "The GitHub Enterprise API url, like https://github.example.com/api/v3/ (default: https://api.github.com/)")
+	cmd.PersistentFlags().StringVar(&config.EnterpriseUploadURL, "upload-url", "", "The GitHub Enterprise upload url, like https://github.example.com/api/uploads/ (default: the --url, or https://uploads.github.com/ if that is unset)")
+	cmd.PersistentFlags().StringSliceVar(&config.IgnoredStatusContexts, "ignored-contexts", []string{}, "CSV list of status contexts, like a deploy preview, which never affect whether a PR is green")
+	cmd.PersistentFlags().BoolVar(&config.UseGraphQL, "use-graphql", false, "If true, fetch each PR along with its commits, events and status in a single GraphQL query rather than with separate REST calls")
+	cmd.PersistentFlags().IntVar(&config.RateLimitWarning, "rate-limit-warning", 1000, "Log a warning when fewer than this many github API calls are left before the rate limit resets")
+	cmd.PersistentFlags().IntVar(&config.MergeableAttempts, "mergeable-attempts", defaultMergeableAttempts, "How many times to refetch a PR, with backoff, while github is still computing whether it is mergeable")
+	cmd.PersistentFlags().DurationVar(&config.CommentDedupWindow, "comment-dedup-window", time.Hour, "Don't post a comment identical to the last one on the same issue within this long, except for e2e triggers and command replies. 0 disables.")
 	cmd.PersistentFlags().AddGoFlagSet(goflag.CommandLine)
 }
 
@@ -383,6 +508,11 @@ func (config *Config) PreExecute() error {
 	if len(config.Project) == 0 {
 		glog.Fatalf("--project is required.")
 	}
+	for _, repo := range config.ExtraRepos {
+		if _, _, ok := splitRepo(repo); !ok {
+			glog.Fatalf("--extra-repos entry %q must be of the form org/project", repo)
+		}
+	}
 
 	token := config.token
 	if len(token) == 0 && len(config.TokenFile) != 0 {
@@ -393,6 +523,15 @@ func (config *Config) PreExecute() error {
 		token = strings.TrimSpace(string(data))
 		config.token = token
 	}
+	mergeToken := config.mergeToken
+	if len(mergeToken) == 0 && len(config.MergeTokenFile) != 0 {
+		data, err := ioutil.ReadFile(config.MergeTokenFile)
+		if err != nil {
+			glog.Fatalf("error reading merge token file: %v", err)
+		}
+		mergeToken = strings.TrimSpace(string(data))
+		config.mergeToken = mergeToken
+	}
 
 	// We need to get our Transport/RoundTripper in order based on arguments
 	//    oauth2 Transport // if we have an auth token
@@ -406,6 +545,7 @@ func (config *Config) PreExecute() error {
 	callLimitTransport := &callLimitRoundTripper{
 		remaining: tokenLimit + 500, // put in 500 so we at least have a couple to check our real limits
 		resetTime: time.Now().Add(1 * time.Minute),
+		warnBelow: config.RateLimitWarning,
 	}
 	config.apiLimit = callLimitTransport
 	transport = callLimitTransport
@@ -433,25 +573,21 @@ func (config *Config) PreExecute() error {
 
 	transport = zeroCacheTransport
 
-	if len(token) > 0 {
-		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-		transport = &oauth2.Transport{
-			Base:   transport,
-			Source: oauth2.ReuseTokenSource(nil, ts),
-		}
-	}
-
-	client := &http.Client{
-		Transport: transport,
-	}
-	config.client = github.NewClient(client)
-	rawurl := config.Url
-	if len(rawurl) > 0 {
-		url, err := url.Parse(rawurl)
-		if err != nil {
-			glog.Fatalf("Unable to parse url: %v: %v", rawurl, err)
+	config.client = github.NewClient(&http.Client{
+		Transport: withToken(transport, token),
+	})
+	if err := config.setEnterpriseURLs(config.client); err != nil {
+		glog.Fatalf("%v", err)
+	}
+	config.mergeClient = nil
+	if len(mergeToken) > 0 {
+		// Merges share the rate limiting, but go out as the merge bot
+		config.mergeClient = github.NewClient(&http.Client{
+			Transport: withToken(transport, mergeToken),
+		})
+		if err := config.setEnterpriseURLs(config.mergeClient); err != nil {
+			glog.Fatalf("%v", err)
 		}
-		config.client.BaseURL = url
 	}
 	config.ResetAPICount()
 	return nil
@@ -493,6 +629,25 @@ func (config *Config) ServeDebugStats(path string) {
 	http.HandleFunc(path, config.serveDebugStats)
 }
 
+// serveOnce makes sure only one server listens on Address
+var serveOnce sync.Once
+
+// ListenAndServe starts serving http.DefaultServeMux on Address in the
+// background, if Address is set. Only the first call starts a server, so
+// every munger with pages to serve, and the webhook, can call it.
+func (config *Config) ListenAndServe() {
+	if config.Address == "" {
+		return
+	}
+	serveOnce.Do(func() {
+		go func() {
+			if err := http.ListenAndServe(config.Address, nil); err != nil {
+				glog.Errorf("Unable to serve on %s: %v", config.Address, err)
+			}
+		}()
+	})
+}
+
 // NextExpectedUpdate will set the debug information concerning when the
 // mungers are likely to run again.
 func (config *Config) NextExpectedUpdate(t time.Time) {
@@ -511,19 +666,145 @@ func (config *Config) ResetAPICount() {
 	config.analytics.lastAPIReset = time.Now()
 }
 
+func splitRepo(repo string) (string, string, bool) {
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// FullName returns "org/project" for the repo the config is for.
+func (config *Config) FullName() string {
+	return config.Org + "/" + config.Project
+}
+
+// Repos returns a config for every repo this process handles: config itself
+// followed by one for each of ExtraRepos. The extra configs are copies of
+// config for a different Org/Project, so they share its client, API limits
+// and cache, but keep their own analytics and comment history.
+func (config *Config) Repos() []*Config {
+	if config.repoConfigs != nil {
+		return config.repoConfigs
+	}
+	repos := []*Config{config}
+	for _, repo := range config.ExtraRepos {
+		org, project, ok := splitRepo(repo)
+		if !ok {
+			glog.Errorf("Ignoring invalid repo %q", repo)
+			continue
+		}
+		extra := *config
+		extra.Org = org
+		extra.Project = project
+		extra.ExtraRepos = nil
+		extra.lastComments = nil
+		repos = append(repos, &extra)
+	}
+	config.repoConfigs = repos
+	return repos
+}
+
+// withToken wraps transport so its requests are authorized by token. With
+// no token the requests go out unauthenticated.
+func withToken(transport http.RoundTripper, token string) http.RoundTripper {
+	if len(token) == 0 {
+		return transport
+	}
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	return &oauth2.Transport{
+		Base:   transport,
+		Source: oauth2.ReuseTokenSource(nil, ts),
+	}
+}
+
 // SetClient should ONLY be used by testing. Normal commands should use PreExecute()
+// The client keeps its own BaseURL and UploadURL, which for tests point at
+// the httptest server, unless EnterpriseBaseURL or EnterpriseUploadURL is set.
 func (config *Config) SetClient(client *github.Client) {
+	if err := config.setEnterpriseURLs(client); err != nil {
+		glog.Errorf("%v", err)
+	}
 	config.client = client
 }
 
-func (config *Config) getPR(num int) (*github.PullRequest, error) {
-	pr, response, err := config.client.PullRequests.Get(config.Org, config.Project, num)
+// SetMergeClient should ONLY be used by testing. It sets the client used to
+// merge PRs, as PreExecute does when --merge-token is given.
+func (config *Config) SetMergeClient(client *github.Client) {
+	if err := config.setEnterpriseURLs(client); err != nil {
+		glog.Errorf("%v", err)
+	}
+	config.mergeClient = client
+}
+
+// mergeAPIClient returns the client to merge PRs with: the merge bot's if
+// there is one, otherwise the usual client.
+func (config *Config) mergeAPIClient() *github.Client {
+	if config.mergeClient != nil {
+		return config.mergeClient
+	}
+	return config.client
+}
+
+// setEnterpriseURLs points client at the GitHub Enterprise server, if one is
+// configured. Otherwise the client is left as it is, talking to github.com.
+func (config *Config) setEnterpriseURLs(client *github.Client) error {
+	if config.EnterpriseBaseURL == "" && config.EnterpriseUploadURL == "" {
+		return nil
+	}
+	upload := config.EnterpriseUploadURL
+	if upload == "" {
+		upload = config.EnterpriseBaseURL
+	}
+	if config.EnterpriseBaseURL != "" {
+		base, err := parseEnterpriseURL(config.EnterpriseBaseURL)
+		if err != nil {
+			return err
+		}
+		client.BaseURL = base
+	}
+	uploadURL, err := parseEnterpriseURL(upload)
+	if err != nil {
+		return err
+	}
+	client.UploadURL = uploadURL
+	return nil
+}
+
+// parseEnterpriseURL parses rawurl, adding the trailing slash go-github
+// needs to resolve API paths against it.
+func parseEnterpriseURL(rawurl string) (*url.URL, error) {
+	if !strings.HasSuffix(rawurl, "/") {
+		rawurl += "/"
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse url: %v: %v", rawurl, err)
+	}
+	return u, nil
+}
+
+// getPR fetches a PR along with its draft flag, which the vendored go-github
+// doesn't decode.
+func (config *Config) getPR(num int) (*github.PullRequest, bool, error) {
+	u := fmt.Sprintf("repos/%v/%v/pulls/%d", config.Org, config.Project, num)
+	req, err := config.client.NewRequest("GET", u, nil)
+	if err != nil {
+		glog.Errorf("Error building request for PR# %d: %v", num, err)
+		return nil, false, err
+	}
+	req.Header.Set("Accept", draftsMediaType)
+	pr := struct {
+		github.PullRequest
+		Draft *bool `json:"draft,omitempty"`
+	}{}
+	response, err := config.client.Do(req, &pr)
 	config.analytics.GetPR.Call(config, response)
 	if err != nil {
 		glog.Errorf("Error getting PR# %d: %v", num, err)
-		return nil, err
+		return nil, false, err
 	}
-	return pr, nil
+	return &pr.PullRequest, pr.Draft != nil && *pr.Draft, nil
 }
 
 func (config *Config) getIssue(num int) (*github.Issue, error) {
@@ -616,6 +897,20 @@ func (config *Config) needsBranchProtection(branch *github.Branch, contexts []st
 	return false
 }
 
+// DefaultBranch returns the name of the repo's default branch
+func (config *Config) DefaultBranch() (string, error) {
+	repo, resp, err := config.client.Repositories.Get(config.Org, config.Project)
+	config.analytics.GetRepo.Call(config, resp)
+	if err != nil {
+		glog.Errorf("Got error getting repo %s/%s: %v", config.Org, config.Project, err)
+		return "", err
+	}
+	if repo.DefaultBranch == nil {
+		return "", fmt.Errorf("repo %s/%s has no default branch", config.Org, config.Project)
+	}
+	return *repo.DefaultBranch, nil
+}
+
 // SetBranchProtection protects a branch and sets the required contexts
 func (config *Config) SetBranchProtection(name string, contexts []string) error {
 	branch, resp, err := config.client.Repositories.GetBranch(config.Org, config.Project, name)
@@ -635,21 +930,27 @@ func (config *Config) SetBranchProtection(name string, contexts []string) error
 // Refresh will refresh the Issue (and PR if this is a PR)
 // (not the commits or events)
 func (obj *MungeObject) Refresh() bool {
+	obj.lastError = nil
 	num := *obj.Issue.Number
 	issue, err := obj.config.getIssue(num)
 	if err != nil {
 		glog.Errorf("Error in Refresh: %v", err)
+		obj.recordError(err)
 		return false
 	}
 	obj.Issue = issue
+	// Events fetched with the PR may be stale now too.
+	obj.prefetchedEvents = false
 	if !obj.IsPR() {
 		return true
 	}
-	pr, err := obj.config.getPR(*obj.Issue.Number)
+	pr, draft, err := obj.config.getPR(*obj.Issue.Number)
 	if err != nil {
+		obj.recordError(err)
 		return false
 	}
 	obj.pr = pr
+	obj.draft = &draft
 	return true
 }
 
@@ -666,8 +967,17 @@ func (config *Config) ListMilestones(state string) ([]*github.Milestone, bool) {
 	return milestones, true
 }
 
-// GetObject will return an object (with only the issue filled in)
+// GetObject will return an object (with only the issue filled in, unless
+// UseGraphQL is set and it is a PR)
 func (config *Config) GetObject(num int) (*MungeObject, error) {
+	if config.UseGraphQL {
+		obj, err := config.getObjectGraphQL(num)
+		if err != nil {
+			glog.Errorf("Falling back to REST for #%d: %v", num, err)
+		} else if obj != nil {
+			return obj, nil
+		}
+	}
 	issue, err := config.getIssue(num)
 	if err != nil {
 		return nil, err
@@ -710,6 +1020,110 @@ func (config *Config) NewIssue(title, body string, labels []string, owner string
 	return obj, nil
 }
 
+// GetBranchHead returns the SHA of the commit at the tip of the branch.
+func (config *Config) GetBranchHead(name string) (string, error) {
+	branch, resp, err := config.client.Repositories.GetBranch(config.Org, config.Project, name)
+	config.analytics.GetBranch.Call(config, resp)
+	if err != nil {
+		glog.Errorf("Got error getting branch %s: %v", name, err)
+		return "", err
+	}
+	if branch.Commit == nil || branch.Commit.SHA == nil {
+		return "", fmt.Errorf("branch %s has no commit", name)
+	}
+	return *branch.Commit.SHA, nil
+}
+
+// CreateBranch creates the branch pointing at sha.
+func (config *Config) CreateBranch(name, sha string) error {
+	config.analytics.CreateRef.Call(config, nil)
+	glog.Infof("Creating branch %s at %s", name, sha)
+	if config.DryRun {
+		return fmt.Errorf("can't create branches in dry-run mode")
+	}
+	ref := &github.Reference{
+		Ref:    stringPtr("refs/heads/" + name),
+		Object: &github.GitObject{SHA: &sha},
+	}
+	if _, _, err := config.client.Git.CreateRef(config.Org, config.Project, ref); err != nil {
+		glog.Errorf("Failed to create branch %s: %v", name, err)
+		return err
+	}
+	return nil
+}
+
+// CreateDeployment creates a github deployment of sha to the environment, for
+// whatever watches the repo's deployments to act on. The commit's statuses
+// aren't checked and nothing is merged into it first.
+func (config *Config) CreateDeployment(sha, environment, description string) error {
+	config.analytics.CreateDeployment.Call(config, nil)
+	glog.Infof("Creating a deployment of %s to %s", sha, environment)
+	if config.DryRun {
+		return nil
+	}
+	_, _, err := config.client.Repositories.CreateDeployment(config.Org, config.Project, &github.DeploymentRequest{
+		Ref:              &sha,
+		Environment:      &environment,
+		Description:      &description,
+		AutoMerge:        boolPtr(false),
+		RequiredContexts: &[]string{},
+	})
+	if err != nil {
+		glog.Errorf("Failed to create a deployment of %s to %s: %v", sha, environment, err)
+		return err
+	}
+	return nil
+}
+
+// MergeIntoBranch merges head, a branch name or SHA, into the branch and
+// returns the SHA of the new merge commit. A merge conflict is an error.
+func (config *Config) MergeIntoBranch(branch, head, message string) (string, error) {
+	config.analytics.MergeBranch.Call(config, nil)
+	glog.Infof("Merging %s into branch %s", head, branch)
+	if config.DryRun {
+		return "", fmt.Errorf("can't merge into branches in dry-run mode")
+	}
+	commit, _, err := config.client.Repositories.Merge(config.Org, config.Project, &github.RepositoryMergeRequest{
+		Base:          &branch,
+		Head:          &head,
+		CommitMessage: &message,
+	})
+	if err != nil {
+		glog.Errorf("Failed to merge %s into branch %s: %v", head, branch, err)
+		return "", err
+	}
+	if commit == nil || commit.SHA == nil {
+		return "", fmt.Errorf("merging %s into branch %s made no commit", head, branch)
+	}
+	return *commit.SHA, nil
+}
+
+// DeleteBranch deletes the branch.
+func (config *Config) DeleteBranch(name string) error {
+	config.analytics.DeleteRef.Call(config, nil)
+	glog.Infof("Deleting branch %s", name)
+	if config.DryRun {
+		return nil
+	}
+	if _, err := config.client.Git.DeleteRef(config.Org, config.Project, "heads/"+name); err != nil {
+		glog.Errorf("Failed to delete branch %s: %v", name, err)
+		return err
+	}
+	return nil
+}
+
+// GetRefStatusState is GetStatusState for any commit, rather than the head
+// of a PR.
+func (config *Config) GetRefStatusState(ref string, requiredContexts []string) (string, error) {
+	combinedStatus, response, err := config.client.Repositories.GetCombinedStatus(config.Org, config.Project, ref, &github.ListOptions{})
+	config.analytics.GetCombinedStatus.Call(config, response)
+	if err != nil {
+		glog.Errorf("Failed to get combined status for %s: %v", ref, err)
+		return "", err
+	}
+	return computeStatus(combinedStatus, requiredContexts), nil
+}
+
 // GetBranchCommits gets recent commits for the given branch.
 func (config *Config) GetBranchCommits(branch string, limit int) ([]*github.RepositoryCommit, error) {
 	commits := []*github.RepositoryCommit{}
@@ -853,6 +1267,22 @@ func (obj *MungeObject) LabelCreator(label string) (string, bool) {
 	return *event.Actor.Login, true
 }
 
+// ForcePushedAfter returns true if github recorded a force-push to the PR's
+// branch after t. Unlike commit dates, which are chosen by whoever pushes,
+// the times of these events can't be faked.
+func (obj *MungeObject) ForcePushedAfter(t time.Time) (bool, bool) {
+	events, ok := obj.GetEvents()
+	if !ok {
+		return false, false
+	}
+	for _, event := range events {
+		if event.Event != nil && *event.Event == "head_ref_force_pushed" && event.CreatedAt != nil && event.CreatedAt.After(t) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
 // HasLabel returns if the label `name` is in the array of `labels`
 func (obj *MungeObject) HasLabel(name string) bool {
 	labels := obj.Issue.Labels
@@ -916,6 +1346,7 @@ func (obj *MungeObject) AddLabels(labels []string) error {
 	if config.DryRun {
 		return nil
 	}
+	obj.prefetchedEvents = false
 	for _, l := range labels {
 		label := github.Label{
 			Name: &l,
@@ -955,6 +1386,7 @@ func (obj *MungeObject) RemoveLabel(label string) error {
 	if config.DryRun {
 		return nil
 	}
+	obj.prefetchedEvents = false
 	if _, err := config.client.Issues.RemoveLabelForIssue(config.Org, config.Project, prNum, label); err != nil {
 		glog.Errorf("Failed to remove %v from issue %d: %v", label, prNum, err)
 		return err
@@ -998,6 +1430,57 @@ func (obj *MungeObject) GetHeadAndBase() (headSHA, baseRef string, ok bool) {
 	return headSHA, baseRef, true
 }
 
+// CommitsBehindBase returns how many commits on the PR's base branch are
+// not in the PR.
+func (obj *MungeObject) CommitsBehindBase() (int, bool) {
+	head, base, ok := obj.GetHeadAndBase()
+	if !ok {
+		return 0, false
+	}
+	config := obj.config
+	comparison, response, err := config.client.Repositories.CompareCommits(config.Org, config.Project, base, head)
+	config.analytics.CompareCommits.Call(config, response)
+	if err != nil {
+		glog.Errorf("PR %d: unable to compare %s with %s: %v", *obj.Issue.Number, head, base, err)
+		return 0, false
+	}
+	if comparison.BehindBy == nil {
+		return 0, true
+	}
+	return *comparison.BehindBy, true
+}
+
+// updateBranchMediaType is required to use the update-branch API.
+const updateBranchMediaType = "application/vnd.github.lydian-preview+json"
+
+// UpdateBranch asks github to merge the PR's base branch into its head
+// branch, as the "Update branch" button does. Github does this in the
+// background. Nothing happens if the head is no longer sha.
+func (obj *MungeObject) UpdateBranch(sha string) bool {
+	config := obj.config
+	prNum := *obj.Issue.Number
+	config.analytics.UpdateBranch.Call(config, nil)
+	glog.Infof("Updating the branch of PR %d at %s", prNum, sha)
+	if config.DryRun {
+		return true
+	}
+
+	u := fmt.Sprintf("repos/%v/%v/pulls/%d/update-branch", config.Org, config.Project, prNum)
+	req, err := config.client.NewRequest("PUT", u, struct {
+		ExpectedHeadSHA string `json:"expected_head_sha"`
+	}{sha})
+	if err != nil {
+		glog.Errorf("%d: unable to build update branch request: %v", prNum, err)
+		return false
+	}
+	req.Header.Set("Accept", updateBranchMediaType)
+	if _, err := config.client.Do(req, nil); err != nil {
+		glog.Errorf("%d: unable to update the branch: %v", prNum, err)
+		return false
+	}
+	return true
+}
+
 // GetSHAFromRef returns the current SHA of the given ref (i.e., branch).
 func (obj *MungeObject) GetSHAFromRef(ref string) (sha string, ok bool) {
 	commit, response, err := obj.config.client.Repositories.GetCommit(obj.config.Org, obj.config.Project, ref)
@@ -1193,6 +1676,10 @@ func (obj *MungeObject) IsPR() bool {
 
 // GetEvents returns a list of all events for a given pr.
 func (obj *MungeObject) GetEvents() ([]*github.IssueEvent, bool) {
+	obj.lastError = nil
+	if obj.prefetchedEvents {
+		return obj.events, true
+	}
 	config := obj.config
 	prNum := *obj.Issue.Number
 	events := []*github.IssueEvent{}
@@ -1209,6 +1696,7 @@ func (obj *MungeObject) GetEvents() ([]*github.IssueEvent, bool) {
 				break
 			}
 			glog.Errorf("Error getting events for issue %d: %v", *obj.Issue.Number, err)
+			obj.recordError(err)
 			return nil, false
 		}
 		if tryNextPageAnyway {
@@ -1269,6 +1757,7 @@ func computeStatus(combinedStatus *github.CombinedStatus, requiredContexts []str
 }
 
 func (obj *MungeObject) getCombinedStatus() (status *github.CombinedStatus, ok bool) {
+	obj.lastError = nil
 	now := time.Now()
 	if now.Before(obj.combinedStatusTime.Add(combinedStatusLifetime)) {
 		return obj.combinedStatus, true
@@ -1288,13 +1777,48 @@ func (obj *MungeObject) getCombinedStatus() (status *github.CombinedStatus, ok b
 	config.analytics.GetCombinedStatus.Call(config, response)
 	if err != nil {
 		glog.Errorf("Failed to get combined status: %v", err)
+		obj.recordError(err)
 		return nil, false
 	}
+	combinedStatus = config.withoutIgnoredContexts(combinedStatus)
 	obj.combinedStatus = combinedStatus
 	obj.combinedStatusTime = now
 	return combinedStatus, true
 }
 
+// withoutIgnoredContexts returns status without the IgnoredStatusContexts,
+// with the overall state worked out again from the rest the way github
+// does.
+func (config *Config) withoutIgnoredContexts(status *github.CombinedStatus) *github.CombinedStatus {
+	if len(config.IgnoredStatusContexts) == 0 {
+		return status
+	}
+	ignored := sets.NewString(config.IgnoredStatusContexts...)
+	out := *status
+	out.Statuses = []github.RepoStatus{}
+	states := sets.String{}
+	for _, s := range status.Statuses {
+		if s.Context != nil && ignored.Has(*s.Context) {
+			continue
+		}
+		out.Statuses = append(out.Statuses, s)
+		if s.State != nil {
+			states.Insert(*s.State)
+		}
+	}
+	count := len(out.Statuses)
+	out.TotalCount = &count
+	switch {
+	case states.Has("failure"), states.Has("error"):
+		out.State = stringPtr("failure")
+	case count == 0, states.Has("pending"):
+		out.State = stringPtr("pending")
+	default:
+		out.State = stringPtr("success")
+	}
+	return &out
+}
+
 // SetStatus allowes you to set the Github Status
 func (obj *MungeObject) SetStatus(state, url, description, context string) bool {
 	config := obj.config
@@ -1341,16 +1865,23 @@ func (obj *MungeObject) GetStatus(context string) (*github.RepoStatus, bool) {
 }
 
 // GetStatusState gets the current status of a PR.
-//    * If any member of the 'requiredContexts' list is missing, it is 'incomplete'
-//    * If any is 'pending', the PR is 'pending'
-//    * If any is 'error', the PR is in 'error'
-//    * If any is 'failure', the PR is 'failure'
-//    * Otherwise the PR is 'success'
+//   - If any member of the 'requiredContexts' list is missing, it is 'incomplete'
+//   - If any is 'pending', the PR is 'pending'
+//   - If any is 'error', the PR is in 'error'
+//   - If any is 'failure', the PR is 'failure'
+//   - Otherwise the PR is 'success'
 func (obj *MungeObject) GetStatusState(requiredContexts []string) (string, bool) {
 	combinedStatus, ok := obj.getCombinedStatus()
 	if !ok || combinedStatus == nil {
 		return "failure", ok
 	}
+	if len(requiredContexts) != 0 && len(obj.config.IgnoredStatusContexts) != 0 {
+		// Requiring only ignored contexts requires nothing
+		requiredContexts = sets.NewString(requiredContexts...).Difference(sets.NewString(obj.config.IgnoredStatusContexts...)).List()
+		if len(requiredContexts) == 0 {
+			return "success", ok
+		}
+	}
 	return computeStatus(combinedStatus, requiredContexts), ok
 }
 
@@ -1381,7 +1912,7 @@ func timeout(sleepTime time.Duration, c chan bool) {
 	c <- true
 }
 
-func (obj *MungeObject) doWaitStatus(pending bool, requiredContexts []string, c chan bool) {
+func (obj *MungeObject) doWaitStatus(pending bool, state StateFunc, c chan bool, abort <-chan struct{}) {
 	config := obj.config
 
 	sleepTime := 30 * time.Second
@@ -1391,9 +1922,11 @@ func (obj *MungeObject) doWaitStatus(pending bool, requiredContexts []string, c
 	}
 
 	for {
-		status, ok := obj.GetStatusState(requiredContexts)
+		status, ok := state()
 		if !ok {
-			time.Sleep(sleepTime)
+			if !sleepOrAbort(sleepTime, abort) {
+				return
+			}
 			continue
 		}
 		var done bool
@@ -1416,29 +1949,69 @@ func (obj *MungeObject) doWaitStatus(pending bool, requiredContexts []string, c
 		} else {
 			glog.V(4).Infof("PR# %d is pending, waiting for %f seconds", *obj.Issue.Number, sleepTime.Seconds())
 		}
-		time.Sleep(sleepTime)
+		if !sleepOrAbort(sleepTime, abort) {
+			return
+		}
 
 		// If it has been closed, assume that we want to break from the poll loop early.
 		obj.Refresh()
 		if obj.Issue != nil && obj.Issue.State != nil && *obj.Issue.State == "closed" {
 			c <- true
+			return
 		}
 	}
 }
 
+// sleepOrAbort sleeps for d and returns true, unless abort is closed first,
+// in which case it returns false right away.
+func sleepOrAbort(d time.Duration, abort <-chan struct{}) bool {
+	select {
+	case <-abort:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
 // WaitForPending will wait for a PR to move into Pending.  This is useful
 // because the request to test a PR again is asynchronous with the PR actually
 // moving into a pending state
 // returns true if it completed and false if it timed out
 func (obj *MungeObject) WaitForPending(requiredContexts []string) bool {
+	return obj.WaitForPendingOrAbort(requiredContexts, nil)
+}
+
+// WaitForPendingOrAbort is WaitForPending, except it also gives up and
+// returns false as soon as abort is closed.
+func (obj *MungeObject) WaitForPendingOrAbort(requiredContexts []string, abort <-chan struct{}) bool {
+	return obj.WaitForPendingStateOrAbort(obj.statusStateFunc(requiredContexts), abort)
+}
+
+// StateFunc returns the state, like GetStatusState does, of whatever is being
+// waited for. The second return value is false if it couldn't be read.
+type StateFunc func() (string, bool)
+
+// statusStateFunc reads the state of requiredContexts from commit statuses.
+func (obj *MungeObject) statusStateFunc(requiredContexts []string) StateFunc {
+	return func() (string, bool) {
+		return obj.GetStatusState(requiredContexts)
+	}
+}
+
+// WaitForPendingStateOrAbort is WaitForPendingOrAbort for a state read by
+// state, for CI results which aren't commit statuses.
+func (obj *MungeObject) WaitForPendingStateOrAbort(state StateFunc, abort <-chan struct{}) bool {
 	timeoutChan := make(chan bool, 1)
 	done := make(chan bool, 1)
 	// Wait for the github e2e test to start
 	go timeout(prMaxWaitTime, timeoutChan)
-	go obj.doWaitStatus(true, requiredContexts, done)
+	go obj.doWaitStatus(true, state, done, abort)
 	select {
 	case <-done:
 		return true
+	case <-abort:
+		glog.Infof("PR# %d stopped waiting to go \"pending\"", *obj.Issue.Number)
+		return false
 	case <-timeoutChan:
 		glog.Errorf("PR# %d timed out waiting to go \"pending\"", *obj.Issue.Number)
 		return false
@@ -1449,14 +2022,29 @@ func (obj *MungeObject) WaitForPending(requiredContexts []string) bool {
 // if so it will sleep and try again until all required status hooks have complete
 // returns true if it completed and false if it timed out
 func (obj *MungeObject) WaitForNotPending(requiredContexts []string) bool {
+	return obj.WaitForNotPendingOrAbort(requiredContexts, nil)
+}
+
+// WaitForNotPendingOrAbort is WaitForNotPending, except it also gives up and
+// returns false as soon as abort is closed.
+func (obj *MungeObject) WaitForNotPendingOrAbort(requiredContexts []string, abort <-chan struct{}) bool {
+	return obj.WaitForNotPendingStateOrAbort(obj.statusStateFunc(requiredContexts), abort)
+}
+
+// WaitForNotPendingStateOrAbort is WaitForNotPendingOrAbort for a state read
+// by state, for CI results which aren't commit statuses.
+func (obj *MungeObject) WaitForNotPendingStateOrAbort(state StateFunc, abort <-chan struct{}) bool {
 	timeoutChan := make(chan bool, 1)
 	done := make(chan bool, 1)
 	// Wait for the github e2e test to finish
 	go timeout(prMaxWaitTime, timeoutChan)
-	go obj.doWaitStatus(false, requiredContexts, done)
+	go obj.doWaitStatus(false, state, done, abort)
 	select {
 	case <-done:
 		return true
+	case <-abort:
+		glog.Infof("PR# %d stopped waiting to go \"not pending\"", *obj.Issue.Number)
+		return false
 	case <-timeoutChan:
 		glog.Errorf("PR# %d timed out waiting to go \"not pending\"", *obj.Issue.Number)
 		return false
@@ -1465,6 +2053,7 @@ func (obj *MungeObject) WaitForNotPending(requiredContexts []string) bool {
 
 // GetCommits returns all of the commits for a given PR
 func (obj *MungeObject) GetCommits() ([]*github.RepositoryCommit, bool) {
+	obj.lastError = nil
 	if obj.commits != nil {
 		return obj.commits, true
 	}
@@ -1476,6 +2065,7 @@ func (obj *MungeObject) GetCommits() ([]*github.RepositoryCommit, bool) {
 		config.analytics.ListCommits.Call(config, response)
 		if err != nil {
 			glog.Errorf("Error commits for PR %d: %v", *obj.Issue.Number, err)
+			obj.recordError(err)
 			return nil, false
 		}
 		commits = append(commits, commitsPage...)
@@ -1505,6 +2095,7 @@ func (obj *MungeObject) GetCommits() ([]*github.RepositoryCommit, bool) {
 
 // ListFiles returns all changed files in a pull-request
 func (obj *MungeObject) ListFiles() ([]*github.CommitFile, bool) {
+	obj.lastError = nil
 	if obj.commitFiles != nil {
 		return obj.commitFiles, true
 	}
@@ -1528,6 +2119,7 @@ func (obj *MungeObject) ListFiles() ([]*github.CommitFile, bool) {
 		config.analytics.ListFiles.Call(config, response)
 		if err != nil {
 			glog.Errorf("Unable to ListFiles: %v", err)
+			obj.recordError(err)
 			return nil, false
 		}
 		allFiles = append(allFiles, files...)
@@ -1542,6 +2134,7 @@ func (obj *MungeObject) ListFiles() ([]*github.CommitFile, bool) {
 
 // GetPR will return the PR of the object.
 func (obj *MungeObject) GetPR() (*github.PullRequest, bool) {
+	obj.lastError = nil
 	if obj.pr != nil {
 		return obj.pr, true
 	}
@@ -1549,12 +2142,14 @@ func (obj *MungeObject) GetPR() (*github.PullRequest, bool) {
 		glog.Errorf("Issue: %d is not a PR", *obj.Issue.Number)
 		return nil, false
 	}
-	pr, err := obj.config.getPR(*obj.Issue.Number)
+	pr, draft, err := obj.config.getPR(*obj.Issue.Number)
 	if err != nil {
 		glog.Errorf("Error in GetPR")
+		obj.recordError(err)
 		return nil, false
 	}
 	obj.pr = pr
+	obj.draft = &draft
 	return pr, true
 }
 
@@ -1668,7 +2263,12 @@ func (obj *MungeObject) OpenPR(numTries int) bool {
 // GetFileContents will return the contents of the `file` in the repo at `sha`
 // as a string
 func (obj *MungeObject) GetFileContents(file, sha string) (string, error) {
-	config := obj.config
+	return obj.config.GetFileContents(file, sha)
+}
+
+// GetFileContents will return the contents of the `file` in the repo at
+// `sha`, or on the default branch if `sha` is empty, as a string
+func (config *Config) GetFileContents(file, sha string) (string, error) {
 	getOpts := &github.RepositoryContentGetOptions{Ref: sha}
 	if len(sha) > 0 {
 		getOpts.Ref = sha
@@ -1709,9 +2309,9 @@ func (obj *MungeObject) MergeCommit() (*string, bool) {
 	return nil, true
 }
 
-// cleanIssueBody removes irrelevant parts from the issue body,
+// CleanIssueBody removes irrelevant parts from the issue body,
 // including Reviewable footers and extra whitespace.
-func cleanIssueBody(issueBody string) string {
+func CleanIssueBody(issueBody string) string {
 	issueBody = reviewableFooterRE.ReplaceAllString(issueBody, "")
 	issueBody = htmlCommentRE.ReplaceAllString(issueBody, "")
 	return strings.TrimSpace(issueBody)
@@ -1720,16 +2320,162 @@ func cleanIssueBody(issueBody string) string {
 // MergePR will merge the given PR, duh
 // "who" is who is doing the merging, like "submit-queue"
 func (obj *MungeObject) MergePR(who string) bool {
+	return obj.MergePRWithMethod(who, "")
+}
+
+// mergeMediaType is required to choose the merge method.
+const mergeMediaType = "application/vnd.github.polaris-preview+json"
+
+type mergeRequest struct {
+	CommitTitle   string `json:"commit_title,omitempty"`
+	CommitMessage string `json:"commit_message"`
+	MergeMethod   string `json:"merge_method,omitempty"`
+	SHA           string `json:"sha,omitempty"`
+}
+
+// mergeAttempts is how many times the merge PUT is sent if github keeps
+// returning errors which may go away by themselves.
+const mergeAttempts = 4
+
+// ErrMergeConflict is returned by TryMergePR when github still refuses the
+// merge with a 409 after retrying, usually because the head of the PR moved.
+var ErrMergeConflict = errors.New("github refused the merge with a conflict")
+
+// merge sends the merge request for the PR. The vendored go-github can only
+// ask for squash merges, so we build the request ourselves. If sha is set
+// github refuses to merge anything else. The commit github made is saved for
+// MergeCommitSHA.
+func (obj *MungeObject) merge(title, mergeBody, method, sha string) error {
+	config := obj.config
+	u := fmt.Sprintf("repos/%v/%v/pulls/%d/merge", config.Org, config.Project, *obj.Issue.Number)
+	client := config.mergeAPIClient()
+	req, err := client.NewRequest("PUT", u, &mergeRequest{CommitTitle: title, CommitMessage: mergeBody, MergeMethod: method, SHA: sha})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", mergeMediaType)
+	result := &github.PullRequestMergeResult{}
+	if _, err = client.Do(req, result); err != nil {
+		return err
+	}
+	if result.SHA != nil {
+		obj.mergeSHA = *result.SHA
+	}
+	return nil
+}
+
+// MergeCommitSHA returns the commit github made when the PR was merged by
+// TryMergePR. It is false if the PR wasn't merged that way or github didn't
+// say.
+func (obj *MungeObject) MergeCommitSHA() (string, bool) {
+	return obj.mergeSHA, obj.mergeSHA != ""
+}
+
+// retryableMergeError returns whether a failed merge is worth trying
+// again, and whether github said there was a conflict.
+func retryableMergeError(err error) (retry, conflict bool) {
+	errResp, ok := err.(*github.ErrorResponse)
+	if !ok || errResp.Response == nil {
+		// Couldn't talk to github at all
+		return true, false
+	}
+	code := errResp.Response.StatusCode
+	switch {
+	case code == http.StatusConflict:
+		return true, true
+	case code >= http.StatusInternalServerError:
+		return true, false
+	case strings.Contains(errResp.Message, "branch was modified. Review and try the merge again."):
+		// The github API https://developer.github.com/v3/pulls/#merge-a-pull-request-merge-button
+		// only documents this for a PUT with the wrong sha, but it also
+		// seems to come back while github is recalculating "mergeable".
+		return true, false
+	}
+	return false, false
+}
+
+// MergePRWithMethod will merge the given PR using method, which is one of
+// "merge", "squash" or "rebase". If method is "" github's default is used.
+// Who is a string which will be included in the merge comment.
+func (obj *MungeObject) MergePRWithMethod(who, method string) bool {
+	return obj.TryMergePR(who, method) == nil
+}
+
+// TryMergePR is MergePRWithMethod, but says why the merge failed. Errors
+// which may be transient are retried with backoff, as long as the PR is
+// still mergeable and its head hasn't moved. ErrMergeConflict is returned
+// if github kept answering with a 409.
+func (obj *MungeObject) TryMergePR(who, method string) error {
+	return obj.TryMergePRWithMessage(who, method, "", "")
+}
+
+// TryMergePRWithMessage is TryMergePR with the commit title and message
+// chosen by the caller. An empty title leaves it to github, and an empty
+// message means the usual one naming who merged the PR.
+func (obj *MungeObject) TryMergePRWithMessage(who, method, title, message string) error {
 	config := obj.config
 	prNum := *obj.Issue.Number
 	config.analytics.Merge.Call(config, nil)
-	glog.Infof("Merging PR# %d", prNum)
+	glog.Infof("Merging PR# %d (method %q)", prNum, method)
 	if config.DryRun {
-		return true
+		return nil
+	}
+	sha, _, ok := obj.GetHeadAndBase()
+	if !ok {
+		return fmt.Errorf("unable to find the head of PR %d", prNum)
 	}
 	mergeBody := fmt.Sprintf("Automatic merge from %s", who)
 	obj.WriteComment(mergeBody)
 
+	if message != "" {
+		mergeBody = message
+	} else {
+		var err error
+		if mergeBody, err = obj.defaultMergeMessage(mergeBody); err != nil {
+			return err
+		}
+	}
+
+	baseDelay := time.Second
+	if config.BaseWaitTime != 0 { // Allow shorter delays in tests.
+		baseDelay = config.BaseWaitTime
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = obj.merge(title, mergeBody, method, sha)
+		if err == nil {
+			return nil
+		}
+		retry, conflict := retryableMergeError(err)
+		if conflict {
+			err = ErrMergeConflict
+		}
+		if !retry || attempt >= mergeAttempts {
+			break
+		}
+		glog.Warningf("Failed to merge PR %d, attempt %d of %d: %v", prNum, attempt, mergeAttempts, err)
+		time.Sleep((1 << uint(attempt)) * baseDelay)
+
+		// Never merge something other than what was tested.
+		if !obj.Refresh() {
+			break
+		}
+		if head, _, ok := obj.GetHeadAndBase(); !ok || head != sha {
+			glog.Infof("PR %d changed from %s while merging, giving up", prNum, sha)
+			err = ErrMergeConflict
+			break
+		}
+		if mergeable, ok := obj.IsMergeable(); ok && !mergeable {
+			break
+		}
+	}
+	glog.Errorf("Failed to merge PR: %d: %v", prNum, err)
+	return err
+}
+
+// defaultMergeMessage adds the PR's title, and the body if it says more
+// than the first commit does, to mergeBody.
+func (obj *MungeObject) defaultMergeMessage(mergeBody string) (string, error) {
 	if obj.Issue.Title != nil {
 		mergeBody = fmt.Sprintf("%s\n\n%s", mergeBody, *obj.Issue.Title)
 	}
@@ -1737,13 +2483,13 @@ func (obj *MungeObject) MergePR(who string) bool {
 	// Get the text of the issue body
 	issueBody := ""
 	if obj.Issue.Body != nil {
-		issueBody = cleanIssueBody(*obj.Issue.Body)
+		issueBody = CleanIssueBody(*obj.Issue.Body)
 	}
 
 	// Get the text of the first commit
 	firstCommit := ""
 	if commits, ok := obj.GetCommits(); !ok {
-		return false
+		return "", fmt.Errorf("unable to get the commits of PR %d", *obj.Issue.Number)
 	} else if commits[0].Commit.Message != nil {
 		firstCommit = *commits[0].Commit.Message
 	}
@@ -1757,25 +2503,7 @@ func (obj *MungeObject) MergePR(who string) bool {
 	if !strings.Contains(firstCommit, issueBody) {
 		mergeBody = fmt.Sprintf("%s\n\n%s", mergeBody, issueBody)
 	}
-
-	_, _, err := config.client.PullRequests.Merge(config.Org, config.Project, prNum, mergeBody, nil)
-
-	// The github API https://developer.github.com/v3/pulls/#merge-a-pull-request-merge-button indicates
-	// we will only get the bellow error if we provided a particular sha to merge PUT. We aren't doing that
-	// so our best guess is that the API also provides this error message when it is recalulating
-	// "mergeable". So if we get this error, check "IsPRMergeable()" which should sleep just a bit until
-	// github is finished calculating. If my guess is correct, that also means we should be able to
-	// then merge this PR, so try again.
-	if err != nil && strings.Contains(err.Error(), "branch was modified. Review and try the merge again.") {
-		if mergeable, _ := obj.IsMergeable(); mergeable {
-			_, _, err = config.client.PullRequests.Merge(config.Org, config.Project, prNum, mergeBody, nil)
-		}
-	}
-	if err != nil {
-		glog.Errorf("Failed to merge PR: %d: %v", prNum, err)
-		return false
-	}
-	return true
+	return mergeBody, nil
 }
 
 // GetPRFixesList returns a list of issue numbers that are referenced in the PR body.
@@ -1853,6 +2581,259 @@ func (obj *MungeObject) ListReviewComments() ([]*github.PullRequestComment, bool
 	return allComments, true
 }
 
+// PullRequestReview is a review submitted on a PR. The vendored go-github
+// predates the reviews API so we decode it ourselves.
+type PullRequestReview struct {
+	ID          *int         `json:"id,omitempty"`
+	User        *github.User `json:"user,omitempty"`
+	State       *string      `json:"state,omitempty"`
+	SubmittedAt *time.Time   `json:"submitted_at,omitempty"`
+}
+
+// reviewsMediaType is required while the reviews API is in preview.
+const reviewsMediaType = "application/vnd.github.black-cat-preview+json"
+
+// ListReviews returns all reviews submitted on the PR in question. Unlike most
+// lists these are not cached on the object, as reviews change without the PR
+// being modified.
+func (obj *MungeObject) ListReviews() ([]*PullRequestReview, bool) {
+	config := obj.config
+	prNum := *obj.Issue.Number
+	allReviews := []*PullRequestReview{}
+
+	page := 1
+	for {
+		u := fmt.Sprintf("repos/%v/%v/pulls/%d/reviews?per_page=100&page=%d", config.Org, config.Project, prNum, page)
+		req, err := config.client.NewRequest("GET", u, nil)
+		if err != nil {
+			glog.Errorf("%d: unable to build reviews request: %v", prNum, err)
+			return nil, false
+		}
+		req.Header.Set("Accept", reviewsMediaType)
+		reviews := []*PullRequestReview{}
+		response, err := config.client.Do(req, &reviews)
+		config.analytics.ListReviews.Call(config, response)
+		if err != nil {
+			glog.Errorf("%d: unable to list reviews: %v", prNum, err)
+			return nil, false
+		}
+		allReviews = append(allReviews, reviews...)
+		if response.NextPage == 0 {
+			break
+		}
+		page = response.NextPage
+	}
+	return allReviews, true
+}
+
+// ListReviewRequests returns the users whose review of the PR has been
+// requested but who haven't submitted one yet.
+func (obj *MungeObject) ListReviewRequests() ([]*github.User, bool) {
+	config := obj.config
+	prNum := *obj.Issue.Number
+
+	u := fmt.Sprintf("repos/%v/%v/pulls/%d/requested_reviewers?per_page=100", config.Org, config.Project, prNum)
+	req, err := config.client.NewRequest("GET", u, nil)
+	if err != nil {
+		glog.Errorf("%d: unable to build review requests request: %v", prNum, err)
+		return nil, false
+	}
+	req.Header.Set("Accept", reviewsMediaType)
+	users := []*github.User{}
+	response, err := config.client.Do(req, &users)
+	config.analytics.ListReviewRequests.Call(config, response)
+	if err != nil {
+		glog.Errorf("%d: unable to list review requests: %v", prNum, err)
+		return nil, false
+	}
+	return users, true
+}
+
+// RequestReviews asks the given users to review the PR.
+func (obj *MungeObject) RequestReviews(users []string) bool {
+	config := obj.config
+	prNum := *obj.Issue.Number
+	config.analytics.RequestReviews.Call(config, nil)
+	glog.Infof("Requesting reviews of %d from %v", prNum, users)
+	if config.DryRun {
+		return true
+	}
+
+	u := fmt.Sprintf("repos/%v/%v/pulls/%d/requested_reviewers", config.Org, config.Project, prNum)
+	req, err := config.client.NewRequest("POST", u, struct {
+		Reviewers []string `json:"reviewers"`
+	}{users})
+	if err != nil {
+		glog.Errorf("%d: unable to build review request: %v", prNum, err)
+		return false
+	}
+	req.Header.Set("Accept", reviewsMediaType)
+	if _, err := config.client.Do(req, nil); err != nil {
+		glog.Errorf("%d: unable to request reviews from %v: %v", prNum, users, err)
+		return false
+	}
+	return true
+}
+
+// CheckRun is a run reported through the github checks API. Like reviews, the
+// vendored go-github doesn't know about these.
+type CheckRun struct {
+	ID          *int            `json:"id,omitempty"`
+	Name        *string         `json:"name,omitempty"`
+	HeadSHA     *string         `json:"head_sha,omitempty"`
+	Status      *string         `json:"status,omitempty"`
+	Conclusion  *string         `json:"conclusion,omitempty"`
+	DetailsURL  *string         `json:"details_url,omitempty"`
+	Output      *CheckRunOutput `json:"output,omitempty"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// CheckRunOutput is the description shown with a CheckRun.
+type CheckRunOutput struct {
+	Title   *string `json:"title,omitempty"`
+	Summary *string `json:"summary,omitempty"`
+}
+
+type checkRunList struct {
+	TotalCount *int        `json:"total_count,omitempty"`
+	CheckRuns  []*CheckRun `json:"check_runs"`
+}
+
+// draftsMediaType is required to see the draft flag on PRs.
+const draftsMediaType = "application/vnd.github.shadow-cat-preview+json"
+
+// IsDraft returns true if the PR is a github draft PR. The draft flag is read
+// along with the PR in GetPR and Refresh; a PR that was loaded some other way
+// (e.g. through graphql) is fetched once to learn it.
+func (obj *MungeObject) IsDraft() (bool, bool) {
+	if obj.draft != nil {
+		return *obj.draft, true
+	}
+	if !obj.IsPR() {
+		return false, true
+	}
+	pr, draft, err := obj.config.getPR(*obj.Issue.Number)
+	if err != nil {
+		obj.recordError(err)
+		return false, false
+	}
+	obj.pr = pr
+	obj.draft = &draft
+	return draft, true
+}
+
+// checksMediaType is required while the checks API is in preview.
+const checksMediaType = "application/vnd.github.antiope-preview+json"
+
+// ListCheckRuns returns the check runs for the head commit of the PR. Like
+// the combined status they are cached for combinedStatusLifetime.
+func (obj *MungeObject) ListCheckRuns() ([]*CheckRun, bool) {
+	config := obj.config
+	pr, ok := obj.GetPR()
+	if !ok {
+		return nil, false
+	}
+	ref := *pr.Head.SHA
+	now := time.Now()
+	if obj.checkRunsSHA == ref && now.Before(obj.checkRunsTime.Add(combinedStatusLifetime)) {
+		return obj.checkRuns, true
+	}
+	allRuns := []*CheckRun{}
+
+	page := 1
+	for {
+		u := fmt.Sprintf("repos/%v/%v/commits/%v/check-runs?per_page=100&page=%d", config.Org, config.Project, ref, page)
+		req, err := config.client.NewRequest("GET", u, nil)
+		if err != nil {
+			glog.Errorf("%d: unable to build check runs request: %v", *obj.Issue.Number, err)
+			return nil, false
+		}
+		req.Header.Set("Accept", checksMediaType)
+		runs := checkRunList{}
+		response, err := config.client.Do(req, &runs)
+		config.analytics.ListCheckRuns.Call(config, response)
+		if err != nil {
+			glog.Errorf("%d: unable to list check runs for %q: %v", *obj.Issue.Number, ref, err)
+			return nil, false
+		}
+		allRuns = append(allRuns, runs.CheckRuns...)
+		if response.NextPage == 0 {
+			break
+		}
+		page = response.NextPage
+	}
+	obj.checkRuns = allRuns
+	obj.checkRunsSHA = ref
+	obj.checkRunsTime = now
+	return allRuns, true
+}
+
+// SetCheckRun creates or updates the check run with the given name on the
+// head commit of the PR. status is one of "queued", "in_progress" or
+// "completed"; conclusion is only sent for completed runs.
+func (obj *MungeObject) SetCheckRun(name, status, conclusion, url, title string) bool {
+	config := obj.config
+	pr, ok := obj.GetPR()
+	if !ok {
+		glog.Errorf("Error in SetCheckRun")
+		return false
+	}
+	ref := *pr.Head.SHA
+	runs, ok := obj.ListCheckRuns()
+	if !ok {
+		return false
+	}
+
+	run := &CheckRun{
+		Name:       &name,
+		HeadSHA:    &ref,
+		Status:     &status,
+		DetailsURL: &url,
+		Output:     &CheckRunOutput{Title: &title, Summary: &title},
+	}
+	if status == "completed" {
+		run.Conclusion = &conclusion
+	}
+	method := "POST"
+	u := fmt.Sprintf("repos/%v/%v/check-runs", config.Org, config.Project)
+	which := -1
+	for i, existing := range runs {
+		if existing.Name != nil && *existing.Name == name && existing.ID != nil {
+			method = "PATCH"
+			u = fmt.Sprintf("%s/%d", u, *existing.ID)
+			which = i
+			break
+		}
+	}
+
+	glog.Infof("PR %d setting %q check run to %q", *obj.Issue.Number, name, title)
+	config.analytics.SetCheckRun.Call(config, nil)
+	if config.DryRun {
+		return true
+	}
+	req, err := config.client.NewRequest(method, u, run)
+	if err != nil {
+		glog.Errorf("%d: unable to build check run request: %v", *obj.Issue.Number, err)
+		return false
+	}
+	req.Header.Set("Accept", checksMediaType)
+	saved := &CheckRun{}
+	if _, err := config.client.Do(req, saved); err != nil {
+		glog.Errorf("Unable to set check run. PR %d Ref: %q: %v", *obj.Issue.Number, ref, err)
+		return false
+	}
+	// Keep the cached runs current, so the next update finds this one
+	if saved.ID != nil && obj.checkRunsSHA == ref {
+		if which >= 0 {
+			obj.checkRuns[which] = saved
+		} else {
+			obj.checkRuns = append(obj.checkRuns, saved)
+		}
+	}
+	return true
+}
+
 // WithListOpt configures the options to list comments of github issue.
 type WithListOpt func(*github.IssueListCommentsOptions) *github.IssueListCommentsOptions
 
@@ -1910,8 +2891,20 @@ func (obj *MungeObject) ListComments(withListOpts ...WithListOpt) ([]*github.Iss
 	return allComments, true
 }
 
-// WriteComment will send the `msg` as a comment to the specified PR
+// WriteComment will send the `msg` as a comment to the specified PR, unless
+// it is the same as the last comment written on it within CommentDedupWindow.
 func (obj *MungeObject) WriteComment(msg string) error {
+	if obj.config.isDuplicateComment(obj.Number(), msg) {
+		glog.Infof("Not commenting in %d, the same comment was posted in the last %v", obj.Number(), obj.config.CommentDedupWindow)
+		return nil
+	}
+	return obj.WriteRepeatableComment(msg)
+}
+
+// WriteRepeatableComment is WriteComment without the CommentDedupWindow
+// check. Use it for comments which must be posted every time, like e2e
+// triggers and replies to commands.
+func (obj *MungeObject) WriteRepeatableComment(msg string) error {
 	config := obj.config
 	prNum := obj.Number()
 	config.analytics.CreateComment.Call(config, nil)
@@ -1927,13 +2920,52 @@ func (obj *MungeObject) WriteComment(msg string) error {
 		glog.Info("Comment in %d was larger than %d and was truncated", prNum, maxCommentLen)
 		msg = msg[:maxCommentLen]
 	}
-	if _, _, err := config.client.Issues.CreateComment(config.Org, config.Project, prNum, &github.IssueComment{Body: &msg}); err != nil {
+	created, _, err := config.client.Issues.CreateComment(config.Org, config.Project, prNum, &github.IssueComment{Body: &msg})
+	if err != nil {
 		glog.Errorf("%v", err)
 		return err
 	}
+	config.recordComment(prNum, msg)
+	if obj.comments != nil && created != nil && created.Body != nil {
+		// Keep the cached comments current, so a later look finds this one
+		obj.comments = append(obj.comments, created)
+	}
 	return nil
 }
 
+// isDuplicateComment returns true if msg is the same as the last comment
+// written on the issue and that was within CommentDedupWindow.
+func (config *Config) isDuplicateComment(num int, msg string) bool {
+	if config.CommentDedupWindow <= 0 {
+		return false
+	}
+	if len(msg) > maxCommentLen {
+		msg = msg[:maxCommentLen]
+	}
+	commentLock.Lock()
+	defer commentLock.Unlock()
+	last, ok := config.lastComments[num]
+	return ok && last.body == msg && config.now().Sub(last.at) < config.CommentDedupWindow
+}
+
+func (config *Config) recordComment(num int, msg string) {
+	commentLock.Lock()
+	defer commentLock.Unlock()
+	if config.lastComments == nil {
+		config.lastComments = map[int]postedComment{}
+	}
+	config.lastComments[num] = postedComment{body: msg, at: config.now()}
+}
+
+// now is the time according to the config's clock, the real one unless a
+// test has set another.
+func (config *Config) now() time.Time {
+	if config.clock == nil {
+		return time.Now()
+	}
+	return config.clock.Now()
+}
+
 // DeleteComment will remove the specified comment
 func (obj *MungeObject) DeleteComment(comment *github.IssueComment) error {
 	config := obj.config
@@ -2027,17 +3059,25 @@ func (obj *MungeObject) IsMergeable() (bool, bool) {
 		return false, ok
 	}
 	prNum := obj.Number()
+	attempts := obj.config.MergeableAttempts
+	if attempts <= 0 {
+		attempts = defaultMergeableAttempts
+	}
 	// Github might not have computed mergeability yet. Try again a few times.
-	for try := 1; try <= 5 && pr.Mergeable == nil; try++ {
-		glog.V(4).Infof("Waiting for mergeability on %q %d", *pr.Title, prNum)
-		// Sleep for 2-32 seconds on successive attempts.
-		// Worst case, we'll wait for up to a minute for GitHub
-		// to compute it before bailing out.
+	for try := 1; try <= attempts && pr.Mergeable == nil; try++ {
+		glog.V(4).Infof("Waiting for mergeability on %q %d (attempt %d of %d)", *pr.Title, prNum, try, attempts)
+		// Sleep for 2-32 seconds on successive attempts. With the default
+		// attempts, we'll wait for up to a minute for GitHub to compute it
+		// before bailing out.
 		baseDelay := time.Second
 		if obj.config.BaseWaitTime != 0 { // Allow shorter delays in tests.
 			baseDelay = obj.config.BaseWaitTime
 		}
-		time.Sleep((1 << uint(try)) * baseDelay)
+		shift := uint(try)
+		if shift > maxMergeableBackoff {
+			shift = maxMergeableBackoff
+		}
+		time.Sleep((1 << shift) * baseDelay)
 		ok := obj.Refresh()
 		if !ok {
 			return false, ok
@@ -2084,8 +3124,8 @@ func (obj *MungeObject) MergedAt() (*time.Time, bool) {
 }
 
 // ForEachIssueDo will run for each Issue in the project that matches:
-//   * pr.Number >= minPRNumber
-//   * pr.Number <= maxPRNumber
+//   - pr.Number >= minPRNumber
+//   - pr.Number <= maxPRNumber
 func (config *Config) ForEachIssueDo(fn MungeFunction) error {
 	page := 1
 	for {
@@ -2122,12 +3162,19 @@ func (config *Config) ForEachIssueDo(fn MungeFunction) error {
 			}
 			glog.V(2).Infof("----==== %d ====----", *issue.Number)
 			glog.V(8).Infof("Issue %d labels: %v isPR: %v", *issue.Number, issue.Labels, issue.PullRequestLinks != nil)
-			obj := MungeObject{
+			obj := &MungeObject{
 				config:      config,
 				Issue:       issue,
 				Annotations: map[string]string{},
 			}
-			if err := fn(&obj); err != nil {
+			if config.UseGraphQL && issue.PullRequestLinks != nil {
+				if fetched, err := config.getObjectGraphQL(*issue.Number); err != nil {
+					glog.Errorf("Falling back to REST for #%d: %v", *issue.Number, err)
+				} else if fetched != nil {
+					obj = fetched
+				}
+			}
+			if err := fn(obj); err != nil {
 				continue
 			}
 		}
@@ -20,11 +20,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"testing"
 	"time"
 
 	github_test "k8s.io/contrib/mungegithub/github/testing"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
 
 	"github.com/google/go-github/github"
 )
@@ -349,6 +351,65 @@ func TestComputeStatus(t *testing.T) {
 	}
 }
 
+func TestIgnoredStatusContexts(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   *github.CombinedStatus
+		required []string
+		expected string
+	}{
+		{
+			name:     "overall",
+			status:   github_test.Status("mysha", []string{"ci"}, []string{"preview"}, nil, nil),
+			expected: "success",
+		},
+		{
+			name:     "required",
+			status:   github_test.Status("mysha", []string{"ci"}, []string{"preview"}, nil, nil),
+			required: []string{"ci", "preview"},
+			expected: "success",
+		},
+		{
+			name:     "only ignored contexts required",
+			status:   github_test.Status("mysha", nil, nil, []string{"preview"}, nil),
+			required: []string{"preview"},
+			expected: "success",
+		},
+		{
+			name:     "pending ignored context",
+			status:   github_test.Status("mysha", []string{"ci"}, nil, []string{"preview"}, nil),
+			expected: "success",
+		},
+		{
+			name:     "nothing else reported",
+			status:   github_test.Status("mysha", nil, []string{"preview"}, nil, nil),
+			expected: "pending",
+		},
+		{
+			name:     "other contexts still count",
+			status:   github_test.Status("mysha", nil, []string{"ci", "preview"}, nil, nil),
+			expected: "failure",
+		},
+	}
+	for _, test := range tests {
+		client, server, _ := github_test.InitServer(t, nil, nil, nil, nil, test.status, nil, nil)
+		config := &Config{
+			client:                client,
+			Org:                   "o",
+			Project:               "r",
+			IgnoredStatusContexts: []string{"preview"},
+		}
+		obj := TestObject(config, github_test.Issue("bob", 1, nil, true), github_test.PullRequest("bob", false, true, true), nil, nil)
+		if state, ok := obj.GetStatusState(test.required); !ok || state != test.expected {
+			t.Errorf("%s: expected %q but got %q", test.name, test.expected, state)
+		}
+		if status, _ := obj.GetStatus("preview"); status != nil {
+			t.Errorf("%s: expected the ignored context to be hidden but got %v", test.name, status)
+		}
+		server.Close()
+	}
+}
+
 func TestGetLastModified(t *testing.T) {
 	tests := []struct {
 		commits      []*github.RepositoryCommit
@@ -476,6 +537,177 @@ func TestRemoveLabel(t *testing.T) {
 	}
 }
 
+func TestWriteCommentDedup(t *testing.T) {
+	tests := []struct {
+		name     string
+		window   time.Duration
+		elapsed  time.Duration
+		comments []string
+		posts    int
+		always   bool
+	}{
+		{
+			name:     "identical comments suppressed",
+			window:   time.Hour,
+			comments: []string{"hello", "hello"},
+			posts:    1,
+		},
+		{
+			name:     "identical comments after the window",
+			window:   time.Hour,
+			elapsed:  time.Hour,
+			comments: []string{"hello", "hello"},
+			posts:    2,
+		},
+		{
+			name:     "WriteRepeatableComment never suppressed",
+			window:   time.Hour,
+			comments: []string{"hello", "hello"},
+			posts:    2,
+			always:   true,
+		},
+		{
+			name:     "different comments posted",
+			window:   time.Hour,
+			comments: []string{"hello", "goodbye", "hello"},
+			posts:    3,
+		},
+		{
+			name:     "window disabled",
+			comments: []string{"hello", "hello"},
+			posts:    2,
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue("", 1, nil, false)
+		client, server, mux := github_test.InitServer(t, issue, nil, nil, nil, nil, nil, nil)
+		posts := 0
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" {
+				t.Errorf("%s: unexpected method %s", test.name, r.Method)
+			}
+			posts++
+			w.Write([]byte("{}"))
+		})
+		config := &Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.CommentDedupWindow = test.window
+		clock := utilclock.NewFakeClock(time.Unix(0, 0))
+		config.clock = clock
+		config.SetClient(client)
+
+		obj, err := config.GetObject(1)
+		if err != nil {
+			t.Fatalf("%s: unable to get issue: %v", test.name, err)
+		}
+		for _, comment := range test.comments {
+			write := obj.WriteComment
+			if test.always {
+				write = obj.WriteRepeatableComment
+			}
+			if err := write(comment); err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			clock.Step(test.elapsed)
+		}
+		if posts != test.posts {
+			t.Errorf("%s: expected %d comments to be posted but got %d", test.name, test.posts, posts)
+		}
+		server.Close()
+	}
+}
+
+func TestRepos(t *testing.T) {
+	issue := github_test.Issue("", 1, nil, false)
+	client, server, mux := github_test.InitServer(t, issue, nil, nil, nil, nil, nil, nil)
+	defer server.Close()
+	other := github_test.Issue("", 1, nil, false)
+	other.Title = stringPtr("In the other repo")
+	mux.HandleFunc("/repos/o2/r2/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(other)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		w.Write(data)
+	})
+
+	config := &Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.ExtraRepos = []string{"o2/r2", "invalid"}
+	config.DryRun = true
+	config.RateLimitWarning = 7
+	config.SetClient(client)
+
+	repos := config.Repos()
+	if len(repos) != 2 || repos[0] != config || repos[1].FullName() != "o2/r2" {
+		t.Fatalf("unexpected repos: %v", repos)
+	}
+	if !repos[1].DryRun || repos[1].RateLimitWarning != 7 {
+		t.Errorf("settings were not copied to the extra repo")
+	}
+	if len(repos[1].ExtraRepos) != 0 || len(repos[1].Repos()) != 1 {
+		t.Errorf("the extra repo has extra repos of its own: %v", repos[1].ExtraRepos)
+	}
+	if again := config.Repos(); again[1] != repos[1] {
+		t.Errorf("expected the same configs on every call")
+	}
+
+	obj, err := repos[1].GetObject(1)
+	if err != nil {
+		t.Fatalf("unable to get issue from the extra repo: %v", err)
+	}
+	if *obj.Issue.Title != "In the other repo" || obj.Repo() != "o2/r2" {
+		t.Errorf("got issue %q from %s", *obj.Issue.Title, obj.Repo())
+	}
+}
+
+func TestSetClientEnterpriseURLs(t *testing.T) {
+	tests := []struct {
+		name           string
+		base           string
+		upload         string
+		expectedBase   string
+		expectedUpload string
+	}{
+		{
+			name:           "unset keeps the client's urls",
+			expectedBase:   "http://127.0.0.1/",
+			expectedUpload: "http://127.0.0.1/",
+		},
+		{
+			name:           "base is used for uploads too",
+			base:           "https://ghe.example.com/api/v3",
+			expectedBase:   "https://ghe.example.com/api/v3/",
+			expectedUpload: "https://ghe.example.com/api/v3/",
+		},
+		{
+			name:           "both",
+			base:           "https://ghe.example.com/api/v3/",
+			upload:         "https://ghe.example.com/api/uploads/",
+			expectedBase:   "https://ghe.example.com/api/v3/",
+			expectedUpload: "https://ghe.example.com/api/uploads/",
+		},
+	}
+	for _, test := range tests {
+		// Like the client from github_test.InitServer
+		client := github.NewClient(nil)
+		local, _ := url.Parse("http://127.0.0.1/")
+		client.BaseURL = local
+		client.UploadURL = local
+
+		config := &Config{EnterpriseBaseURL: test.base, EnterpriseUploadURL: test.upload}
+		config.SetClient(client)
+		if got := client.BaseURL.String(); got != test.expectedBase {
+			t.Errorf("%s: expected base url %q but got %q", test.name, test.expectedBase, got)
+		}
+		if got := client.UploadURL.String(); got != test.expectedUpload {
+			t.Errorf("%s: expected upload url %q but got %q", test.name, test.expectedUpload, got)
+		}
+	}
+}
+
 func TestPRGetFixesList(t *testing.T) {
 	tests := []struct {
 		issue    *github.Issue
@@ -591,10 +823,156 @@ Automatic merge from submit-queue (batch tested with PRs 1, 2, 1234, 57)
 		},
 	}
 	for testNum, test := range tests {
-		body := cleanIssueBody(test.body)
+		body := CleanIssueBody(test.body)
 		if body != test.expected {
-			t.Errorf("%d: cleanIssueBody(%#v) == %#v != %#v",
+			t.Errorf("%d: CleanIssueBody(%#v) == %#v != %#v",
 				testNum, test.body, body, test.expected)
 		}
 	}
 }
+
+func TestIsMergeablePolls(t *testing.T) {
+	tests := []struct {
+		name         string
+		attempts     int
+		undetermined int // fetches before github knows
+		expectOK     bool
+	}{
+		{name: "known at once", attempts: 3, expectOK: true},
+		{name: "known on a later fetch", attempts: 3, undetermined: 2, expectOK: true},
+		{name: "out of attempts", attempts: 1, undetermined: 2, expectOK: false},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue("", 1, nil, true)
+		client, server, mux := github_test.InitServer(t, issue, nil, nil, nil, nil, nil, nil)
+		fetches := 0
+		mux.HandleFunc("/repos/o/r/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+			pr := github_test.PullRequest("bob", false, true, true)
+			if fetches < test.undetermined {
+				pr.Mergeable = nil
+			}
+			fetches++
+			data, _ := json.Marshal(pr)
+			w.Write(data)
+		})
+		config := &Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.BaseWaitTime = time.Microsecond
+		config.MergeableAttempts = test.attempts
+		config.SetClient(client)
+
+		obj, err := config.GetObject(1)
+		if err != nil {
+			t.Fatalf("%s: unable to get issue: %v", test.name, err)
+		}
+		mergeable, ok := obj.IsMergeable()
+		if ok != test.expectOK || mergeable != test.expectOK {
+			t.Errorf("%s: expected mergeable=%v ok=%v but got %v %v", test.name, test.expectOK, test.expectOK, mergeable, ok)
+		}
+		server.Close()
+	}
+}
+
+func TestMergeUsesMergeToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		mergeToken string
+		expectAuth string
+	}{
+		{name: "merge bot", mergeToken: "merge-token", expectAuth: "Bearer merge-token"},
+		{name: "only one token", expectAuth: "Bearer token"},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue("", 1, nil, true)
+		pr := github_test.PullRequest("bob", false, true, true)
+		client, server, mux := github_test.InitServer(t, issue, pr, nil, nil, nil, nil, nil)
+		mergeAuth, commentAuth := "", ""
+		mux.HandleFunc("/repos/o/r/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+			mergeAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{"merged": true}`))
+		})
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			commentAuth = r.Header.Get("Authorization")
+			w.Write([]byte("{}"))
+		})
+		withAuth := func(token string) *github.Client {
+			c := github.NewClient(&http.Client{Transport: withToken(http.DefaultTransport, token)})
+			c.BaseURL = client.BaseURL
+			return c
+		}
+
+		config := &Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(withAuth("token"))
+		if test.mergeToken != "" {
+			config.SetMergeClient(withAuth(test.mergeToken))
+		}
+
+		obj, err := config.GetObject(1)
+		if err != nil {
+			t.Fatalf("%s: unable to get issue: %v", test.name, err)
+		}
+		if err := obj.TryMergePRWithMessage("submit-queue", "", "", "a message"); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if mergeAuth != test.expectAuth {
+			t.Errorf("%s: expected the merge to be sent with %q but got %q", test.name, test.expectAuth, mergeAuth)
+		}
+		if commentAuth != "Bearer token" {
+			t.Errorf("%s: expected the comment to be sent with the regular token but got %q", test.name, commentAuth)
+		}
+		server.Close()
+	}
+}
+
+func TestWaitStatusStops(t *testing.T) {
+	tests := []struct {
+		name     string
+		readable bool
+		closed   bool
+		wait     time.Duration
+	}{
+		{name: "aborted while the state can't be read", wait: time.Hour},
+		{name: "aborted while pending", readable: true, wait: time.Hour},
+		{name: "PR closed", readable: true, closed: true, wait: time.Microsecond},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue("", 1, nil, true)
+		if test.closed {
+			issue.State = stringPtr("closed")
+		}
+		client, server, _ := github_test.InitServer(t, issue, github_test.PullRequest("bob", false, true, true), nil, nil, nil, nil, nil)
+		config := &Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.BaseWaitTime = test.wait
+		config.SetClient(client)
+
+		obj, err := config.GetObject(1)
+		if err != nil {
+			t.Fatalf("%s: unable to get issue: %v", test.name, err)
+		}
+		state := func() (string, bool) { return "success", test.readable }
+		c := make(chan bool, 1)
+		abort := make(chan struct{})
+		returned := make(chan struct{})
+		go func() {
+			obj.doWaitStatus(true, state, c, abort)
+			close(returned)
+		}()
+		if !test.closed {
+			close(abort)
+		}
+		select {
+		case <-returned:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: still waiting", test.name)
+		}
+		if done := len(c) == 1; done != test.closed {
+			t.Errorf("%s: expected done=%v but got %v", test.name, test.closed, done)
+		}
+		server.Close()
+	}
+}
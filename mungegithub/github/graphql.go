@@ -0,0 +1,451 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+)
+
+// prQuery fetches everything GetPR, GetCommits, GetEvents and
+// getCombinedStatus would otherwise need a REST call (or several) each for.
+// The connections are fetched a page at a time, like the REST calls; if a PR
+// has more than that the field is left for REST to fill in.
+const prQuery = `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    issueOrPullRequest(number: $number) {
+      __typename
+      ... on PullRequest {
+        number
+        title
+        body
+        state
+        url
+        createdAt
+        updatedAt
+        closedAt
+        mergedAt
+        merged
+        mergeable
+        additions
+        deletions
+        changedFiles
+        author { login }
+        assignees(first: 100) { nodes { login } }
+        labels(first: 100) { nodes { name color } }
+        milestone { number title }
+        headRefName
+        headRefOid
+        baseRefName
+        baseRefOid
+        commits(first: 100) {
+          pageInfo { hasNextPage }
+          nodes {
+            commit {
+              oid
+              message
+              author { name email date user { login } }
+              committer { name email date user { login } }
+              parents(first: 10) { nodes { oid } }
+            }
+          }
+        }
+        timelineItems(first: 100, itemTypes: [LABELED_EVENT, UNLABELED_EVENT, CLOSED_EVENT, REOPENED_EVENT, MERGED_EVENT, HEAD_REF_FORCE_PUSHED_EVENT]) {
+          pageInfo { hasNextPage }
+          nodes {
+            __typename
+            ... on LabeledEvent { createdAt actor { login } label { name color } }
+            ... on UnlabeledEvent { createdAt actor { login } label { name color } }
+            ... on ClosedEvent { createdAt actor { login } }
+            ... on ReopenedEvent { createdAt actor { login } }
+            ... on MergedEvent { createdAt actor { login } commit { oid } }
+            ... on HeadRefForcePushedEvent { createdAt actor { login } }
+          }
+        }
+        headCommit: commits(last: 1) {
+          nodes {
+            commit {
+              status {
+                state
+                contexts { context state description targetUrl createdAt creator { login } }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// The GraphQL timeline item types and the REST event names they become.
+var graphQLEvents = map[string]string{
+	"LabeledEvent":            "labeled",
+	"UnlabeledEvent":          "unlabeled",
+	"ClosedEvent":             "closed",
+	"ReopenedEvent":           "reopened",
+	"MergedEvent":             "merged",
+	"HeadRefForcePushedEvent": "head_ref_force_pushed",
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLResponse struct {
+	Data   *graphQLData `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type graphQLData struct {
+	Repository *struct {
+		IssueOrPullRequest *graphQLPullRequest `json:"issueOrPullRequest"`
+	} `json:"repository"`
+}
+
+type graphQLUser struct {
+	Login string `json:"login"`
+}
+
+type graphQLLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type graphQLPageInfo struct {
+	HasNextPage bool `json:"hasNextPage"`
+}
+
+type graphQLGitActor struct {
+	Name  string       `json:"name"`
+	Email string       `json:"email"`
+	Date  time.Time    `json:"date"`
+	User  *graphQLUser `json:"user"`
+}
+
+type graphQLCommit struct {
+	Oid       string          `json:"oid"`
+	Message   string          `json:"message"`
+	Author    graphQLGitActor `json:"author"`
+	Committer graphQLGitActor `json:"committer"`
+	Parents   struct {
+		Nodes []struct {
+			Oid string `json:"oid"`
+		} `json:"nodes"`
+	} `json:"parents"`
+	Status *struct {
+		State    string `json:"state"`
+		Contexts []struct {
+			Context     string       `json:"context"`
+			State       string       `json:"state"`
+			Description string       `json:"description"`
+			TargetURL   string       `json:"targetUrl"`
+			CreatedAt   time.Time    `json:"createdAt"`
+			Creator     *graphQLUser `json:"creator"`
+		} `json:"contexts"`
+	} `json:"status"`
+}
+
+type graphQLCommits struct {
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		Commit graphQLCommit `json:"commit"`
+	} `json:"nodes"`
+}
+
+type graphQLPullRequest struct {
+	Typename     string       `json:"__typename"`
+	Number       int          `json:"number"`
+	Title        string       `json:"title"`
+	Body         string       `json:"body"`
+	State        string       `json:"state"`
+	URL          string       `json:"url"`
+	CreatedAt    time.Time    `json:"createdAt"`
+	UpdatedAt    time.Time    `json:"updatedAt"`
+	ClosedAt     *time.Time   `json:"closedAt"`
+	MergedAt     *time.Time   `json:"mergedAt"`
+	Merged       bool         `json:"merged"`
+	Mergeable    string       `json:"mergeable"`
+	Additions    int          `json:"additions"`
+	Deletions    int          `json:"deletions"`
+	ChangedFiles int          `json:"changedFiles"`
+	Author       *graphQLUser `json:"author"`
+	Assignees    struct {
+		Nodes []graphQLUser `json:"nodes"`
+	} `json:"assignees"`
+	Labels struct {
+		Nodes []graphQLLabel `json:"nodes"`
+	} `json:"labels"`
+	Milestone *struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+	} `json:"milestone"`
+	HeadRefName   string         `json:"headRefName"`
+	HeadRefOid    string         `json:"headRefOid"`
+	BaseRefName   string         `json:"baseRefName"`
+	BaseRefOid    string         `json:"baseRefOid"`
+	Commits       graphQLCommits `json:"commits"`
+	TimelineItems struct {
+		PageInfo graphQLPageInfo `json:"pageInfo"`
+		Nodes    []struct {
+			Typename  string        `json:"__typename"`
+			CreatedAt time.Time     `json:"createdAt"`
+			Actor     *graphQLUser  `json:"actor"`
+			Label     *graphQLLabel `json:"label"`
+			Commit    *struct {
+				Oid string `json:"oid"`
+			} `json:"commit"`
+		} `json:"nodes"`
+	} `json:"timelineItems"`
+	HeadCommit graphQLCommits `json:"headCommit"`
+}
+
+// graphQLURL is where the GraphQL API lives relative to the REST one:
+// https://api.github.com/graphql for github.com, but /api/graphql next to
+// /api/v3/ on GitHub Enterprise.
+func (config *Config) graphQLURL() string {
+	if strings.HasSuffix(config.client.BaseURL.Path, "/api/v3/") {
+		return "../graphql"
+	}
+	return "graphql"
+}
+
+// getObjectGraphQL fetches PR num, its commits, its events and the status of
+// its head in a single GraphQL query. It returns nil and no error if num is
+// an issue rather than a PR, which REST handles just as well.
+func (config *Config) getObjectGraphQL(num int) (*MungeObject, error) {
+	req, err := config.client.NewRequest("POST", config.graphQLURL(), graphQLRequest{
+		Query: prQuery,
+		Variables: map[string]interface{}{
+			"owner":  config.Org,
+			"name":   config.Project,
+			"number": num,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := graphQLResponse{}
+	response, err := config.client.Do(req, &out)
+	config.analytics.GraphQL.Call(config, response)
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Errors) != 0 {
+		return nil, fmt.Errorf("GraphQL query for #%d failed: %s", num, out.Errors[0].Message)
+	}
+	if out.Data == nil || out.Data.Repository == nil || out.Data.Repository.IssueOrPullRequest == nil {
+		return nil, fmt.Errorf("GraphQL query for #%d returned nothing", num)
+	}
+	pr := out.Data.Repository.IssueOrPullRequest
+	if pr.Typename != "PullRequest" {
+		return nil, nil
+	}
+	obj := &MungeObject{
+		config:      config,
+		Annotations: map[string]string{},
+	}
+	pr.fill(obj, time.Now())
+	return obj, nil
+}
+
+// fill sets the fields of obj which the REST calls would have, in the same
+// shape. Connections with more than one page are left unset.
+func (pr *graphQLPullRequest) fill(obj *MungeObject, now time.Time) {
+	state := strings.ToLower(pr.State)
+	if state == "merged" {
+		state = "closed"
+	}
+	var user *github.User
+	if pr.Author != nil {
+		user = &github.User{Login: stringPtr(pr.Author.Login)}
+	}
+	assignees := []*github.User{}
+	for _, a := range pr.Assignees.Nodes {
+		assignees = append(assignees, &github.User{Login: stringPtr(a.Login)})
+	}
+	var assignee *github.User
+	if len(assignees) != 0 {
+		assignee = assignees[0]
+	}
+	labels := []github.Label{}
+	for _, l := range pr.Labels.Nodes {
+		labels = append(labels, github.Label{Name: stringPtr(l.Name), Color: stringPtr(l.Color)})
+	}
+	var milestone *github.Milestone
+	if pr.Milestone != nil {
+		milestone = &github.Milestone{Number: &pr.Milestone.Number, Title: stringPtr(pr.Milestone.Title)}
+	}
+	createdAt, updatedAt := pr.CreatedAt, pr.UpdatedAt
+
+	obj.Issue = &github.Issue{
+		Number:           &pr.Number,
+		State:            &state,
+		Title:            stringPtr(pr.Title),
+		Body:             stringPtr(pr.Body),
+		User:             user,
+		Labels:           labels,
+		Assignee:         assignee,
+		Assignees:        assignees,
+		ClosedAt:         pr.ClosedAt,
+		CreatedAt:        &createdAt,
+		UpdatedAt:        &updatedAt,
+		HTMLURL:          stringPtr(pr.URL),
+		Milestone:        milestone,
+		PullRequestLinks: &github.PullRequestLinks{HTMLURL: stringPtr(pr.URL)},
+	}
+
+	var mergeable *bool
+	switch pr.Mergeable {
+	case "MERGEABLE":
+		mergeable = boolPtr(true)
+	case "CONFLICTING":
+		mergeable = boolPtr(false)
+	}
+	obj.pr = &github.PullRequest{
+		Number:       &pr.Number,
+		State:        &state,
+		Title:        stringPtr(pr.Title),
+		Body:         stringPtr(pr.Body),
+		CreatedAt:    &createdAt,
+		UpdatedAt:    &updatedAt,
+		ClosedAt:     pr.ClosedAt,
+		MergedAt:     pr.MergedAt,
+		User:         user,
+		Merged:       boolPtr(pr.Merged),
+		Mergeable:    mergeable,
+		Additions:    &pr.Additions,
+		Deletions:    &pr.Deletions,
+		ChangedFiles: &pr.ChangedFiles,
+		HTMLURL:      stringPtr(pr.URL),
+		Assignee:     assignee,
+		Assignees:    assignees,
+		Milestone:    milestone,
+		Head:         &github.PullRequestBranch{Ref: stringPtr(pr.HeadRefName), SHA: stringPtr(pr.HeadRefOid)},
+		Base:         &github.PullRequestBranch{Ref: stringPtr(pr.BaseRefName), SHA: stringPtr(pr.BaseRefOid)},
+	}
+
+	if !pr.Commits.PageInfo.HasNextPage {
+		commits := []*github.RepositoryCommit{}
+		for _, node := range pr.Commits.Nodes {
+			commits = append(commits, node.Commit.repositoryCommit())
+		}
+		obj.commits = commits
+	}
+
+	if !pr.TimelineItems.PageInfo.HasNextPage {
+		events := []*github.IssueEvent{}
+		for _, node := range pr.TimelineItems.Nodes {
+			name, ok := graphQLEvents[node.Typename]
+			if !ok {
+				glog.V(4).Infof("Ignoring GraphQL timeline item %q on #%d", node.Typename, pr.Number)
+				continue
+			}
+			createdAt := node.CreatedAt
+			event := &github.IssueEvent{
+				Event:     stringPtr(name),
+				CreatedAt: &createdAt,
+			}
+			if node.Actor != nil {
+				event.Actor = &github.User{Login: stringPtr(node.Actor.Login)}
+			}
+			if node.Label != nil {
+				event.Label = &github.Label{Name: stringPtr(node.Label.Name), Color: stringPtr(node.Label.Color)}
+			}
+			if node.Commit != nil {
+				event.CommitID = stringPtr(node.Commit.Oid)
+			}
+			events = append(events, event)
+		}
+		obj.events = events
+		obj.prefetchedEvents = true
+	}
+
+	status := &github.CombinedStatus{
+		State:      stringPtr("pending"),
+		SHA:        stringPtr(pr.HeadRefOid),
+		TotalCount: new(int),
+		Statuses:   []github.RepoStatus{},
+	}
+	if len(pr.HeadCommit.Nodes) != 0 && pr.HeadCommit.Nodes[0].Commit.Status != nil {
+		s := pr.HeadCommit.Nodes[0].Commit.Status
+		status.State = stringPtr(combinedState(s.State))
+		for _, c := range s.Contexts {
+			createdAt := c.CreatedAt
+			repoStatus := github.RepoStatus{
+				State:       stringPtr(strings.ToLower(c.State)),
+				TargetURL:   stringPtr(c.TargetURL),
+				Description: stringPtr(c.Description),
+				Context:     stringPtr(c.Context),
+				CreatedAt:   &createdAt,
+			}
+			if c.Creator != nil {
+				repoStatus.Creator = &github.User{Login: stringPtr(c.Creator.Login)}
+			}
+			status.Statuses = append(status.Statuses, repoStatus)
+		}
+		count := len(status.Statuses)
+		status.TotalCount = &count
+	}
+	obj.combinedStatus = obj.config.withoutIgnoredContexts(status)
+	obj.combinedStatusTime = now
+}
+
+func (c *graphQLCommit) repositoryCommit() *github.RepositoryCommit {
+	parents := []github.Commit{}
+	for _, p := range c.Parents.Nodes {
+		parents = append(parents, github.Commit{SHA: stringPtr(p.Oid)})
+	}
+	author, committer := c.Author.Date, c.Committer.Date
+	commit := &github.RepositoryCommit{
+		SHA: stringPtr(c.Oid),
+		Commit: &github.Commit{
+			SHA:       stringPtr(c.Oid),
+			Author:    &github.CommitAuthor{Name: stringPtr(c.Author.Name), Email: stringPtr(c.Author.Email), Date: &author},
+			Committer: &github.CommitAuthor{Name: stringPtr(c.Committer.Name), Email: stringPtr(c.Committer.Email), Date: &committer},
+			Message:   stringPtr(c.Message),
+			Parents:   parents,
+		},
+		Parents: parents,
+	}
+	if c.Author.User != nil {
+		commit.Author = &github.User{Login: stringPtr(c.Author.User.Login)}
+	}
+	if c.Committer.User != nil {
+		commit.Committer = &github.User{Login: stringPtr(c.Committer.User.Login)}
+	}
+	return commit
+}
+
+// combinedState maps a GraphQL StatusState to the REST combined state, which
+// has no "expected" and folds "error" into "failure".
+func combinedState(state string) string {
+	switch state {
+	case "SUCCESS":
+		return "success"
+	case "FAILURE", "ERROR":
+		return "failure"
+	default:
+		return "pending"
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+// graphQLPR is the GraphQL answer for the same PR the REST fixtures in
+// TestGetObjectGraphQL describe.
+const graphQLPR = `{"data": {"repository": {"issueOrPullRequest": {
+  "__typename": "PullRequest",
+  "number": 1,
+  "title": "My PR title",
+  "state": "OPEN",
+  "url": "PR URL",
+  "merged": false,
+  "mergeable": "MERGEABLE",
+  "author": {"login": "bob"},
+  "assignees": {"nodes": []},
+  "labels": {"nodes": [{"name": "lgtm"}, {"name": "approved"}]},
+  "headRefOid": "mysha",
+  "baseRefName": "master",
+  "commits": {"pageInfo": {"hasNextPage": false}, "nodes": [
+    {"commit": {"oid": "mysha0", "committer": {"date": "1970-01-01T00:00:10Z"}}},
+    {"commit": {"oid": "mysha1", "committer": {"date": "1970-01-01T00:00:11Z"}}}
+  ]},
+  "timelineItems": {"pageInfo": {"hasNextPage": false}, "nodes": [
+    {"__typename": "LabeledEvent", "createdAt": "1970-01-01T00:00:20Z", "actor": {"login": "alice"}, "label": {"name": "lgtm"}},
+    {"__typename": "LabeledEvent", "createdAt": "1970-01-01T00:00:21Z", "actor": {"login": "carol"}, "label": {"name": "approved"}}
+  ]},
+  "headCommit": {"nodes": [{"commit": {"status": {"state": "FAILURE", "contexts": [
+    {"context": "ctx-a", "state": "SUCCESS"},
+    {"context": "ctx-b", "state": "FAILURE"}
+  ]}}}]}
+}}}}`
+
+func TestGetObjectGraphQL(t *testing.T) {
+	SetCombinedStatusLifetime(time.Hour)
+	defer SetCombinedStatusLifetime(5 * time.Second)
+
+	issue := github_test.Issue("bob", 1, []string{"lgtm", "approved"}, true)
+	pr := github_test.PullRequest("bob", false, true, true)
+	events := github_test.Events([]github_test.LabelTime{
+		{User: "alice", Label: "lgtm", Time: 20},
+		{User: "carol", Label: "approved", Time: 21},
+	})
+	commits := github_test.Commits(2, 10)
+	status := github_test.Status("mysha", []string{"ctx-a"}, []string{"ctx-b"}, nil, nil)
+	client, server, mux := github_test.InitServer(t, issue, pr, events, commits, status, nil, nil)
+	defer server.Close()
+
+	var variables map[string]interface{}
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Unexpected method: %s", r.Method)
+		}
+		req := graphQLRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		variables = req.Variables
+		w.Write([]byte(graphQLPR))
+	})
+
+	restConfig := &Config{client: client, Org: "o", Project: "r"}
+	rest, err := restConfig.GetObject(1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	config := &Config{client: client, Org: "o", Project: "r", UseGraphQL: true}
+	obj, err := config.GetObject(1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := map[string]interface{}{"owner": "o", "name": "r", "number": float64(1)}; !reflect.DeepEqual(variables, expected) {
+		t.Errorf("Expected the variables %v, got %v", expected, variables)
+	}
+
+	for _, o := range []*MungeObject{rest, obj} {
+		if !o.IsPR() || *o.Issue.User.Login != "bob" || !o.HasLabels([]string{"lgtm", "approved"}) {
+			t.Errorf("Unexpected issue: %v", o.Issue)
+		}
+	}
+
+	restPR, _ := rest.GetPR()
+	gotPR, ok := obj.GetPR()
+	if !ok || *gotPR.Head.SHA != *restPR.Head.SHA || *gotPR.Base.Ref != *restPR.Base.Ref ||
+		*gotPR.Mergeable != *restPR.Mergeable || *gotPR.Merged != *restPR.Merged || *gotPR.User.Login != *restPR.User.Login {
+		t.Errorf("Expected a PR like %v, got %v", restPR, gotPR)
+	}
+
+	commitTimes := func(o *MungeObject) []string {
+		commits, ok := o.GetCommits()
+		if !ok {
+			t.Fatalf("Unable to get commits")
+		}
+		out := []string{}
+		for _, c := range commits {
+			out = append(out, *c.SHA+" "+c.Commit.Committer.Date.UTC().String())
+		}
+		sort.Strings(out)
+		return out
+	}
+	if expected, got := commitTimes(rest), commitTimes(obj); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected the commits %v, got %v", expected, got)
+	}
+
+	labelEvents := func(o *MungeObject) []string {
+		events, ok := o.GetEvents()
+		if !ok {
+			t.Fatalf("Unable to get events")
+		}
+		out := []string{}
+		for _, e := range events {
+			out = append(out, *e.Event+" "+*e.Label.Name+" "+*e.Actor.Login+" "+e.CreatedAt.UTC().String())
+		}
+		sort.Strings(out)
+		return out
+	}
+	if expected, got := labelEvents(rest), labelEvents(obj); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected the events %v, got %v", expected, got)
+	}
+	expected, _ := rest.LabelTime("lgtm")
+	if got, ok := obj.LabelTime("lgtm"); !ok || !expected.Equal(*got) {
+		t.Errorf("Expected lgtm at %v, got %v", expected, got)
+	}
+
+	for _, contexts := range [][]string{nil, {"ctx-a"}, {"ctx-b"}} {
+		expected, _ := rest.GetStatusState(contexts)
+		if got, ok := obj.GetStatusState(contexts); !ok || got != expected {
+			t.Errorf("Expected %v to be %q, got %q", contexts, expected, got)
+		}
+	}
+
+	if a := config.analytics; a.GetIssue.Count != 0 || a.GetPR.Count != 0 || a.ListCommits.Count != 0 ||
+		a.ListIssueEvents.Count != 0 || a.GetCombinedStatus.Count != 0 || a.GraphQL.Count != 1 {
+		t.Errorf("Expected a single GraphQL call and no REST calls, got %+v", a)
+	}
+}
+
+func TestGetObjectGraphQLIssue(t *testing.T) {
+	client, server, mux := github_test.InitServer(t, github_test.Issue("bob", 1, nil, false), nil, nil, nil, nil, nil, nil)
+	defer server.Close()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"repository": {"issueOrPullRequest": {"__typename": "Issue"}}}}`))
+	})
+
+	config := &Config{client: client, Org: "o", Project: "r", UseGraphQL: true}
+	obj, err := config.GetObject(1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if obj.IsPR() || *obj.Issue.Title != "My issue title" || config.analytics.GetIssue.Count != 1 {
+		t.Errorf("Expected issues to be fetched with REST, got %v", obj.Issue)
+	}
+}
+
+func TestGraphQLURL(t *testing.T) {
+	for base, expected := range map[string]string{
+		"https://api.github.com/":            "https://api.github.com/graphql",
+		"https://github.example.com/api/v3/": "https://github.example.com/api/graphql",
+	} {
+		client := github.NewClient(nil)
+		config := &Config{}
+		config.EnterpriseBaseURL = base
+		config.SetClient(client)
+		req, err := client.NewRequest("POST", config.graphQLURL(), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := req.URL.String(); got != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, base, got)
+		}
+	}
+}
+
+func TestGraphQLForcePushedAfter(t *testing.T) {
+	client, server, mux := github_test.InitServer(t, nil, nil, nil, nil, nil, nil, nil)
+	defer server.Close()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"repository": {"issueOrPullRequest": {
+  "__typename": "PullRequest",
+  "number": 1,
+  "state": "OPEN",
+  "author": {"login": "bob"},
+  "labels": {"nodes": [{"name": "lgtm"}]},
+  "headRefOid": "mysha",
+  "commits": {"pageInfo": {"hasNextPage": false}, "nodes": []},
+  "timelineItems": {"pageInfo": {"hasNextPage": false}, "nodes": [
+    {"__typename": "LabeledEvent", "createdAt": "1970-01-01T00:00:20Z", "actor": {"login": "alice"}, "label": {"name": "lgtm"}},
+    {"__typename": "HeadRefForcePushedEvent", "createdAt": "1970-01-01T00:00:30Z", "actor": {"login": "bob"}}
+  ]},
+  "headCommit": {"nodes": []}
+}}}}`))
+	})
+
+	config := &Config{client: client, Org: "o", Project: "r", UseGraphQL: true}
+	obj, err := config.GetObject(1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lgtm, ok := obj.LabelTime("lgtm")
+	if !ok {
+		t.Fatalf("Unable to find when lgtm was added")
+	}
+	if pushed, ok := obj.ForcePushedAfter(*lgtm); !ok || !pushed {
+		t.Errorf("Expected the force push after lgtm to be found, got %v, %v", pushed, ok)
+	}
+	if pushed, ok := obj.ForcePushedAfter(time.Unix(40, 0)); !ok || pushed {
+		t.Errorf("Expected no force push after the last one, got %v, %v", pushed, ok)
+	}
+	if a := config.analytics; a.ListIssueEvents.Count != 0 {
+		t.Errorf("Expected the events to come from GraphQL, got %+v", a)
+	}
+}
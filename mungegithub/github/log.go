@@ -0,0 +1,104 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// Logger writes glog lines which start with key=value fields, like
+// `org=o project=r issue=1234 sha=abc123 Merging`, so that everything logged
+// about one PR can be found with grep.
+type Logger struct {
+	fields []string
+}
+
+// Log returns a Logger with the org, project and number of the issue or PR.
+// The head SHA is included if the PR has already been fetched; Log never
+// calls github.
+func (obj *MungeObject) Log() Logger {
+	l := Logger{}.With("org", obj.Org()).With("project", obj.Project())
+	if obj.Issue != nil && obj.Issue.Number != nil {
+		l = l.With("issue", *obj.Issue.Number)
+	}
+	if obj.pr != nil && obj.pr.Head != nil && obj.pr.Head.SHA != nil {
+		l = l.With("sha", *obj.pr.Head.SHA)
+	}
+	return l
+}
+
+// With returns a copy of the Logger which also logs key=value. Values
+// containing spaces are quoted.
+func (l Logger) With(key string, value interface{}) Logger {
+	v := fmt.Sprint(value)
+	if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+		v = fmt.Sprintf("%q", v)
+	}
+	fields := make([]string, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+	return Logger{fields: append(fields, key+"="+v)}
+}
+
+// WithReason is With("reason", reason), for the submit queue's reasons.
+func (l Logger) WithReason(reason string) Logger {
+	return l.With("reason", reason)
+}
+
+// line is the fields followed by the message.
+func (l Logger) line(format string, args ...interface{}) string {
+	msg := fmt.Sprintf(format, args...)
+	if len(l.fields) == 0 {
+		return msg
+	}
+	return strings.Join(l.fields, " ") + " " + msg
+}
+
+// Infof is glog.Infof with the Logger's fields.
+func (l Logger) Infof(format string, args ...interface{}) {
+	glog.InfoDepth(1, l.line(format, args...))
+}
+
+// Warningf is glog.Warningf with the Logger's fields.
+func (l Logger) Warningf(format string, args ...interface{}) {
+	glog.WarningDepth(1, l.line(format, args...))
+}
+
+// Errorf is glog.Errorf with the Logger's fields.
+func (l Logger) Errorf(format string, args ...interface{}) {
+	glog.ErrorDepth(1, l.line(format, args...))
+}
+
+// VerboseLogger is a Logger which only logs at a glog verbosity.
+type VerboseLogger struct {
+	l       Logger
+	enabled glog.Verbose
+}
+
+// V is glog.V for the Logger.
+func (l Logger) V(level glog.Level) VerboseLogger {
+	return VerboseLogger{l: l, enabled: glog.V(level)}
+}
+
+// Infof logs if the verbosity is high enough.
+func (v VerboseLogger) Infof(format string, args ...interface{}) {
+	if v.enabled {
+		glog.InfoDepth(1, v.l.line(format, args...))
+	}
+}
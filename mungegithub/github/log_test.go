@@ -0,0 +1,77 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"testing"
+
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+)
+
+func TestLogFields(t *testing.T) {
+	config := &Config{Org: "o", Project: "r"}
+	issue := github_test.Issue("bob", 7, nil, true)
+
+	tests := []struct {
+		name     string
+		obj      *MungeObject
+		logger   func(obj *MungeObject) Logger
+		expected string
+	}{
+		{
+			name:     "issue",
+			obj:      TestObject(config, issue, nil, nil, nil),
+			logger:   (*MungeObject).Log,
+			expected: "org=o project=r issue=7 hello 3",
+		},
+		{
+			name:     "PR",
+			obj:      TestObject(config, issue, github_test.PullRequest("bob", false, true, true), nil, nil),
+			logger:   (*MungeObject).Log,
+			expected: "org=o project=r issue=7 sha=mysha hello 3",
+		},
+		{
+			name: "reason",
+			obj:  TestObject(config, issue, github_test.PullRequest("bob", false, true, true), nil, nil),
+			logger: func(obj *MungeObject) Logger {
+				return obj.Log().WithReason("PR is a work in progress.")
+			},
+			expected: `org=o project=r issue=7 sha=mysha reason="PR is a work in progress." hello 3`,
+		},
+		{
+			name: "empty value",
+			obj:  TestObject(config, issue, nil, nil, nil),
+			logger: func(obj *MungeObject) Logger {
+				return obj.Log().With("job", "")
+			},
+			expected: `org=o project=r issue=7 job="" hello 3`,
+		},
+	}
+	for _, test := range tests {
+		if line := test.logger(test.obj).line("hello %d", 3); line != test.expected {
+			t.Errorf("%s: expected %q but got %q", test.name, test.expected, line)
+		}
+	}
+
+	// With must not change the Logger it was called on.
+	base := TestObject(config, issue, nil, nil, nil).Log()
+	_ = base.With("a", 1)
+	_ = base.With("b", 2)
+	if line := base.line("x"); line != "org=o project=r issue=7 x" {
+		t.Errorf("expected With to leave the base Logger alone but got %q", line)
+	}
+}
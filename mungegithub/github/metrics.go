@@ -0,0 +1,63 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// apiMetrics are about the calls which reach github, not those answered by
+// the local cache.
+var apiMetrics = struct {
+	Calls         *prometheus.CounterVec
+	RateRemaining prometheus.Gauge
+}{
+	Calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_api_calls_total",
+		Help: "Number of github API calls, by the kind of object, like issues or pulls, they were for",
+	}, []string{"category"}),
+	RateRemaining: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "github_api_rate_limit_remaining",
+		Help: "How many github API calls may be made before the rate limit resets",
+	}),
+}
+
+func init() {
+	prometheus.MustRegister(apiMetrics.Calls)
+	prometheus.MustRegister(apiMetrics.RateRemaining)
+}
+
+// apiCategory returns what a call to the API path is about: the kind of
+// object for calls under /repos/:owner/:repo, like "issues" or "statuses",
+// and the first part of the path, like "search", otherwise.
+func apiCategory(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	// GitHub Enterprise serves the API under /api/v3, and GraphQL at
+	// /api/graphql
+	if len(parts) > 1 && parts[0] == "api" {
+		parts = parts[1:]
+		if len(parts) > 1 && parts[0] == "v3" {
+			parts = parts[1:]
+		}
+	}
+	if len(parts) > 3 && parts[0] == "repos" {
+		return parts[3]
+	}
+	return parts[0]
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	out := &dto.Metric{}
+	if err := m.Write(out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.Gauge != nil {
+		return out.Gauge.GetValue()
+	}
+	return out.Counter.GetValue()
+}
+
+func TestAPIMetrics(t *testing.T) {
+	_, server, mux := github_test.InitServer(t, nil, nil, nil, nil, nil, nil, nil)
+	defer server.Close()
+
+	remaining := []int{300, 40}
+	calls := 0
+	mux.HandleFunc("/repos/o/r/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateRemaining, strconv.Itoa(remaining[calls]))
+		// Long enough ago that running low doesn't make the test sleep
+		w.Header().Set(headerRateReset, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+		calls++
+		data, _ := json.Marshal(github_test.Issue("bob", 1, nil, false))
+		w.Write(data)
+	})
+
+	limit := &callLimitRoundTripper{remaining: 1000, warnBelow: 100}
+	client := github.NewClient(&http.Client{Transport: limit})
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	config := &Config{client: client, apiLimit: limit, Org: "o", Project: "r"}
+
+	issueCalls := apiMetrics.Calls.WithLabelValues("issues")
+	before := metricValue(t, issueCalls)
+	for i, expected := range remaining {
+		if _, err := config.GetObject(1); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := metricValue(t, apiMetrics.RateRemaining); got != float64(expected) {
+			t.Errorf("Expected %d remaining calls, got %v", expected, got)
+		}
+		if got := metricValue(t, issueCalls) - before; got != float64(i+1) {
+			t.Errorf("Expected %d issues calls, got %v", i+1, got)
+		}
+	}
+	if stats := config.GetDebugStats(); stats.LimitRemaining != 40 {
+		t.Errorf("Expected the debug stats to show 40 remaining calls, got %d", stats.LimitRemaining)
+	}
+}
+
+func TestAPICategory(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/repos/o/r/issues/1/comments": "issues",
+		"/repos/o/r/pulls/1":           "pulls",
+		"/api/v3/repos/o/r/statuses/x": "statuses",
+		"/repos/o/r":                   "repos",
+		"/search/issues":               "search",
+		"/api/graphql":                 "graphql",
+	} {
+		if got := apiCategory(path); got != expected {
+			t.Errorf("Expected %s to be %q, got %q", path, expected, got)
+		}
+	}
+}
@@ -294,6 +294,8 @@ func setMux(t *testing.T, mux *http.ServeMux, path string, thing interface{}) {
 			data, err = json.Marshal(thing)
 		case []*github.User:
 			data, err = json.Marshal(thing)
+		case []github.User:
+			data, err = json.Marshal(thing)
 		}
 		if err != nil {
 			t.Errorf("%v", err)
@@ -0,0 +1,173 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+)
+
+// How many webhooks can be waiting to be munged. Once full, new ones are
+// dropped and those issues wait for the next full pass.
+const webHookQueueSize = 1000
+
+// WebHook receives github webhooks for the repos in a Config and remembers
+// which issues they were about, so that those can be munged straight away
+// instead of on the next pass over every issue. That pass still happens, so a
+// webhook which never arrives only costs latency.
+type WebHook struct {
+	config *Config
+	secret []byte
+	queue  chan webHookItem
+}
+
+// webHookItem is an issue to munge, given by number or, for status events,
+// by a commit of the PR.
+type webHookItem struct {
+	config *Config
+	number int
+	sha    string
+}
+
+// NewWebHook returns a WebHook for the repos in config which only accepts
+// webhooks signed with secret.
+func NewWebHook(config *Config, secret string) *WebHook {
+	return &WebHook{
+		config: config,
+		secret: []byte(secret),
+		queue:  make(chan webHookItem, webHookQueueSize),
+	}
+}
+
+// ServeHTTP checks the signature of a webhook and queues the issue it is
+// about. pull_request, issue_comment and status events are used, others are
+// accepted and ignored.
+func (h *WebHook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload, err := github.ValidatePayload(r, h.secret)
+	if err != nil {
+		glog.Errorf("Rejecting webhook: %v", err)
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	var repo *github.Repository
+	item := webHookItem{}
+	switch eventType := github.WebHookType(r); eventType {
+	case "pull_request", "issue_comment", "status":
+		event, err := github.ParseWebHook(eventType, payload)
+		if err != nil {
+			glog.Errorf("Unable to parse %s webhook: %v", eventType, err)
+			http.Error(w, "unable to parse the payload", http.StatusBadRequest)
+			return
+		}
+		switch event := event.(type) {
+		case *github.PullRequestEvent:
+			repo = event.Repo
+			if event.Number != nil {
+				item.number = *event.Number
+			}
+		case *github.IssueCommentEvent:
+			repo = event.Repo
+			if event.Issue != nil && event.Issue.Number != nil {
+				item.number = *event.Issue.Number
+			}
+		case *github.StatusEvent:
+			repo = event.Repo
+			if event.SHA != nil {
+				item.sha = *event.SHA
+			}
+		}
+	default:
+		glog.V(4).Infof("Ignoring %s webhook", eventType)
+	}
+	if repo != nil && repo.FullName != nil && (item.number != 0 || item.sha != "") {
+		for _, config := range h.config.Repos() {
+			if config.FullName() == *repo.FullName {
+				item.config = config
+			}
+		}
+	}
+	if item.config != nil {
+		select {
+		case h.queue <- item:
+		default:
+			glog.Errorf("Dropping webhook for %s, %d are already waiting", item.config.FullName(), webHookQueueSize)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// MungeUntil calls fn on the issues webhooks have been received for, as
+// they arrive, until the deadline.
+func (h *WebHook) MungeUntil(deadline time.Time, fn MungeFunction) {
+	timer := time.NewTimer(deadline.Sub(time.Now()))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return
+		case item := <-h.queue:
+			h.munge(item, fn)
+		}
+	}
+}
+
+func (h *WebHook) munge(item webHookItem, fn MungeFunction) {
+	config := item.config
+	numbers := []int{item.number}
+	if item.sha != "" {
+		var err error
+		if numbers, err = config.openPRsWithCommit(item.sha); err != nil {
+			return
+		}
+	}
+	for _, num := range numbers {
+		if num < config.MinPRNumber || num > config.MaxPRNumber {
+			continue
+		}
+		obj, err := config.GetObject(num)
+		if err != nil {
+			glog.Errorf("Unable to munge %s#%d after a webhook: %v", config.FullName(), num, err)
+			continue
+		}
+		glog.V(2).Infof("----==== %d (webhook) ====----", num)
+		fn(obj)
+	}
+}
+
+// openPRsWithCommit returns the numbers of the open PRs which contain the
+// commit sha.
+func (config *Config) openPRsWithCommit(sha string) ([]int, error) {
+	query := fmt.Sprintf("%s repo:%s type:pr state:open", sha, config.FullName())
+	result, response, err := config.client.Search.Issues(query, nil)
+	config.analytics.SearchIssues.Call(config, response)
+	if err != nil {
+		glog.Errorf("Unable to find the PRs for %s: %v", sha, err)
+		return nil, err
+	}
+	numbers := []int{}
+	for _, issue := range result.Issues {
+		if issue.Number != nil {
+			numbers = append(numbers, *issue.Number)
+		}
+	}
+	return numbers, nil
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func signedWebHook(t *testing.T, eventType string, event interface{}, secret string) *http.Request {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(payload)
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Github-Event", eventType)
+	req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestWebHookStatusEvent(t *testing.T) {
+	issue := github_test.Issue("bob", 1, nil, true)
+	client, server, mux := github_test.InitServer(t, issue, nil, nil, nil, nil, nil, nil)
+	defer server.Close()
+	mux.HandleFunc("/search/issues", func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query().Get("q"); q != "mysha repo:o/r type:pr state:open" {
+			t.Errorf("unexpected query %q", q)
+		}
+		data, _ := json.Marshal(github.IssuesSearchResult{Issues: []github.Issue{*issue}})
+		w.Write(data)
+	})
+	config := &Config{Org: "o", Project: "r", MaxPRNumber: maxInt}
+	config.SetClient(client)
+	hook := NewWebHook(config, "secret")
+
+	status := github.StatusEvent{
+		SHA:   stringPtr("mysha"),
+		State: stringPtr("success"),
+		Repo:  &github.Repository{FullName: stringPtr("o/r")},
+	}
+	tests := []struct {
+		name   string
+		req    *http.Request
+		code   int
+		munged []int
+	}{
+		{
+			name:   "signed status event",
+			req:    signedWebHook(t, "status", status, "secret"),
+			code:   http.StatusOK,
+			munged: []int{1},
+		},
+		{
+			name: "wrong secret",
+			req:  signedWebHook(t, "status", status, "not the secret"),
+			code: http.StatusForbidden,
+		},
+		{
+			name: "other repo",
+			req: signedWebHook(t, "status", github.StatusEvent{
+				SHA:  stringPtr("mysha"),
+				Repo: &github.Repository{FullName: stringPtr("o/other")},
+			}, "secret"),
+			code: http.StatusOK,
+		},
+		{
+			name: "ignored event type",
+			req:  signedWebHook(t, "watch", github.WatchEvent{}, "secret"),
+			code: http.StatusOK,
+		},
+	}
+	for _, test := range tests {
+		res := httptest.NewRecorder()
+		hook.ServeHTTP(res, test.req)
+		if res.Code != test.code {
+			t.Errorf("%s: expected %d but got %d", test.name, test.code, res.Code)
+		}
+		munged := []int{}
+		hook.MungeUntil(time.Now().Add(50*time.Millisecond), func(obj *MungeObject) error {
+			munged = append(munged, obj.Number())
+			return nil
+		})
+		if len(munged) != len(test.munged) || (len(munged) > 0 && munged[0] != test.munged[0]) {
+			t.Errorf("%s: expected %v to be munged but got %v", test.name, test.munged, munged)
+		}
+	}
+}
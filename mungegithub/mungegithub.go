@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"time"
@@ -46,6 +47,7 @@ type mungeConfig struct {
 	Once                bool
 	Period              time.Duration
 	StateMachineEnabled bool
+	WebhookSecret       string
 	features.Features
 }
 
@@ -55,9 +57,19 @@ func addMungeFlags(config *mungeConfig, cmd *cobra.Command) {
 	cmd.Flags().StringSliceVar(&config.PRMungersList, "pr-mungers", []string{}, "A list of pull request mungers to run")
 	cmd.Flags().StringSliceVar(&config.IssueReportsList, "issue-reports", []string{}, "A list of issue reports to run. If set, will run the reports and exit.")
 	cmd.Flags().DurationVar(&config.Period, "period", 10*time.Minute, "The period for running mungers")
+	cmd.Flags().StringVar(&config.WebhookSecret, "webhook-secret", "", "If set, github webhooks signed with this secret are accepted at /webhook on --address and the issues they are about are munged between periods")
 }
 
 func doMungers(config *mungeConfig) error {
+	var hook *github_util.WebHook
+	if config.WebhookSecret != "" && !config.Once {
+		if config.Address == "" {
+			return fmt.Errorf("--webhook-secret needs --address to receive the webhooks on")
+		}
+		hook = github_util.NewWebHook(&config.Config, config.WebhookSecret)
+		http.Handle("/webhook", hook)
+		config.ListenAndServe()
+	}
 	for {
 		nextRunStartTime := time.Now().Add(config.Period)
 		glog.Infof("Running mungers")
@@ -66,24 +78,30 @@ func doMungers(config *mungeConfig) error {
 		config.Features.EachLoop()
 		mungers.EachLoop()
 
-		if err := config.ForEachIssueDo(mungers.MungeIssue); err != nil {
-			glog.Errorf("Error munging PRs: %v", err)
-		}
+		for _, repo := range config.Config.Repos() {
+			if err := repo.ForEachIssueDo(mungers.MungeIssue); err != nil {
+				glog.Errorf("Error munging PRs in %s: %v", repo.FullName(), err)
+			}
 
-		if config.StateMachineEnabled {
-			if err := config.ForEachIssueDo(fsm.ComputeState); err != nil {
-				glog.Errorf("Error computing state: %v", err)
+			if config.StateMachineEnabled && repo == &config.Config {
+				if err := repo.ForEachIssueDo(fsm.ComputeState); err != nil {
+					glog.Errorf("Error computing state in %s: %v", repo.FullName(), err)
+				}
 			}
-		}
 
-		config.ResetAPICount()
+			repo.ResetAPICount()
+		}
 		if config.Once {
 			break
 		}
 		if nextRunStartTime.After(time.Now()) {
 			sleepDuration := nextRunStartTime.Sub(time.Now())
 			glog.Infof("Sleeping for %v\n", sleepDuration)
-			time.Sleep(sleepDuration)
+			if hook != nil {
+				hook.MungeUntil(nextRunStartTime, mungers.MungeIssue)
+			} else {
+				time.Sleep(sleepDuration)
+			}
 		} else {
 			glog.Infof("Not sleeping as we took more than %v to complete one loop\n", config.Period)
 		}
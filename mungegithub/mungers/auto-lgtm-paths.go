@@ -0,0 +1,177 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	githubapi "github.com/google/go-github/github"
+	"github.com/spf13/cobra"
+	"k8s.io/contrib/mungegithub/features"
+	"k8s.io/contrib/mungegithub/github"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// AutoLGTMPaths adds the lgtm label to PRs from trusted authors which only
+// change files matching Paths. A trusted author is one listed in Authors or,
+// with PushAccess, anyone with push access to the repo.
+//
+// A path without a '/' is matched against each file's base name, a path
+// ending in '/' matches everything under that directory and anything else
+// is matched against the whole file name, all using path.Match.
+type AutoLGTMPaths struct {
+	Paths      []string
+	Authors    []string
+	PushAccess bool
+	Label      string
+
+	config  *github.Config
+	authors sets.String
+	// pushUsers is refreshed every loop when PushAccess is set.
+	pushUsers sets.String
+	// labeled is the head SHA each PR was at when the label was added, so
+	// a human removing it again isn't overridden until there's a new push.
+	labeled map[int]string
+}
+
+func init() {
+	RegisterMungerOrDie(&AutoLGTMPaths{})
+}
+
+// Name is the name usable in --pr-mungers
+func (a *AutoLGTMPaths) Name() string { return "auto-lgtm-paths" }
+
+// RequiredFeatures is a slice of 'features' that must be provided
+func (a *AutoLGTMPaths) RequiredFeatures() []string { return []string{} }
+
+// Initialize will initialize the munger
+func (a *AutoLGTMPaths) Initialize(config *github.Config, features *features.Features) error {
+	a.Paths = cleanStringSlice(a.Paths)
+	if len(a.Paths) == 0 {
+		return fmt.Errorf("--auto-lgtm-paths must not be empty")
+	}
+	for _, p := range a.Paths {
+		if _, err := path.Match(strings.TrimSuffix(p, "/"), ""); err != nil {
+			return fmt.Errorf("bad --auto-lgtm-paths glob %q: %v", p, err)
+		}
+	}
+	a.authors = sets.NewString()
+	for _, author := range cleanStringSlice(a.Authors) {
+		a.authors.Insert(strings.ToLower(author))
+	}
+	if a.authors.Len() == 0 && !a.PushAccess {
+		return fmt.Errorf("auto-lgtm-paths needs --auto-lgtm-authors or --auto-lgtm-push-access")
+	}
+	a.config = config
+	a.pushUsers = sets.NewString()
+	a.labeled = map[int]string{}
+	return nil
+}
+
+// EachLoop is called at the start of every munge loop
+func (a *AutoLGTMPaths) EachLoop() error {
+	if !a.PushAccess {
+		return nil
+	}
+	push, _, err := a.config.UsersWithAccess()
+	if err != nil {
+		return err
+	}
+	users := sets.NewString()
+	for _, user := range push {
+		users.Insert(strings.ToLower(*user.Login))
+	}
+	a.pushUsers = users
+	return nil
+}
+
+// AddFlags will add any request flags to the cobra `cmd`
+func (a *AutoLGTMPaths) AddFlags(cmd *cobra.Command, config *github.Config) {
+	cmd.Flags().StringSliceVar(&a.Paths, "auto-lgtm-paths", []string{}, "Globs for the files a PR may change and still be lgtm'd by the auto-lgtm-paths munger, e.g. '*.md,OWNERS,docs/'")
+	cmd.Flags().StringSliceVar(&a.Authors, "auto-lgtm-authors", []string{}, "Authors whose PRs the auto-lgtm-paths munger may lgtm")
+	cmd.Flags().BoolVar(&a.PushAccess, "auto-lgtm-push-access", false, "Let the auto-lgtm-paths munger lgtm PRs from anyone with push access to the repo")
+	cmd.Flags().StringVar(&a.Label, "auto-lgtm-label", lgtmLabel, "Label the auto-lgtm-paths munger adds")
+}
+
+func (a *AutoLGTMPaths) matches(filename string) bool {
+	for _, p := range a.Paths {
+		if strings.HasSuffix(p, "/") {
+			dir := path.Dir(filename) + "/"
+			for ; dir != "./"; dir = path.Dir(strings.TrimSuffix(dir, "/")) + "/" {
+				if ok, _ := path.Match(p, dir); ok {
+					return true
+				}
+			}
+			continue
+		}
+		name := filename
+		if !strings.Contains(p, "/") {
+			name = path.Base(filename)
+		}
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AutoLGTMPaths) allFilesMatch(files []*githubapi.CommitFile) bool {
+	if len(files) == 0 {
+		return false
+	}
+	for _, file := range files {
+		if file.Filename == nil || !a.matches(*file.Filename) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *AutoLGTMPaths) trusted(obj *github.MungeObject) bool {
+	if obj.Issue.User == nil || obj.Issue.User.Login == nil {
+		return false
+	}
+	author := strings.ToLower(*obj.Issue.User.Login)
+	return a.authors.Has(author) || (a.PushAccess && a.pushUsers.Has(author))
+}
+
+// Munge is the workhorse the will actually make updates to the PR
+func (a *AutoLGTMPaths) Munge(obj *github.MungeObject) {
+	if !obj.IsPR() || obj.HasLabel(a.Label) || !a.trusted(obj) {
+		return
+	}
+	sha, _, ok := obj.GetHeadAndBase()
+	if !ok {
+		return
+	}
+	num := *obj.Issue.Number
+	if a.labeled[num] == sha {
+		return
+	}
+	delete(a.labeled, num)
+
+	files, ok := obj.ListFiles()
+	if !ok || !a.allFilesMatch(files) {
+		return
+	}
+	obj.Log().Infof("adding %q, all %d files match --auto-lgtm-paths", a.Label, len(files))
+	if err := obj.AddLabel(a.Label); err == nil {
+		a.labeled[num] = sha
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestAutoLGTMPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		author  string
+		files   []string
+		labeled bool
+	}{
+		{
+			name:    "all in the set",
+			author:  "alice",
+			files:   []string{"README.md", "docs/design/thing.md", "docs/images/thing.png", "pkg/api/OWNERS"},
+			labeled: true,
+		},
+		{
+			name:   "mixed files",
+			author: "alice",
+			files:  []string{"docs/design/thing.md", "pkg/api/types.go"},
+		},
+		{
+			name:   "look-alike directory",
+			author: "alice",
+			files:  []string{"pkg/docs/thing.go"},
+		},
+		{
+			name:   "untrusted author",
+			author: "mallory",
+			files:  []string{"README.md"},
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(test.author, 1, nil, true)
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), nil, nil, nil, nil, commitFiles(test.files))
+		added := []string{}
+		mux.HandleFunc("/repos/o/r/issues/1/labels", func(w http.ResponseWriter, r *http.Request) {
+			labels := []string{}
+			json.NewDecoder(r.Body).Decode(&labels)
+			added = append(added, labels...)
+			data, _ := json.Marshal([]github.Label{})
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		a := &AutoLGTMPaths{
+			Paths:   []string{"*.md", "OWNERS", "docs/"},
+			Authors: []string{"Alice"},
+			Label:   lgtmLabel,
+		}
+		if err := a.Initialize(config, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		a.Munge(github_util.TestObject(config, issue, ValidPR(), nil, nil))
+
+		if labeled := len(added) == 1 && added[0] == lgtmLabel; labeled != test.labeled {
+			t.Errorf("%s: expected labeled=%v but added %v", test.name, test.labeled, added)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+
+	"k8s.io/contrib/mungegithub/features"
+	"k8s.io/contrib/mungegithub/github"
+
+	"github.com/spf13/cobra"
+)
+
+const autoUpdateBranchLabel = "auto-update-branch"
+
+// AutoUpdateBranch brings PRs with Label up to date with their base branch,
+// using github's "Update branch", once they are CommitsBehind commits behind
+// it. PRs whose CI is still running are left alone, since updating them
+// would only restart it.
+type AutoUpdateBranch struct {
+	Label         string
+	CommitsBehind int
+
+	// updated is the head SHA each PR was at when its update was requested,
+	// so the update isn't requested again before github has pushed it.
+	updated map[int]string
+}
+
+func init() {
+	RegisterMungerOrDie(&AutoUpdateBranch{})
+}
+
+// Name is the name usable in --pr-mungers
+func (a *AutoUpdateBranch) Name() string { return "auto-update-branch" }
+
+// RequiredFeatures is a slice of 'features' that must be provided
+func (a *AutoUpdateBranch) RequiredFeatures() []string { return []string{} }
+
+// Initialize will initialize the munger
+func (a *AutoUpdateBranch) Initialize(config *github.Config, features *features.Features) error {
+	if a.CommitsBehind < 1 {
+		return fmt.Errorf("--auto-update-branch-commits-behind must be at least 1")
+	}
+	a.updated = map[int]string{}
+	return nil
+}
+
+// EachLoop is called at the start of every munge loop
+func (a *AutoUpdateBranch) EachLoop() error { return nil }
+
+// AddFlags will add any request flags to the cobra `cmd`
+func (a *AutoUpdateBranch) AddFlags(cmd *cobra.Command, config *github.Config) {
+	cmd.Flags().StringVar(&a.Label, "auto-update-branch-label", autoUpdateBranchLabel, "Label which lets the auto-update-branch munger update a PR with its base branch")
+	cmd.Flags().IntVar(&a.CommitsBehind, "auto-update-branch-commits-behind", 1, "How many commits behind its base branch a PR must be before the auto-update-branch munger updates it")
+}
+
+// Munge is the workhorse that will actually update the PRs
+func (a *AutoUpdateBranch) Munge(obj *github.MungeObject) {
+	if !obj.IsPR() || !obj.HasLabel(a.Label) {
+		return
+	}
+	sha, _, ok := obj.GetHeadAndBase()
+	if !ok {
+		return
+	}
+	num := *obj.Issue.Number
+	if a.updated[num] == sha {
+		return
+	}
+	delete(a.updated, num)
+
+	// A conflict needs a human, github can't update the branch.
+	if mergeable, ok := obj.IsMergeable(); !ok || !mergeable {
+		return
+	}
+	if state, ok := obj.GetStatusState(nil); !ok || state == "pending" {
+		obj.Log().V(4).Infof("not updating the branch while CI is running")
+		return
+	}
+	behind, ok := obj.CommitsBehindBase()
+	if !ok || behind < a.CommitsBehind {
+		return
+	}
+
+	obj.Log().Infof("updating the branch, %d commits behind", behind)
+	if obj.UpdateBranch(sha) {
+		a.updated[num] = sha
+	}
+}
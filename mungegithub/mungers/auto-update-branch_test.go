@@ -0,0 +1,116 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestAutoUpdateBranch(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	pending := github_test.Status("mysha", []string{requiredReTestContext1}, nil, []string{requiredReTestContext2}, nil)
+	tests := []struct {
+		name    string
+		labels  []string
+		status  *github.CombinedStatus
+		behind  int
+		updated bool
+	}{
+		{
+			name:    "behind",
+			labels:  []string{autoUpdateBranchLabel},
+			status:  SuccessStatus(),
+			behind:  5,
+			updated: true,
+		},
+		{
+			name:    "failing CI is updated too",
+			labels:  []string{autoUpdateBranchLabel},
+			status:  RetestFailStatus(),
+			behind:  5,
+			updated: true,
+		},
+		{
+			name:   "already testing",
+			labels: []string{autoUpdateBranchLabel},
+			status: pending,
+			behind: 5,
+		},
+		{
+			name:   "not far enough behind",
+			labels: []string{autoUpdateBranchLabel},
+			status: SuccessStatus(),
+			behind: 2,
+		},
+		{
+			name:   "no label",
+			status: SuccessStatus(),
+			behind: 5,
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(someUserName, 1, test.labels, true)
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), nil, Commits(), test.status, nil, nil)
+		behind := test.behind
+		mux.HandleFunc("/repos/o/r/compare/master...mysha", func(w http.ResponseWriter, r *http.Request) {
+			data, _ := json.Marshal(github.CommitsComparison{BehindBy: &behind})
+			w.Write(data)
+		})
+		updates := []string{}
+		mux.HandleFunc("/repos/o/r/pulls/1/update-branch", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "PUT" {
+				t.Errorf("%s: unexpected %s to update the branch", test.name, r.Method)
+			}
+			body := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&body)
+			updates = append(updates, body["expected_head_sha"])
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("{}"))
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		a := &AutoUpdateBranch{Label: autoUpdateBranchLabel, CommitsBehind: 3}
+		if err := a.Initialize(config, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Github hasn't pushed the update by the second loop
+		for i := 0; i < 2; i++ {
+			a.Munge(github_util.TestObject(config, issue, ValidPR(), Commits(), nil))
+		}
+
+		expected := "[]"
+		if test.updated {
+			expected = "[mysha]"
+		}
+		if got := fmt.Sprint(updates); got != expected {
+			t.Errorf("%s: expected updates %s but got %s", test.name, expected, got)
+		}
+		server.Close()
+	}
+}
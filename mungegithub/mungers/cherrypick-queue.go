@@ -92,7 +92,7 @@ func (c *CherrypickQueue) Initialize(config *github.Config, features *features.F
 		http.HandleFunc("/raw", c.serveRaw)
 		http.HandleFunc("/queue-info", c.serveQueueInfo)
 		config.ServeDebugStats("/stats")
-		go http.ListenAndServe(config.Address, nil)
+		config.ListenAndServe()
 	}
 	c.lastMergedAndApproved = map[int]*github.MungeObject{}
 	c.lastMerged = map[int]*github.MungeObject{}
@@ -0,0 +1,197 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+
+	"k8s.io/contrib/mungegithub/features"
+	"k8s.io/contrib/mungegithub/github"
+	"k8s.io/contrib/mungegithub/mungers/mungerutil"
+
+	githubapi "github.com/google/go-github/github"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	claNotSignedLabel = "cla-not-signed"
+	// How long before the deadline the author is reminded, and the least
+	// time they get after the reminder.
+	claReminderNotice  = 7 * day
+	claReminderPrefix  = "The CLA for this PR has not been signed"
+	claReminderComment = claReminderPrefix + ` for %s. If it is still missing on %s this PR will be labeled %q%s.
+
+%s please sign the CLA, or ask for help if you believe you already have.`
+	claNotSignedComment = `This PR has gone %s without a signed CLA. Closing this PR; it will be reopened automatically once the CLA is signed.
+
+%s`
+)
+
+// ClaNotSigned labels, and optionally closes, PRs which have been labeled
+// "cncf-cla: no" for longer than GracePeriod. The author is reminded
+// before that happens. The label is removed, and the PR reopened, once the
+// CLA is signed.
+type ClaNotSigned struct {
+	GracePeriod time.Duration
+	Close       bool
+	// CLALabel is the submit queue's --cla-label, which shows the author
+	// signed the CLA.
+	CLALabel string
+	clock    utilclock.Clock
+	flags    *pflag.FlagSet
+}
+
+func init() {
+	RegisterMungerOrDie(&ClaNotSigned{clock: utilclock.RealClock{}})
+}
+
+// Name is the name usable in --pr-mungers
+func (c *ClaNotSigned) Name() string { return "cla-not-signed" }
+
+// RequiredFeatures is a slice of 'features' that must be provided
+func (c *ClaNotSigned) RequiredFeatures() []string { return []string{} }
+
+// Initialize will initialize the munger
+func (c *ClaNotSigned) Initialize(config *github.Config, features *features.Features) error {
+	if c.GracePeriod < day {
+		return fmt.Errorf("--cla-not-signed-grace-period must be at least a day")
+	}
+	if c.flags != nil {
+		if f := c.flags.Lookup("cla-label"); f != nil {
+			c.CLALabel = f.Value.String()
+		}
+	}
+	if c.CLALabel == "" {
+		c.CLALabel = claYesLabel
+	}
+	return nil
+}
+
+// EachLoop is called at the start of every munge loop
+func (c *ClaNotSigned) EachLoop() error { return nil }
+
+// AddFlags will add any request flags to the cobra `cmd`
+func (c *ClaNotSigned) AddFlags(cmd *cobra.Command, config *github.Config) {
+	// --cla-label is the submit queue's, and is read in Initialize
+	c.flags = cmd.Flags()
+	cmd.Flags().DurationVar(&c.GracePeriod, "cla-not-signed-grace-period", 30*day, fmt.Sprintf("How long a PR may be labeled %q before it is labeled %q", cncfClaNoLabel, claNotSignedLabel))
+	cmd.Flags().BoolVar(&c.Close, "cla-not-signed-close", false, fmt.Sprintf("Close PRs when they are labeled %q. They are reopened once the CLA is signed, if this munger sees closed PRs (--state=all)", claNotSignedLabel))
+}
+
+// hasSignedCLA accepts the same labels as the submit queue does.
+func (c *ClaNotSigned) hasSignedCLA(obj *github.MungeObject) bool {
+	return obj.HasLabel(c.CLALabel) || obj.HasLabel(cncfClaYesLabel) || obj.HasLabel(claHumanLabel)
+}
+
+// findCLAReminder returns the latest reminder posted since `since`, or nil.
+func findCLAReminder(obj *github.MungeObject, since time.Time) (*githubapi.IssueComment, bool) {
+	comments, ok := obj.ListComments()
+	if !ok {
+		return nil, false
+	}
+	var latest *githubapi.IssueComment
+	for _, comment := range comments {
+		if !validComment(comment) || !mergeBotComment(comment) {
+			continue
+		}
+		if !strings.HasPrefix(*comment.Body, claReminderPrefix) || comment.CreatedAt.Before(since) {
+			continue
+		}
+		if latest == nil || latest.CreatedAt.Before(*comment.CreatedAt) {
+			latest = comment
+		}
+	}
+	return latest, true
+}
+
+func (c *ClaNotSigned) remind(obj *github.MungeObject, missingFor time.Duration) {
+	closing := ""
+	if c.Close {
+		closing = " and closed"
+	}
+	author := mungerutil.GetIssueUsers(obj.Issue).Author.Mention().Join()
+	deadline := c.clock.Now().Add(claReminderNotice).Format("Jan 2, 2006")
+	obj.WriteComment(fmt.Sprintf(claReminderComment, durationToDays(missingFor), deadline, claNotSignedLabel, closing, author))
+}
+
+// Munge is the workhorse that will actually label and close the PRs
+func (c *ClaNotSigned) Munge(obj *github.MungeObject) {
+	if !obj.IsPR() {
+		return
+	}
+	closed := obj.Issue.State != nil && *obj.Issue.State == "closed"
+
+	if c.hasSignedCLA(obj) {
+		if !obj.HasLabel(claNotSignedLabel) {
+			return
+		}
+		obj.RemoveLabel(claNotSignedLabel)
+		if closed {
+			obj.OpenPR(3)
+		}
+		return
+	}
+	if !obj.HasLabel(cncfClaNoLabel) {
+		return
+	}
+
+	missingSince, ok := obj.LabelTime(cncfClaNoLabel)
+	if !ok || missingSince == nil {
+		return
+	}
+	missingFor := c.clock.Since(*missingSince)
+	deadline := missingSince.Add(c.GracePeriod)
+
+	reminder, ok := findCLAReminder(obj, *missingSince)
+	if !ok {
+		return
+	}
+	if reminder == nil {
+		if c.clock.Now().After(deadline.Add(-claReminderNotice)) && !obj.HasLabel(claNotSignedLabel) {
+			c.remind(obj, missingFor)
+		}
+		return
+	}
+	// The author always gets the full notice, even if the reminder was
+	// posted late.
+	if earliest := reminder.CreatedAt.Add(claReminderNotice); earliest.After(deadline) {
+		deadline = earliest
+	}
+	if c.clock.Now().Before(deadline) {
+		return
+	}
+
+	// Only close the PR when labeling it, so that anybody who reopens it by
+	// hand doesn't have to fight the bot.
+	if obj.HasLabel(claNotSignedLabel) {
+		return
+	}
+	obj.AddLabel(claNotSignedLabel)
+	if c.Close && !closed {
+		mention := mungerutil.GetIssueUsers(obj.Issue).AllUsers().Mention().Join()
+		if mention != "" {
+			mention = "cc " + mention
+		}
+		obj.WriteComment(fmt.Sprintf(claNotSignedComment, durationToDays(missingFor), mention))
+		obj.ClosePR()
+	}
+}
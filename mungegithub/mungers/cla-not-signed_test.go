@@ -0,0 +1,322 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+	"github.com/spf13/cobra"
+)
+
+const customCLALabel = "cla/custom"
+
+// claNotSignedServer fakes github for one PR, recording what the munger
+// does to it.
+type claNotSignedServer struct {
+	config   *github_util.Config
+	issue    *github.Issue
+	pr       *github.PullRequest
+	comments []*github.IssueComment
+	added    []string
+	removed  []string
+	state    string
+	close    func()
+}
+
+func newCLANotSignedServer(t *testing.T, labels []string, closed bool, missingSince time.Time) *claNotSignedServer {
+	s := &claNotSignedServer{
+		issue: github_test.Issue(someUserName, 1, labels, true),
+		pr:    github_test.PullRequest(someUserName, false, true, true),
+	}
+	if closed {
+		s.issue.State = stringPtr("closed")
+	}
+	events := github_test.Events([]github_test.LabelTime{{User: "bot", Label: cncfClaNoLabel, Time: missingSince.Unix()}})
+
+	client, server, mux := github_test.InitServer(t, s.issue, nil, nil, nil, nil, nil, nil)
+	s.close = server.Close
+	mux.HandleFunc("/repos/o/r/issues/1/events", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := json.Marshal(events)
+		w.Write(data)
+	})
+	mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			c := new(github.IssueComment)
+			json.NewDecoder(r.Body).Decode(c)
+			s.comments = append(s.comments, github_test.IssueComment(len(s.comments)+1, *c.Body, botName, 0))
+			w.Write([]byte("{}"))
+			return
+		}
+		data, _ := json.Marshal(s.comments)
+		w.Write(data)
+	})
+	mux.HandleFunc("/repos/o/r/issues/1/labels", func(w http.ResponseWriter, r *http.Request) {
+		labels := []string{}
+		json.NewDecoder(r.Body).Decode(&labels)
+		s.added = append(s.added, labels...)
+		w.Write([]byte("[]"))
+	})
+	mux.HandleFunc("/repos/o/r/issues/1/labels/"+claNotSignedLabel, func(w http.ResponseWriter, r *http.Request) {
+		s.removed = append(s.removed, claNotSignedLabel)
+	})
+	mux.HandleFunc("/repos/o/r/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			pr := new(github.PullRequest)
+			json.NewDecoder(r.Body).Decode(pr)
+			s.state = *pr.State
+		}
+		data, _ := json.Marshal(s.pr)
+		w.Write(data)
+	})
+
+	s.config = &github_util.Config{}
+	s.config.Org = "o"
+	s.config.Project = "r"
+	s.config.SetClient(client)
+	return s
+}
+
+// object returns a fresh MungeObject, as the munger would get on each loop.
+func (s *claNotSignedServer) object() *github_util.MungeObject {
+	return github_util.TestObject(s.config, s.issue, s.pr, nil, nil)
+}
+
+// remindedAt backdates the munger's reminder, as though it were posted at t.
+func (s *claNotSignedServer) remindedAt(t time.Time) {
+	for _, c := range s.comments {
+		if strings.HasPrefix(*c.Body, claReminderPrefix) {
+			c.CreatedAt = timePtr(t)
+		}
+	}
+}
+
+func TestClaNotSigned(t *testing.T) {
+	now := time.Unix(0, 0).Add(365 * day)
+	reminder := func(at time.Duration) []*github.IssueComment {
+		c := github_test.IssueComment(1, claReminderPrefix+" for 25 days.", botName, now.Add(-at).Unix())
+		return []*github.IssueComment{c}
+	}
+
+	tests := []struct {
+		name     string
+		labels   []string
+		closed   bool
+		missing  time.Duration
+		comments []*github.IssueComment
+		close    bool
+		reminded bool
+		added    bool
+		removed  bool
+		state    string
+	}{
+		{
+			name:    "recently missing",
+			labels:  []string{cncfClaNoLabel},
+			missing: 5 * day,
+		},
+		{
+			name:     "reminder due",
+			labels:   []string{cncfClaNoLabel},
+			missing:  25 * day,
+			reminded: true,
+		},
+		{
+			name:     "past the grace period without a reminder",
+			labels:   []string{cncfClaNoLabel},
+			missing:  40 * day,
+			reminded: true,
+		},
+		{
+			name:     "reminded too recently",
+			labels:   []string{cncfClaNoLabel},
+			missing:  40 * day,
+			comments: reminder(2 * day),
+		},
+		{
+			name:     "reminder from before the label doesn't count",
+			labels:   []string{cncfClaNoLabel},
+			missing:  25 * day,
+			comments: reminder(30 * day),
+			reminded: true,
+		},
+		{
+			name:     "past the grace period",
+			labels:   []string{cncfClaNoLabel},
+			missing:  40 * day,
+			comments: reminder(15 * day),
+			added:    true,
+		},
+		{
+			name:     "past the grace period and closing",
+			labels:   []string{cncfClaNoLabel},
+			missing:  40 * day,
+			comments: reminder(15 * day),
+			close:    true,
+			added:    true,
+			state:    "closed",
+		},
+		{
+			name:     "already labeled",
+			labels:   []string{cncfClaNoLabel, claNotSignedLabel},
+			missing:  40 * day,
+			comments: reminder(15 * day),
+			close:    true,
+		},
+		{
+			name:    "signed later",
+			labels:  []string{cncfClaYesLabel, claNotSignedLabel},
+			closed:  true,
+			missing: 40 * day,
+			close:   true,
+			removed: true,
+			state:   "open",
+		},
+		{
+			name:    "human approved",
+			labels:  []string{claHumanLabel, cncfClaNoLabel},
+			missing: 40 * day,
+		},
+		{
+			name:    "configured label",
+			labels:  []string{customCLALabel, cncfClaNoLabel},
+			missing: 40 * day,
+		},
+		{
+			name:     "default label when another is configured",
+			labels:   []string{claYesLabel, cncfClaNoLabel},
+			missing:  40 * day,
+			comments: reminder(15 * day),
+			added:    true,
+		},
+	}
+	for _, test := range tests {
+		s := newCLANotSignedServer(t, test.labels, test.closed, now.Add(-test.missing))
+		s.comments = test.comments
+		c := &ClaNotSigned{
+			GracePeriod: 30 * day,
+			Close:       test.close,
+			CLALabel:    customCLALabel,
+			clock:       utilclock.NewFakeClock(now),
+		}
+		c.Munge(s.object())
+
+		reminded := false
+		for _, comment := range s.comments[len(test.comments):] {
+			if strings.HasPrefix(*comment.Body, claReminderPrefix) {
+				reminded = true
+			}
+		}
+		if reminded != test.reminded {
+			t.Errorf("%s: expected reminded=%v but got %v", test.name, test.reminded, reminded)
+		}
+		if added := len(s.added) > 0; added != test.added {
+			t.Errorf("%s: expected added=%v but got %v", test.name, test.added, s.added)
+		}
+		if removed := len(s.removed) > 0; removed != test.removed {
+			t.Errorf("%s: expected removed=%v but got %v", test.name, test.removed, s.removed)
+		}
+		if s.state != test.state {
+			t.Errorf("%s: expected the PR to be set %q but got %q", test.name, test.state, s.state)
+		}
+		s.close()
+	}
+}
+
+func TestClaNotSignedLabelFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		flag     string
+		expected string
+	}{
+		{name: "default", expected: claYesLabel},
+		{name: "configured", flag: customCLALabel, expected: customCLALabel},
+	}
+	for _, test := range tests {
+		// The submit queue owns --cla-label
+		cmd := &cobra.Command{}
+		sq := &SubmitQueue{}
+		sq.AddFlags(cmd, &github_util.Config{})
+		c := &ClaNotSigned{}
+		c.AddFlags(cmd, &github_util.Config{})
+		if test.flag != "" {
+			if err := cmd.Flags().Set("cla-label", test.flag); err != nil {
+				t.Fatalf("%s: unexpected error: %v", test.name, err)
+			}
+		}
+		if err := c.Initialize(&github_util.Config{}, nil); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if c.CLALabel != test.expected {
+			t.Errorf("%s: expected label %q but got %q", test.name, test.expected, c.CLALabel)
+		}
+	}
+}
+
+func TestClaNotSignedOverTime(t *testing.T) {
+	start := time.Unix(0, 0).Add(365 * day)
+	s := newCLANotSignedServer(t, []string{cncfClaNoLabel}, false, start)
+	defer s.close()
+	clock := utilclock.NewFakeClock(start)
+	c := &ClaNotSigned{GracePeriod: 30 * day, Close: true, clock: clock}
+
+	clock.Step(22 * day)
+	c.Munge(s.object())
+	if len(s.comments) != 0 {
+		t.Fatalf("expected no reminder yet but got %v", s.comments)
+	}
+
+	clock.Step(2 * day)
+	c.Munge(s.object())
+	if len(s.comments) != 1 || !strings.HasPrefix(*s.comments[0].Body, claReminderPrefix) {
+		t.Fatalf("expected a reminder but got %v", s.comments)
+	}
+	s.remindedAt(clock.Now())
+
+	clock.Step(5 * day)
+	c.Munge(s.object())
+	if len(s.comments) != 1 || len(s.added) != 0 || s.state != "" {
+		t.Fatalf("expected nothing to happen before the deadline but got comments=%v labels=%v state=%q", s.comments, s.added, s.state)
+	}
+
+	clock.Step(2 * day)
+	c.Munge(s.object())
+	if len(s.added) != 1 || s.added[0] != claNotSignedLabel {
+		t.Errorf("expected %q to be added but got %v", claNotSignedLabel, s.added)
+	}
+	if s.state != "closed" {
+		t.Errorf("expected the PR to be closed but got %q", s.state)
+	}
+
+	s.state = ""
+	s.issue.State = stringPtr("closed")
+	s.issue.Labels = []github.Label{{Name: stringPtr(cncfClaYesLabel)}, {Name: stringPtr(claNotSignedLabel)}}
+	clock.Step(day)
+	c.Munge(s.object())
+	if len(s.removed) != 1 || s.state != "open" {
+		t.Errorf("expected the PR to be reopened without %q but got removed=%v state=%q", claNotSignedLabel, s.removed, s.state)
+	}
+}
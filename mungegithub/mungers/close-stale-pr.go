@@ -21,6 +21,8 @@ import (
 	"regexp"
 	"time"
 
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+
 	"k8s.io/contrib/mungegithub/features"
 	"k8s.io/contrib/mungegithub/github"
 	"k8s.io/contrib/mungegithub/mungers/mungerutil"
@@ -30,19 +32,17 @@ import (
 )
 
 const (
-	day              = time.Hour * 24
-	keepOpenLabel    = "keep-open"
-	stalePullRequest = 90 * day // Close the PR if no human interaction for `stalePullRequest`
-	startWarning     = 60 * day
-	remindWarning    = 30 * day
-	closingComment   = `This PR hasn't been active in %s. Closing this PR. Please reopen if you would like to work towards merging this change, if/when the PR is ready for the next round of review.
+	day            = time.Hour * 24
+	keepOpenLabel  = "keep-open"
+	remindWarning  = 30 * day
+	closingComment = `This PR hasn't been active in %s. Closing this PR. Please reopen if you would like to work towards merging this change, if/when the PR is ready for the next round of review.
 
 %s
-You can add 'keep-open' label to prevent this from happening again, or add a comment to keep it open another 90 days`
+You can add 'keep-open' label to prevent this from happening again, or add a comment to keep it open another %s`
 	warningComment = `This PR hasn't been active in %s. It will be closed in %s (%s).
 
 %s
-You can add 'keep-open' label to prevent this from happening, or add a comment to keep it open another 90 days`
+You can add 'keep-open' label to prevent this from happening, or add a comment to keep it open another %s`
 )
 
 var (
@@ -51,34 +51,45 @@ var (
 )
 
 // CloseStalePR will ask the Bot to close any PullRequest that didn't
-// have any human interactions in `stalePullRequest` duration.
+// have any human interactions or new commits in StaleAfter + GracePeriod.
+// A warning is posted once the PR has been inactive for StaleAfter.
 //
 // This is done by checking both review and issue comments, and by
 // ignoring comments done with a bot name. We also consider re-open on the PR.
-type CloseStalePR struct{}
+type CloseStalePR struct {
+	StaleAfter  time.Duration
+	GracePeriod time.Duration
+	clock       utilclock.Clock
+}
 
 func init() {
-	s := CloseStalePR{}
+	s := &CloseStalePR{clock: utilclock.RealClock{}}
 	RegisterMungerOrDie(s)
 	RegisterStaleComments(s)
 }
 
 // Name is the name usable in --pr-mungers
-func (CloseStalePR) Name() string { return "close-stale-pr" }
+func (s *CloseStalePR) Name() string { return "close-stale-pr" }
 
 // RequiredFeatures is a slice of 'features' that must be provided
-func (CloseStalePR) RequiredFeatures() []string { return []string{} }
+func (s *CloseStalePR) RequiredFeatures() []string { return []string{} }
 
 // Initialize will initialize the munger
-func (CloseStalePR) Initialize(config *github.Config, features *features.Features) error {
+func (s *CloseStalePR) Initialize(config *github.Config, features *features.Features) error {
+	if s.StaleAfter < 0 || s.GracePeriod < day {
+		return fmt.Errorf("--stale-pr-after must not be negative and --stale-pr-grace-period must be at least a day")
+	}
 	return nil
 }
 
 // EachLoop is called at the start of every munge loop
-func (CloseStalePR) EachLoop() error { return nil }
+func (s *CloseStalePR) EachLoop() error { return nil }
 
 // AddFlags will add any request flags to the cobra `cmd`
-func (CloseStalePR) AddFlags(cmd *cobra.Command, config *github.Config) {}
+func (s *CloseStalePR) AddFlags(cmd *cobra.Command, config *github.Config) {
+	cmd.Flags().DurationVar(&s.StaleAfter, "stale-pr-after", 30*day, "How long a PR may go without human activity or new commits before it is warned that it will be closed")
+	cmd.Flags().DurationVar(&s.GracePeriod, "stale-pr-grace-period", 60*day, "How long after --stale-pr-after a PR is closed if it is still inactive")
+}
 
 func findLastHumanPullRequestUpdate(obj *github.MungeObject) (*time.Time, bool) {
 	pr, ok := obj.GetPR()
@@ -167,6 +178,10 @@ func findLastModificationTime(obj *github.MungeObject) (*time.Time, bool) {
 	if !ok {
 		return nil, ok
 	}
+	lastCommit, ok := obj.LastModifiedTime()
+	if !ok {
+		return nil, ok
+	}
 
 	lastModif := lastHumanPR
 	if lastHumanIssue.After(*lastModif) {
@@ -175,6 +190,9 @@ func findLastModificationTime(obj *github.MungeObject) (*time.Time, bool) {
 	if lastInterestingEvent.After(*lastModif) {
 		lastModif = lastInterestingEvent
 	}
+	if lastCommit != nil && lastCommit.After(*lastModif) {
+		lastModif = lastCommit
+	}
 
 	return lastModif, true
 }
@@ -222,7 +240,7 @@ func durationToDays(duration time.Duration) string {
 	return fmt.Sprintf("%d %s", days, dayString)
 }
 
-func closePullRequest(obj *github.MungeObject, inactiveFor time.Duration) {
+func (s *CloseStalePR) closePullRequest(obj *github.MungeObject, inactiveFor time.Duration) {
 	mention := mungerutil.GetIssueUsers(obj.Issue).AllUsers().Mention().Join()
 	if mention != "" {
 		mention = "cc " + mention + "\n"
@@ -236,17 +254,17 @@ func closePullRequest(obj *github.MungeObject, inactiveFor time.Duration) {
 		obj.DeleteComment(comment)
 	}
 
-	obj.WriteComment(fmt.Sprintf(closingComment, durationToDays(inactiveFor), mention))
+	obj.WriteComment(fmt.Sprintf(closingComment, durationToDays(inactiveFor), mention, durationToDays(s.StaleAfter+s.GracePeriod)))
 	obj.ClosePR()
 }
 
-func postWarningComment(obj *github.MungeObject, inactiveFor time.Duration, closeIn time.Duration) {
+func (s *CloseStalePR) postWarningComment(obj *github.MungeObject, inactiveFor time.Duration, closeIn time.Duration) {
 	mention := mungerutil.GetIssueUsers(obj.Issue).AllUsers().Mention().Join()
 	if mention != "" {
 		mention = "cc " + mention + "\n"
 	}
 
-	closeDate := time.Now().Add(closeIn).Format("Jan 2, 2006")
+	closeDate := s.clock.Now().Add(closeIn).Format("Jan 2, 2006")
 
 	obj.WriteComment(fmt.Sprintf(
 		warningComment,
@@ -254,10 +272,11 @@ func postWarningComment(obj *github.MungeObject, inactiveFor time.Duration, clos
 		durationToDays(closeIn),
 		closeDate,
 		mention,
+		durationToDays(s.StaleAfter+s.GracePeriod),
 	))
 }
 
-func checkAndWarn(obj *github.MungeObject, inactiveFor time.Duration, closeIn time.Duration) {
+func (s *CloseStalePR) checkAndWarn(obj *github.MungeObject, inactiveFor time.Duration, closeIn time.Duration) {
 	if closeIn < day {
 		// We are going to close the PR in less than a day. Too late to warn
 		return
@@ -268,18 +287,18 @@ func checkAndWarn(obj *github.MungeObject, inactiveFor time.Duration, closeIn ti
 	}
 	if comment == nil {
 		// We don't already have the comment. Post it
-		postWarningComment(obj, inactiveFor, closeIn)
-	} else if time.Since(*comment.UpdatedAt) > remindWarning {
+		s.postWarningComment(obj, inactiveFor, closeIn)
+	} else if s.clock.Since(*comment.UpdatedAt) > remindWarning {
 		// It's time to warn again
 		obj.DeleteComment(comment)
-		postWarningComment(obj, inactiveFor, closeIn)
+		s.postWarningComment(obj, inactiveFor, closeIn)
 	} else {
 		// We already have a warning, and it's not expired. Do nothing
 	}
 }
 
 // Munge is the workhorse that will actually close the PRs
-func (CloseStalePR) Munge(obj *github.MungeObject) {
+func (s *CloseStalePR) Munge(obj *github.MungeObject) {
 	if !obj.IsPR() {
 		return
 	}
@@ -293,12 +312,12 @@ func (CloseStalePR) Munge(obj *github.MungeObject) {
 		return
 	}
 
-	closeIn := -time.Since(lastModif.Add(stalePullRequest))
-	inactiveFor := time.Since(*lastModif)
+	closeIn := -s.clock.Since(lastModif.Add(s.StaleAfter + s.GracePeriod))
+	inactiveFor := s.clock.Since(*lastModif)
 	if closeIn <= 0 {
-		closePullRequest(obj, inactiveFor)
-	} else if closeIn <= startWarning {
-		checkAndWarn(obj, inactiveFor, closeIn)
+		s.closePullRequest(obj, inactiveFor)
+	} else if closeIn <= s.GracePeriod {
+		s.checkAndWarn(obj, inactiveFor, closeIn)
 	} else {
 		// Pull-request is active. Remove previous potential warning
 		comment, ok := findLatestWarningComment(obj)
@@ -308,7 +327,7 @@ func (CloseStalePR) Munge(obj *github.MungeObject) {
 	}
 }
 
-func (CloseStalePR) isStaleComment(obj *github.MungeObject, comment *githubapi.IssueComment) bool {
+func (s *CloseStalePR) isStaleComment(obj *github.MungeObject, comment *githubapi.IssueComment) bool {
 	if !mergeBotComment(comment) {
 		return false
 	}
@@ -321,6 +340,6 @@ func (CloseStalePR) isStaleComment(obj *github.MungeObject, comment *githubapi.I
 }
 
 // StaleComments returns a slice of stale comments
-func (s CloseStalePR) StaleComments(obj *github.MungeObject, comments []*githubapi.IssueComment) []*githubapi.IssueComment {
+func (s *CloseStalePR) StaleComments(obj *github.MungeObject, comments []*githubapi.IssueComment) []*githubapi.IssueComment {
 	return forEachCommentTest(obj, comments, s.isStaleComment)
 }
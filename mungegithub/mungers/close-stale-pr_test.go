@@ -0,0 +1,155 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestCloseStalePR(t *testing.T) {
+	now := time.Unix(0, 0).Add(365 * day)
+	ago := func(d time.Duration) int64 { return now.Add(-d).Unix() }
+	comment := func(id int, user string, at int64) *github.IssueComment {
+		c := github_test.IssueComment(id, "hello", user, at)
+		c.UpdatedAt = c.CreatedAt
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		labels   []string
+		created  time.Duration
+		commit   time.Duration
+		comments []*github.IssueComment
+		warned   bool
+		closed   bool
+	}{
+		{
+			name:    "active",
+			created: 10 * day,
+			commit:  10 * day,
+		},
+		{
+			name:    "stale",
+			created: 40 * day,
+			commit:  40 * day,
+			warned:  true,
+		},
+		{
+			name:    "new commit resets the timer",
+			created: 100 * day,
+			commit:  5 * day,
+		},
+		{
+			name:     "human comment resets the timer",
+			created:  100 * day,
+			commit:   100 * day,
+			comments: []*github.IssueComment{comment(1, "alice", ago(5*day))},
+		},
+		{
+			name:     "bot comments don't count",
+			created:  40 * day,
+			commit:   40 * day,
+			comments: []*github.IssueComment{comment(1, jenkinsBotName, ago(5*day))},
+			warned:   true,
+		},
+		{
+			name:    "closed after the grace period",
+			created: 100 * day,
+			commit:  100 * day,
+			closed:  true,
+		},
+		{
+			name:    "keep-open",
+			labels:  []string{keepOpenLabel},
+			created: 100 * day,
+			commit:  100 * day,
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(someUserName, 1, test.labels, true)
+		issue.CreatedAt = timePtr(now.Add(-test.created))
+		pr := github_test.PullRequest(someUserName, false, true, true)
+		pr.CreatedAt = timePtr(now.Add(-test.created))
+		commits := github_test.Commits(1, ago(test.commit))
+
+		client, server, mux := github_test.InitServer(t, issue, nil, nil, commits, nil, nil, nil)
+		mux.HandleFunc("/repos/o/r/issues/1/events", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("[]"))
+		})
+		posted := []string{}
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				c := new(github.IssueComment)
+				json.NewDecoder(r.Body).Decode(c)
+				posted = append(posted, *c.Body)
+				w.Write([]byte("{}"))
+				return
+			}
+			data, _ := json.Marshal(test.comments)
+			w.Write(data)
+		})
+		mux.HandleFunc("/repos/o/r/pulls/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("[]"))
+		})
+		closed := false
+		mux.HandleFunc("/repos/o/r/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PATCH" {
+				closed = true
+			}
+			data, _ := json.Marshal(pr)
+			w.Write(data)
+		})
+
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		obj := github_util.TestObject(config, issue, pr, commits, []*github.IssueEvent{})
+
+		s := &CloseStalePR{
+			StaleAfter:  30 * day,
+			GracePeriod: 60 * day,
+			clock:       utilclock.NewFakeClock(now),
+		}
+		s.Munge(obj)
+
+		warned := false
+		for _, body := range posted {
+			if warningCommentRE.MatchString(body) {
+				warned = true
+			}
+		}
+		if warned != test.warned {
+			t.Errorf("%s: expected warned=%v but got %v: %q", test.name, test.warned, warned, posted)
+		}
+		if closed != test.closed {
+			t.Errorf("%s: expected closed=%v but got %v", test.name, test.closed, closed)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"fmt"
+
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// NewFailures returns the tests which failed in the build of job but not in
+// the latest build of baseJob, which runs the same tests against the base
+// branch. It returns an error if no failed tests can be found in the build,
+// since then a failure of the build can't be put down to the base branch.
+func (e *RealE2ETester) NewFailures(job string, number int, baseJob string) ([]string, error) {
+	failures, err := e.failureReasons(job, number, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(failures) == 0 {
+		return nil, fmt.Errorf("no failed tests found in %v/%v", job, number)
+	}
+
+	// A missing latest-build.txt is -1 rather than an error
+	baseNumber, err := e.LatestRunOfJob(baseJob)
+	if err != nil || baseNumber < 0 {
+		return nil, fmt.Errorf("unable to find the latest build of %v: %v", baseJob, err)
+	}
+	baseFailures, err := e.failureReasons(baseJob, baseNumber, true)
+	if err != nil {
+		return nil, err
+	}
+
+	newFailures := sets.NewString()
+	for test := range failures {
+		if _, ok := baseFailures[test]; !ok {
+			newFailures.Insert(test)
+		}
+	}
+	jobLog(job).With("build", number).V(2).Infof("had %d failed tests, %d of them not failing in %v/%v", len(failures), newFailures.Len(), baseJob, baseNumber)
+	return newFailures.List(), nil
+}
+
+// FailedTests returns the names, as "name {classname}", of the tests which
+// failed in the build of job. Like NewFailures, it returns an error if there
+// are none, since then the build failed for some other reason.
+func (e *RealE2ETester) FailedTests(job string, number int) ([]string, error) {
+	failures, err := e.failureReasons(job, number, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(failures) == 0 {
+		return nil, fmt.Errorf("no failed tests found in %v/%v", job, number)
+	}
+	return sets.StringKeySet(failures).List(), nil
+}
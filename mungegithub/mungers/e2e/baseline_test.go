@@ -0,0 +1,101 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"k8s.io/contrib/test-utils/utils"
+)
+
+// junitFailing returns a junit file in which the named tests failed.
+func junitFailing(tests ...string) []byte {
+	out := fmt.Sprintf("%v\n<testsuite tests=\"%d\" failures=\"%d\">\n", ExpectedXMLHeader, len(tests)+1, len(tests))
+	out += "<testcase name=\"passing\" classname=\"e2e\"/>\n"
+	for _, test := range tests {
+		out += fmt.Sprintf("<testcase name=\"%s\" classname=\"e2e\"><failure>it broke</failure></testcase>\n", test)
+	}
+	return []byte(out + "</testsuite>")
+}
+
+func TestNewFailures(t *testing.T) {
+	paths := map[string][]byte{
+		"/bucket/logs/pr/1/artifacts/junit_01.xml":      junitFailing("A"),
+		"/bucket/logs/pr/2/artifacts/junit_01.xml":      junitFailing("A", "B"),
+		"/bucket/logs/pr/3/artifacts/junit_01.xml":      junitFailing(),
+		"/bucket/logs/base/latest-build.txt":            []byte("7"),
+		"/bucket/logs/base/7/artifacts/junit_01.xml":    junitFailing("A"),
+		"/bucket/logs/passing/latest-build.txt":         []byte("8"),
+		"/bucket/logs/passing/8/artifacts/junit_01.xml": junitFailing(),
+	}
+	server := httptest.NewServer(&testHandler{
+		handler: func(res http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/storage/v1/b/bucket/o" {
+				// The junit files of the build whose prefix was asked for
+				prefix := req.URL.Query().Get("prefix")
+				files := []string{}
+				for path := range paths {
+					if name := path[len("/bucket/"):]; len(name) > len(prefix) && name[:len(prefix)] == prefix {
+						files = append(files, name)
+					}
+				}
+				res.Write(genMockGCSListResponse(files...))
+				return
+			}
+			data, found := paths[req.URL.Path]
+			if !found {
+				res.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(res, "Unknown path: %s", req.URL.Path)
+				return
+			}
+			res.WriteHeader(http.StatusOK)
+			res.Write(data)
+		},
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		build    int
+		baseJob  string
+		expected []string
+		err      bool
+	}{
+		{name: "failing on the base branch too", build: 1, baseJob: "base", expected: []string{}},
+		{name: "a new failure", build: 2, baseJob: "base", expected: []string{"B {e2e}"}},
+		{name: "base branch passing", build: 1, baseJob: "passing", expected: []string{"A {e2e}"}},
+		{name: "no failed tests", build: 3, baseJob: "base", err: true},
+		{name: "no base branch builds", build: 1, baseJob: "unknown", err: true},
+	}
+	for _, test := range tests {
+		e2e := &RealE2ETester{
+			BuildStatus:          map[string]BuildInfo{},
+			GoogleGCSBucketUtils: utils.NewTestUtils("bucket", "logs", server.URL),
+		}
+		failures, err := e2e.NewFailures("pr", test.build, test.baseJob)
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error=%v but got %v", test.name, test.err, err)
+		}
+		if err == nil && !reflect.DeepEqual(failures, test.expected) {
+			t.Errorf("%s: expected new failures %v but got %v", test.name, test.expected, failures)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+)
+
+// The build log, relative to the build's directory.
+const buildLogPath = "build-log.txt"
+
+// ResultCategory is what ClassifyBuild decided about a build.
+type ResultCategory int
+
+const (
+	// BuildPassed means the build's finished.json says it passed.
+	BuildPassed ResultCategory = iota
+	// TestFailure means the build failed and nothing suggests it wasn't
+	// the fault of the code under test.
+	TestFailure
+	// InfraFailure means the build failed and its log matched one of the
+	// InfraFailurePatterns, e.g. because the cluster never came up.
+	InfraFailure
+)
+
+func (c ResultCategory) String() string {
+	switch c {
+	case BuildPassed:
+		return "passed"
+	case TestFailure:
+		return "test failure"
+	case InfraFailure:
+		return "infrastructure failure"
+	}
+	return fmt.Sprintf("ResultCategory(%d)", int(c))
+}
+
+// ClassifyBuild says whether the build passed and, if it didn't, whether
+// its build log looks like an infrastructure failure. A build which can't be
+// read is a TestFailure, along with the error.
+func (e *RealE2ETester) ClassifyBuild(job string, number int) (ResultCategory, error) {
+	passed, err := e.GoogleGCSBucketUtils.CheckFinishedStatus(job, number)
+	if err != nil {
+		return TestFailure, err
+	}
+	if passed {
+		return BuildPassed, nil
+	}
+	if len(e.InfraFailurePatterns) == 0 {
+		return TestFailure, nil
+	}
+
+	response, err := e.GoogleGCSBucketUtils.GetFileFromJenkinsGoogleBucket(job, number, buildLogPath)
+	if err != nil {
+		return TestFailure, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return TestFailure, fmt.Errorf("got a %v response for %v/%v/%v", response.StatusCode, job, number, buildLogPath)
+	}
+	log, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return TestFailure, err
+	}
+	category := classifyLog(log, e.InfraFailurePatterns)
+	jobLog(job).With("build", number).V(2).Infof("failed with a %v", category)
+	return category, nil
+}
+
+// classifyLog returns InfraFailure if the failed build's log matches any of
+// the patterns.
+func classifyLog(log []byte, infraPatterns []*regexp.Regexp) ResultCategory {
+	for _, re := range infraPatterns {
+		if re.Match(log) {
+			return InfraFailure
+		}
+	}
+	return TestFailure
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"k8s.io/contrib/test-utils/utils"
+)
+
+const (
+	infraLog = `Starting cluster using provider: gce
+Waiting up to 300 seconds for cluster initialization.
+Cluster failed to initialize within 300 seconds.
+Build step 'Execute shell' marked build as failure`
+	testLog = `Running Suite: Kubernetes e2e suite
+• Failure [61.224 seconds]
+[k8s.io] Pods should be submitted and removed
+Ran 164 of 271 Specs in 1239.516 seconds
+FAIL! -- 163 Passed | 1 Failed | 0 Pending | 107 Skipped`
+)
+
+func TestClassifyBuild(t *testing.T) {
+	paths := map[string][]byte{
+		"/bucket/logs/job/1/finished.json": marshalOrDie(utils.FinishedFile{Result: "SUCCESS"}, t),
+		"/bucket/logs/job/2/finished.json": marshalOrDie(utils.FinishedFile{Result: "FAILURE"}, t),
+		"/bucket/logs/job/2/build-log.txt": []byte(infraLog),
+		"/bucket/logs/job/3/finished.json": marshalOrDie(utils.FinishedFile{Result: "FAILURE"}, t),
+		"/bucket/logs/job/3/build-log.txt": []byte(testLog),
+		"/bucket/logs/job/4/finished.json": marshalOrDie(utils.FinishedFile{Result: "FAILURE"}, t),
+	}
+	server := httptest.NewServer(&testHandler{
+		handler: func(res http.ResponseWriter, req *http.Request) {
+			data, found := paths[req.URL.Path]
+			if !found {
+				res.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(res, "Unknown path: %s", req.URL.Path)
+				return
+			}
+			res.WriteHeader(http.StatusOK)
+			res.Write(data)
+		},
+	})
+	defer server.Close()
+	patterns := []*regexp.Regexp{
+		regexp.MustCompile(`Cluster failed to initialize`),
+		regexp.MustCompile(`(?m)^Error: timed out waiting for nodes`),
+	}
+
+	tests := []struct {
+		name     string
+		build    int
+		patterns []*regexp.Regexp
+		expected ResultCategory
+		err      bool
+	}{
+		{name: "passed", build: 1, patterns: patterns, expected: BuildPassed},
+		{name: "cluster didn't come up", build: 2, patterns: patterns, expected: InfraFailure},
+		{name: "test failed", build: 3, patterns: patterns, expected: TestFailure},
+		{name: "no patterns", build: 2, expected: TestFailure},
+		{name: "no build log", build: 4, patterns: patterns, expected: TestFailure, err: true},
+	}
+	for _, test := range tests {
+		e2e := &RealE2ETester{
+			InfraFailurePatterns: test.patterns,
+			BuildStatus:          map[string]BuildInfo{},
+			GoogleGCSBucketUtils: utils.NewTestUtils("bucket", "logs", server.URL),
+		}
+		category, err := e2e.ClassifyBuild("job", test.build)
+		if category != test.expected {
+			t.Errorf("%s: expected %v but got %v", test.name, test.expected, category)
+		}
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error=%v but got %v", test.name, test.err, err)
+		}
+	}
+}
@@ -21,15 +21,19 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"k8s.io/contrib/mungegithub/github"
 	cache "k8s.io/contrib/mungegithub/mungers/flakesync"
 	"k8s.io/contrib/test-utils/utils"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
 	"k8s.io/kubernetes/pkg/util/sets"
 
-	"github.com/golang/glog"
 	"io/ioutil"
 )
 
@@ -39,6 +43,20 @@ type E2ETester interface {
 	GCSWeakStable() bool
 	GetBuildStatus() map[string]BuildInfo
 	Flakes() cache.Flakes
+	// AbortPR is called when the github e2e run for the PR at sha is no
+	// longer wanted, because the PR was closed or has new commits.
+	AbortPR(pr int, sha string)
+	// ClassifyBuild says whether a build passed, failed its tests or hit
+	// an infrastructure problem.
+	ClassifyBuild(job string, number int) (ResultCategory, error)
+	// NewFailures returns the tests which failed in a build of job but
+	// not in the latest build of baseJob.
+	NewFailures(job string, number int, baseJob string) ([]string, error)
+	// FailedTests returns the tests which failed in a build of job.
+	FailedTests(job string, number int) ([]string, error)
+	// StaleJobs returns the blocking jobs which, when GCSBasedStable last
+	// looked, had no build newer than the staleness threshold.
+	StaleJobs() []string
 }
 
 // BuildInfo tells the build ID and the build success
@@ -54,8 +72,45 @@ type RealE2ETester struct {
 	NonBlockingJobNames []string
 	WeakStableJobNames  []string
 
+	// Matrix jobs are checked cell by cell, along with the
+	// BlockingJobNames and NonBlockingJobNames.
+	Matrix []MatrixJob
+
+	// WeakSuccessResults are the results in finished.json which count as
+	// a pass for WeakStableJobNames. If unset only SUCCESS does. They don't
+	// apply to builds which say whether they passed.
+	WeakSuccessResults []string
+
+	// When the latest build of a weak stable job fails without a test
+	// failure, WeakStablePassesRequired of the WeakStableBuildsChecked
+	// builds before it must have passed. Builds which can't be read count
+	// as failures. If unset, 1 of the 2 previous builds must have passed.
+	WeakStablePassesRequired int
+	WeakStableBuildsChecked  int
+
+	// InfraFailurePatterns match the build logs of failures which weren't
+	// caused by the code under test.
+	InfraFailurePatterns []*regexp.Regexp
+
+	// InfraTestPatterns match the names, as "name {classname}", of JUnit
+	// test cases which check the test infrastructure rather than the code.
+	// A weak stable job whose only failed tests match is treated as if it
+	// failed without a test failure.
+	InfraTestPatterns []*regexp.Regexp
+
+	// MaxConcurrency is how many jobs are checked at once. If unset,
+	// defaultMaxConcurrency is used.
+	MaxConcurrency int
+
+	// MaxBuildAge, if set, is how long ago, by Clock, the latest build of
+	// a blocking job may have finished. Older results mean the job is
+	// stuck, so it isn't stable.
+	MaxBuildAge time.Duration
+	Clock       utilclock.Clock
+
 	sync.Mutex
 	BuildStatus          map[string]BuildInfo // protect by mutex
+	staleJobs            []string             // protect by mutex
 	GoogleGCSBucketUtils *utils.Utils
 
 	flakeCache        *cache.Cache
@@ -88,6 +143,13 @@ func (e *RealE2ETester) locked(f func()) {
 	f()
 }
 
+// AbortPR logs that the run was abandoned. PR runs are started by the CI
+// bot in response to a comment and there is no way to stop them from here,
+// so the submit queue simply stops waiting for the result.
+func (e *RealE2ETester) AbortPR(pr int, sha string) {
+	github.Logger{}.With("issue", pr).With("sha", sha).Infof("Abandoning the github e2e run")
+}
+
 // GetBuildStatus returns the build status. This map is a copy and is thus safe
 // for the caller to use in any way.
 func (e *RealE2ETester) GetBuildStatus() map[string]BuildInfo {
@@ -112,6 +174,8 @@ func (e *RealE2ETester) setBuildStatus(build, status string, id string) {
 }
 
 const (
+	defaultMaxConcurrency = 8
+
 	// ExpectedXMLHeader is the expected header of junit_XX.xml file
 	ExpectedXMLHeader = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>"
 )
@@ -139,7 +203,7 @@ func (e *RealE2ETester) getGCSResult(j cache.Job, n cache.Number) (*cache.Result
 func (e *RealE2ETester) getGCSPostsubmitResult(j cache.Job, n cache.Number) (*cache.Result, error) {
 	stable, err := e.GoogleGCSBucketUtils.CheckFinishedStatus(string(j), int(n))
 	if err != nil {
-		glog.V(4).Infof("Error looking up job: %v, build number: %v", j, n)
+		jobLog(j).With("build", n).V(4).Infof("Error looking up job: %v", err)
 		// Not actually fatal!
 	}
 	r := &cache.Result{
@@ -155,7 +219,7 @@ func (e *RealE2ETester) getGCSPostsubmitResult(j cache.Job, n cache.Number) (*ca
 	// This isn't stable-- see if we can find a reason.
 	thisFailures, err := e.failureReasons(string(j), int(n), true)
 	if err != nil {
-		glog.V(4).Infof("Error looking up job failure reasons: %v, build number: %v: %v", j, n, err)
+		jobLog(j).With("build", n).V(4).Infof("Error looking up job failure reasons: %v", err)
 		thisFailures = nil // ensure we fall through
 	}
 	if len(thisFailures) == 0 {
@@ -196,11 +260,11 @@ func (e *RealE2ETester) getGCSPresubmitResult(j cache.Job, n cache.Number) (*cac
 	// run as a whole succeeded).
 	thisFailures, err := e.failureReasons(string(j), int(n), true)
 	if err != nil {
-		glog.V(2).Infof("Error looking up job failure reasons: %v, build number: %v: %v", j, n, err)
+		jobLog(j).With("build", n).V(2).Infof("Error looking up job failure reasons: %v", err)
 		return r, nil
 	}
 	if len(thisFailures) == 0 {
-		glog.V(2).Infof("No flakes in %v/%v.", j, n)
+		jobLog(j).With("build", n).V(2).Infof("No flakes")
 		return r, nil
 	}
 
@@ -221,7 +285,7 @@ func (e *RealE2ETester) checkPassFail(job string, number int) (stable, ignorable
 
 	thisResult, err := e.GetBuildResult(job, number)
 	if err != nil || thisResult.Status == cache.ResultFailed {
-		glog.V(4).Infof("Found unstable job: %v, build number: %v: (err: %v) %#v", job, number, err, thisResult)
+		jobLog(job).With("build", number).V(4).Infof("Found unstable job: (err: %v) %#v", err, thisResult)
 		e.setBuildStatus(job, "Not Stable", strconv.Itoa(number))
 		return false, false
 	}
@@ -233,7 +297,7 @@ func (e *RealE2ETester) checkPassFail(job string, number int) (stable, ignorable
 
 	lastResult, err := e.GetBuildResult(job, number-1)
 	if err != nil || lastResult.Status == cache.ResultFailed {
-		glog.V(4).Infof("prev job doesn't help: %v, build number: %v (the previous build); (err %v) %#v", job, number-1, err, lastResult)
+		jobLog(job).With("build", number-1).V(4).Infof("prev job doesn't help (the previous build); (err %v) %#v", err, lastResult)
 		e.setBuildStatus(job, "Not Stable", strconv.Itoa(number))
 		return true, false
 	}
@@ -250,11 +314,11 @@ func (e *RealE2ETester) checkPassFail(job string, number int) (stable, ignorable
 		}
 	}
 	if len(intersection) == 0 {
-		glog.V(2).Infof("Ignoring failure of %v/%v since it didn't happen the previous run this run = %v; prev run = %v.", job, number, thisResult.Flakes, lastResult.Flakes)
+		jobLog(job).With("build", number).V(2).Infof("Ignoring failure since it didn't happen the previous run this run = %v; prev run = %v.", thisResult.Flakes, lastResult.Flakes)
 		e.setBuildStatus(job, "Ignorable flake", strconv.Itoa(number))
 		return true, true
 	}
-	glog.V(2).Infof("Failure of %v/%v is legit. Tests that failed multiple times in a row: %v", job, number, intersection)
+	jobLog(job).With("build", number).V(2).Infof("Failure is legit. Tests that failed multiple times in a row: %v", intersection)
 	e.setBuildStatus(job, "Not Stable", strconv.Itoa(number))
 	return false, false
 }
@@ -264,32 +328,87 @@ func (e *RealE2ETester) LatestRunOfJob(jobName string) (int, error) {
 	return e.GoogleGCSBucketUtils.GetLastestBuildNumberFromJenkinsGoogleBucket(jobName)
 }
 
+// jobLog returns a Logger for the given job, to which the build number can
+// be added.
+func jobLog(job interface{}) github.Logger {
+	return github.Logger{}.With("job", job)
+}
+
+// forEachJob calls f for each of the jobs, running at most e.MaxConcurrency
+// calls at once. It returns once every call has finished.
+func (e *RealE2ETester) forEachJob(jobs []string, f func(job string)) {
+	workers := e.MaxConcurrency
+	if workers <= 0 {
+		workers = defaultMaxConcurrency
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	jobCh := make(chan string)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobCh {
+				f(job)
+			}
+		}()
+	}
+	for _, job := range jobs {
+		jobCh <- job
+	}
+	close(jobCh)
+	wg.Wait()
+}
+
 // GCSBasedStable is a version of Stable function that depends on files stored in GCS instead of Jenkis
 func (e *RealE2ETester) GCSBasedStable() (allStable, ignorableFlakes bool) {
 	allStable = true
+	resultLock := sync.Mutex{}
+	blocking, nonBlocking := e.matrixJobs()
+	stale := []string{}
 
-	for _, job := range e.BlockingJobNames {
+	e.forEachJob(blocking, func(job string) {
 		lastBuildNumber, err := e.GoogleGCSBucketUtils.GetLastestBuildNumberFromJenkinsGoogleBucket(job)
-		glog.V(4).Infof("Checking status of %v, %v", job, lastBuildNumber)
+		jobLog(job).With("build", lastBuildNumber).V(4).Infof("Checking status")
 		if err != nil {
-			glog.Errorf("Error while getting data for %v: %v", job, err)
+			jobLog(job).Errorf("Error while getting the latest build: %v", err)
 			e.setBuildStatus(job, "Not Stable", strconv.Itoa(lastBuildNumber))
-			continue
+			resultLock.Lock()
+			allStable = false
+			resultLock.Unlock()
+			return
+		}
+
+		if e.buildTooOld(job, lastBuildNumber) {
+			e.setBuildStatus(job, "Stale", strconv.Itoa(lastBuildNumber))
+			resultLock.Lock()
+			allStable = false
+			stale = append(stale, job)
+			resultLock.Unlock()
+			return
 		}
 
 		stable, flakes := e.checkPassFail(job, lastBuildNumber)
+		resultLock.Lock()
 		allStable = allStable && stable
 		ignorableFlakes = ignorableFlakes || flakes
-	}
+		resultLock.Unlock()
+	})
+
+	sort.Strings(stale)
+	e.locked(func() { e.staleJobs = stale })
 
 	// Also get status for non-blocking jobs
-	for _, job := range e.NonBlockingJobNames {
+	e.forEachJob(nonBlocking, func(job string) {
 		lastBuildNumber, err := e.GoogleGCSBucketUtils.GetLastestBuildNumberFromJenkinsGoogleBucket(job)
-		glog.V(4).Infof("Checking status of %v, %v", job, lastBuildNumber)
+		jobLog(job).With("build", lastBuildNumber).V(4).Infof("Checking status")
 		if err != nil {
-			glog.Errorf("Error while getting data for %v: %v", job, err)
+			jobLog(job).Errorf("Error while getting the latest build: %v", err)
 			e.setBuildStatus(job, "[nonblocking] Not Stable", strconv.Itoa(lastBuildNumber))
-			continue
+			return
 		}
 
 		if thisResult, err := e.GetBuildResult(job, lastBuildNumber); err != nil || thisResult.Status != cache.ResultStable {
@@ -297,11 +416,42 @@ func (e *RealE2ETester) GCSBasedStable() (allStable, ignorableFlakes bool) {
 		} else {
 			e.setBuildStatus(job, "[nonblocking] Stable", strconv.Itoa(lastBuildNumber))
 		}
-	}
+	})
 
 	return allStable, ignorableFlakes
 }
 
+// buildTooOld returns true if the build of job finished more than
+// MaxBuildAge ago. Builds whose finished.json can't be read aren't judged
+// here; checkPassFail will find them unstable.
+func (e *RealE2ETester) buildTooOld(job string, number int) bool {
+	if e.MaxBuildAge <= 0 {
+		return false
+	}
+	finished, err := e.GoogleGCSBucketUtils.GetFinishedFile(job, number)
+	if err != nil || finished.Timestamp == 0 {
+		return false
+	}
+	clock := e.Clock
+	if clock == nil {
+		clock = utilclock.RealClock{}
+	}
+	age := clock.Since(time.Unix(int64(finished.Timestamp), 0))
+	if age <= e.MaxBuildAge {
+		return false
+	}
+	jobLog(job).With("build", number).Errorf("The latest build finished %v ago. The job may be stuck", age)
+	return true
+}
+
+// StaleJobs returns the blocking jobs whose latest build had finished more
+// than MaxBuildAge before the last GCSBasedStable.
+func (e *RealE2ETester) StaleJobs() []string {
+	e.Lock()
+	defer e.Unlock()
+	return append([]string{}, e.staleJobs...)
+}
+
 func getJUnitFailures(r io.Reader) (failures map[string]string, err error) {
 	type Testcase struct {
 		Name      string `xml:"name,attr"`
@@ -358,7 +508,7 @@ func (e *RealE2ETester) failureReasons(job string, buildNumber int, completeList
 	prefix := "artifacts/junit"
 	junitList, err := e.GoogleGCSBucketUtils.ListFilesInBuild(job, buildNumber, prefix)
 	if err != nil {
-		glog.Errorf("Failed to list junit files for %v/%v/%v: %v", job, buildNumber, prefix, err)
+		jobLog(job).With("build", buildNumber).Errorf("Failed to list junit files in %v: %v", prefix, err)
 	}
 
 	// If we're here it means that build failed, so we need to look for a reason
@@ -393,62 +543,131 @@ func (e *RealE2ETester) failureReasons(job string, buildNumber int, completeList
 	return failedTests, nil
 }
 
+// withoutInfraTests returns failures less the tests matching any of the
+// InfraTestPatterns.
+func (e *RealE2ETester) withoutInfraTests(job string, buildNumber int, failures map[string]string) map[string]string {
+	if len(e.InfraTestPatterns) == 0 {
+		return failures
+	}
+	kept := map[string]string{}
+	for test, reason := range failures {
+		infra := false
+		for _, re := range e.InfraTestPatterns {
+			if re.MatchString(test) {
+				infra = true
+				break
+			}
+		}
+		if infra {
+			jobLog(job).With("build", buildNumber).V(2).Infof("Treating the failure of %q as an infrastructure failure", test)
+			continue
+		}
+		kept[test] = reason
+	}
+	return kept
+}
+
 // GCSWeakStable is a version of GCSBasedStable with a slightly relaxed condition.
 // This function says that e2e's are unstable only if there were real test failures
 // (i.e. there was a test that failed, so no timeouts/cluster startup failures counts),
 // or test failed for any reason 3 times in a row.
 func (e *RealE2ETester) GCSWeakStable() bool {
 	allStable := true
-	for _, job := range e.WeakStableJobNames {
-		lastBuildNumber, err := e.GoogleGCSBucketUtils.GetLastestBuildNumberFromJenkinsGoogleBucket(job)
-		glog.V(4).Infof("Checking status of %v, %v", job, lastBuildNumber)
-		if err != nil {
-			glog.Errorf("Error while getting data for %v: %v", job, err)
-			e.setBuildStatus(job, "Not Stable", strconv.Itoa(lastBuildNumber))
-			continue
-		}
-		if stable, err := e.GoogleGCSBucketUtils.CheckFinishedStatus(job, lastBuildNumber); stable && err == nil {
-			e.setBuildStatus(job, "Stable", strconv.Itoa(lastBuildNumber))
-			continue
+	resultLock := sync.Mutex{}
+	e.forEachJob(e.WeakStableJobNames, func(job string) {
+		if !e.weakStable(job) {
+			resultLock.Lock()
+			allStable = false
+			resultLock.Unlock()
 		}
+	})
+	return allStable
+}
 
-		if e.resolutionTracker.Resolved(cache.Job(job), cache.Number(lastBuildNumber)) {
-			e.setBuildStatus(job, "Problem Resolved", strconv.Itoa(lastBuildNumber))
-			continue
+// weakFinishedStatus is CheckFinishedStatus for weak stable jobs, which
+// also pass with any of the WeakSuccessResults.
+func (e *RealE2ETester) weakFinishedStatus(job string, buildNumber int) (bool, error) {
+	finished, err := e.GoogleGCSBucketUtils.GetFinishedFile(job, buildNumber)
+	if err != nil {
+		return false, err
+	}
+	if finished.Passed != nil || len(e.WeakSuccessResults) == 0 {
+		return finished.Success(), nil
+	}
+	for _, result := range e.WeakSuccessResults {
+		if finished.Result == result {
+			return true, nil
 		}
+	}
+	return false, nil
+}
 
-		failures, err := e.failureReasons(job, lastBuildNumber, false)
-		if err != nil {
-			glog.Errorf("Error while getting data for %v/%v: %v", job, lastBuildNumber, err)
-			e.setBuildStatus(job, "Not Stable", strconv.Itoa(lastBuildNumber))
-			continue
-		}
+// weakStablePolicy returns how many of how many previous builds must pass
+// after a weak failure.
+func (e *RealE2ETester) weakStablePolicy() (int, int) {
+	required, checked := e.WeakStablePassesRequired, e.WeakStableBuildsChecked
+	if checked <= 0 {
+		checked = 2
+	}
+	if required <= 0 {
+		required = 1
+	}
+	if required > checked {
+		required = checked
+	}
+	return required, checked
+}
 
-		thisStable := len(failures) == 0
+// weakStable checks a single job for GCSWeakStable and records its status.
+func (e *RealE2ETester) weakStable(job string) bool {
+	lastBuildNumber, err := e.GoogleGCSBucketUtils.GetLastestBuildNumberFromJenkinsGoogleBucket(job)
+	jobLog(job).With("build", lastBuildNumber).V(4).Infof("Checking status")
+	if err != nil {
+		jobLog(job).Errorf("Error while getting the latest build: %v", err)
+		e.setBuildStatus(job, "Not Stable", strconv.Itoa(lastBuildNumber))
+		return false
+	}
+	if stable, err := e.weakFinishedStatus(job, lastBuildNumber); stable && err == nil {
+		e.setBuildStatus(job, "Stable", strconv.Itoa(lastBuildNumber))
+		return true
+	}
 
-		if thisStable == false {
-			allStable = false
-			e.setBuildStatus(job, "Not Stable", strconv.Itoa(lastBuildNumber))
-			glog.Infof("WeakStable failed because found a failure in JUnit file for build %v; %v and possibly more failed", lastBuildNumber, failures)
-			continue
-		}
+	if e.resolutionTracker.Resolved(cache.Job(job), cache.Number(lastBuildNumber)) {
+		e.setBuildStatus(job, "Problem Resolved", strconv.Itoa(lastBuildNumber))
+		return true
+	}
 
-		// If we're here it means that we weren't able to find a test that failed, which means that the reason of build failure is comming from the infrastructure
-		// Check results of previous two builds.
-		unstable := make([]int, 0)
-		if stable, err := e.GoogleGCSBucketUtils.CheckFinishedStatus(job, lastBuildNumber-1); !stable || err != nil {
-			unstable = append(unstable, lastBuildNumber-1)
-		}
-		if stable, err := e.GoogleGCSBucketUtils.CheckFinishedStatus(job, lastBuildNumber-2); !stable || err != nil {
-			unstable = append(unstable, lastBuildNumber-2)
-		}
-		if len(unstable) > 1 {
-			e.setBuildStatus(job, "Not Stable", strconv.Itoa(lastBuildNumber))
-			allStable = false
-			glog.Infof("WeakStable failed because found a weak failure in build %v and builds %v failed.", lastBuildNumber, unstable)
-			continue
+	// All the failures are needed to know whether any aren't infra ones
+	failures, err := e.failureReasons(job, lastBuildNumber, len(e.InfraTestPatterns) > 0)
+	if err != nil {
+		jobLog(job).With("build", lastBuildNumber).Errorf("Error while getting data: %v", err)
+		e.setBuildStatus(job, "Not Stable", strconv.Itoa(lastBuildNumber))
+		return false
+	}
+	failures = e.withoutInfraTests(job, lastBuildNumber, failures)
+
+	thisStable := len(failures) == 0
+
+	if thisStable == false {
+		e.setBuildStatus(job, "Not Stable", strconv.Itoa(lastBuildNumber))
+		jobLog(job).With("build", lastBuildNumber).Infof("WeakStable failed because found a failure in JUnit file; %v and possibly more failed", failures)
+		return false
+	}
+
+	// If we're here it means that we weren't able to find a test that failed, which means that the reason of build failure is comming from the infrastructure
+	// Check results of previous builds.
+	required, checked := e.weakStablePolicy()
+	unstable := make([]int, 0)
+	for n := lastBuildNumber - 1; n >= lastBuildNumber-checked; n-- {
+		if stable, err := e.weakFinishedStatus(job, n); !stable || err != nil {
+			unstable = append(unstable, n)
 		}
-		e.setBuildStatus(job, "Stable", strconv.Itoa(lastBuildNumber))
 	}
-	return allStable
+	if checked-len(unstable) < required {
+		e.setBuildStatus(job, "Not Stable", strconv.Itoa(lastBuildNumber))
+		jobLog(job).With("build", lastBuildNumber).Infof("WeakStable failed because found a weak failure and builds %v failed, %v of the previous %v must pass.", unstable, required, checked)
+		return false
+	}
+	e.setBuildStatus(job, "Stable", strconv.Itoa(lastBuildNumber))
+	return true
 }
@@ -23,10 +23,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"regexp"
 	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"k8s.io/contrib/test-utils/utils"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
 	"strings"
 )
 
@@ -607,6 +611,253 @@ func TestCheckGCSWeakBuilds(t *testing.T) {
 	}
 }
 
+func TestWeakSuccessResults(t *testing.T) {
+	latestBuildNumber := 42
+	paths := map[string][]byte{
+		"/bucket/logs/foo/latest-build.txt": []byte(strconv.Itoa(latestBuildNumber)),
+		fmt.Sprintf("/bucket/logs/foo/%v/finished.json", latestBuildNumber): marshalOrDie(utils.FinishedFile{
+			Result:    "UNSTABLE",
+			Timestamp: 1234,
+		}, t),
+		"/storage/v1/b/bucket/o": genMockGCSListResponse(),
+	}
+	server := httptest.NewServer(&testHandler{
+		handler: func(res http.ResponseWriter, req *http.Request) {
+			data, found := paths[req.URL.Path]
+			if !found {
+				res.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(res, "Unknown path: %s", req.URL.Path)
+				return
+			}
+			res.WriteHeader(http.StatusOK)
+			res.Write(data)
+		},
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		weak         bool
+		results      []string
+		expectStable bool
+	}{
+		{name: "weak job with the default results", weak: true, expectStable: false},
+		{name: "weak job allowing UNSTABLE", weak: true, results: []string{"SUCCESS", "UNSTABLE"}, expectStable: true},
+		{name: "strong job", results: []string{"SUCCESS", "UNSTABLE"}, expectStable: false},
+	}
+	for _, test := range tests {
+		e2e := &RealE2ETester{
+			WeakSuccessResults:   test.results,
+			BuildStatus:          map[string]BuildInfo{},
+			GoogleGCSBucketUtils: utils.NewTestUtils("bucket", "logs", server.URL),
+		}
+		if test.weak {
+			e2e.WeakStableJobNames = []string{"foo"}
+		} else {
+			e2e.BlockingJobNames = []string{"foo"}
+		}
+		e2e.Init(nil)
+
+		var stable bool
+		if test.weak {
+			stable = e2e.GCSWeakStable()
+		} else {
+			stable, _ = e2e.GCSBasedStable()
+		}
+		if stable != test.expectStable {
+			t.Errorf("%s: expected stable=%v but got %v: %v", test.name, test.expectStable, stable, e2e.BuildStatus)
+		}
+	}
+}
+
+func TestWeakStablePassesRequired(t *testing.T) {
+	latestBuildNumber := 42
+	paths := map[string][]byte{
+		"/bucket/logs/foo/latest-build.txt": []byte(strconv.Itoa(latestBuildNumber)),
+		"/storage/v1/b/bucket/o":            genMockGCSListResponse(),
+	}
+	// Only the latest build failed weakly, and of those before it 41 and 39 passed.
+	for n, result := range map[int]string{42: "FAILURE", 41: "SUCCESS", 40: "FAILURE", 39: "SUCCESS"} {
+		paths[fmt.Sprintf("/bucket/logs/foo/%v/finished.json", n)] = marshalOrDie(utils.FinishedFile{
+			Result:    result,
+			Timestamp: 1234,
+		}, t)
+	}
+	server := httptest.NewServer(&testHandler{
+		handler: func(res http.ResponseWriter, req *http.Request) {
+			data, found := paths[req.URL.Path]
+			if !found {
+				res.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(res, "Unknown path: %s", req.URL.Path)
+				return
+			}
+			res.WriteHeader(http.StatusOK)
+			res.Write(data)
+		},
+	})
+	defer server.Close()
+
+	tests := []struct {
+		required     int
+		checked      int
+		expectStable bool
+	}{
+		{expectStable: true}, // 1 of 2
+		{required: 2, checked: 2, expectStable: false},
+		{required: 2, checked: 3, expectStable: true},
+		{required: 3, checked: 3, expectStable: false},
+		{required: 5, checked: 1, expectStable: true}, // at most all of them
+	}
+	for _, test := range tests {
+		e2e := &RealE2ETester{
+			WeakStableJobNames:       []string{"foo"},
+			WeakStablePassesRequired: test.required,
+			WeakStableBuildsChecked:  test.checked,
+			BuildStatus:              map[string]BuildInfo{},
+			GoogleGCSBucketUtils:     utils.NewTestUtils("bucket", "logs", server.URL),
+		}
+		e2e.Init(nil)
+		if stable := e2e.GCSWeakStable(); stable != test.expectStable {
+			t.Errorf("%d of %d: expected stable=%v but got %v: %v", test.required, test.checked, test.expectStable, stable, e2e.BuildStatus)
+		}
+	}
+}
+
+func TestConcurrentJobPolling(t *testing.T) {
+	const (
+		latency     = 100 * time.Millisecond
+		concurrency = 3
+	)
+	jobs := []string{"a", "b", "c", "d", "e", "f"}
+	tests := []struct {
+		name         string
+		missing      string // a job whose latest build can't be found
+		failing      string // a job whose latest build failed
+		expectStable bool
+	}{
+		{name: "all stable", expectStable: true},
+		{name: "one failing", failing: "d", expectStable: false},
+		{name: "one erroring", missing: "e", expectStable: false},
+	}
+	for _, test := range tests {
+		var inFlight, maxInFlight int32
+		mux := http.NewServeMux()
+		for i, job := range jobs {
+			if job == test.missing {
+				continue
+			}
+			result := "SUCCESS"
+			if job == test.failing {
+				result = "FAILURE"
+			}
+			build := 10 + i
+			// Both the latest build and its result are slow to fetch
+			slow := func() {
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					max := atomic.LoadInt32(&maxInFlight)
+					if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+						break
+					}
+				}
+				time.Sleep(latency)
+			}
+			mux.HandleFunc(fmt.Sprintf("/bucket/logs/%s/latest-build.txt", job), func(res http.ResponseWriter, req *http.Request) {
+				slow()
+				res.Write([]byte(strconv.Itoa(build)))
+			})
+			mux.HandleFunc(fmt.Sprintf("/bucket/logs/%s/%d/finished.json", job, build), func(res http.ResponseWriter, req *http.Request) {
+				slow()
+				res.Write(marshalOrDie(utils.FinishedFile{Result: result, Timestamp: 1234}, t))
+			})
+		}
+		mux.HandleFunc("/storage/v1/b/bucket/o", func(res http.ResponseWriter, req *http.Request) {
+			res.Write(genMockGCSListResponse())
+		})
+		server := httptest.NewServer(mux)
+
+		e2e := &RealE2ETester{
+			BlockingJobNames:     jobs,
+			MaxConcurrency:       concurrency,
+			BuildStatus:          map[string]BuildInfo{},
+			GoogleGCSBucketUtils: utils.NewTestUtils("bucket", "logs", server.URL),
+		}
+		e2e.Init(nil)
+
+		start := time.Now()
+		stable, _ := e2e.GCSBasedStable()
+		elapsed := time.Since(start)
+		server.Close()
+
+		if stable != test.expectStable {
+			t.Errorf("%s: expected stable=%v, saw: %v", test.name, test.expectStable, stable)
+		}
+		if len(e2e.BuildStatus) != len(jobs) {
+			t.Errorf("%s: expected status for %d jobs, saw: %v", test.name, len(jobs), e2e.BuildStatus)
+		}
+		if max := atomic.LoadInt32(&maxInFlight); max > concurrency {
+			t.Errorf("%s: expected at most %d concurrent requests, saw %d", test.name, concurrency, max)
+		}
+		// Fetching the results one at a time alone would take
+		// len(jobs)*latency, on top of the latest build lookups. Missing
+		// builds are retried, so don't bother timing those.
+		if test.missing == "" && elapsed >= time.Duration(len(jobs))*latency {
+			t.Errorf("%s: polling took %v, jobs were not checked concurrently", test.name, elapsed)
+		}
+	}
+}
+
+func TestStaleBuilds(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	tests := []struct {
+		name         string
+		finished     time.Time
+		maxAge       time.Duration
+		expectStable bool
+		expectStale  []string
+	}{
+		{name: "recent build", finished: now.Add(-time.Hour), maxAge: 2 * time.Hour, expectStable: true, expectStale: []string{}},
+		{name: "old build", finished: now.Add(-3 * time.Hour), maxAge: 2 * time.Hour, expectStable: false, expectStale: []string{"foo"}},
+		{name: "disabled", finished: now.Add(-300 * time.Hour), expectStable: true, expectStale: []string{}},
+	}
+	for _, test := range tests {
+		finished := test.finished
+		mux := http.NewServeMux()
+		mux.HandleFunc("/bucket/logs/foo/latest-build.txt", func(res http.ResponseWriter, req *http.Request) {
+			res.Write([]byte("42"))
+		})
+		mux.HandleFunc("/bucket/logs/foo/42/finished.json", func(res http.ResponseWriter, req *http.Request) {
+			res.Write(marshalOrDie(utils.FinishedFile{Result: "SUCCESS", Timestamp: uint64(finished.Unix())}, t))
+		})
+		mux.HandleFunc("/storage/v1/b/bucket/o", func(res http.ResponseWriter, req *http.Request) {
+			res.Write(genMockGCSListResponse())
+		})
+		server := httptest.NewServer(mux)
+
+		e2e := &RealE2ETester{
+			BlockingJobNames:     []string{"foo"},
+			MaxBuildAge:          test.maxAge,
+			Clock:                utilclock.NewFakeClock(now),
+			BuildStatus:          map[string]BuildInfo{},
+			GoogleGCSBucketUtils: utils.NewTestUtils("bucket", "logs", server.URL),
+		}
+		e2e.Init(nil)
+		stable, _ := e2e.GCSBasedStable()
+		server.Close()
+
+		if stable != test.expectStable {
+			t.Errorf("%s: expected stable=%v, saw: %v", test.name, test.expectStable, stable)
+		}
+		if stale := e2e.StaleJobs(); !reflect.DeepEqual(stale, test.expectStale) {
+			t.Errorf("%s: expected the stale jobs %v, saw: %v", test.name, test.expectStale, stale)
+		}
+		if !test.expectStable && e2e.BuildStatus["foo"].Status != "Stale" {
+			t.Errorf("%s: expected foo to be Stale, saw: %v", test.name, e2e.BuildStatus["foo"])
+		}
+	}
+}
+
 func TestJUnitFailureParse(t *testing.T) {
 	//parse junit xml result with <testsuite> as top tag
 	junitFailReader := bytes.NewReader(getRealJUnitFailure())
@@ -635,3 +886,77 @@ func TestJUnitFailureParse(t *testing.T) {
 		t.Errorf("Expected %v, got %v", e, a)
 	}
 }
+
+func TestInfraTestPatterns(t *testing.T) {
+	latestBuildNumber := 42
+	junit := func(failed string) []byte {
+		return []byte(fmt.Sprintf(`%v
+<testsuite tests="2" failures="1" time="1234">
+<testcase name="[k8s.io] Pods should be schedulable" classname="Kubernetes e2e suite"/>
+<testcase name=%q classname="Kubernetes e2e suite"><failure>failed</failure></testcase>
+</testsuite>`, ExpectedXMLHeader, failed))
+	}
+	tests := []struct {
+		name         string
+		failed       string
+		patterns     []string
+		expectStable bool
+	}{
+		{
+			name:         "infra test failure is weak",
+			failed:       "Cluster setup",
+			patterns:     []string{`^Cluster setup \{`, `^Node health`},
+			expectStable: true,
+		},
+		{
+			name:     "real failure still blocks",
+			failed:   "[k8s.io] Kubectl client should patch",
+			patterns: []string{`^Cluster setup \{`},
+		},
+		{
+			name:   "no patterns",
+			failed: "Cluster setup",
+		},
+	}
+	for _, test := range tests {
+		paths := map[string][]byte{
+			"/bucket/logs/foo/latest-build.txt":          []byte(strconv.Itoa(latestBuildNumber)),
+			"/storage/v1/b/bucket/o":                     genMockGCSListResponse("logs/foo/42/artifacts/junit_01.xml", "logs/foo/42/artifacts/junit_02.xml"),
+			"/bucket/logs/foo/42/artifacts/junit_01.xml": getJUnit(5, 0),
+			"/bucket/logs/foo/42/artifacts/junit_02.xml": junit(test.failed),
+		}
+		for n, result := range map[int]string{42: "FAILURE", 41: "SUCCESS", 40: "SUCCESS"} {
+			paths[fmt.Sprintf("/bucket/logs/foo/%v/finished.json", n)] = marshalOrDie(utils.FinishedFile{
+				Result:    result,
+				Timestamp: 1234,
+			}, t)
+		}
+		server := httptest.NewServer(&testHandler{
+			handler: func(res http.ResponseWriter, req *http.Request) {
+				data, found := paths[req.URL.Path]
+				if !found {
+					res.WriteHeader(http.StatusNotFound)
+					fmt.Fprintf(res, "Unknown path: %s", req.URL.Path)
+					return
+				}
+				res.WriteHeader(http.StatusOK)
+				res.Write(data)
+			},
+		})
+		patterns := []*regexp.Regexp{}
+		for _, p := range test.patterns {
+			patterns = append(patterns, regexp.MustCompile(p))
+		}
+		e2e := &RealE2ETester{
+			WeakStableJobNames:   []string{"foo"},
+			InfraTestPatterns:    patterns,
+			BuildStatus:          map[string]BuildInfo{},
+			GoogleGCSBucketUtils: utils.NewTestUtils("bucket", "logs", server.URL),
+		}
+		e2e.Init(nil)
+		if stable := e2e.GCSWeakStable(); stable != test.expectStable {
+			t.Errorf("%s: expected stable=%v but got %v: %v", test.name, test.expectStable, stable, e2e.BuildStatus)
+		}
+		server.Close()
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package fake
 
 import (
+	"fmt"
+
 	"k8s.io/contrib/mungegithub/mungers/e2e"
 	cache "k8s.io/contrib/mungegithub/mungers/flakesync"
 )
@@ -26,6 +28,57 @@ type FakeE2ETester struct {
 	JobNames           []string
 	WeakStableJobNames []string
 	NotStableJobNames  []string
+
+	// Categories is what ClassifyBuild returns for each job. Jobs which
+	// aren't listed are a TestFailure.
+	Categories map[string]e2e.ResultCategory
+
+	// NewFailureResults is what NewFailures returns for each job. Jobs
+	// which aren't listed return an error.
+	NewFailureResults map[string][]string
+
+	// FailedTestResults is what FailedTests returns for each job. Jobs
+	// which aren't listed return an error.
+	FailedTestResults map[string][]string
+
+	// Aborted maps each PR passed to AbortPR to the sha it was aborted at.
+	Aborted map[int]string
+
+	// StaleJobNames are returned by StaleJobs, and make GCSBasedStable
+	// false.
+	StaleJobNames []string
+}
+
+// AbortPR records the PR in e.Aborted.
+func (e *FakeE2ETester) AbortPR(pr int, sha string) {
+	if e.Aborted == nil {
+		e.Aborted = map[int]string{}
+	}
+	e.Aborted[pr] = sha
+}
+
+// ClassifyBuild returns the job's entry in e.Categories.
+func (e *FakeE2ETester) ClassifyBuild(job string, number int) (e2e.ResultCategory, error) {
+	if category, ok := e.Categories[job]; ok {
+		return category, nil
+	}
+	return e2e.TestFailure, nil
+}
+
+// NewFailures returns the job's entry in e.NewFailureResults.
+func (e *FakeE2ETester) NewFailures(job string, number int, baseJob string) ([]string, error) {
+	if failures, ok := e.NewFailureResults[job]; ok {
+		return failures, nil
+	}
+	return nil, fmt.Errorf("no failed tests found in %v/%v", job, number)
+}
+
+// FailedTests returns the job's entry in e.FailedTestResults.
+func (e *FakeE2ETester) FailedTests(job string, number int) ([]string, error) {
+	if failures, ok := e.FailedTestResults[job]; ok {
+		return failures, nil
+	}
+	return nil, fmt.Errorf("no failed tests found in %v/%v", job, number)
 }
 
 // Flakes returns nil.
@@ -33,8 +86,11 @@ func (e *FakeE2ETester) Flakes() cache.Flakes {
 	return nil
 }
 
-// GCSBasedStable is always true.
-func (e *FakeE2ETester) GCSBasedStable() (bool, bool) { return true, false }
+// GCSBasedStable is true unless there are StaleJobNames.
+func (e *FakeE2ETester) GCSBasedStable() (bool, bool) { return len(e.StaleJobNames) == 0, false }
+
+// StaleJobs returns e.StaleJobNames.
+func (e *FakeE2ETester) StaleJobs() []string { return e.StaleJobNames }
 
 // GCSWeakStable is always true.
 func (e *FakeE2ETester) GCSWeakStable() bool { return true }
@@ -0,0 +1,112 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"fmt"
+	"os"
+
+	"k8s.io/kubernetes/pkg/util/sets"
+	"k8s.io/kubernetes/pkg/util/yaml"
+)
+
+// MatrixJob is a job which is run once for every cloud and version. Each
+// cell of the matrix is a job of its own in GCS, named like "foo-gce-1.8".
+// The cells, like "gce-1.8", which are Optional are checked but only
+// reported, like NonBlockingJobNames; the rest must all pass.
+type MatrixJob struct {
+	Job      string   `json:"job"`
+	Clouds   []string `json:"clouds"`
+	Versions []string `json:"versions"`
+	Optional []string `json:"optional,omitempty"`
+}
+
+func cellName(cloud, version string) string {
+	return cloud + "-" + version
+}
+
+// Cells returns the names of the jobs for the cells which must pass and
+// for the optional ones, in order of cloud and then version.
+func (m MatrixJob) Cells() (required, optional []string) {
+	optionalCells := sets.NewString(m.Optional...)
+	for _, cloud := range m.Clouds {
+		for _, version := range m.Versions {
+			cell := cellName(cloud, version)
+			job := m.Job + "-" + cell
+			if optionalCells.Has(cell) {
+				optional = append(optional, job)
+			} else {
+				required = append(required, job)
+			}
+		}
+	}
+	return required, optional
+}
+
+func (m MatrixJob) validate() error {
+	if m.Job == "" || len(m.Clouds) == 0 || len(m.Versions) == 0 {
+		return fmt.Errorf("matrix job %q needs a job name, clouds and versions", m.Job)
+	}
+	cells := sets.NewString()
+	for _, cloud := range m.Clouds {
+		for _, version := range m.Versions {
+			cells.Insert(cellName(cloud, version))
+		}
+	}
+	for _, cell := range m.Optional {
+		if !cells.Has(cell) {
+			return fmt.Errorf("matrix job %q has no cell %q", m.Job, cell)
+		}
+	}
+	return nil
+}
+
+// LoadMatrix reads a YAML or JSON list of MatrixJobs from the file at
+// path. An empty path means no matrix.
+func LoadMatrix(path string) ([]MatrixJob, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the e2e matrix: %v", err)
+	}
+	defer file.Close()
+	matrix := []MatrixJob{}
+	if err := yaml.NewYAMLToJSONDecoder(file).Decode(&matrix); err != nil {
+		return nil, fmt.Errorf("unable to decode the e2e matrix %s: %v", path, err)
+	}
+	for _, m := range matrix {
+		if err := m.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return matrix, nil
+}
+
+// matrixJobs returns the BlockingJobNames and the required matrix cells,
+// and the NonBlockingJobNames and the optional cells.
+func (e *RealE2ETester) matrixJobs() (blocking, nonBlocking []string) {
+	blocking = append([]string{}, e.BlockingJobNames...)
+	nonBlocking = append([]string{}, e.NonBlockingJobNames...)
+	for _, m := range e.Matrix {
+		required, optional := m.Cells()
+		blocking = append(blocking, required...)
+		nonBlocking = append(nonBlocking, optional...)
+	}
+	return blocking, nonBlocking
+}
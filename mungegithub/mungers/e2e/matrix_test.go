@@ -0,0 +1,163 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"k8s.io/contrib/test-utils/utils"
+)
+
+func TestMatrixCells(t *testing.T) {
+	m := MatrixJob{
+		Job:      "foo",
+		Clouds:   []string{"gce", "gke"},
+		Versions: []string{"1.7", "1.8"},
+		Optional: []string{"gke-1.7"},
+	}
+	required, optional := m.Cells()
+	if expected := []string{"foo-gce-1.7", "foo-gce-1.8", "foo-gke-1.8"}; !reflect.DeepEqual(required, expected) {
+		t.Errorf("expected the required cells %v, saw: %v", expected, required)
+	}
+	if expected := []string{"foo-gke-1.7"}; !reflect.DeepEqual(optional, expected) {
+		t.Errorf("expected the optional cells %v, saw: %v", expected, optional)
+	}
+}
+
+func TestLoadMatrix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "e2e-matrix")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		contents string
+		expected []MatrixJob
+		err      bool
+	}{
+		{
+			name:     "yaml",
+			contents: "- job: foo\n  clouds: [gce]\n  versions: ['1.8']\n",
+			expected: []MatrixJob{{Job: "foo", Clouds: []string{"gce"}, Versions: []string{"1.8"}}},
+		},
+		{
+			name:     "json",
+			contents: `[{"job": "foo", "clouds": ["gce", "aws"], "versions": ["1.8"], "optional": ["aws-1.8"]}]`,
+			expected: []MatrixJob{{Job: "foo", Clouds: []string{"gce", "aws"}, Versions: []string{"1.8"}, Optional: []string{"aws-1.8"}}},
+		},
+		{
+			name:     "no versions",
+			contents: "- job: foo\n  clouds: [gce]\n",
+			err:      true,
+		},
+		{
+			name:     "unknown optional cell",
+			contents: "- job: foo\n  clouds: [gce]\n  versions: ['1.8']\n  optional: [gke-1.8]\n",
+			err:      true,
+		},
+	}
+	for i, test := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("matrix-%d.yaml", i))
+		if err := ioutil.WriteFile(path, []byte(test.contents), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		matrix, err := LoadMatrix(path)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, saw: %v", test.name, matrix)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		} else if !reflect.DeepEqual(matrix, test.expected) {
+			t.Errorf("%s: expected %v, saw: %v", test.name, test.expected, matrix)
+		}
+	}
+	if matrix, err := LoadMatrix(""); err != nil || matrix != nil {
+		t.Errorf("expected no matrix without a file, saw: %v, %v", matrix, err)
+	}
+}
+
+func TestMatrixStable(t *testing.T) {
+	matrix := []MatrixJob{{
+		Job:      "foo",
+		Clouds:   []string{"gce", "gke"},
+		Versions: []string{"1.7", "1.8"},
+		Optional: []string{"gke-1.7"},
+	}}
+	tests := []struct {
+		name           string
+		failing        string
+		expectStable   bool
+		expectedStatus string
+	}{
+		{name: "all cells pass", expectStable: true},
+		{name: "a required cell fails", failing: "foo-gce-1.8", expectStable: false, expectedStatus: "Not Stable"},
+		{name: "an optional cell fails", failing: "foo-gke-1.7", expectStable: true, expectedStatus: "[nonblocking] Not Stable"},
+	}
+	for _, test := range tests {
+		mux := http.NewServeMux()
+		required, optional := matrix[0].Cells()
+		for i, job := range append(required, optional...) {
+			result := "SUCCESS"
+			if job == test.failing {
+				result = "FAILURE"
+			}
+			build := 10 + i
+			mux.HandleFunc(fmt.Sprintf("/bucket/logs/%s/latest-build.txt", job), func(res http.ResponseWriter, req *http.Request) {
+				res.Write([]byte(strconv.Itoa(build)))
+			})
+			mux.HandleFunc(fmt.Sprintf("/bucket/logs/%s/%d/finished.json", job, build), func(res http.ResponseWriter, req *http.Request) {
+				res.Write(marshalOrDie(utils.FinishedFile{Result: result, Timestamp: 1234}, t))
+			})
+		}
+		mux.HandleFunc("/storage/v1/b/bucket/o", func(res http.ResponseWriter, req *http.Request) {
+			res.Write(genMockGCSListResponse())
+		})
+		server := httptest.NewServer(mux)
+
+		e2e := &RealE2ETester{
+			Matrix:               matrix,
+			BuildStatus:          map[string]BuildInfo{},
+			GoogleGCSBucketUtils: utils.NewTestUtils("bucket", "logs", server.URL),
+		}
+		e2e.Init(nil)
+		stable, _ := e2e.GCSBasedStable()
+		server.Close()
+
+		if stable != test.expectStable {
+			t.Errorf("%s: expected stable=%v, saw: %v", test.name, test.expectStable, stable)
+		}
+		if len(e2e.BuildStatus) != 4 {
+			t.Errorf("%s: expected status for every cell, saw: %v", test.name, e2e.BuildStatus)
+		}
+		if test.failing != "" && e2e.BuildStatus[test.failing].Status != test.expectedStatus {
+			t.Errorf("%s: expected %s to be %q, saw: %v", test.name, test.failing, test.expectedStatus, e2e.BuildStatus[test.failing])
+		}
+	}
+}
@@ -118,7 +118,7 @@ func (r *ResolutionTracker) SetHTTP(res http.ResponseWriter, req *http.Request)
 	r.lock.Lock()
 	defer r.lock.Unlock()
 	r.resolved[key] = req.URL.Query().Get("resolved") != "false"
-	glog.Infof("Marking manually resolved: %v %v: %v", key.job, key.number, r.resolved[key])
+	jobLog(key.job).With("build", key.number).Infof("Marking manually resolved: %v", r.resolved[key])
 	r.serveKeyLocked(key, res)
 }
 
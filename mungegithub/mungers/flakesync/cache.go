@@ -105,11 +105,12 @@ type Cache struct {
 	expireList *list.List
 	maxFlakes  int // tests can modify this
 
-	// only one expensive lookup at a time. Also, don't lock the cache
-	// while we're doing an expensive update. If you lock both locks, you
-	// must lock this one first.
-	expensiveLookupLock sync.Mutex
-	doExpensiveLookup   ResultFunc
+	// only one expensive lookup at a time per job & number, so lookups of
+	// different runs can proceed in parallel. Also, don't lock the cache
+	// while we're doing an expensive update. If you lock both a lookup lock
+	// and the cache lock, you must lock the lookup lock first.
+	lookupLocks       map[key]*sync.Mutex
+	doExpensiveLookup ResultFunc
 }
 
 // ResultFunc should look up the job & number from its source (GCS or
@@ -122,6 +123,7 @@ func NewCache(getFunc ResultFunc) *Cache {
 		byJob:             jobMap{},
 		flakeQueue:        flakeMap{},
 		expireList:        list.New(),
+		lookupLocks:       map[key]*sync.Mutex{},
 		doExpensiveLookup: getFunc,
 		maxFlakes:         maxFlakes,
 	}
@@ -145,9 +147,23 @@ func (c *Cache) lookup(j Job, n Number) (*Result, bool) {
 	return r, ok
 }
 
+// lookupLock returns the lock guarding the expensive lookup of j & n.
+func (c *Cache) lookupLock(j Job, n Number) *sync.Mutex {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	k := key{j, n}
+	l, ok := c.lookupLocks[k]
+	if !ok {
+		l = &sync.Mutex{}
+		c.lookupLocks[k] = l
+	}
+	return l
+}
+
 func (c *Cache) populate(j Job, n Number) (*Result, error) {
-	c.expensiveLookupLock.Lock()
-	defer c.expensiveLookupLock.Unlock()
+	l := c.lookupLock(j, n)
+	l.Lock()
+	defer l.Unlock()
 	if r, ok := c.lookup(j, n); ok {
 		// added to the queue in the time it took us to get the lock.
 		return r, nil
@@ -0,0 +1,133 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/features"
+	"k8s.io/contrib/mungegithub/github"
+
+	"github.com/golang/glog"
+	githubapi "github.com/google/go-github/github"
+	"github.com/spf13/cobra"
+)
+
+const (
+	needsIssueLabel = "needs-issue"
+
+	needsIssuePrefix = "This PR does not reference an issue."
+	needsIssueFmt    = "@%s " + needsIssuePrefix + " Please add one to its description, like %q. The " + needsIssueLabel + " label will be removed once it does."
+
+	// Matches "Fixes #123", "closes org/repo#123" and "Issue: #123"
+	defaultIssueReferencePattern = `(?i)(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?|issue:)\s*(?:[\w.-]+/[\w.-]+)?#\d+`
+	defaultIssueReferenceExample = "Fixes #123"
+)
+
+// IssueReferenceMunger adds the needs-issue label, and asks the author to
+// fix it, when a PR's description has nothing matching Pattern. The label
+// is removed once it does.
+type IssueReferenceMunger struct {
+	Pattern string
+	Example string
+	pattern *regexp.Regexp
+}
+
+func init() {
+	i := &IssueReferenceMunger{}
+	RegisterMungerOrDie(i)
+	RegisterStaleComments(i)
+}
+
+// Name is the name usable in --pr-mungers
+func (i *IssueReferenceMunger) Name() string { return "issue-reference" }
+
+// RequiredFeatures is a slice of 'features' that must be provided
+func (i *IssueReferenceMunger) RequiredFeatures() []string { return []string{} }
+
+// Initialize will initialize the munger
+func (i *IssueReferenceMunger) Initialize(config *github.Config, features *features.Features) error {
+	re, err := regexp.Compile(i.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid --issue-reference-pattern %q: %v", i.Pattern, err)
+	}
+	i.pattern = re
+	return nil
+}
+
+// EachLoop is called at the start of every munge loop
+func (i *IssueReferenceMunger) EachLoop() error { return nil }
+
+// AddFlags will add any request flags to the cobra `cmd`
+func (i *IssueReferenceMunger) AddFlags(cmd *cobra.Command, config *github.Config) {
+	cmd.Flags().StringVar(&i.Pattern, "issue-reference-pattern", defaultIssueReferencePattern, "Regexp which a PR's description must match to reference an issue")
+	cmd.Flags().StringVar(&i.Example, "issue-reference-example", defaultIssueReferenceExample, "An issue reference matching --issue-reference-pattern, to show authors what to add")
+}
+
+// hasReference returns true if obj's description, less its HTML comments,
+// matches the pattern.
+func (i *IssueReferenceMunger) hasReference(obj *github.MungeObject) bool {
+	if obj.Issue.Body == nil {
+		return false
+	}
+	return i.pattern.MatchString(github.CleanIssueBody(*obj.Issue.Body))
+}
+
+// Munge is the workhorse the will actually make updates to the PR
+func (i *IssueReferenceMunger) Munge(obj *github.MungeObject) {
+	if !obj.IsPR() {
+		return
+	}
+
+	if i.hasReference(obj) {
+		if obj.HasLabel(needsIssueLabel) {
+			obj.RemoveLabel(needsIssueLabel)
+		}
+		return
+	}
+
+	if obj.HasLabel(needsIssueLabel) {
+		return
+	}
+	obj.AddLabel(needsIssueLabel)
+	author := "UNKNOWN"
+	if obj.Issue.User != nil && obj.Issue.User.Login != nil {
+		author = *obj.Issue.User.Login
+	}
+	obj.WriteComment(fmt.Sprintf(needsIssueFmt, author, i.Example))
+}
+
+func (i *IssueReferenceMunger) isStaleComment(obj *github.MungeObject, comment *githubapi.IssueComment) bool {
+	if !mergeBotComment(comment) || !strings.Contains(*comment.Body, needsIssuePrefix) {
+		return false
+	}
+	stale := i.hasReference(obj)
+	if stale {
+		glog.V(6).Infof("Found stale IssueReferenceMunger comment")
+	}
+	return stale
+}
+
+// StaleComments returns a slice of stale comments
+func (i *IssueReferenceMunger) StaleComments(obj *github.MungeObject, comments []*githubapi.IssueComment) []*githubapi.IssueComment {
+	if i.pattern == nil {
+		return nil
+	}
+	return forEachCommentTest(obj, comments, i.isStaleComment)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestIssueReferenceMunge(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		pattern     string
+		labels      []string
+		expectLabel bool
+		added       bool
+		removed     bool
+		commented   bool
+	}{
+		{
+			name: "references an issue",
+			body: "Does a thing.\n\nFixes #123",
+		},
+		{
+			name: "references an issue in another repo",
+			body: "closes kubernetes/kubernetes#123",
+		},
+		{
+			name:        "no reference",
+			body:        "Does a thing.",
+			expectLabel: true,
+			added:       true,
+			commented:   true,
+		},
+		{
+			name:        "placeholder left from the template",
+			body:        "<!-- e.g. Fixes #123 -->\nDoes a thing.",
+			expectLabel: true,
+			added:       true,
+			commented:   true,
+		},
+		{
+			name:        "already labeled",
+			body:        "Does a thing.",
+			labels:      []string{needsIssueLabel},
+			expectLabel: true,
+		},
+		{
+			name:    "reference added since it was labeled",
+			body:    "Fixes #123",
+			labels:  []string{needsIssueLabel},
+			removed: true,
+		},
+		{
+			name:        "custom pattern",
+			body:        "Fixes #123",
+			pattern:     `(?m)^Issue: [A-Z]+-\d+$`,
+			expectLabel: true,
+			added:       true,
+			commented:   true,
+		},
+		{
+			name:    "custom pattern matches",
+			body:    "Does a thing.\nIssue: PROJ-42",
+			pattern: `(?m)^Issue: [A-Z]+-\d+$`,
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(someUserName, 1, test.labels, true)
+		issue.Body = stringPtr(test.body)
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), nil, nil, nil, nil, nil)
+		added, removed := false, false
+		mux.HandleFunc("/repos/o/r/issues/1/labels/", func(w http.ResponseWriter, r *http.Request) {
+			if strings.TrimPrefix(r.URL.Path, "/repos/o/r/issues/1/labels/") == needsIssueLabel {
+				removed = true
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+		mux.HandleFunc("/repos/o/r/issues/1/labels", func(w http.ResponseWriter, r *http.Request) {
+			added = true
+			data, _ := json.Marshal([]github.Label{{}})
+			w.Write(data)
+		})
+		comments := []string{}
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			c := new(github.IssueComment)
+			json.NewDecoder(r.Body).Decode(c)
+			comments = append(comments, *c.Body)
+			data, _ := json.Marshal(c)
+			w.Write(data)
+		})
+
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		obj, err := config.GetObject(1)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		pattern := test.pattern
+		if pattern == "" {
+			pattern = defaultIssueReferencePattern
+		}
+		i := IssueReferenceMunger{Pattern: pattern, Example: defaultIssueReferenceExample}
+		if err := i.Initialize(config, nil); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		i.Munge(obj)
+		server.Close()
+
+		if obj.HasLabel(needsIssueLabel) != test.expectLabel {
+			t.Errorf("%s: expected the %s label=%v but got %v", test.name, needsIssueLabel, test.expectLabel, obj.Issue.Labels)
+		}
+		if added != test.added || removed != test.removed {
+			t.Errorf("%s: expected added=%v removed=%v but got %v %v", test.name, test.added, test.removed, added, removed)
+		}
+		commented := len(comments) == 1 && strings.Contains(comments[0], needsIssuePrefix)
+		if commented != test.commented || (!test.commented && len(comments) != 0) {
+			t.Errorf("%s: expected a comment=%v but got %q", test.name, test.commented, comments)
+		}
+
+		// The comment is stale once the reference is there
+		comment := github_test.IssueComment(1, "@"+someUserName+" "+needsIssuePrefix, botName, 10)
+		stale := len(i.StaleComments(obj, []*github.IssueComment{comment})) == 1
+		if stale == test.expectLabel {
+			t.Errorf("%s: expected the needs-issue comment to be stale=%v", test.name, !test.expectLabel)
+		}
+	}
+
+	if err := (&IssueReferenceMunger{Pattern: "("}).Initialize(nil, nil); err == nil {
+		t.Errorf("expected an invalid pattern to be rejected")
+	}
+}
@@ -39,9 +39,21 @@ type Munger interface {
 	EachLoop() error
 }
 
+// ExtraRepoMunger is implemented by the mungers which also handle the
+// issues of --extra-repos. Everything else, like the local checkout and
+// OWNERS, only describes the main repo, so the other mungers only see its
+// issues.
+type ExtraRepoMunger interface {
+	Munger
+	HandlesExtraRepos() bool
+}
+
 var mungerMap = map[string]Munger{}
 var mungers = []Munger{}
 
+// mainRepo is the "org/project" of the repo every munger handles
+var mainRepo string
+
 // GetAllMungers returns a slice of all registered mungers. This list is
 // completely independant of the mungers selected at runtime in --pr-mungers.
 // This is all possible mungers.
@@ -85,6 +97,7 @@ func RegisterMungers(requestedMungers []string) error {
 
 // InitializeMungers will call munger.Initialize() for the requested mungers.
 func InitializeMungers(config *github.Config, features *features.Features) error {
+	mainRepo = config.FullName()
 	for _, munger := range mungers {
 		if err := munger.Initialize(config, features); err != nil {
 			return err
@@ -124,10 +137,19 @@ func RegisterMungerOrDie(munger Munger) {
 	}
 }
 
-// MungeIssue will call each activated munger with the given object
+// MungeIssue will call each activated munger with the given object. Issues
+// in the extra repos only go to the ExtraRepoMungers.
 func MungeIssue(obj *github.MungeObject) error {
 	for _, munger := range mungers {
+		if obj.Repo() != mainRepo && !handlesExtraRepos(munger) {
+			continue
+		}
 		munger.Munge(obj)
 	}
 	return nil
 }
+
+func handlesExtraRepos(munger Munger) bool {
+	m, ok := munger.(ExtraRepoMunger)
+	return ok && m.HandlesExtraRepos()
+}
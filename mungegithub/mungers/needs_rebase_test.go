@@ -0,0 +1,127 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestNeedsRebase(t *testing.T) {
+	tests := []struct {
+		name      string
+		pr        *github.PullRequest
+		labels    []string
+		added     []string
+		removed   bool
+		commented bool
+	}{
+		{
+			name:      "conflicting",
+			pr:        UnMergeablePR(),
+			added:     []string{needsRebaseLabel},
+			commented: true,
+		},
+		{
+			name:   "still conflicting",
+			pr:     UnMergeablePR(),
+			labels: []string{needsRebaseLabel},
+		},
+		{
+			name: "github hasn't worked it out yet",
+			pr:   UndeterminedMergeablePR(),
+		},
+		{
+			name:   "github hasn't worked it out again",
+			pr:     UndeterminedMergeablePR(),
+			labels: []string{needsRebaseLabel},
+		},
+		{
+			name:    "conflicts resolved",
+			pr:      ValidPR(),
+			labels:  []string{needsRebaseLabel},
+			removed: true,
+		},
+		{
+			name: "never conflicting",
+			pr:   ValidPR(),
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(someUserName, 1, test.labels, true)
+		client, server, mux := github_test.InitServer(t, issue, test.pr, nil, nil, nil, nil, nil)
+		var added []string
+		removed := false
+		commented := ""
+		mux.HandleFunc("/repos/o/r/issues/1/labels", func(w http.ResponseWriter, r *http.Request) {
+			labels := []string{}
+			json.NewDecoder(r.Body).Decode(&labels)
+			added = append(added, labels...)
+			w.Write([]byte("[]"))
+		})
+		mux.HandleFunc("/repos/o/r/issues/1/labels/"+needsRebaseLabel, func(w http.ResponseWriter, r *http.Request) {
+			removed = true
+		})
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			c := new(github.IssueComment)
+			json.NewDecoder(r.Body).Decode(c)
+			commented = *c.Body
+			w.Write([]byte("{}"))
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.BaseWaitTime = time.Millisecond
+		config.SetClient(client)
+
+		obj := github_util.TestObject(config, issue, test.pr, nil, nil)
+		NeedsRebaseMunger{}.Munge(obj)
+
+		if !reflect.DeepEqual(added, test.added) {
+			t.Errorf("%s: expected %v to be added but got %v", test.name, test.added, added)
+		}
+		if removed != test.removed {
+			t.Errorf("%s: expected removed=%v but got %v", test.name, test.removed, removed)
+		}
+		if (commented != "") != test.commented {
+			t.Errorf("%s: expected commented=%v but got %q", test.name, test.commented, commented)
+		}
+		if test.commented && !rebaseRE.MatchString(commented) {
+			t.Errorf("%s: expected a needs rebase comment but got %q", test.name, commented)
+		}
+		server.Close()
+	}
+}
+
+func TestNeedsRebaseStaleComment(t *testing.T) {
+	comment := github_test.IssueComment(1, "@"+someUserName+" PR needs rebase", botName, 10)
+	for _, labels := range [][]string{{needsRebaseLabel}, nil} {
+		obj := github_util.TestObject(nil, github_test.Issue(someUserName, 1, labels, true), nil, nil, nil)
+		stale := NeedsRebaseMunger{}.isStaleComment(obj, comment)
+		if expected := len(labels) == 0; stale != expected {
+			t.Errorf("labels %v: expected stale=%v but got %v", labels, expected, stale)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"k8s.io/contrib/mungegithub/features"
+	"k8s.io/contrib/mungegithub/github"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// leastLoadedStrategy picks the owner we've asked for the fewest reviews.
+	leastLoadedStrategy = "least-loaded"
+	// randomStrategy picks any of the owners.
+	randomStrategy = "random"
+)
+
+// ownersSource finds the reviewers listed in the OWNERS files closest to a
+// path. *features.RepoInfo is the real implementation.
+type ownersSource interface {
+	LeafReviewers(path string) sets.String
+}
+
+// ReviewRequester asks the owners of the files changed by a PR to review it,
+// using the github review request API. Files are skipped if someone who owns
+// them has already been asked or has already reviewed.
+type ReviewRequester struct {
+	Strategy     string
+	MaxReviewers int
+
+	owners  ownersSource
+	aliases *features.Aliases
+	// load is the number of reviews requested from each user since the
+	// munger started.
+	load map[string]int
+	// handled is the head SHA of each PR the last time reviews were
+	// requested, so a PR is only looked at again after a push.
+	handled map[int]string
+}
+
+func init() {
+	RegisterMungerOrDie(&ReviewRequester{})
+}
+
+// Name is the name usable in --pr-mungers
+func (r *ReviewRequester) Name() string { return "request-reviewers" }
+
+// RequiredFeatures is a slice of 'features' that must be provided
+func (r *ReviewRequester) RequiredFeatures() []string {
+	return []string{features.RepoFeatureName}
+}
+
+// Initialize will initialize the munger
+func (r *ReviewRequester) Initialize(config *github.Config, features *features.Features) error {
+	if r.Strategy != leastLoadedStrategy && r.Strategy != randomStrategy {
+		return fmt.Errorf("--request-reviewers-strategy must be %q or %q, not %q", leastLoadedStrategy, randomStrategy, r.Strategy)
+	}
+	if r.MaxReviewers < 1 {
+		return fmt.Errorf("--request-reviewers-max must be at least 1")
+	}
+	if r.owners == nil && features != nil {
+		r.owners = features.Repos
+		r.aliases = features.Aliases
+	}
+	r.load = map[string]int{}
+	r.handled = map[int]string{}
+	return nil
+}
+
+// EachLoop is called at the start of every munge loop
+func (r *ReviewRequester) EachLoop() error { return nil }
+
+// AddFlags will add any request flags to the cobra `cmd`
+func (r *ReviewRequester) AddFlags(cmd *cobra.Command, config *github.Config) {
+	cmd.Flags().StringVar(&r.Strategy, "request-reviewers-strategy", leastLoadedStrategy, "How to choose between the owners of a file: least-loaded or random")
+	cmd.Flags().IntVar(&r.MaxReviewers, "request-reviewers-max", 2, "The most reviewers to request on a single PR")
+}
+
+// fileOwners returns the reviewers for the path, without the PR author.
+func (r *ReviewRequester) fileOwners(path, author string) sets.String {
+	owners := r.owners.LeafReviewers(path)
+	if r.aliases != nil && r.aliases.IsEnabled {
+		owners = r.aliases.Expand(owners)
+	}
+	return owners.Difference(sets.NewString(author))
+}
+
+// pick chooses one of the owners according to the strategy.
+func (r *ReviewRequester) pick(owners []string) string {
+	if r.Strategy == randomStrategy {
+		return owners[rand.Intn(len(owners))]
+	}
+	// owners is sorted, so ties go to the first name
+	best := owners[0]
+	for _, owner := range owners[1:] {
+		if r.load[owner] < r.load[best] {
+			best = owner
+		}
+	}
+	return best
+}
+
+// Munge is the workhorse the will actually make updates to the PR
+func (r *ReviewRequester) Munge(obj *github.MungeObject) {
+	if !obj.IsPR() {
+		return
+	}
+	num := *obj.Issue.Number
+	sha, _, ok := obj.GetHeadAndBase()
+	if !ok {
+		return
+	}
+	if r.handled[num] == sha {
+		return
+	}
+
+	files, ok := obj.ListFiles()
+	if !ok {
+		return
+	}
+	requested, ok := obj.ListReviewRequests()
+	if !ok {
+		return
+	}
+	reviews, ok := obj.ListReviews()
+	if !ok {
+		return
+	}
+
+	// Anyone already asked or who has already reviewed covers the files
+	// they own.
+	covered := sets.NewString()
+	for _, user := range requested {
+		if user != nil && user.Login != nil {
+			covered.Insert(*user.Login)
+		}
+	}
+	for _, review := range reviews {
+		if review.User != nil && review.User.Login != nil {
+			covered.Insert(*review.User.Login)
+		}
+	}
+
+	paths := []string{}
+	for _, file := range files {
+		if file != nil && file.Filename != nil {
+			paths = append(paths, *file.Filename)
+		}
+	}
+	sort.Strings(paths)
+
+	author := *obj.Issue.User.Login
+	chosen := []string{}
+	for _, path := range paths {
+		owners := r.fileOwners(path, author)
+		if owners.Len() == 0 || owners.HasAny(covered.List()...) {
+			continue
+		}
+		if len(chosen) >= r.MaxReviewers {
+			glog.V(4).Infof("%d: already requested %d reviewers, not requesting one for %s", num, len(chosen), path)
+			break
+		}
+		reviewer := r.pick(owners.List())
+		chosen = append(chosen, reviewer)
+		covered.Insert(reviewer)
+	}
+
+	if len(chosen) > 0 {
+		if !obj.RequestReviews(chosen) {
+			return
+		}
+		for _, reviewer := range chosen {
+			r.load[reviewer]++
+		}
+	}
+	r.handled[num] = sha
+}
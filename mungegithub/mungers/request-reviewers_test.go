@@ -0,0 +1,196 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/google/go-github/github"
+)
+
+// fakeOwners maps directories to the reviewers in their OWNERS file. A path
+// is owned by the OWNERS file in its closest directory which has one.
+type fakeOwners map[string][]string
+
+func (f fakeOwners) LeafReviewers(path string) sets.String {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if owners, ok := f[dir]; ok {
+			return sets.NewString(owners...)
+		}
+		if dir == "." || dir == "/" {
+			return sets.NewString()
+		}
+	}
+}
+
+func changedFiles(names ...string) []*github.CommitFile {
+	files := []*github.CommitFile{}
+	for _, name := range names {
+		files = append(files, &github.CommitFile{Filename: stringPtr(name)})
+	}
+	return files
+}
+
+func TestRequestReviewers(t *testing.T) {
+	owners := fakeOwners{
+		".":     {"root1", "root2"},
+		"pkg":   {"pkg1", "pkg2"},
+		"pkg/a": {"a1"},
+		"docs":  {"author", "docs1"},
+	}
+
+	tests := []struct {
+		name      string
+		files     []*github.CommitFile
+		requested []string
+		reviewed  []string
+		max       int
+		load      map[string]int
+		expected  []string
+	}{
+		{
+			name:     "one reviewer per OWNERS file",
+			files:    changedFiles("pkg/b.go", "pkg/a/a.go", "README.md"),
+			max:      5,
+			expected: []string{"root1", "a1", "pkg1"},
+		},
+		{
+			name:     "files owned by the same people need one reviewer",
+			files:    changedFiles("pkg/b.go", "pkg/c.go"),
+			max:      5,
+			expected: []string{"pkg1"},
+		},
+		{
+			name:     "least loaded owner is chosen",
+			files:    changedFiles("pkg/b.go"),
+			max:      5,
+			load:     map[string]int{"pkg1": 3, "pkg2": 1},
+			expected: []string{"pkg2"},
+		},
+		{
+			name:     "author is never requested",
+			files:    changedFiles("docs/index.md"),
+			max:      5,
+			load:     map[string]int{"docs1": 10},
+			expected: []string{"docs1"},
+		},
+		{
+			name:      "files with a pending review request are skipped",
+			files:     changedFiles("pkg/b.go", "pkg/a/a.go"),
+			requested: []string{"pkg2"},
+			max:       5,
+			expected:  []string{"a1"},
+		},
+		{
+			name:     "files already reviewed are skipped",
+			files:    changedFiles("pkg/a/a.go"),
+			reviewed: []string{"a1"},
+			max:      5,
+		},
+		{
+			name:     "capped at max reviewers",
+			files:    changedFiles("pkg/b.go", "pkg/a/a.go", "README.md"),
+			max:      2,
+			expected: []string{"root1", "a1"},
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue("author", 1, nil, true)
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), nil, nil, nil, nil, test.files)
+
+		requested := []*github.User{}
+		for _, login := range test.requested {
+			requested = append(requested, &github.User{Login: stringPtr(login)})
+		}
+		reviews := []*github_util.PullRequestReview{}
+		for _, login := range test.reviewed {
+			reviews = append(reviews, &github_util.PullRequestReview{User: &github.User{Login: stringPtr(login)}, State: stringPtr("COMMENTED")})
+		}
+		var got []string
+		mux.HandleFunc("/repos/o/r/pulls/1/requested_reviewers", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				body := struct {
+					Reviewers []string `json:"reviewers"`
+				}{}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("%s: unexpected error: %v", test.name, err)
+				}
+				got = body.Reviewers
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprint(w, "{}")
+				return
+			}
+			data, _ := json.Marshal(requested)
+			w.Write(data)
+		})
+		mux.HandleFunc("/repos/o/r/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+			data, _ := json.Marshal(reviews)
+			w.Write(data)
+		})
+
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		obj := github_util.TestObject(config, issue, ValidPR(), nil, nil)
+
+		r := &ReviewRequester{Strategy: leastLoadedStrategy, MaxReviewers: test.max, owners: owners}
+		if err := r.Initialize(config, nil); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		for user, load := range test.load {
+			r.load[user] = load
+		}
+		r.Munge(obj)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected reviews from %v but requested %v", test.name, test.expected, got)
+		}
+
+		// Nothing new is requested until the PR changes
+		got = nil
+		r.Munge(obj)
+		if got != nil {
+			t.Errorf("%s: requested %v again for the same commit", test.name, got)
+		}
+		server.Close()
+	}
+}
+
+func TestRequestReviewersBalancesLoad(t *testing.T) {
+	r := &ReviewRequester{Strategy: leastLoadedStrategy, MaxReviewers: 1}
+	if err := r.Initialize(nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	counts := map[string]int{}
+	for i := 0; i < 9; i++ {
+		reviewer := r.pick([]string{"a", "b", "c"})
+		r.load[reviewer]++
+		counts[reviewer]++
+	}
+	if !reflect.DeepEqual(counts, map[string]int{"a": 3, "b": 3, "c": 3}) {
+		t.Errorf("expected reviews to be spread evenly but got %v", counts)
+	}
+}
@@ -33,6 +33,9 @@ import (
 
 const (
 	labelSizePrefix = "size/"
+
+	splitCommentPrefix = "This PR is too big to review easily."
+	splitCommentFmt    = "@%s " + splitCommentPrefix + " It changes %d lines in %d files, please consider splitting it into smaller PRs."
 )
 
 var (
@@ -42,8 +45,12 @@ var (
 // SizeMunger will update a label on a PR based on how many lines are changed.
 // It will exclude certain files in it's calculations based on the config
 // file provided in --generated-files-config
+// If the PR changes more than MaxLines lines or MaxFiles files the author is
+// asked to split it.
 type SizeMunger struct {
 	GeneratedFilesFile string
+	MaxLines           int
+	MaxFiles           int
 	genFilePaths       sets.String
 	genFilePrefixes    sets.String
 	genFileNames       sets.String
@@ -75,6 +82,8 @@ func (SizeMunger) EachLoop() error { return nil }
 // AddFlags will add any request flags to the cobra `cmd`
 func (s *SizeMunger) AddFlags(cmd *cobra.Command, config *github.Config) {
 	cmd.Flags().StringVar(&s.GeneratedFilesFile, "generated-files-config", "", "file in the repo containing the generated file rules")
+	cmd.Flags().IntVar(&s.MaxLines, "size-split-lines", 0, "Ask the author to split PRs which change more than this many lines, not counting generated files. 0 disables.")
+	cmd.Flags().IntVar(&s.MaxFiles, "size-split-files", 0, "Ask the author to split PRs which change more than this many files, not counting generated files. 0 disables.")
 }
 
 // getGeneratedFiles returns a list of all automatically generated files in the repo. These include
@@ -160,23 +169,16 @@ func (s *SizeMunger) getGeneratedFiles(obj *github.MungeObject) {
 	return
 }
 
-// Munge is the workhorse the will actually make updates to the PR
-func (s *SizeMunger) Munge(obj *github.MungeObject) {
-	if !obj.IsPR() {
-		return
-	}
-
-	issue := obj.Issue
-
+// countChanges returns the lines added and deleted and the number of files
+// changed by the PR, leaving out generated files.
+func (s *SizeMunger) countChanges(obj *github.MungeObject) (adds, dels, changed int, ok bool) {
 	s.getGeneratedFiles(obj)
 
 	files, ok := obj.ListFiles()
 	if !ok {
-		return
+		return 0, 0, 0, false
 	}
 
-	adds := 0
-	dels := 0
 	for _, f := range files {
 		skip := false
 		for p := range s.genPathPrefixes {
@@ -204,6 +206,27 @@ func (s *SizeMunger) Munge(obj *github.MungeObject) {
 		if f.Deletions != nil {
 			dels += *f.Deletions
 		}
+		changed++
+	}
+	return adds, dels, changed, true
+}
+
+// tooBig returns true if the author should be asked to split the PR.
+func (s *SizeMunger) tooBig(lines, files int) bool {
+	return (s.MaxLines > 0 && lines > s.MaxLines) || (s.MaxFiles > 0 && files > s.MaxFiles)
+}
+
+// Munge is the workhorse the will actually make updates to the PR
+func (s *SizeMunger) Munge(obj *github.MungeObject) {
+	if !obj.IsPR() {
+		return
+	}
+
+	issue := obj.Issue
+
+	adds, dels, changed, ok := s.countChanges(obj)
+	if !ok {
+		return
 	}
 
 	newSize := calculateSize(adds, dels)
@@ -224,6 +247,25 @@ func (s *SizeMunger) Munge(obj *github.MungeObject) {
 		body := fmt.Sprintf("Labelling this PR as %s", newLabel)
 		obj.WriteComment(body)
 	}
+
+	if s.tooBig(adds+dels, changed) {
+		s.askToSplit(obj, adds+dels, changed)
+	}
+}
+
+// askToSplit comments asking the author to split the PR, unless we already
+// have.
+func (s *SizeMunger) askToSplit(obj *github.MungeObject, lines, files int) {
+	comments, ok := obj.ListComments()
+	if !ok {
+		return
+	}
+	for _, comment := range comments {
+		if mergeBotComment(comment) && comment.Body != nil && strings.Contains(*comment.Body, splitCommentPrefix) {
+			return
+		}
+	}
+	obj.WriteComment(fmt.Sprintf(splitCommentFmt, *obj.Issue.User.Login, lines, files))
 }
 
 func hasPrefix(filename string, filePrefixes sets.String) bool {
@@ -271,6 +313,12 @@ func (s *SizeMunger) isStaleComment(obj *github.MungeObject, comment *githubapi.
 		return false
 	}
 	stale := sizeRE.MatchString(*comment.Body)
+	if !stale && strings.Contains(*comment.Body, splitCommentPrefix) {
+		// Stale once the PR has been split up
+		if adds, dels, changed, ok := s.countChanges(obj); ok {
+			stale = !s.tooBig(adds+dels, changed)
+		}
+	}
 	if stale {
 		glog.V(6).Infof("Found stale SizeMunger comment")
 	}
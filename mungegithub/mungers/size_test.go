@@ -0,0 +1,191 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestCalculateSize(t *testing.T) {
+	tests := []struct {
+		adds     int
+		dels     int
+		expected string
+	}{
+		{0, 0, sizeXS},
+		{9, 0, sizeXS},
+		{5, 5, sizeS},
+		{29, 0, sizeS},
+		{0, 30, sizeM},
+		{99, 0, sizeM},
+		{100, 0, sizeL},
+		{250, 249, sizeL},
+		{500, 0, sizeXL},
+		{999, 0, sizeXL},
+		{1000, 0, sizeXXL},
+		{5000, 5000, sizeXXL},
+	}
+	for _, test := range tests {
+		if got := calculateSize(test.adds, test.dels); got != test.expected {
+			t.Errorf("%d+%d: expected %s but got %s", test.adds, test.dels, test.expected, got)
+		}
+	}
+}
+
+func sizedFiles(lines ...int) []*github.CommitFile {
+	files := []*github.CommitFile{}
+	for i, n := range lines {
+		files = append(files, &github.CommitFile{
+			Filename:  stringPtr(strings.Repeat("f", i+1) + ".go"),
+			Additions: intPtr(n),
+			Deletions: intPtr(0),
+		})
+	}
+	return files
+}
+
+func TestSizeMunge(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    []string
+		files     []*github.CommitFile
+		comments  []*github.IssueComment
+		maxLines  int
+		maxFiles  int
+		expected  string
+		removed   []string
+		askSplit  bool
+		noComment bool
+	}{
+		{
+			name:     "labels a new PR",
+			files:    sizedFiles(5, 4),
+			expected: labelSizePrefix + sizeXS,
+		},
+		{
+			name:     "replaces the old label when the PR grows",
+			labels:   []string{labelSizePrefix + sizeXS},
+			files:    sizedFiles(5, 5),
+			expected: labelSizePrefix + sizeS,
+			removed:  []string{labelSizePrefix + sizeXS},
+		},
+		{
+			name:      "keeps the label if the size is the same",
+			labels:    []string{labelSizePrefix + sizeM},
+			files:     sizedFiles(99),
+			expected:  labelSizePrefix + sizeM,
+			noComment: true,
+		},
+		{
+			name:     "at the line limit",
+			files:    sizedFiles(100),
+			maxLines: 100,
+			expected: labelSizePrefix + sizeL,
+		},
+		{
+			name:     "over the line limit",
+			files:    sizedFiles(101),
+			maxLines: 100,
+			expected: labelSizePrefix + sizeL,
+			askSplit: true,
+		},
+		{
+			name:     "over the file limit",
+			files:    sizedFiles(1, 1, 1),
+			maxFiles: 2,
+			expected: labelSizePrefix + sizeXS,
+			askSplit: true,
+		},
+		{
+			name:   "already asked to split",
+			labels: []string{labelSizePrefix + sizeL},
+			files:  sizedFiles(101),
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "@"+someUserName+" "+splitCommentPrefix, botName, 10),
+			},
+			maxLines:  100,
+			expected:  labelSizePrefix + sizeL,
+			noComment: true,
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(someUserName, 1, test.labels, true)
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), nil, nil, nil, nil, test.files)
+		removed := []string{}
+		mux.HandleFunc("/repos/o/r/issues/1/labels/", func(w http.ResponseWriter, r *http.Request) {
+			removed = append(removed, strings.TrimPrefix(r.URL.Path, "/repos/o/r/issues/1/labels/"))
+			w.WriteHeader(http.StatusOK)
+		})
+		mux.HandleFunc("/repos/o/r/issues/1/labels", func(w http.ResponseWriter, r *http.Request) {
+			data, _ := json.Marshal([]github.Label{{}})
+			w.Write(data)
+		})
+		comments := []string{}
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				c := new(github.IssueComment)
+				json.NewDecoder(r.Body).Decode(c)
+				comments = append(comments, *c.Body)
+				data, _ := json.Marshal(c)
+				w.Write(data)
+				return
+			}
+			data, _ := json.Marshal(test.comments)
+			w.Write(data)
+		})
+
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		obj, err := config.GetObject(1)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		s := SizeMunger{MaxLines: test.maxLines, MaxFiles: test.maxFiles}
+		s.Munge(obj)
+
+		if !obj.HasLabel(test.expected) {
+			t.Errorf("%s: expected label %s but got %v", test.name, test.expected, obj.Issue.Labels)
+		}
+		if len(removed) != len(test.removed) || (len(removed) > 0 && removed[0] != test.removed[0]) {
+			t.Errorf("%s: expected %v to be removed but removed %v", test.name, test.removed, removed)
+		}
+		asked := false
+		for _, body := range comments {
+			if strings.Contains(body, splitCommentPrefix) {
+				asked = true
+			}
+		}
+		if asked != test.askSplit {
+			t.Errorf("%s: expected asked to split=%v but comments were %q", test.name, test.askSplit, comments)
+		}
+		if test.noComment && len(comments) != 0 {
+			t.Errorf("%s: unexpected comments %q", test.name, comments)
+		}
+		server.Close()
+	}
+}
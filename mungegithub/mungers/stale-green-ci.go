@@ -101,7 +101,7 @@ func (s *StaleGreenCI) Munge(obj *github.MungeObject) {
 			return
 		}
 		if time.Since(*statusTime) > staleGreenCIHours*time.Hour {
-			obj.WriteComment(greenMsgBody)
+			obj.WriteRepeatableComment(greenMsgBody)
 			ok := obj.WaitForPending(requiredContexts)
 			if !ok {
 				glog.Errorf("Failed waiting for PR to start testing")
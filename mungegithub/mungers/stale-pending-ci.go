@@ -104,7 +104,7 @@ func (s *StalePendingCI) Munge(obj *github.MungeObject) {
 			return
 		}
 		if time.Since(*statusTime) > stalePendingCIHours*time.Hour {
-			obj.WriteComment(pendingMsgBody)
+			obj.WriteRepeatableComment(pendingMsgBody)
 			return
 		}
 	}
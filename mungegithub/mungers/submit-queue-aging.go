@@ -0,0 +1,56 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// effectivePriority is obj's priority, raised by one tier for every
+// PriorityAgingInterval it has spent on the queue, but never above P0. PRs
+// which skip the retest already go first and don't age. Revert PRs go before
+// even P0.
+// sq.Lock() must be held.
+func (sq *SubmitQueue) effectivePriority(obj *github.MungeObject) int {
+	if sq.isRevert(obj) {
+		return revertMergePriority
+	}
+	prio := priority(obj)
+	if sq.PriorityAgingInterval <= 0 || prio <= 0 {
+		return prio
+	}
+	since, ok := sq.queuedSince[sq.prKey(obj)]
+	if !ok {
+		return prio
+	}
+	tiers := int(sq.clock.Now().Sub(since) / sq.PriorityAgingInterval)
+	if tiers >= prio {
+		return 0
+	}
+	return prio - tiers
+}
+
+// recordQueued remembers when obj joined the queue, for effectivePriority.
+// sq.Lock() must be held.
+func (sq *SubmitQueue) recordQueued(obj *github.MungeObject) {
+	if sq.queuedSince == nil {
+		sq.queuedSince = map[string]time.Time{}
+	}
+	sq.queuedSince[sq.prKey(obj)] = sq.clock.Now()
+}
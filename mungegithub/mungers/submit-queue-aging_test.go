@@ -0,0 +1,106 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+
+	"github.com/google/go-github/github"
+)
+
+func TestPriorityAging(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		expected []string
+	}{
+		{
+			name:     "aging disabled",
+			expected: []string{"2", "3"},
+		},
+		{
+			name:     "aged P3 outranks a fresh P2",
+			interval: 2 * time.Hour,
+			expected: []string{"3", "2"},
+		},
+		{
+			name:     "not aged enough",
+			interval: 4 * time.Hour,
+			expected: []string{"2", "3"},
+		},
+	}
+	for _, test := range tests {
+		issueToEvents := map[int][]github_test.LabelTime{
+			2: {{User: "me", Label: lgtmLabel, Time: 1}},
+			3: {{User: "me", Label: lgtmLabel, Time: 2}},
+		}
+		client, server, mux := github_test.InitServer(t, nil, nil, github_test.MultiIssueEvents(issueToEvents, "labeled"), nil, nil, nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		clock := sq.clock.(*utilclock.FakeClock)
+		sq.PriorityAgingInterval = test.interval
+
+		queue := func(issue *github.Issue) {
+			github_test.ServeIssue(t, mux, issue)
+			obj, err := config.GetObject(*issue.Number)
+			if err != nil {
+				t.Fatalf("%s: unable to get issue: %v", test.name, err)
+			}
+			sq.githubE2EQueue[strconv.Itoa(*issue.Number)] = obj
+			sq.recordQueued(obj)
+		}
+		// The P3 has been waiting for 5 hours, the P2 just arrived
+		queue(github_test.Issue(someUserName, 3, []string{"priority/P3"}, true))
+		clock.Step(5 * time.Hour)
+		queue(github_test.Issue(someUserName, 2, []string{"priority/P2"}, true))
+
+		if order := sq.orderedE2EQueue(); !reflect.DeepEqual(order, test.expected) {
+			t.Errorf("%s: expected the queue %v but got %v", test.name, test.expected, order)
+		}
+		server.Close()
+	}
+}
+
+func TestEffectivePriorityCap(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	clock := sq.clock.(*utilclock.FakeClock)
+	sq.PriorityAgingInterval = time.Hour
+
+	obj := github_util.TestObject(nil, github_test.Issue(someUserName, 1, []string{"priority/P2"}, true), nil, nil, nil)
+	if prio := sq.effectivePriority(obj); prio != 2 {
+		t.Errorf("expected an unqueued PR to keep priority 2, got %d", prio)
+	}
+	sq.recordQueued(obj)
+	clock.Step(time.Hour)
+	if prio := sq.effectivePriority(obj); prio != 1 {
+		t.Errorf("expected priority 1 after an hour, got %d", prio)
+	}
+	clock.Step(10 * time.Hour)
+	if prio := sq.effectivePriority(obj); prio != 0 {
+		t.Errorf("expected aging to stop at P0, got %d", prio)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"sort"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+
+	githubapi "github.com/google/go-github/github"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// byCreation sorts comments oldest first.
+type byCreation []*githubapi.IssueComment
+
+func (b byCreation) Len() int           { return len(b) }
+func (b byCreation) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byCreation) Less(i, j int) bool { return b[i].CreatedAt.Before(*b[j].CreatedAt) }
+
+// commentApprovers returns the people whose latest "/lgtm" or
+// "/lgtm cancel" comment since the last commit was an lgtm. Only those who
+// could lgtm the PR count: the author can't, and anyone else must be
+// assigned to it or be authorized to give the bot commands.
+func (sq *SubmitQueue) commentApprovers(obj *github.MungeObject) (sets.String, bool) {
+	lastModified, ok := obj.LastModifiedTime()
+	if !ok || lastModified == nil {
+		return nil, false
+	}
+	comments, ok := obj.ListComments()
+	if !ok {
+		return nil, false
+	}
+	recent := c.FilterComments(comments, c.And{c.HumanActor(), c.CreatedAfter(*lastModified), c.CommandName("lgtm")})
+	sort.Stable(byCreation(recent))
+
+	approvers := sets.NewString()
+	for _, comment := range recent {
+		login := strings.ToLower(*comment.User.Login)
+		if strings.EqualFold(c.ParseCommand(comment).Arguments, "cancel") {
+			approvers.Delete(login)
+		} else {
+			approvers.Insert(login)
+		}
+	}
+	for _, login := range approvers.List() {
+		if !sq.mayApprove(obj, login) {
+			approvers.Delete(login)
+		}
+	}
+	return approvers, true
+}
+
+// mayApprove returns true if login, who isn't obj's author, is assigned to
+// obj or is authorized to give the bot commands.
+func (sq *SubmitQueue) mayApprove(obj *github.MungeObject, login string) bool {
+	if obj.Issue.User != nil && obj.Issue.User.Login != nil && strings.EqualFold(*obj.Issue.User.Login, login) {
+		return false
+	}
+	for _, assignee := range obj.Issue.Assignees {
+		if assignee.Login != nil && strings.EqualFold(*assignee.Login, login) {
+			return true
+		}
+	}
+	return sq.isAuthorized(login)
+}
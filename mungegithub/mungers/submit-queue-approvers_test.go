@@ -0,0 +1,154 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestApprovingReviewsRequired(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	// The last commit is at 9
+	tests := []struct {
+		name       string
+		reviewMode bool
+		reviews    []*github_util.PullRequestReview
+		comments   []*github.IssueComment
+		assignees  []string
+		approvers  int
+	}{
+		{
+			name:       "reviews one short",
+			reviewMode: true,
+			reviews:    []*github_util.PullRequestReview{review("bob", "APPROVED", 10), review("alice", "APPROVED", 8)},
+			approvers:  1,
+		},
+		{
+			name:       "exactly enough reviews",
+			reviewMode: true,
+			reviews:    []*github_util.PullRequestReview{review("bob", "APPROVED", 10), review("alice", "APPROVED", 11)},
+			approvers:  2,
+		},
+		{
+			name: "comments one short",
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "/lgtm", "bob", 10),
+				github_test.IssueComment(2, "/lgtm", "Bob", 11),
+				github_test.IssueComment(3, "/lgtm", "alice", 8),
+			},
+			approvers: 1,
+		},
+		{
+			name: "exactly enough comments",
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "/lgtm", "bob", 10),
+				github_test.IssueComment(2, "looks good", "carol", 11),
+				github_test.IssueComment(3, "/lgtm", "alice", 12),
+			},
+			approvers: 2,
+		},
+		{
+			name: "cancelled",
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "/lgtm", "bob", 10),
+				github_test.IssueComment(2, "/lgtm", "alice", 11),
+				github_test.IssueComment(3, "/lgtm cancel", "alice", 12),
+			},
+			approvers: 1,
+		},
+		{
+			name: "bots don't count",
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "/lgtm", "bob", 10),
+				github_test.IssueComment(2, "/lgtm", botName, 11),
+			},
+			approvers: 1,
+		},
+		{
+			name: "the author doesn't count",
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "/lgtm", "bob", 10),
+				github_test.IssueComment(2, "/lgtm", someUserName, 11),
+			},
+			approvers: 1,
+		},
+		{
+			name: "people who can't lgtm don't count",
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "/lgtm", "bob", 10),
+				github_test.IssueComment(2, "/lgtm", "mallory", 11),
+			},
+			approvers: 1,
+		},
+		{
+			name: "assignees count",
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "/lgtm", "bob", 10),
+				github_test.IssueComment(2, "/lgtm", "carol", 11),
+			},
+			assignees: []string{"carol"},
+			approvers: 2,
+		},
+	}
+	for _, test := range tests {
+		issue := LGTMApprovedIssue()
+		for _, assignee := range test.assignees {
+			login := assignee
+			issue.Assignees = append(issue.Assignees, &github.User{Login: &login})
+		}
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		reviews, comments := test.reviews, test.comments
+		mux.HandleFunc("/repos/o/r/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+			data, _ := json.Marshal(reviews)
+			w.Write(data)
+		})
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			data, _ := json.Marshal(comments)
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.githubConfig = config
+		sq.CommandWhitelist = []string{"alice", "bob", someUserName}
+		sq.ReviewMode = test.reviewMode
+		sq.ApprovingReviewsRequired = 2
+
+		obj := github_util.TestObject(config, issue, ValidPR(), Commits(), NewLGTMEvents())
+		shouldPass := test.approvers >= 2
+		if valid := sq.validForMerge(obj); valid != shouldPass {
+			t.Errorf("%s: expected valid=%v but got %v (%q)", test.name, shouldPass, valid, sq.prStatus["1"].Reason)
+		}
+		expected := fmt.Sprintf(needsMoreApprovalsFmt, test.approvers, 2)
+		if !shouldPass && sq.prStatus["1"].Reason != expected {
+			t.Errorf("%s: expected reason %q but got %q", test.name, expected, sq.prStatus["1"].Reason)
+		}
+		server.Close()
+	}
+}
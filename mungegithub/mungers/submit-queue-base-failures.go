@@ -0,0 +1,90 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// baseBranchOverrider is who the overrides recorded for pre-existing
+// failures are credited to.
+const baseBranchOverrider = "the base branch"
+
+// parseBaseBranchJobs turns "pr-job=base-job" entries into a map from each
+// github e2e job to the job which runs its tests against the base branch.
+func parseBaseBranchJobs(specs []string) (map[string]string, error) {
+	jobs := map[string]string{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid base branch job %q, expected something like pull-kubernetes-e2e-gce=ci-kubernetes-e2e-gce", spec)
+		}
+		jobs[parts[0]] = parts[1]
+	}
+	return jobs, nil
+}
+
+// onlyBaseBranchFailures returns true if every failed context's build,
+// found from its target URL as for infraFailure, only failed tests which
+// also failed in the latest build of its job's base branch job. The
+// contexts are then overridden for the PR's head commit, so they count as
+// passing from here on.
+func (sq *SubmitQueue) onlyBaseBranchFailures(obj *github.MungeObject, contexts []string) bool {
+	sha, _, ok := obj.GetHeadAndBase()
+	if !ok {
+		return false
+	}
+	failed := []string{}
+	for _, context := range contexts {
+		if success, ok := sq.statusBackend().IsSuccess(obj, []string{context}); ok && success {
+			continue
+		}
+		url, ok := sq.statusBackend().TargetURL(obj, context)
+		if !ok || url == "" {
+			return false
+		}
+		job, number, ok := jobAndBuild(url)
+		if !ok {
+			return false
+		}
+		baseJob, ok := sq.baseBranchJobs[job]
+		if !ok {
+			obj.Log().V(4).Infof("%s has no base branch job to compare against", job)
+			return false
+		}
+		newFailures, err := sq.e2e.NewFailures(job, number, baseJob)
+		if err != nil {
+			obj.Log().With("job", job).With("build", number).Errorf("unable to compare with %s: %v", baseJob, err)
+			return false
+		}
+		if len(newFailures) > 0 {
+			obj.Log().Infof("%s/%d has failures which %s doesn't: %v", job, number, baseJob, newFailures)
+			return false
+		}
+		failed = append(failed, context)
+	}
+	if len(failed) == 0 {
+		return false
+	}
+	for _, context := range failed {
+		sq.overrideContext(obj, sha, context, baseBranchOverrider)
+	}
+	return true
+}
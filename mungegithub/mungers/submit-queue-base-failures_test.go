@@ -0,0 +1,99 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	fake_e2e "k8s.io/contrib/mungegithub/mungers/e2e/fake"
+
+	"github.com/google/go-github/github"
+)
+
+func TestIgnoreBaseBranchFailures(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name        string
+		ignore      bool
+		newFailures map[string][]string
+		failed      bool
+	}{
+		{
+			name:        "only failing on the base branch",
+			ignore:      true,
+			newFailures: map[string][]string{requiredReTestContext2: {}},
+		},
+		{
+			name:        "a new failure",
+			ignore:      true,
+			newFailures: map[string][]string{requiredReTestContext2: {"TestNew {e2e}"}},
+			failed:      true,
+		},
+		{
+			name:   "can't compare",
+			ignore: true,
+			failed: true,
+		},
+		{
+			name:        "not ignoring",
+			newFailures: map[string][]string{requiredReTestContext2: {}},
+			failed:      true,
+		},
+	}
+	for _, test := range tests {
+		ciStatus := SuccessStatus()
+		for i := range ciStatus.Statuses {
+			ciStatus.Statuses[i].TargetURL = stringPtr(fmt.Sprintf("https://gubernator/build/bucket/pr-logs/pull/1/%s/5/", *ciStatus.Statuses[i].Context))
+		}
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), ciStatus, nil, nil)
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			c := new(github.IssueComment)
+			json.NewDecoder(r.Body).Decode(c)
+			go fakeRunGithubE2ESuccess(ciStatus, true, false)
+			w.Write([]byte("{}"))
+		})
+		mux.HandleFunc("/repos/o/r/statuses/mysha", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.BaseWaitTime = time.Millisecond
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		sq.IgnoreBaseBranchFailures = test.ignore
+		sq.baseBranchJobs = map[string]string{requiredReTestContext2: "ci-" + requiredReTestContext2}
+		sq.e2e.(*fake_e2e.FakeE2ETester).NewFailureResults = test.newFailures
+
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		sq.githubE2EQueue["1"] = obj
+		if failed := sq.retestPR(obj); failed != test.failed {
+			t.Errorf("%s: expected failed=%v but got %v (%q)", test.name, test.failed, failed, sq.prStatus["1"].Reason)
+		}
+		if valid := sq.validForMerge(obj); valid == test.failed {
+			t.Errorf("%s: expected valid=%v after the run but got %v (%q)", test.name, !test.failed, valid, sq.prStatus["1"].Reason)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"k8s.io/contrib/mungegithub/github"
+
+	"github.com/golang/glog"
+)
+
+// repoBranch is a branch of one of the repos the queue handles.
+type repoBranch struct {
+	repo   string
+	branch string
+}
+
+// baseBranchIsRed returns true if any of the BaseBranchContexts is failing
+// on the head of the PR's base branch. The state of each branch is only
+// fetched once per munge loop. Pending or unreported contexts don't hold
+// merges, since the base branch is usually being retested after the last
+// merge.
+func (sq *SubmitQueue) baseBranchIsRed(obj *github.MungeObject) (bool, bool) {
+	if len(sq.BaseBranchContexts) == 0 {
+		return false, true
+	}
+	branch, ok := obj.Branch()
+	if !ok || branch == "" {
+		return false, false
+	}
+
+	key := repoBranch{repo: obj.Repo(), branch: branch}
+	sq.Lock()
+	state, cached := sq.baseBranchStates[key]
+	sq.Unlock()
+	if !cached {
+		var err error
+		// Github resolves the branch name to the commit at its head.
+		state, err = sq.repoConfig(obj).GetRefStatusState(branch, sq.BaseBranchContexts)
+		if err != nil {
+			return false, false
+		}
+		glog.V(2).Infof("%v on the head of %s in %s: %s", sq.BaseBranchContexts, branch, key.repo, state)
+		sq.Lock()
+		if sq.baseBranchStates == nil {
+			sq.baseBranchStates = map[repoBranch]string{}
+		}
+		sq.baseBranchStates[key] = state
+		sq.Unlock()
+	}
+	return state == "failure" || state == "error", true
+}
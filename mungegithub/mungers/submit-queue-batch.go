@@ -177,7 +177,8 @@ func (sq *SubmitQueue) batchIntersectsQueue(batch Batch) bool {
 	sq.Lock()
 	defer sq.Unlock()
 	for _, pull := range batch.Pulls {
-		if _, ok := sq.githubE2EQueue[pull.Number]; ok {
+		// Batches are only run for the main repo, whose PRs are keyed by number
+		if _, ok := sq.githubE2EQueue[strconv.Itoa(pull.Number)]; ok {
 			return true
 		}
 	}
@@ -313,15 +314,14 @@ func (sq *SubmitQueue) doBatchMerge(batch Batch) {
 	for _, pull := range batch.Pulls[match:] {
 		obj, err := sq.githubConfig.GetObject(pull.Number)
 		if err != nil {
-			glog.Errorf("error getting object for pr #%d: %v", pull.Number, err)
+			github.Logger{}.With("issue", pull.Number).Errorf("error getting object: %v", err)
 			return
 		}
 		if sha, _, ok := obj.GetHeadAndBase(); !ok {
-			glog.Errorf("error getting pr #%d sha", pull.Number, err)
+			obj.Log().Errorf("error getting pr sha")
 			return
 		} else if sha != pull.Sha {
-			glog.Errorf("error: batch PR #%d HEAD changed: %s instead of %s",
-				sha, pull.Sha)
+			obj.Log().Errorf("error: batch PR HEAD changed: %s instead of %s", sha, pull.Sha)
 			return
 		}
 		if !sq.validForMergeExt(obj, false) {
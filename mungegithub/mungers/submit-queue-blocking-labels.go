@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// parseBlockingLabels turns "label=reason" entries into a map from each
+// label to the reason reported while a PR has it.
+func parseBlockingLabels(specs []string) (map[string]string, error) {
+	reasons := map[string]string{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid blocking label %q, expected something like needs-rebase=PR needs a rebase", spec)
+		}
+		reasons[parts[0]] = strings.TrimSpace(parts[1])
+	}
+	return reasons, nil
+}
+
+// blockingLabelReason returns the reason for the first, by name, of the
+// --blocking-labels which obj has, or "" if it has none of them.
+func (sq *SubmitQueue) blockingLabelReason(obj *github.MungeObject) string {
+	labels := make([]string, 0, len(sq.blockingLabels))
+	for label := range sq.blockingLabels {
+		if obj.HasLabel(label) {
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	sort.Strings(labels)
+	return sq.blockingLabels[labels[0]]
+}
+
+// missingRequiredLabels returns the --required-labels which obj doesn't
+// have, in the order they were given.
+func (sq *SubmitQueue) missingRequiredLabels(obj *github.MungeObject) []string {
+	missing := []string{}
+	for _, label := range sq.RequiredLabels {
+		if !obj.HasLabel(label) {
+			missing = append(missing, label)
+		}
+	}
+	return missing
+}
+
+// isBlockedAuthor returns true if obj was written by one of the
+// --blocked-users.
+func (sq *SubmitQueue) isBlockedAuthor(obj *github.MungeObject) bool {
+	if obj.Issue.User == nil || obj.Issue.User.Login == nil {
+		return false
+	}
+	for _, user := range sq.BlockedUsers {
+		if strings.EqualFold(user, *obj.Issue.User.Login) {
+			return true
+		}
+	}
+	return false
+}
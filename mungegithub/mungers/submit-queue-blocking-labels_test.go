@@ -0,0 +1,202 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestParseBlockingLabels(t *testing.T) {
+	reasons, err := parseBlockingLabels([]string{"needs-rebase=PR needs a rebase", "needs-api-review=API changes=need review"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r := reasons["needs-api-review"]; r != "API changes=need review" {
+		t.Errorf("expected the reason to keep everything after the first = but got %q", r)
+	}
+	for _, bad := range []string{"needs-rebase", "=PR needs a rebase", "needs-rebase= "} {
+		if _, err := parseBlockingLabels([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestBlockingLabels(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	const needsRebase = "PR needs a rebase"
+	tests := []struct {
+		name   string
+		labels []string
+		reason string
+	}{
+		{
+			name:   "no blocking label",
+			labels: []string{claYesLabel, lgtmLabel, approvedLabel},
+		},
+		{
+			name:   "custom blocking label",
+			labels: []string{claYesLabel, lgtmLabel, approvedLabel, "needs-rebase"},
+			reason: needsRebase,
+		},
+		{
+			name:   "two blocking labels",
+			labels: []string{claYesLabel, lgtmLabel, approvedLabel, "needs-rebase", "needs-api-review"},
+			reason: "Needs API review",
+		},
+		{
+			name:   "do-not-merge comes first",
+			labels: []string{claYesLabel, lgtmLabel, approvedLabel, "needs-rebase", doNotMergeLabel},
+			reason: fmt.Sprintf(noMergeFmt, doNotMergeLabel),
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(someUserName, 1, test.labels, true)
+		client, server, _ := github_test.InitServer(t, issue, ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		blocking, err := parseBlockingLabels([]string{"needs-rebase=" + needsRebase, "needs-api-review=Needs API review"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		sq.blockingLabels = blocking
+		obj := github_util.TestObject(config, issue, ValidPR(), Commits(), NewLGTMEvents())
+
+		valid := sq.validForMerge(obj)
+		if test.reason == "" {
+			if !valid {
+				t.Errorf("%s: expected PR to be mergeable, got %q", test.name, sq.prStatus["1"].Reason)
+			}
+		} else if r := sq.prStatus["1"].Reason; valid || r != test.reason {
+			t.Errorf("%s: expected reason %q but got %q (valid=%v)", test.name, test.reason, r, valid)
+		}
+		server.Close()
+	}
+}
+
+func TestBlockedUsers(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name    string
+		blocked []string
+		merged  bool
+		reason  string
+	}{
+		{
+			name:   "not blocked",
+			merged: true,
+			reason: emergencyMerged,
+		},
+		{
+			name:    "blocked",
+			blocked: []string{"other", someUserName},
+			reason:  blockedAuthor,
+		},
+	}
+	for _, test := range tests {
+		// Everything which could let the PR skip a check is present
+		labels := []string{claYesLabel, lgtmLabel, approvedLabel, retestNotRequiredLabel, emergencyMergeLabel}
+		issue := github_test.Issue(someUserName, 1, labels, true)
+		events := append(NewLGTMEvents(), github_test.Events([]github_test.LabelTime{{User: "alice", Label: emergencyMergeLabel, Time: 30}})...)
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), events, Commits(), SuccessStatus(), nil, nil)
+		merged := false
+		mux.HandleFunc("/repos/o/r/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+			merged = true
+			data, _ := json.Marshal(github.PullRequestMergeResult{})
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.BlockedUsers = test.blocked
+		sq.CommandWhitelist = []string{someUserName}
+		sq.EmergencyMergeLabel = emergencyMergeLabel
+		sq.EmergencyMergeAdmins = []string{"alice"}
+		sq.Munge(github_util.TestObject(config, issue, ValidPR(), Commits(), events))
+
+		if merged != test.merged {
+			t.Errorf("%s: expected merged=%v but got %v", test.name, test.merged, merged)
+		}
+		if r := sq.prStatus["1"].Reason; r != test.reason {
+			t.Errorf("%s: expected reason %q but got %q", test.name, test.reason, r)
+		}
+		if len(sq.githubE2EQueue) != 0 {
+			t.Errorf("%s: expected nothing to be queued but got %v", test.name, sq.githubE2EQueue)
+		}
+		server.Close()
+	}
+}
+
+func TestRequiredLabels(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	const approvedForMerge = "approved-for-merge"
+	issue := github_test.Issue(someUserName, 1, []string{claYesLabel, lgtmLabel, approvedLabel}, true)
+	client, server, mux := github_test.InitServer(t, issue, ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	mux.HandleFunc("/repos/o/r/issues/1/labels", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := json.Marshal([]github.Label{})
+		w.Write(data)
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	sq.RequiredLabels = []string{approvedForMerge, "cherrypick-approved"}
+	obj := github_util.TestObject(config, issue, ValidPR(), Commits(), NewLGTMEvents())
+
+	sq.Munge(obj)
+	expected := missingRequiredLabel + ": " + approvedForMerge + ", cherrypick-approved"
+	if r := sq.prStatus["1"].Reason; r != expected {
+		t.Errorf("expected reason %q but got %q", expected, r)
+	}
+	if len(sq.githubE2EQueue) != 0 {
+		t.Errorf("expected the PR to be held, but it was queued")
+	}
+
+	// Only the ones still missing are reported
+	obj.AddLabel(approvedForMerge)
+	sq.Munge(obj)
+	expected = missingRequiredLabel + ": cherrypick-approved"
+	if r := sq.prStatus["1"].Reason; r != expected {
+		t.Errorf("expected reason %q but got %q", expected, r)
+	}
+
+	obj.AddLabel("cherrypick-approved")
+	sq.Munge(obj)
+	if _, queued := sq.githubE2EQueue["1"]; !queued {
+		t.Errorf("expected the PR to be queued once it has the required labels, got %q", sq.prStatus["1"].Reason)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// ciFailures is when the queue first saw each of a commit's required
+// contexts not passing.
+type ciFailures struct {
+	sha   string
+	since map[string]time.Time
+}
+
+// ciFailureToReport returns the failing context to report and whether it
+// is still within the CIFailureGrace. A context which has been failing for
+// longer than the grace is reported ahead of those which haven't. failed
+// must not be empty.
+func (sq *SubmitQueue) ciFailureToReport(obj *github.MungeObject, failed []string) (string, bool) {
+	if sq.CIFailureGrace <= 0 {
+		return failed[0], false
+	}
+	sha, _, ok := obj.GetHeadAndBase()
+	if !ok {
+		return failed[0], false
+	}
+
+	key := sq.prKey(obj)
+	now := sq.clock.Now()
+	sq.Lock()
+	defer sq.Unlock()
+	previous := sq.ciFailures[key]
+	current := ciFailures{sha: sha, since: map[string]time.Time{}}
+	for _, context := range failed {
+		since, found := previous.since[context]
+		if !found || previous.sha != sha {
+			since = now
+		}
+		current.since[context] = since
+	}
+	if sq.ciFailures == nil {
+		sq.ciFailures = map[string]ciFailures{}
+	}
+	// Contexts which have recovered are forgotten, so they get the whole
+	// grace again if they fail later.
+	sq.ciFailures[key] = current
+
+	for _, context := range failed {
+		if now.Sub(current.since[context]) >= sq.CIFailureGrace {
+			return context, false
+		}
+	}
+	return failed[0], true
+}
+
+// forgetCIFailures is called once all of obj's required contexts pass.
+func (sq *SubmitQueue) forgetCIFailures(obj *github.MungeObject) {
+	sq.Lock()
+	defer sq.Unlock()
+	delete(sq.ciFailures, sq.prKey(obj))
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+)
+
+func TestCIFailureGrace(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	ciStatus := RetestFailStatus()
+	client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), ciStatus, nil, nil)
+	defer server.Close()
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+	sq := getTestSQ(false, config, server)
+	clock := sq.clock.(*utilclock.FakeClock)
+	sq.CIFailureGrace = 10 * time.Minute
+
+	setContext2 := func(pass bool) {
+		for i := range ciStatus.Statuses {
+			if *ciStatus.Statuses[i].Context == requiredReTestContext2 {
+				setStatus(&ciStatus.Statuses[i], pass)
+			}
+		}
+	}
+	check := func(step string, valid bool, reason string) {
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		if v := sq.validForMerge(obj); v != valid {
+			t.Errorf("%s: expected valid=%v but got %v (%q)", step, valid, v, sq.prStatus["1"].Reason)
+		}
+		if r := sq.prStatus["1"].Reason; !valid && r != reason {
+			t.Errorf("%s: expected reason %q but got %q", step, reason, r)
+		}
+	}
+	pending := fmt.Sprintf(ciPendingFmt, requiredReTestContext2)
+	failure := fmt.Sprintf(ciFailureFmt, requiredReTestContext2)
+
+	check("just failed", false, pending)
+	clock.Step(5 * time.Minute)
+	check("still within the grace", false, pending)
+
+	setContext2(true)
+	clock.Step(time.Minute)
+	check("recovered", true, "")
+
+	// Failing again gets the whole grace again
+	setContext2(false)
+	clock.Step(time.Minute)
+	check("failed again", false, pending)
+	clock.Step(9 * time.Minute)
+	check("failed again, within the grace", false, pending)
+	clock.Step(time.Minute)
+	check("kept failing", false, failure)
+
+	sq.CIFailureGrace = 0
+	check("no grace", false, failure)
+}
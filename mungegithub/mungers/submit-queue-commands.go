@@ -0,0 +1,157 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+
+	"github.com/golang/glog"
+	githubapi "github.com/google/go-github/github"
+)
+
+const (
+	requeueCommand = "REQUEUE"
+)
+
+var (
+	// Matches a line addressed to the merge bot, e.g. "@k8s-merge-robot requeue"
+	sqCommandRegex = regexp.MustCompile(`(?m)^@` + regexp.QuoteMeta(botName) + `\s+([^\s]+) *?([^\n]*)`)
+)
+
+// sqCommand is a command which users can give the submit queue by
+// mentioning the bot in a comment. handler returns the reply to post.
+// Commands which are adminOnly may only be given by the WhitelistAdmins.
+type sqCommand struct {
+	authorized bool
+	adminOnly  bool
+	handler    func(sq *SubmitQueue, obj *github.MungeObject, user string, cmd *c.Command) string
+}
+
+var sqCommands = map[string]sqCommand{
+	requeueCommand:      {authorized: true, handler: (*SubmitQueue).requeueCommand},
+	pauseCommand:        {authorized: true, handler: (*SubmitQueue).pauseCommand},
+	resumeCommand:       {authorized: true, handler: (*SubmitQueue).pauseCommand},
+	dependsCommand:      {handler: (*SubmitQueue).dependsCommand},
+	overrideCommand:     {authorized: true, handler: (*SubmitQueue).overrideCommand},
+	whitelistCommand:    {adminOnly: true, handler: (*SubmitQueue).whitelistCommand},
+	whyCommand:          {handler: (*SubmitQueue).whyCommand},
+	priorityCommand:     {authorized: true, handler: (*SubmitQueue).priorityCommand},
+	skipTestCommand:     {authorized: true, handler: (*SubmitQueue).skipTestCommand},
+	unskipTestCommand:   {authorized: true, handler: (*SubmitQueue).skipTestCommand},
+	allowRetestsCommand: {adminOnly: true, handler: (*SubmitQueue).allowRetestsCommand},
+}
+
+// parseSQCommand returns the command addressed to the merge bot in the
+// comment, or nil if there is none.
+func parseSQCommand(comment *githubapi.IssueComment) *c.Command {
+	if comment == nil || comment.Body == nil {
+		return nil
+	}
+	match := sqCommandRegex.FindStringSubmatch(*comment.Body)
+	if match == nil {
+		return nil
+	}
+	return &c.Command{
+		Name:      strings.ToUpper(match[1]),
+		Arguments: strings.TrimSpace(match[2]),
+	}
+}
+
+// isAuthorized returns true if the user may give the submit queue privileged
+// commands: they must be in CommandWhitelist or have push access to the repo.
+func (sq *SubmitQueue) isAuthorized(user string) bool {
+	for _, allowed := range sq.commandWhitelist() {
+		if strings.EqualFold(allowed, user) {
+			return true
+		}
+	}
+	pushUsers, _, err := sq.githubConfig.UsersWithAccess()
+	if err != nil {
+		glog.Errorf("Unable to determine if %s has push access: %v", user, err)
+		return false
+	}
+	for _, u := range pushUsers {
+		if strings.EqualFold(*u.Login, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleCommands runs any commands addressed to the bot since the bot last
+// commented on the PR. Since every command gets a reply this means each
+// command is only run once.
+func (sq *SubmitQueue) handleCommands(obj *github.MungeObject) {
+	comments, ok := obj.ListComments()
+	if !ok {
+		return
+	}
+	lastBotComment := c.LastComment(comments, c.MungeBotAuthor(), &time.Time{})
+	pending := c.FilterComments(comments, c.And{c.HumanActor(), c.CreatedAfter(*lastBotComment)})
+
+	for _, comment := range pending {
+		cmd := parseSQCommand(comment)
+		if cmd == nil {
+			continue
+		}
+		command, found := sqCommands[cmd.Name]
+		if !found {
+			continue
+		}
+		user := *comment.User.Login
+		var reply string
+		if (command.authorized && !sq.isAuthorized(user)) || (command.adminOnly && !sq.isWhitelistAdmin(user)) {
+			obj.Log().Infof("ignoring %s from unauthorized user %s", cmd.Name, user)
+			reply = fmt.Sprintf("@%s you are not authorized to use `%s`.", user, strings.ToLower(cmd.Name))
+		} else {
+			obj.Log().Infof("%s requested by %s", cmd.Name, user)
+			reply = command.handler(sq, obj, user, cmd)
+		}
+		if reply == "" {
+			continue
+		}
+		if err := obj.WriteRepeatableComment(reply); err != nil {
+			obj.Log().Errorf("unable to reply to %s: %v", cmd.Name, err)
+		}
+	}
+}
+
+// requeueCommand forgets everything the queue knows about the PR so that it
+// is evaluated from scratch by the rest of Munge().
+func (sq *SubmitQueue) requeueCommand(obj *github.MungeObject, user string, cmd *c.Command) string {
+	if !obj.Refresh() {
+		return fmt.Sprintf("@%s unable to refresh this PR, please try again later.", user)
+	}
+
+	key := sq.prKey(obj)
+	sq.Lock()
+	delete(sq.prStatus, key)
+	delete(sq.lastPRStatus, key)
+	delete(sq.e2eTimedOut, key)
+	// Don't pull the rug out from under a running e2e test
+	if !sq.runningLocked(obj) {
+		sq.deleteQueueItem(obj)
+	}
+	sq.Unlock()
+	return fmt.Sprintf("Requeued at the request of @%s.", user)
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+// commandTestSQ returns a submit queue and object for issue 1 whose comments
+// are the given ones. Replies the bot posts are appended to *replies.
+func commandTestSQ(t *testing.T, comments []*github.IssueComment, replies *[]string) (*SubmitQueue, *github_util.MungeObject, func()) {
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			c := new(github.IssueComment)
+			json.NewDecoder(r.Body).Decode(c)
+			*replies = append(*replies, *c.Body)
+			data, _ := json.Marshal(c)
+			w.Write(data)
+			return
+		}
+		data, err := json.Marshal(comments)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		w.Write(data)
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	sq.githubConfig = config
+	sq.CommandWhitelist = []string{"alice"}
+
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	return sq, obj, server.Close
+}
+
+func TestParseSQCommand(t *testing.T) {
+	tests := []struct {
+		body string
+		name string
+		args string
+	}{
+		{body: "@" + botName + " requeue", name: requeueCommand},
+		{body: "some text\n@" + botName + " requeue please  \nmore", name: requeueCommand, args: "please"},
+		{body: "@" + botName + "2 requeue"},
+		{body: "please @" + botName + " requeue"},
+		{body: "/requeue"},
+	}
+	for _, test := range tests {
+		cmd := parseSQCommand(github_test.IssueComment(1, test.body, "alice", 10))
+		if test.name == "" {
+			if cmd != nil {
+				t.Errorf("%q: unexpected command %v", test.body, cmd)
+			}
+			continue
+		}
+		if cmd == nil || cmd.Name != test.name || cmd.Arguments != test.args {
+			t.Errorf("%q: expected %s(%q) but got %v", test.body, test.name, test.args, cmd)
+		}
+	}
+}
+
+func TestRequeueCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		comments  []*github.IssueComment
+		reply     string
+		requeued  bool
+		noReplies bool
+	}{
+		{
+			name: "authorized",
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "@"+botName+" requeue", "alice", 10),
+			},
+			reply:    "Requeued at the request of @alice",
+			requeued: true,
+		},
+		{
+			name: "unauthorized",
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "@"+botName+" requeue", "mallory", 10),
+			},
+			reply: "@mallory you are not authorized",
+		},
+		{
+			name: "already handled",
+			comments: []*github.IssueComment{
+				github_test.IssueComment(1, "@"+botName+" requeue", "alice", 10),
+				github_test.IssueComment(2, "Requeued at the request of @alice.", botName, 11),
+			},
+			noReplies: true,
+		},
+	}
+	for _, test := range tests {
+		replies := []string{}
+		sq, obj, done := commandTestSQ(t, test.comments, &replies)
+		sq.lastPRStatus["1"] = submitStatus{Reason: ciFailure}
+		sq.githubE2EQueue["1"] = obj
+
+		sq.handleCommands(obj)
+
+		if test.noReplies {
+			if len(replies) != 0 {
+				t.Errorf("%s: unexpected replies %q", test.name, replies)
+			}
+		} else if len(replies) != 1 || !strings.HasPrefix(replies[0], test.reply) {
+			t.Errorf("%s: expected reply %q but got %q", test.name, test.reply, replies)
+		}
+		_, cached := sq.lastPRStatus["1"]
+		_, queued := sq.githubE2EQueue["1"]
+		if test.requeued == cached || test.requeued == queued {
+			t.Errorf("%s: expected requeued=%v but status cached=%v queued=%v", test.name, test.requeued, cached, queued)
+		}
+		done()
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"net/http"
+	"net/url"
+)
+
+const redacted = "REDACTED"
+
+// exportedConfig is the effective configuration served at /config. It only
+// has settings which are safe to show; secrets are left out or redacted.
+// Durations are strings like "2h0m0s".
+type exportedConfig struct {
+	Org     string
+	Project string
+
+	// These may come from the RepoConfigFile rather than the flags
+	RequiredStatusContexts []string
+	RequiredRetestContexts []string
+	DoNotMergeMilestones   []string
+	RepoConfigFile         string
+
+	OptionalUntilReported []string
+	MissingContextTimeout string
+	CIFailureGrace        string
+	UseChecks             bool
+	StickyStatusComment   bool
+
+	CLAContext      string
+	CLALabel        string
+	LGTMLabel       string
+	DoNotMergeLabel string
+	BlockingLabels  []string
+	RequiredLabels  []string
+	BlockedUsers    []string
+	MaxLGTMAge      string
+	AllowSelfLGTM   bool
+
+	BlockingJobNames    []string
+	NonBlockingJobNames []string
+	WeakStableJobNames  []string
+	JobWeights          []string
+	E2EMatrixFile       string
+	MaxBuildAge         string
+	E2ERetries          int
+	SpeculativeE2E      bool
+	SkipTestDuration    string
+	MaxE2EDuration      string
+
+	MaxRetestsPerDay     int
+	RetestLimitResetHour int
+
+	RevertTitlePrefixes []string
+	RevertLabel         string
+	RevertSkipRetest    bool
+
+	MergeMethod      string
+	MergeWindow      []string
+	ETABuckets       []string
+	MergeDeployments []string
+
+	// The whitelist for privileged commands comes from CommandWhitelist,
+	// the changes saved in WhitelistFile and the repo's collaborators.
+	CommandWhitelist []string
+	WhitelistFile    string
+	WhitelistAdmins  []string
+
+	TrackerURL      string
+	BatchURL        string
+	SlackWebhookURL string
+}
+
+// redactURL removes any password from raw.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if u.User == nil {
+		return raw
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+		return u.String()
+	}
+	return raw
+}
+
+func (sq *SubmitQueue) exportConfig() exportedConfig {
+	sq.Lock()
+	defer sq.Unlock()
+	out := exportedConfig{
+		RequiredStatusContexts: sq.RequiredStatusContexts,
+		RequiredRetestContexts: sq.RequiredRetestContexts,
+		DoNotMergeMilestones:   sq.DoNotMergeMilestones,
+		RepoConfigFile:         sq.RepoConfigFile,
+		OptionalUntilReported:  sq.OptionalUntilReported,
+		MissingContextTimeout:  sq.MissingContextTimeout.String(),
+		CIFailureGrace:         sq.CIFailureGrace.String(),
+		UseChecks:              sq.UseChecks,
+		StickyStatusComment:    sq.StickyStatusComment,
+		CLAContext:             sq.CLAContext,
+		CLALabel:               sq.CLALabel,
+		LGTMLabel:              sq.LGTMLabel,
+		DoNotMergeLabel:        sq.DoNotMergeLabel,
+		BlockingLabels:         sq.BlockingLabels,
+		RequiredLabels:         sq.RequiredLabels,
+		BlockedUsers:           sq.BlockedUsers,
+		MaxLGTMAge:             sq.MaxLGTMAge.String(),
+		AllowSelfLGTM:          sq.AllowSelfLGTM,
+		BlockingJobNames:       sq.BlockingJobNames,
+		NonBlockingJobNames:    sq.NonBlockingJobNames,
+		WeakStableJobNames:     sq.WeakStableJobNames,
+		JobWeights:             sq.JobWeights,
+		E2EMatrixFile:          sq.E2EMatrixFile,
+		MaxBuildAge:            sq.MaxBuildAge.String(),
+		E2ERetries:             sq.E2ERetries,
+		SpeculativeE2E:         sq.SpeculativeE2E,
+		SkipTestDuration:       sq.SkipTestDuration.String(),
+		MaxRetestsPerDay:       sq.MaxRetestsPerDay,
+		RetestLimitResetHour:   sq.RetestLimitResetHour,
+		MaxE2EDuration:         sq.MaxE2EDuration.String(),
+		RevertTitlePrefixes:    sq.RevertTitlePrefixes,
+		RevertLabel:            sq.RevertLabel,
+		RevertSkipRetest:       sq.RevertSkipRetest,
+		MergeMethod:            sq.MergeMethod,
+		MergeWindow:            sq.MergeWindow,
+		ETABuckets:             sq.ETABuckets,
+		MergeDeployments:       sq.MergeDeployments,
+		CommandWhitelist:       sq.CommandWhitelist,
+		WhitelistFile:          sq.WhitelistFile,
+		WhitelistAdmins:        sq.WhitelistAdmins,
+		TrackerURL:             redactURL(sq.TrackerURL),
+		BatchURL:               redactURL(sq.BatchURL),
+	}
+	if sq.githubConfig != nil {
+		out.Org = sq.githubConfig.Org
+		out.Project = sq.githubConfig.Project
+	}
+	// The webhook URL is the credential
+	if sq.SlackWebhookURL != "" {
+		out.SlackWebhookURL = redacted
+	}
+	return out
+}
+
+func (sq *SubmitQueue) serveConfig(res http.ResponseWriter, req *http.Request) {
+	sq.serve(sq.marshal(sq.exportConfig()), res, req)
+}
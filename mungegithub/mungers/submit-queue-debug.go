@@ -0,0 +1,156 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// prTimes are the times the queue uses to decide if a PR was changed after
+// it was labeled, as served by /pr-times.
+type prTimes struct {
+	Number           int
+	LastModifiedTime *time.Time
+	// LabelTimes is when each label on the PR was last added.
+	LabelTimes map[string]*time.Time
+	// ModifiedAfterLabel is the result of ModifiedAfterLabeled for each
+	// label in LabelTimes, as used for the lgtmEarly and approvedEarly
+	// checks. Labels which it could not decide for are left out.
+	ModifiedAfterLabel map[string]bool
+	Commits            []commitTimes
+}
+
+type commitTimes struct {
+	SHA           string
+	AuthorDate    *time.Time
+	CommitterDate *time.Time
+}
+
+// getPRTimes computes the times for a single PR.
+func getPRTimes(obj *github.MungeObject) (*prTimes, error) {
+	commits, ok := obj.GetCommits()
+	if !ok {
+		return nil, fmt.Errorf("unable to get the commits of %d", obj.Number())
+	}
+	if _, ok := obj.GetEvents(); !ok {
+		return nil, fmt.Errorf("unable to get the events of %d", obj.Number())
+	}
+
+	times := &prTimes{
+		Number:             obj.Number(),
+		LabelTimes:         map[string]*time.Time{},
+		ModifiedAfterLabel: map[string]bool{},
+	}
+	times.LastModifiedTime, _ = obj.LastModifiedTime()
+	for _, c := range commits {
+		commit := commitTimes{}
+		if c.SHA != nil {
+			commit.SHA = *c.SHA
+		}
+		if c.Commit != nil && c.Commit.Author != nil {
+			commit.AuthorDate = c.Commit.Author.Date
+		}
+		if c.Commit != nil && c.Commit.Committer != nil {
+			commit.CommitterDate = c.Commit.Committer.Date
+		}
+		times.Commits = append(times.Commits, commit)
+	}
+	for _, label := range obj.Issue.Labels {
+		if label.Name == nil {
+			continue
+		}
+		labelTime, ok := obj.LabelTime(*label.Name)
+		if !ok {
+			continue
+		}
+		times.LabelTimes[*label.Name] = labelTime
+		if after, ok := obj.ModifiedAfterLabeled(*label.Name); ok {
+			times.ModifiedAfterLabel[*label.Name] = after
+		}
+	}
+	return times, nil
+}
+
+// servePRTimes serves the times for the PR given by the number parameter,
+// e.g. /pr-times?number=1234.
+func (sq *SubmitQueue) servePRTimes(res http.ResponseWriter, req *http.Request) {
+	num, err := strconv.Atoi(req.URL.Query().Get("number"))
+	if err != nil {
+		res.Header().Set("Content-type", "text/plain")
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("number must be a PR number\n"))
+		return
+	}
+	obj, err := sq.githubConfig.GetObject(num)
+	if err != nil {
+		res.Header().Set("Content-type", "text/plain")
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte(fmt.Sprintf("unable to get %d: %v\n", num, err)))
+		return
+	}
+	times, err := getPRTimes(obj)
+	if err != nil {
+		sq.serve(nil, res, req)
+		return
+	}
+	sq.serve(sq.marshal(times), res, req)
+}
+
+// prHistoryEntry is one status the queue gave a PR, as served by
+// /pr-history.
+type prHistoryEntry struct {
+	Time   time.Time
+	Reason string
+}
+
+// prHistory returns the statuses of PR num in the statusHistory, oldest
+// first. An empty repo matches the queue's own repo. Only the latest
+// maxStatusHistory statuses of all PRs are kept, and only while a PR is
+// queued, so older history is lost.
+func (sq *SubmitQueue) prHistory(repo string, num int) []prHistoryEntry {
+	if repo == "" && sq.githubConfig != nil {
+		repo = sq.githubConfig.FullName()
+	}
+	sq.Lock()
+	defer sq.Unlock()
+	history := []prHistoryEntry{}
+	for _, status := range sq.statusHistory {
+		if status.Number != num || (repo != "" && status.Repo != repo) {
+			continue
+		}
+		history = append(history, prHistoryEntry{Time: status.Time, Reason: status.Reason})
+	}
+	return history
+}
+
+// servePRHistory serves the statuses the PR given by the number, and
+// optionally repo, parameters was given, e.g. /pr-history?number=1234.
+func (sq *SubmitQueue) servePRHistory(res http.ResponseWriter, req *http.Request) {
+	num, err := strconv.Atoi(req.URL.Query().Get("number"))
+	if err != nil {
+		res.Header().Set("Content-type", "text/plain")
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("number must be a PR number\n"))
+		return
+	}
+	sq.serve(sq.marshal(sq.prHistory(req.URL.Query().Get("repo"), num)), res, req)
+}
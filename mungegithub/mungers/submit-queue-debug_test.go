@@ -0,0 +1,178 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestServePRTimes(t *testing.T) {
+	tests := []struct {
+		name        string
+		issueEvents []*github.IssueEvent
+		lgtmTime    int64
+		lgtmEarly   bool
+	}{
+		{
+			name:        "labeled after the last commit",
+			issueEvents: NewLGTMEvents(),
+			lgtmTime:    12,
+		},
+		{
+			name:        "labeled before the last commit",
+			issueEvents: OldLGTMEvents(),
+			lgtmTime:    8,
+			lgtmEarly:   true,
+		},
+	}
+	for _, test := range tests {
+		client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), test.issueEvents, Commits(), nil, nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		sq.githubConfig = config
+
+		res := httptest.NewRecorder()
+		sq.servePRTimes(res, httptest.NewRequest("GET", "/pr-times?number=1", nil))
+		if res.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected response code %d: %s", test.name, res.Code, res.Body.String())
+		}
+		times := prTimes{}
+		if err := json.Unmarshal(res.Body.Bytes(), &times); err != nil {
+			t.Fatalf("%s: unable to decode response %q: %v", test.name, res.Body.String(), err)
+		}
+
+		// Commits() were made at 7, 8 and 9
+		if times.LastModifiedTime == nil || !times.LastModifiedTime.Equal(time.Unix(9, 0)) {
+			t.Errorf("%s: expected last modified at 9 but got %v", test.name, times.LastModifiedTime)
+		}
+		if len(times.Commits) != 3 {
+			t.Errorf("%s: expected 3 commits but got %v", test.name, times.Commits)
+		}
+		if lgtm := times.LabelTimes[lgtmLabel]; lgtm == nil || !lgtm.Equal(time.Unix(test.lgtmTime, 0)) {
+			t.Errorf("%s: expected %s at %d but got %v", test.name, lgtmLabel, test.lgtmTime, lgtm)
+		}
+		if early, ok := times.ModifiedAfterLabel[lgtmLabel]; !ok || early != test.lgtmEarly {
+			t.Errorf("%s: expected modified after %s to be %v but got %v", test.name, lgtmLabel, test.lgtmEarly, times.ModifiedAfterLabel)
+		}
+		server.Close()
+	}
+}
+
+func TestServePRTimesBadNumber(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	res := httptest.NewRecorder()
+	sq.servePRTimes(res, httptest.NewRequest("GET", "/pr-times?number=abc", nil))
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("expected %d but got %d", http.StatusBadRequest, res.Code)
+	}
+}
+
+func TestServePRHistory(t *testing.T) {
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	sq := getTestSQ(false, config, nil)
+	sq.githubConfig = config
+
+	status := func(repo string, num int, minutes int, reason string) submitStatus {
+		return submitStatus{
+			Time:              time.Unix(0, 0).Add(time.Duration(minutes) * time.Minute).UTC(),
+			statusPullRequest: statusPullRequest{Number: num, Repo: repo},
+			Reason:            reason,
+		}
+	}
+	sq.statusHistory = []submitStatus{
+		status("o/r", 1, 1, ghE2EQueued),
+		status("o/r", 2, 2, ghE2EQueued),
+		status("o/r", 1, 3, ghE2ERunning),
+		status("o/other", 1, 4, merged),
+		{Reason: e2eFailure},
+		status("o/r", 1, 5, ghE2EFailed),
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		expected []prHistoryEntry
+	}{
+		{
+			name: "the queue's repo",
+			url:  "/pr-history?number=1",
+			expected: []prHistoryEntry{
+				{Time: time.Unix(60, 0).UTC(), Reason: ghE2EQueued},
+				{Time: time.Unix(180, 0).UTC(), Reason: ghE2ERunning},
+				{Time: time.Unix(300, 0).UTC(), Reason: ghE2EFailed},
+			},
+		},
+		{
+			name:     "another repo",
+			url:      "/pr-history?number=1&repo=o/other",
+			expected: []prHistoryEntry{{Time: time.Unix(240, 0).UTC(), Reason: merged}},
+		},
+		{
+			name:     "no history",
+			url:      "/pr-history?number=3",
+			expected: []prHistoryEntry{},
+		},
+	}
+	for _, test := range tests {
+		res := httptest.NewRecorder()
+		sq.servePRHistory(res, httptest.NewRequest("GET", test.url, nil))
+		if res.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected response code %d: %s", test.name, res.Code, res.Body.String())
+		}
+		history := []prHistoryEntry{}
+		if err := json.Unmarshal(res.Body.Bytes(), &history); err != nil {
+			t.Fatalf("%s: unable to decode response %q: %v", test.name, res.Body.String(), err)
+		}
+		if !reflect.DeepEqual(history, test.expected) {
+			t.Errorf("%s: expected %v but got %v", test.name, test.expected, history)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	sq.servePRHistory(res, httptest.NewRequest("GET", "/pr-history?number=abc", nil))
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for a bad number but got %d", http.StatusBadRequest, res.Code)
+	}
+}
+
+func TestStatusHistoryBounded(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	for i := 0; i < maxStatusHistory+10; i++ {
+		sq.appendStatusHistory(submitStatus{statusPullRequest: statusPullRequest{Number: i}})
+	}
+	if len(sq.statusHistory) != maxStatusHistory {
+		t.Fatalf("expected %d statuses but got %d", maxStatusHistory, len(sq.statusHistory))
+	}
+	if first := sq.statusHistory[0].Number; first != 10 {
+		t.Errorf("expected the oldest statuses to be dropped, the first is %d", first)
+	}
+}
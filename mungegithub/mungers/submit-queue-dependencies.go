@@ -0,0 +1,227 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"k8s.io/contrib/mungegithub/github"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+const (
+	dependsCommand = "DEPENDS"
+
+	blockedByDependency = "PR is waiting for a PR it depends on to merge."
+
+	dependsAcceptedFmt = "This PR will not be merged until #%d is merged or closed."
+)
+
+var (
+	// Matches the arguments of "@k8s-merge-robot depends on #123"
+	dependsOnRegex = regexp.MustCompile(`^on\s+#(\d+)$`)
+	// Matches the reply to a depends command which was accepted
+	dependsAcceptedRegex = regexp.MustCompile(`^This PR will not be merged until #(\d+) is merged or closed\.$`)
+)
+
+// prDependencies are the PRs which must be merged or closed before a PR may
+// merge. They are in the same repo as the PR, which config is for.
+type prDependencies struct {
+	config *github.Config
+	on     sets.Int
+}
+
+// dependsCommand makes the PR wait for the PR given as "on #123". Only the
+// PR's author and users who may give privileged commands can add one.
+func (sq *SubmitQueue) dependsCommand(obj *github.MungeObject, user string, cmd *c.Command) string {
+	if user != *obj.Issue.User.Login && !sq.isAuthorized(user) {
+		return fmt.Sprintf("@%s only the author of this PR can add dependencies to it.", user)
+	}
+	match := dependsOnRegex.FindStringSubmatch(cmd.Arguments)
+	if match == nil {
+		return fmt.Sprintf("@%s I didn't understand that, please use `depends on #123`.", user)
+	}
+	dep, _ := strconv.Atoi(match[1])
+	num := *obj.Issue.Number
+	if dep == num {
+		return fmt.Sprintf("@%s a PR can't depend on itself.", user)
+	}
+
+	config := sq.repoConfig(obj)
+	depObj, err := config.GetObject(dep)
+	if err != nil {
+		return fmt.Sprintf("@%s unable to find #%d.", user, dep)
+	}
+	if depObj.Issue.State != nil && *depObj.Issue.State == "closed" {
+		return fmt.Sprintf("@%s #%d is already merged or closed.", user, dep)
+	}
+
+	key := sq.prKey(obj)
+	depKey := sq.keyFor(config.FullName(), dep)
+	sq.Lock()
+	defer sq.Unlock()
+	if sq.dependsOnLocked(depKey, key) {
+		return fmt.Sprintf("@%s #%d already depends on this PR, so this would never merge.", user, dep)
+	}
+	sq.addDependencyLocked(key, config, dep)
+	return fmt.Sprintf(dependsAcceptedFmt, dep)
+}
+
+// addDependencyLocked makes the PR with key wait for dep, in the repo config
+// is for. sq.Lock() must be held.
+func (sq *SubmitQueue) addDependencyLocked(key string, config *github.Config, dep int) {
+	if sq.dependencies == nil {
+		sq.dependencies = map[string]*prDependencies{}
+	}
+	deps, ok := sq.dependencies[key]
+	if !ok {
+		deps = &prDependencies{config: config, on: sets.NewInt()}
+		sq.dependencies[key] = deps
+	}
+	deps.on.Insert(dep)
+}
+
+// loadDependencies rebuilds obj's dependencies from the bot's replies to the
+// depends commands it accepted, so that they survive a restart. Those on PRs
+// which have since been merged or closed are left out.
+func (sq *SubmitQueue) loadDependencies(obj *github.MungeObject) {
+	comments, ok := obj.ListComments()
+	if !ok {
+		return
+	}
+	accepted := sets.NewInt()
+	for _, comment := range c.FilterComments(comments, c.MungeBotAuthor()) {
+		if comment.Body == nil {
+			continue
+		}
+		if match := dependsAcceptedRegex.FindStringSubmatch(*comment.Body); match != nil {
+			dep, _ := strconv.Atoi(match[1])
+			accepted.Insert(dep)
+		}
+	}
+	if accepted.Len() == 0 {
+		return
+	}
+
+	key := sq.prKey(obj)
+	sq.Lock()
+	known := sets.NewInt()
+	if deps, ok := sq.dependencies[key]; ok {
+		known = sets.NewInt(deps.on.List()...)
+	}
+	sq.Unlock()
+
+	config := sq.repoConfig(obj)
+	for _, dep := range accepted.Difference(known).List() {
+		depObj, err := config.GetObject(dep)
+		if err != nil {
+			obj.Log().Errorf("unable to check on #%d, which it depends on: %v", dep, err)
+			continue
+		}
+		if depObj.Issue.State != nil && *depObj.Issue.State == "closed" {
+			continue
+		}
+		sq.Lock()
+		sq.addDependencyLocked(key, config, dep)
+		sq.Unlock()
+	}
+}
+
+// repoConfig returns the config for the repo obj is in.
+func (sq *SubmitQueue) repoConfig(obj *github.MungeObject) *github.Config {
+	for _, config := range sq.githubConfig.Repos() {
+		if config.FullName() == obj.Repo() {
+			return config
+		}
+	}
+	return sq.githubConfig
+}
+
+// dependsOnLocked returns true if the PR with key from waits, directly or
+// through other PRs, for the PR with key to. sq.Lock() must be held.
+func (sq *SubmitQueue) dependsOnLocked(from, to string) bool {
+	seen := sets.NewString()
+	pending := []string{from}
+	for len(pending) > 0 {
+		key := pending[0]
+		pending = pending[1:]
+		if key == to {
+			return true
+		}
+		if seen.Has(key) {
+			continue
+		}
+		seen.Insert(key)
+		deps, ok := sq.dependencies[key]
+		if !ok {
+			continue
+		}
+		for _, dep := range deps.on.List() {
+			pending = append(pending, sq.keyFor(deps.config.FullName(), dep))
+		}
+	}
+	return false
+}
+
+// hasDependencies returns true if obj is still waiting for another PR.
+func (sq *SubmitQueue) hasDependencies(obj *github.MungeObject) bool {
+	sq.Lock()
+	defer sq.Unlock()
+	deps, ok := sq.dependencies[sq.prKey(obj)]
+	return ok && deps.on.Len() > 0
+}
+
+// resolveDependencies forgets dependencies on PRs which have been merged or
+// closed, letting the PRs waiting for them merge.
+func (sq *SubmitQueue) resolveDependencies() {
+	type dependency struct {
+		key    string
+		config *github.Config
+		num    int
+	}
+	sq.Lock()
+	all := []dependency{}
+	for key, deps := range sq.dependencies {
+		for _, num := range deps.on.List() {
+			all = append(all, dependency{key, deps.config, num})
+		}
+	}
+	sq.Unlock()
+
+	for _, dep := range all {
+		obj, err := dep.config.GetObject(dep.num)
+		if err != nil {
+			github.Logger{}.With("issue", dep.key).Errorf("unable to check on #%d, which it depends on: %v", dep.num, err)
+			continue
+		}
+		if obj.Issue.State == nil || *obj.Issue.State != "closed" {
+			continue
+		}
+		github.Logger{}.With("issue", dep.key).Infof("no longer waits for #%d, which was merged or closed", dep.num)
+		sq.Lock()
+		if deps, ok := sq.dependencies[dep.key]; ok {
+			deps.on.Delete(dep.num)
+			if deps.on.Len() == 0 {
+				delete(sq.dependencies, dep.key)
+			}
+		}
+		sq.Unlock()
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+
+	"github.com/google/go-github/github"
+)
+
+func TestDependsCommand(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	prA := github_test.Issue(someUserName, 2, nil, true)
+	github_test.ServeIssue(t, mux, prA)
+	closed := github_test.Issue(someUserName, 3, nil, true)
+	closed.State = stringPtr("closed")
+	github_test.ServeIssue(t, mux, closed)
+
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	sq.githubConfig = config
+	sq.CommandWhitelist = []string{"alice"}
+	prB := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+
+	depends := func(user, args string) string {
+		return sq.dependsCommand(prB, user, &c.Command{Name: dependsCommand, Arguments: args})
+	}
+	replies := []struct {
+		user  string
+		args  string
+		reply string
+	}{
+		{user: "mallory", args: "on #2", reply: "@mallory only the author"},
+		{user: someUserName, args: "#2", reply: "@" + someUserName + " I didn't understand"},
+		{user: someUserName, args: "on #1", reply: "@" + someUserName + " a PR can't depend on itself"},
+		{user: someUserName, args: "on #3", reply: "@" + someUserName + " #3 is already merged or closed"},
+	}
+	for _, test := range replies {
+		if reply := depends(test.user, test.args); !strings.HasPrefix(reply, test.reply) {
+			t.Errorf("%s %q: expected reply %q but got %q", test.user, test.args, test.reply, reply)
+		}
+	}
+	if len(sq.dependencies) != 0 {
+		t.Fatalf("expected no dependencies but got %v", sq.dependencies)
+	}
+
+	if reply := depends("alice", "on  #2"); !strings.HasPrefix(reply, "This PR will not be merged until #2") {
+		t.Errorf("unexpected reply %q", reply)
+	}
+
+	// #2 now can't wait for #1
+	cycle := sq.dependsCommand(github_util.TestObject(config, prA, ValidPR(), Commits(), NewLGTMEvents()), someUserName, &c.Command{Name: dependsCommand, Arguments: "on #1"})
+	if !strings.Contains(cycle, "already depends on this PR") {
+		t.Errorf("expected the cycle to be rejected but got %q", cycle)
+	}
+	if _, ok := sq.dependencies["2"]; ok {
+		t.Errorf("cycle was recorded")
+	}
+
+	if sq.validForMerge(prB) {
+		t.Errorf("expected PR to be held until #2 merges")
+	}
+	if r := sq.prStatus["1"].Reason; r != blockedByDependency {
+		t.Errorf("expected reason %q but got %q", blockedByDependency, r)
+	}
+
+	// Still open
+	sq.resolveDependencies()
+	if sq.validForMerge(prB) {
+		t.Errorf("expected PR to be held while #2 is open")
+	}
+
+	prA.State = stringPtr("closed")
+	sq.resolveDependencies()
+	if !sq.validForMerge(prB) {
+		t.Errorf("expected PR to be mergeable once #2 merged, got %q", sq.prStatus["1"].Reason)
+	}
+	if len(sq.dependencies) != 0 {
+		t.Errorf("expected dependencies to be forgotten but got %v", sq.dependencies)
+	}
+}
+
+func TestLoadDependencies(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	github_test.ServeIssue(t, mux, github_test.Issue(someUserName, 2, nil, true))
+	closed := github_test.Issue(someUserName, 3, nil, true)
+	closed.State = stringPtr("closed")
+	github_test.ServeIssue(t, mux, closed)
+	github_test.ServeIssue(t, mux, github_test.Issue(someUserName, 4, nil, true))
+	comments := []*github.IssueComment{
+		github_test.IssueComment(1, "@"+botName+" depends on #2", someUserName, 10),
+		github_test.IssueComment(2, fmt.Sprintf(dependsAcceptedFmt, 2), botName, 11),
+		github_test.IssueComment(3, fmt.Sprintf(dependsAcceptedFmt, 3), botName, 12),
+		// Only the bot's own replies count
+		github_test.IssueComment(4, fmt.Sprintf(dependsAcceptedFmt, 4), "mallory", 13),
+	}
+	mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(comments)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		w.Write(data)
+	})
+
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	// A freshly started queue knows of no dependencies
+	sq := getTestSQ(false, config, server)
+	sq.githubConfig = config
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	sq.loadDependencies(obj)
+
+	deps, ok := sq.dependencies["1"]
+	if !ok {
+		t.Fatalf("expected the dependencies to be rebuilt from the comments")
+	}
+	if on := deps.on.List(); !reflect.DeepEqual(on, []int{2}) {
+		t.Errorf("expected to depend on [2] but got %v", on)
+	}
+	if sq.validForMerge(obj) {
+		t.Errorf("expected PR to be held until #2 merges")
+	}
+	if r := sq.prStatus["1"].Reason; r != blockedByDependency {
+		t.Errorf("expected reason %q but got %q", blockedByDependency, r)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// parseMergeDeployments turns "org/project=environment" entries into a map
+// from each repo to the environment its merges are deployed to.
+func parseMergeDeployments(specs []string) (map[string]string, error) {
+	deployments := map[string]string{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || !strings.Contains(parts[0], "/") || parts[1] == "" {
+			return nil, fmt.Errorf("invalid merge deployment %q, expected something like kubernetes/kubernetes=staging", spec)
+		}
+		deployments[parts[0]] = parts[1]
+	}
+	return deployments, nil
+}
+
+// deployMerge creates a github deployment of the commit obj was just merged
+// as, if its repo is one of the MergeDeployments. A failure is only logged;
+// the PR is merged either way.
+func (sq *SubmitQueue) deployMerge(obj *github.MungeObject) {
+	environment, ok := sq.mergeDeployments[obj.Repo()]
+	if !ok {
+		return
+	}
+	sha, ok := obj.MergeCommitSHA()
+	if !ok {
+		obj.Log().Errorf("not deploying to %s, the merge commit is unknown", environment)
+		return
+	}
+	description := fmt.Sprintf("Merge of #%d by the submit queue", *obj.Issue.Number)
+	if err := sq.repoConfig(obj).CreateDeployment(sha, environment, description); err != nil {
+		obj.Log().Errorf("unable to deploy %s to %s: %v", sha, environment, err)
+	}
+}
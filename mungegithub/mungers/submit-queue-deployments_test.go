@@ -0,0 +1,108 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestParseMergeDeployments(t *testing.T) {
+	deployments, err := parseMergeDeployments([]string{"o/r=staging", "o/other=prod=eu"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deployments["o/r"] != "staging" || deployments["o/other"] != "prod=eu" {
+		t.Errorf("unexpected deployments %v", deployments)
+	}
+	for _, spec := range []string{"o/r", "r=staging", "o/r="} {
+		if _, err := parseMergeDeployments([]string{spec}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func TestMergeDeployments(t *testing.T) {
+	tests := []struct {
+		name        string
+		deployments []string
+		deployed    bool
+	}{
+		{name: "not deployed", deployed: false},
+		{name: "another repo", deployments: []string{"o/other=staging"}, deployed: false},
+		{name: "deployed", deployments: []string{"o/r=staging"}, deployed: true},
+	}
+	for _, test := range tests {
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		mux.HandleFunc("/repos/o/r/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+			data, _ := json.Marshal(github.PullRequestMergeResult{SHA: stringPtr("mergesha"), Merged: boolPtr(true)})
+			w.Write(data)
+		})
+		var requests []github.DeploymentRequest
+		mux.HandleFunc("/repos/o/r/deployments", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" {
+				t.Errorf("%s: unexpected method %s", test.name, r.Method)
+			}
+			request := github.DeploymentRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			requests = append(requests, request)
+			w.Write([]byte("{}"))
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.githubConfig = config
+		deployments, err := parseMergeDeployments(test.deployments)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		sq.mergeDeployments = deployments
+
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		if !sq.mergePullRequest(obj, merged, "") {
+			t.Errorf("%s: merge failed", test.name)
+		}
+		if !test.deployed {
+			if len(requests) != 0 {
+				t.Errorf("%s: expected no deployment but got %v", test.name, requests)
+			}
+			server.Close()
+			continue
+		}
+		if len(requests) != 1 {
+			t.Fatalf("%s: expected one deployment but got %d", test.name, len(requests))
+		}
+		if ref := requests[0].Ref; ref == nil || *ref != "mergesha" {
+			t.Errorf("%s: expected the merge commit to be deployed but got %v", test.name, ref)
+		}
+		if env := requests[0].Environment; env == nil || *env != "staging" {
+			t.Errorf("%s: expected the staging environment but got %v", test.name, env)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// e2eOverride is how a label changes the github e2e contexts a PR must pass.
+type e2eOverride struct {
+	require sets.String
+	skip    sets.String
+}
+
+// parseE2ELabelContexts turns "label=+context" and "label=-context" entries
+// into the contexts each label adds to or removes from the defaults.
+func parseE2ELabelContexts(specs []string) (map[string]e2eOverride, error) {
+	overrides := map[string]e2eOverride{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || len(parts[1]) < 2 || (parts[1][0] != '+' && parts[1][0] != '-') {
+			return nil, fmt.Errorf("invalid e2e label context %q, expected something like label=+context or label=-context", spec)
+		}
+		label, context := parts[0], parts[1][1:]
+		override, ok := overrides[label]
+		if !ok {
+			override = e2eOverride{require: sets.NewString(), skip: sets.NewString()}
+			overrides[label] = override
+		}
+		if parts[1][0] == '+' {
+			override.require.Insert(context)
+		} else {
+			override.skip.Insert(context)
+		}
+	}
+	return overrides, nil
+}
+
+// retestContexts returns the github e2e contexts obj must pass: the default
+// RequiredRetestContexts plus any its labels require, less any its labels
+// skip. If one label requires a context and another skips it, it is required.
+func (sq *SubmitQueue) retestContexts(obj *github.MungeObject) []string {
+	if len(sq.e2eLabelContexts) == 0 {
+		return sq.RequiredRetestContexts
+	}
+	require := sets.NewString()
+	skip := sets.NewString()
+	for label, override := range sq.e2eLabelContexts {
+		if obj.HasLabel(label) {
+			require = require.Union(override.require)
+			skip = skip.Union(override.skip)
+		}
+	}
+	skip = skip.Difference(require)
+
+	contexts := []string{}
+	for _, context := range sq.RequiredRetestContexts {
+		if !skip.Has(context) {
+			contexts = append(contexts, context)
+		}
+	}
+	return append(contexts, require.Difference(sets.NewString(sq.RequiredRetestContexts...)).List()...)
+}
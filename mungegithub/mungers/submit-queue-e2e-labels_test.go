@@ -0,0 +1,128 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+)
+
+func TestParseE2ELabelContexts(t *testing.T) {
+	overrides, err := parseE2ELabelContexts([]string{"area/gpu=+gpu-e2e", "area/gpu=+gpu-soak", "kind/docs=-" + requiredReTestContext1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := overrides["area/gpu"].require.List(); !reflect.DeepEqual(got, []string{"gpu-e2e", "gpu-soak"}) {
+		t.Errorf("expected area/gpu to require two contexts but got %v", got)
+	}
+	if got := overrides["kind/docs"].skip.List(); !reflect.DeepEqual(got, []string{requiredReTestContext1}) {
+		t.Errorf("expected kind/docs to skip %s but got %v", requiredReTestContext1, got)
+	}
+
+	for _, bad := range []string{"area/gpu", "=+gpu-e2e", "area/gpu=gpu-e2e", "area/gpu=+"} {
+		if _, err := parseE2ELabelContexts([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestRetestContexts(t *testing.T) {
+	specs := []string{
+		"area/gpu=+gpu-e2e",
+		"kind/docs=-" + requiredReTestContext1,
+		"kind/flaky=-" + requiredReTestContext2,
+		"area/node=+" + requiredReTestContext2,
+	}
+	tests := []struct {
+		name     string
+		labels   []string
+		expected []string
+	}{
+		{
+			name:     "defaults",
+			expected: []string{requiredReTestContext1, requiredReTestContext2},
+		},
+		{
+			name:     "label adds a context",
+			labels:   []string{"area/gpu"},
+			expected: []string{requiredReTestContext1, requiredReTestContext2, "gpu-e2e"},
+		},
+		{
+			name:     "label skips a context",
+			labels:   []string{"kind/docs"},
+			expected: []string{requiredReTestContext2},
+		},
+		{
+			name:     "adding and skipping",
+			labels:   []string{"kind/docs", "area/gpu"},
+			expected: []string{requiredReTestContext2, "gpu-e2e"},
+		},
+		{
+			name:     "requiring wins over skipping",
+			labels:   []string{"kind/flaky", "area/node"},
+			expected: []string{requiredReTestContext1, requiredReTestContext2},
+		},
+	}
+	for _, test := range tests {
+		sq := getTestSQ(false, nil, nil)
+		overrides, err := parseE2ELabelContexts(specs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		sq.e2eLabelContexts = overrides
+		obj := github_util.TestObject(nil, github_test.Issue(someUserName, 1, test.labels, true), nil, nil, nil)
+		if got := sq.retestContexts(obj); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v but got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestE2ELabelContextsValidForMerge(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	overrides, err := parseE2ELabelContexts([]string{claYesLabel + "=+gpu-e2e"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sq.e2eLabelContexts = overrides
+
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	if sq.validForMerge(obj) {
+		t.Errorf("expected PR to need the gpu-e2e context its label requires")
+	}
+	if r := sq.prStatus["1"].Reason; !strings.HasSuffix(r, "gpu-e2e") {
+		t.Errorf("expected the missing gpu-e2e context to be the reason but got %q", r)
+	}
+
+	// retest-not-required still skips everything and goes first
+	retestNotRequired := github_test.Issue(someUserName, 1, []string{claYesLabel, retestNotRequiredLabel}, true)
+	if p := priority(github_util.TestObject(config, retestNotRequired, nil, nil, nil)); p != retestNotRequiredMergePriority {
+		t.Errorf("expected priority %d but got %d", retestNotRequiredMergePriority, p)
+	}
+}
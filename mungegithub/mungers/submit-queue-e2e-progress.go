@@ -0,0 +1,56 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+const ghE2ERunningForFmt = "Running github e2e tests a second time (%dm so far)."
+
+// reportE2EProgress updates the status of obj, if its github e2e run is in
+// progress, with how long the run has been going. Only whole steps of
+// E2EProgressInterval are reported so github is only told when the
+// description changes, however often Munge() sees the PR.
+func (sq *SubmitQueue) reportE2EProgress(obj *github.MungeObject) {
+	if sq.E2EProgressInterval <= 0 {
+		return
+	}
+	sq.Lock()
+	if sq.abortE2E == nil || !sq.runningLocked(obj) || sq.prStatus[sq.prKey(obj)].Reason != ghE2ERunning {
+		sq.Unlock()
+		return
+	}
+	elapsed := sq.clock.Since(sq.e2eStarted)
+	elapsed -= elapsed % sq.E2EProgressInterval
+	if elapsed < time.Minute {
+		sq.Unlock()
+		return
+	}
+	description := fmt.Sprintf(ghE2ERunningForFmt, int(elapsed/time.Minute))
+	if description == sq.e2eProgress {
+		sq.Unlock()
+		return
+	}
+	sq.e2eProgress = description
+	sq.Unlock()
+
+	sq.statusBackend().Set(obj, sq.reasonToState(ghE2ERunning), statusURL(obj), description)
+}
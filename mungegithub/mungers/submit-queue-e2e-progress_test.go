@@ -0,0 +1,72 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+)
+
+// recordingBackend remembers the descriptions it is asked to set.
+type recordingBackend struct {
+	commitStatusBackend
+	descriptions []string
+}
+
+func (b *recordingBackend) Set(obj *github_util.MungeObject, state, url, description string) bool {
+	b.descriptions = append(b.descriptions, description)
+	return true
+}
+
+func TestReportE2EProgress(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	clock := sq.clock.(*utilclock.FakeClock)
+	backend := &recordingBackend{}
+	sq.backend = backend
+	sq.E2EProgressInterval = 5 * time.Minute
+
+	obj := github_util.TestObject(nil, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	sq.githubE2EQueue["1"] = obj
+	sq.githubE2ERunning = obj
+	sq.prStatus["1"] = submitStatus{Reason: ghE2ERunning}
+	abort := sq.startE2ERun(obj)
+
+	// Munge() sees the running PR every 2 minutes
+	for i := 0; i < 6; i++ {
+		sq.reportE2EProgress(obj)
+		clock.Step(2 * time.Minute)
+	}
+	expected := []string{
+		"Running github e2e tests a second time (5m so far).",
+		"Running github e2e tests a second time (10m so far).",
+	}
+	if !reflect.DeepEqual(backend.descriptions, expected) {
+		t.Errorf("expected the descriptions %q but got %q", expected, backend.descriptions)
+	}
+
+	// Nothing is reported once the run is over
+	sq.finishE2ERun(abort)
+	clock.Step(time.Hour)
+	sq.reportE2EProgress(obj)
+	if len(backend.descriptions) != len(expected) {
+		t.Errorf("expected no more updates after the run but got %q", backend.descriptions)
+	}
+}
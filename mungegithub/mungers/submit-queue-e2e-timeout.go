@@ -0,0 +1,94 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// abortTimedOutE2E cancels the github e2e run for obj if it has been going
+// for longer than MaxE2EDuration, so a hung run doesn't hold up the queue
+// forever. It returns true if the run was cancelled.
+func (sq *SubmitQueue) abortTimedOutE2E(obj *github.MungeObject) bool {
+	if sq.MaxE2EDuration <= 0 {
+		return false
+	}
+	sq.Lock()
+	if sq.abortE2E == nil || !sq.runningLocked(obj) {
+		sq.Unlock()
+		return false
+	}
+	runningFor := sq.clock.Since(sq.e2eStarted)
+	if runningFor <= sq.MaxE2EDuration {
+		sq.Unlock()
+		return false
+	}
+	obj.Log().Infof("abandoning github e2e run at %s after %v", sq.runningSHA, runningFor)
+	abortedSHA := sq.cancelE2ERunLocked(obj)
+	if sq.e2eTimedOut == nil {
+		sq.e2eTimedOut = map[string]string{}
+	}
+	sq.e2eTimedOut[sq.prKey(obj)] = abortedSHA
+	sq.Unlock()
+
+	sq.e2e.AbortPR(obj.Number(), abortedSHA)
+	sq.e2eStatusLock.Lock()
+	defer sq.e2eStatusLock.Unlock()
+	sq.SetMergeStatus(obj, e2eTimeout)
+	return true
+}
+
+// heldAfterE2ETimeout returns true if obj's github e2e run timed out and it
+// must stay out of the queue. Pushing to the PR gives it another chance.
+func (sq *SubmitQueue) heldAfterE2ETimeout(obj *github.MungeObject) bool {
+	key := sq.prKey(obj)
+	sq.Lock()
+	sha, timedOut := sq.e2eTimedOut[key]
+	sq.Unlock()
+	if !timedOut {
+		return false
+	}
+	if head, _, ok := obj.GetHeadAndBase(); ok && head != sha {
+		sq.Lock()
+		delete(sq.e2eTimedOut, key)
+		sq.Unlock()
+		return false
+	}
+	if sq.RequeueAfterE2ETimeout {
+		return false
+	}
+	sq.SetMergeStatus(obj, e2eTimeout)
+	return true
+}
+
+// timedOutLast moves the PRs whose github e2e run timed out to the back of
+// the queue, keeping the order otherwise. sq.Lock() must be held.
+func (sq *SubmitQueue) timedOutLast(prs []*github.MungeObject) []*github.MungeObject {
+	if len(sq.e2eTimedOut) == 0 {
+		return prs
+	}
+	out := make([]*github.MungeObject, 0, len(prs))
+	last := []*github.MungeObject{}
+	for _, obj := range prs {
+		if _, timedOut := sq.e2eTimedOut[sq.prKey(obj)]; timedOut {
+			last = append(last, obj)
+		} else {
+			out = append(out, obj)
+		}
+	}
+	return append(out, last...)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	fake_e2e "k8s.io/contrib/mungegithub/mungers/e2e/fake"
+
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+)
+
+func TestE2ETimeout(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	// The retest is requested but never starts
+	mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("/repos/o/r/statuses/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.BaseWaitTime = time.Millisecond
+	config.SetClient(client)
+	sq := getTestSQ(false, config, server)
+	clock := sq.clock.(*utilclock.FakeClock)
+	fake := &fake_e2e.FakeE2ETester{}
+	sq.e2e = fake
+	sq.MaxE2EDuration = time.Hour
+
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	sq.githubE2EQueue["1"] = obj
+	sq.githubE2ERunning = obj
+	finished := make(chan bool, 1)
+	go func() { finished <- sq.retestPR(obj) }()
+	started := func() bool {
+		sq.Lock()
+		defer sq.Unlock()
+		return sq.abortE2E != nil && sq.prStatus["1"].Reason == ghE2EWaitingStart
+	}
+	for !started() {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Step(time.Hour)
+	if sq.abortTimedOutE2E(obj) {
+		t.Fatalf("run was abandoned before --max-e2e-duration passed")
+	}
+	clock.Step(time.Minute)
+	if !sq.abortTimedOutE2E(obj) {
+		t.Fatalf("expected the run to be abandoned after --max-e2e-duration")
+	}
+	select {
+	case failed := <-finished:
+		if !failed {
+			t.Errorf("expected the retest to report a status change")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("retest kept waiting after timing out")
+	}
+	if fake.Aborted[1] != "mysha" {
+		t.Errorf("expected the e2e tester to abort mysha but got %v", fake.Aborted)
+	}
+	if sq.onQueue(obj) {
+		t.Errorf("timed out PR is still queued")
+	}
+	if r := sq.prStatus["1"].Reason; r != e2eTimeout {
+		t.Errorf("expected reason %q but got %q", e2eTimeout, r)
+	}
+
+	// The same commit stays out of the queue...
+	if !sq.heldAfterE2ETimeout(obj) {
+		t.Errorf("expected the timed out commit to be held")
+	}
+	// ...unless timed out PRs are requeued, at the back.
+	sq.RequeueAfterE2ETimeout = true
+	if sq.heldAfterE2ETimeout(obj) {
+		t.Errorf("expected the timed out commit to be requeued")
+	}
+	other := github_util.TestObject(config, github_test.Issue(someUserName, 2, nil, true), ValidPR(), nil, nil)
+	sq.Lock()
+	ordered := sq.timedOutLast([]*github_util.MungeObject{obj, other})
+	sq.Unlock()
+	if len(ordered) != 2 || ordered[0] != other || ordered[1] != obj {
+		t.Errorf("expected the timed out PR to be last")
+	}
+
+	// A new commit gets a fresh start.
+	sq.RequeueAfterE2ETimeout = false
+	pr := ValidPR()
+	pr.Head.SHA = stringPtr("newsha")
+	pushed := github_util.TestObject(config, LGTMApprovedIssue(), pr, Commits(), NewLGTMEvents())
+	if sq.heldAfterE2ETimeout(pushed) {
+		t.Errorf("expected a new commit not to be held")
+	}
+	if _, timedOut := sq.e2eTimedOut["1"]; timedOut {
+		t.Errorf("expected the new commit to clear the timeout")
+	}
+}
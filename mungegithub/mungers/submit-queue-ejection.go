@@ -0,0 +1,135 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+const ejectionCommentPrefix = "This PR was removed from the submit queue because "
+
+// ejectionMessages explains, for the reasons that aren't caused by a human
+// changing the PR, why a PR was dropped from the queue.
+var ejectionMessages = map[string]string{
+	ghE2EFailed: "the tests which are rerun before merging failed",
+	unmergeable: "it no longer merges cleanly and needs a rebase",
+	e2eFailure:  "the continuously running e2e tests failed while it was being merged",
+}
+
+// ejectionMessage returns why the PR was dropped from the queue, in
+// Reason, and any links which help figure out what went wrong. It returns
+// false if the reason isn't worth telling the author about.
+func (sq *SubmitQueue) ejectionMessage(obj *github.MungeObject, reason string) (commentData, bool) {
+	var details bytes.Buffer
+	data := commentData{}
+	addLink := func(context string) {
+		if url := sq.writeStatusLink(&details, obj, context); data.BuildURL == "" {
+			data.BuildURL = url
+		}
+	}
+	switch {
+	case strings.HasPrefix(reason, ciFailure+": "):
+		context := strings.TrimPrefix(reason, ciFailure+": ")
+		addLink(context)
+		data.Reason = fmt.Sprintf("the required status %q is not green", context)
+		data.Details = details.String()
+		return data, true
+	case strings.HasPrefix(reason, missingContext+": "):
+		context := strings.TrimPrefix(reason, missingContext+": ")
+		data.Reason = fmt.Sprintf("the required status %q has never been reported, it may be misconfigured", context)
+		return data, true
+	case reason == ghE2EFailed:
+		for _, context := range sq.retestContexts(obj) {
+			if success, ok := sq.statusBackend().IsSuccess(obj, []string{context}); ok && !success {
+				addLink(context)
+			}
+		}
+	case reason == e2eFailure:
+		sq.writeFailingJobs(&details)
+	}
+	msg, ok := ejectionMessages[reason]
+	data.Reason = msg
+	data.Details = details.String()
+	return data, ok
+}
+
+// writeStatusLink lists the context's target URL, if it has one, and
+// returns it.
+func (sq *SubmitQueue) writeStatusLink(out *bytes.Buffer, obj *github.MungeObject, context string) string {
+	url, ok := sq.statusBackend().TargetURL(obj, context)
+	if !ok || url == "" {
+		return ""
+	}
+	fmt.Fprintf(out, "* %s: %s\n\n", context, url)
+	return url
+}
+
+// writeFailingJobs lists the blocking jobs which are failing, with the build
+// number from their latest-build.txt.
+func (sq *SubmitQueue) writeFailingJobs(out *bytes.Buffer) {
+	builds := sq.e2e.GetBuildStatus()
+	jobs := []string{}
+	for _, job := range sq.BlockingJobNames {
+		if build, ok := builds[job]; ok && build.Status == "Not Stable" {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Strings(jobs)
+	for _, job := range jobs {
+		id := builds[job].ID
+		if sq.features != nil && sq.features.GCSInfo != nil && sq.features.GCSInfo.BucketName != "" {
+			gcsPath := fmt.Sprintf("/%s/%s/%s/%s/", sq.features.GCSInfo.BucketName, sq.features.GCSInfo.LogDir, job, id)
+			fmt.Fprintf(out, "* %s #%s: %s\n\n", job, id, makeGubernatorLink(gcsPath))
+		} else {
+			fmt.Fprintf(out, "* %s #%s\n\n", job, id)
+		}
+	}
+}
+
+// writeEjectionComment tells the author why their PR left the queue. It
+// doesn't comment again if the PR was already ejected for the same reason.
+func (sq *SubmitQueue) writeEjectionComment(obj *github.MungeObject, reason string) {
+	if !sq.EjectionComment {
+		return
+	}
+	data, ok := sq.ejectionMessage(obj, reason)
+	if !ok {
+		return
+	}
+
+	key := sq.prKey(obj)
+	sq.Lock()
+	if sq.ejectionReasons == nil {
+		sq.ejectionReasons = map[string]string{}
+	}
+	if sq.ejectionReasons[key] == reason {
+		sq.Unlock()
+		obj.Log().WithReason(reason).V(4).Infof("already commented about ejection")
+		return
+	}
+	sq.ejectionReasons[key] = reason
+	sq.Unlock()
+
+	if err := obj.WriteComment(sq.renderComment(obj, ejectedTemplate, data)); err != nil {
+		obj.Log().WithReason(reason).Errorf("unable to comment about ejection: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	fake_e2e "k8s.io/contrib/mungegithub/mungers/e2e/fake"
+
+	"github.com/google/go-github/github"
+)
+
+func TestEjectionComment(t *testing.T) {
+	tests := []struct {
+		name     string
+		queued   bool
+		reasons  []string
+		comments []string
+	}{
+		{
+			name:     "ci failure",
+			queued:   true,
+			reasons:  []string{fmt.Sprintf(ciFailureFmt, notRequiredReTestContext1)},
+			comments: []string{`the required status "` + notRequiredReTestContext1 + `" is not green`},
+		},
+		{
+			name:     "same reason twice",
+			queued:   true,
+			reasons:  []string{unmergeable, unmergeable},
+			comments: []string{"needs a rebase"},
+		},
+		{
+			name:    "not queued",
+			reasons: []string{unmergeable},
+		},
+		{
+			name:    "human caused",
+			queued:  true,
+			reasons: []string{fmt.Sprintf(noLGTMFmt, lgtmLabel)},
+		},
+		{
+			name:     "e2e failure links the failing build",
+			queued:   true,
+			reasons:  []string{e2eFailure},
+			comments: []string{"* foo #1"},
+		},
+	}
+	for _, test := range tests {
+		replies := []string{}
+		sq, obj, done := commandTestSQ(t, []*github.IssueComment{}, &replies)
+		sq.EjectionComment = true
+		sq.e2e = &fake_e2e.FakeE2ETester{NotStableJobNames: sq.BlockingJobNames}
+
+		for _, reason := range test.reasons {
+			if test.queued {
+				sq.githubE2EQueue["1"] = obj
+			}
+			sq.SetMergeStatus(obj, reason)
+			if reason == e2eFailure {
+				// e2eFailure doesn't itself dequeue the PR,
+				// doGithubE2EAndMerge does.
+				sq.writeEjectionComment(obj, reason)
+			}
+		}
+
+		if len(replies) != len(test.comments) {
+			t.Errorf("%s: expected %d comments but got %q", test.name, len(test.comments), replies)
+		}
+		for i := range replies {
+			if i >= len(test.comments) {
+				break
+			}
+			if !strings.Contains(replies[i], ejectionCommentPrefix) || !strings.Contains(replies[i], test.comments[i]) {
+				t.Errorf("%s: expected comment containing %q but got %q", test.name, test.comments[i], replies[i])
+			}
+		}
+		done()
+	}
+}
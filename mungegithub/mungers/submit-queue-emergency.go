@@ -0,0 +1,67 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"sync/atomic"
+
+	"k8s.io/contrib/mungegithub/github"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+const emergencyMergeLabel = "emergency-merge"
+
+// emergencyMergeApplier returns who added the EmergencyMergeLabel, and
+// whether they are allowed to.
+func (sq *SubmitQueue) emergencyMergeApplier(obj *github.MungeObject) (string, bool) {
+	if sq.EmergencyMergeLabel == "" || !obj.HasLabel(sq.EmergencyMergeLabel) {
+		return "", false
+	}
+	user, ok := obj.LabelCreator(sq.EmergencyMergeLabel)
+	if !ok {
+		return "", false
+	}
+	return user, sets.NewString(sq.EmergencyMergeAdmins...).Has(user)
+}
+
+// isEmergencyMerge returns true if the PR should skip the github e2e tests.
+func (sq *SubmitQueue) isEmergencyMerge(obj *github.MungeObject) bool {
+	_, ok := sq.emergencyMergeApplier(obj)
+	return ok
+}
+
+// maybeEmergencyMerge merges a PR which passed validForMerge straight away,
+// without queueing it for the github e2e tests, if an admin asked for that.
+// Returns false if the PR should be queued as usual.
+func (sq *SubmitQueue) maybeEmergencyMerge(obj *github.MungeObject) bool {
+	user, ok := sq.emergencyMergeApplier(obj)
+	if !ok {
+		if user != "" {
+			obj.Log().With("applier", user).Warningf("AUDIT: ignoring %q, which was not added by one of %v", sq.EmergencyMergeLabel, sq.EmergencyMergeAdmins)
+		}
+		return false
+	}
+	log := obj.Log().With("applier", user).WithReason(emergencyMerged)
+	log.Warningf("AUDIT: emergency merge requested with %q, skipping the github e2e tests", sq.EmergencyMergeLabel)
+	atomic.AddInt32(&sq.instantMerges, 1)
+	if !sq.mergePullRequest(obj, emergencyMerged, "") {
+		log.Errorf("AUDIT: emergency merge failed")
+		return false
+	}
+	log.Warningf("AUDIT: emergency merge done")
+	return true
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+const etaLabelPrefix = "merge-eta/"
+
+// parseETABuckets parses boundaries like "1h" or "1d", which time doesn't
+// understand, and returns them in increasing order.
+func parseETABuckets(specs []string) ([]time.Duration, error) {
+	buckets := []time.Duration{}
+	for _, spec := range specs {
+		var d time.Duration
+		var err error
+		if strings.HasSuffix(spec, "d") {
+			var days int
+			days, err = strconv.Atoi(strings.TrimSuffix(spec, "d"))
+			d = time.Duration(days) * 24 * time.Hour
+		} else {
+			d, err = time.ParseDuration(spec)
+		}
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid merge ETA bucket %q, expected something like 4h or 1d", spec)
+		}
+		buckets = append(buckets, d)
+	}
+	sort.Sort(durations(buckets))
+	return buckets, nil
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+
+// formatETABucket renders d as short as it goes, so 24h is "1d".
+func formatETABucket(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
+
+// etaLabel returns the label for the first bucket eta is under, like
+// merge-eta/under-1h, or merge-eta/over-1d past the last one.
+func (sq *SubmitQueue) etaLabel(eta time.Duration) string {
+	for _, bucket := range sq.etaBuckets {
+		if eta < bucket {
+			return etaLabelPrefix + "under-" + formatETABucket(bucket)
+		}
+	}
+	return etaLabelPrefix + "over-" + formatETABucket(sq.etaBuckets[len(sq.etaBuckets)-1])
+}
+
+// updateETALabel gives obj the label for how long it should take to merge,
+// given its place in the queue and the merge rate, and removes any other
+// merge-eta/ label. PRs which aren't queued, or when there's no estimate
+// because nothing is merging, don't get one.
+func (sq *SubmitQueue) updateETALabel(obj *github.MungeObject) {
+	if len(sq.etaBuckets) == 0 || !obj.IsPR() {
+		return
+	}
+	want := ""
+	sq.Lock()
+	key := sq.prKey(obj)
+	for i, k := range sq.orderedE2EQueue() {
+		if k == key {
+			if eta, ok := sq.estimateTimeToMerge(i, sq.calcMergeRateWithTail()); ok {
+				want = sq.etaLabel(eta)
+			}
+			break
+		}
+	}
+	sq.Unlock()
+	sq.setETALabel(obj, want)
+}
+
+// setETALabel makes label obj's only merge-eta/ label, or removes them all
+// if it is "".
+func (sq *SubmitQueue) setETALabel(obj *github.MungeObject, label string) {
+	has := false
+	for _, l := range obj.Issue.Labels {
+		if l.Name == nil || !strings.HasPrefix(*l.Name, etaLabelPrefix) {
+			continue
+		}
+		if *l.Name == label {
+			has = true
+			continue
+		}
+		if err := obj.RemoveLabel(*l.Name); err != nil {
+			obj.Log().Errorf("unable to remove the %s label: %v", *l.Name, err)
+		}
+	}
+	if label != "" && !has {
+		if err := obj.AddLabel(label); err != nil {
+			obj.Log().Errorf("unable to add the %s label: %v", label, err)
+		}
+	}
+}
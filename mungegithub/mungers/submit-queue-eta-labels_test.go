@@ -0,0 +1,124 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func etaLabels(obj *github_util.MungeObject) []string {
+	labels := []string{}
+	for _, l := range obj.Issue.Labels {
+		if strings.HasPrefix(*l.Name, etaLabelPrefix) {
+			labels = append(labels, *l.Name)
+		}
+	}
+	return labels
+}
+
+func TestETALabels(t *testing.T) {
+	client, server, mux := github_test.InitServer(t, nil, nil, nil, nil, nil, nil, nil)
+	defer server.Close()
+	for i := 1; i <= 5; i++ {
+		path := fmt.Sprintf("/repos/o/r/issues/%d/labels", i)
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			data, _ := json.Marshal([]github.Label{})
+			w.Write(data)
+		})
+		mux.HandleFunc(path+"/", func(w http.ResponseWriter, r *http.Request) {})
+	}
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+	sq := getTestSQ(false, config, server)
+	buckets, err := parseETABuckets([]string{"1d", "1h", "3h"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sq.etaBuckets = buckets
+	// One merge an hour
+	sq.mergeRate = 24
+	sq.lastMergeTime = sq.clock.Now()
+
+	// With distinct priorities the queue is in this order
+	objs := []*github_util.MungeObject{}
+	for i := 0; i < 5; i++ {
+		issue := github_test.Issue(someUserName, i+1, []string{fmt.Sprintf("priority/P%d", i)}, true)
+		obj := github_util.TestObject(config, issue, ValidPR(), nil, nil)
+		objs = append(objs, obj)
+		sq.githubE2EQueue[strconv.Itoa(i+1)] = obj
+	}
+	// A stale label from an earlier position
+	objs[0].Issue.Labels = append(objs[0].Issue.Labels, github.Label{Name: stringPtr(etaLabelPrefix + "over-1d")})
+
+	expected := [][]string{
+		{"merge-eta/under-3h", "merge-eta/under-3h", "merge-eta/under-1d", "merge-eta/under-1d", "merge-eta/under-1d"},
+		{"", "merge-eta/under-3h", "merge-eta/under-3h", "merge-eta/under-1d", "merge-eta/under-1d"},
+		{"", "", "merge-eta/under-3h", "merge-eta/under-3h", "merge-eta/under-1d"},
+	}
+	for round, want := range expected {
+		for _, obj := range objs {
+			sq.updateETALabel(obj)
+		}
+		for i, obj := range objs {
+			got := etaLabels(obj)
+			if want[i] == "" {
+				if len(got) != 0 {
+					t.Errorf("round %d: expected #%d to have no merge-eta label, got %v", round, i+1, got)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(got, []string{want[i]}) {
+				t.Errorf("round %d: expected #%d to have %s, got %v", round, i+1, want[i], got)
+			}
+		}
+		// The head of the queue merges
+		key := sq.orderedE2EQueue()[0]
+		delete(sq.githubE2EQueue, key)
+		sq.lastMergeTime = sq.clock.Now()
+	}
+
+	// Past the last bucket
+	sq.mergeRate = 1
+	sq.updateETALabel(objs[4])
+	if got := etaLabels(objs[4]); !reflect.DeepEqual(got, []string{"merge-eta/over-1d"}) {
+		t.Errorf("expected merge-eta/over-1d, got %v", got)
+	}
+	// Removed once it merges
+	sq.setETALabel(objs[4], "")
+	if got := etaLabels(objs[4]); len(got) != 0 {
+		t.Errorf("expected no merge-eta label after merging, got %v", got)
+	}
+
+	for _, bad := range []string{"soon", "0h", "-1d"} {
+		if _, err := parseETABuckets([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// parseFairnessQuotas turns "P0=5" style entries into a map from priority to
+// the number of merges in a row that priority gets.
+func parseFairnessQuotas(specs []string) (map[int]int, error) {
+	quotas := map[int]int{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(strings.ToUpper(parts[0]), "P") {
+			return nil, fmt.Errorf("invalid fairness quota %q, expected something like P0=5", spec)
+		}
+		prio, err := strconv.Atoi(parts[0][1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority in fairness quota %q: %v", spec, err)
+		}
+		quota, err := strconv.Atoi(parts[1])
+		if err != nil || quota < 1 {
+			return nil, fmt.Errorf("invalid fairness quota %q, the quota must be a positive number", spec)
+		}
+		quotas[prio] = quota
+	}
+	return quotas, nil
+}
+
+// applyFairness takes the queue in strict priority order. If the tier at the
+// front has used up its quota of merges in a row the first PR of the next
+// tier is moved to the front instead, and so on down the tiers.
+// sq.Lock() must be held.
+func (sq *SubmitQueue) applyFairness(prs []*github.MungeObject) []*github.MungeObject {
+	if len(sq.fairnessQuotas) == 0 || len(prs) == 0 {
+		return prs
+	}
+
+	// index of the first PR of each tier, highest priority first
+	firsts := []int{0}
+	for i := 1; i < len(prs); i++ {
+		if sq.effectivePriority(prs[i]) != sq.effectivePriority(prs[i-1]) {
+			firsts = append(firsts, i)
+		}
+	}
+
+	chosen := 0
+	for t := 0; t < len(firsts)-1; t++ {
+		prio := sq.effectivePriority(prs[firsts[t]])
+		quota, ok := sq.fairnessQuotas[prio]
+		if !ok || sq.mergeStreaks[prio] < quota {
+			break
+		}
+		chosen = firsts[t+1]
+	}
+	if chosen == 0 {
+		return prs
+	}
+
+	out := make([]*github.MungeObject, 0, len(prs))
+	out = append(out, prs[chosen])
+	out = append(out, prs[:chosen]...)
+	out = append(out, prs[chosen+1:]...)
+	return out
+}
+
+// recordMergePriority counts a merge of a PR with the given priority. Every
+// higher priority tier's streak ends since a lower priority PR got through.
+// sq.Lock() must be held.
+func (sq *SubmitQueue) recordMergePriority(prio int) {
+	if len(sq.fairnessQuotas) == 0 {
+		return
+	}
+	if sq.mergeStreaks == nil {
+		sq.mergeStreaks = map[int]int{}
+	}
+	for p := range sq.mergeStreaks {
+		if p < prio {
+			sq.mergeStreaks[p] = 0
+		}
+	}
+	sq.mergeStreaks[prio]++
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestParseFairnessQuotas(t *testing.T) {
+	quotas, err := parseFairnessQuotas([]string{"P0=5", "p1=2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := map[int]int{0: 5, 1: 2}; !reflect.DeepEqual(quotas, expected) {
+		t.Errorf("expected %v but got %v", expected, quotas)
+	}
+	for _, bad := range []string{"P0", "0=5", "Px=5", "P0=0", "P0=x"} {
+		if _, err := parseFairnessQuotas([]string{bad}); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+// simulateMerges merges the front of the queue n times. Before each merge a
+// fresh P0 PR, numbered from 100, arrives. It returns the PRs in the order
+// they merged.
+func simulateMerges(t *testing.T, sq *SubmitQueue, config *github_util.Config, mux *http.ServeMux, n int) []int {
+	merged := []int{}
+	for i := 0; i < n; i++ {
+		num := 100 + i
+		issue := github_test.Issue(someUserName, num, []string{"priority/P0"}, true)
+		github_test.ServeIssue(t, mux, issue)
+		obj, err := config.GetObject(num)
+		if err != nil {
+			t.Fatalf("unable to get issue %d: %v", num, err)
+		}
+		sq.githubE2EQueue[strconv.Itoa(num)] = obj
+
+		first := sq.orderedE2EQueue()[0]
+		sq.recordMergePriority(priority(sq.githubE2EQueue[first]))
+		merged = append(merged, *sq.githubE2EQueue[first].Issue.Number)
+		delete(sq.githubE2EQueue, first)
+	}
+	return merged
+}
+
+func TestFairnessQueueOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		quotas   map[int]int
+		expected []int
+	}{
+		{
+			name:     "strict priority starves P3",
+			expected: []int{1, 100, 101, 102, 103, 104},
+		},
+		{
+			name:     "P3 advances after 2 P0s",
+			quotas:   map[int]int{0: 2},
+			expected: []int{1, 100, 3, 101, 102, 103},
+		},
+	}
+	for _, test := range tests {
+		issueToEvents := map[int][]github_test.LabelTime{}
+		for _, num := range []int{1, 3, 100, 101, 102, 103, 104, 105} {
+			issueToEvents[num] = []github_test.LabelTime{{User: "me", Label: lgtmLabel, Time: int64(num)}}
+		}
+		client, server, mux := github_test.InitServer(t, nil, nil, github_test.MultiIssueEvents(issueToEvents, "labeled"), nil, nil, nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		sq.fairnessQuotas = test.quotas
+
+		for _, issue := range []*github.Issue{
+			github_test.Issue(someUserName, 1, []string{"priority/P0"}, true),
+			github_test.Issue(someUserName, 3, []string{"priority/P3"}, true),
+		} {
+			github_test.ServeIssue(t, mux, issue)
+			obj, err := config.GetObject(*issue.Number)
+			if err != nil {
+				t.Fatalf("%s: unable to get issue: %v", test.name, err)
+			}
+			sq.githubE2EQueue[strconv.Itoa(*issue.Number)] = obj
+		}
+
+		if merged := simulateMerges(t, sq, config, mux, len(test.expected)); !reflect.DeepEqual(merged, test.expected) {
+			t.Errorf("%s: expected merge order %v but got %v", test.name, test.expected, merged)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseJobWeights turns "job=5" style entries into a map from job name to
+// its weight in the health calculation.
+func parseJobWeights(specs []string) (map[string]float64, error) {
+	weights := map[string]float64{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid job weight %q, expected something like ci-kubernetes-e2e-gce=5", spec)
+		}
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid job weight %q, the weight must be a number which isn't negative", spec)
+		}
+		weights[parts[0]] = weight
+	}
+	return weights, nil
+}
+
+// jobWeight is how much job counts towards the health: its --job-weights
+// entry, or 1.
+func (sq *SubmitQueue) jobWeight(job string) float64 {
+	if weight, ok := sq.jobWeights[job]; ok {
+		return weight
+	}
+	return 1
+}
+
+// weightedStability is the weighted fraction of record's jobs which were
+// stable, so a heavily weighted job failing counts for more than several
+// light ones passing. A record without jobs is all or nothing.
+func (sq *SubmitQueue) weightedStability(record healthRecord) float64 {
+	var stable, total float64
+	for job, ok := range record.Jobs {
+		weight := sq.jobWeight(job)
+		total += weight
+		if ok {
+			stable += weight
+		}
+	}
+	if total == 0 {
+		if record.Overall {
+			return 1
+		}
+		return 0
+	}
+	return stable / total
+}
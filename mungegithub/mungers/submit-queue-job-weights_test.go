@@ -0,0 +1,67 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHealthJobWeights(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	weights, err := parseJobWeights([]string{"gce=10", "flaky=0.5"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sq.jobWeights = weights
+
+	now := time.Now()
+	sq.healthHistory = []healthRecord{
+		// The heavily weighted job failing dominates: 1.5 / 11.5
+		{Time: now, Overall: false, Jobs: map[string]bool{"gce": false, "flaky": true, "other": true}},
+		// While the light one failing barely matters: 11 / 11.5
+		{Time: now, Overall: false, Jobs: map[string]bool{"gce": true, "flaky": false, "other": true}},
+		{Time: now, Overall: true, Jobs: map[string]bool{"gce": true, "flaky": true, "other": true}},
+		{Time: now, Overall: true},
+	}
+	sq.updateHealth()
+
+	if sq.health.NumStable != 3 {
+		t.Errorf("expected weights not to change NumStable, got %d", sq.health.NumStable)
+	}
+	// updateHealth records one more, stable, loop of its own
+	expected := (1.5/11.5 + 11/11.5 + 1 + 1 + 1) / 5
+	if math.Abs(sq.health.WeightedStable-expected) > 1e-9 {
+		t.Errorf("expected WeightedStable %v, got %v", expected, sq.health.WeightedStable)
+	}
+
+	// Equal weights are just the fraction of the jobs which were stable
+	sq.jobWeights = map[string]float64{}
+	sq.healthHistory = sq.healthHistory[:2]
+	sq.updateHealth()
+	expected = (2.0/3 + 2.0/3 + 1) / 3
+	if math.Abs(sq.health.WeightedStable-expected) > 1e-9 {
+		t.Errorf("expected unweighted WeightedStable %v, got %v", expected, sq.health.WeightedStable)
+	}
+
+	for _, bad := range []string{"gce", "=5", "gce=x", "gce=-1"} {
+		if _, err := parseJobWeights([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
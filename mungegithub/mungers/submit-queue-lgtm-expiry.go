@@ -0,0 +1,79 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+const (
+	lgtmExpiryComment = "comment"
+	lgtmExpiryRemove  = "remove"
+
+	lgtmExpiredFmt        = "@%s this PR has had the %s label for %v without being able to merge."
+	lgtmExpiredRemovedFmt = lgtmExpiredFmt + " The label has been removed, so it will need another review once it is fixed."
+)
+
+// expireLGTM acts on obj, which can't be merged, if it has had the lgtm
+// label for longer than MaxLGTMAge: it comments, once for each lgtm, and
+// with the LGTMExpiryAction "remove" also takes the label away.
+func (sq *SubmitQueue) expireLGTM(obj *github.MungeObject) {
+	if sq.MaxLGTMAge <= 0 || !obj.HasLabel(sq.LGTMLabel) {
+		return
+	}
+	labeled, ok := obj.LabelTime(sq.LGTMLabel)
+	if !ok || labeled == nil {
+		return
+	}
+	age := sq.clock.Since(*labeled)
+	if age <= sq.MaxLGTMAge {
+		return
+	}
+
+	key := sq.prKey(obj)
+	sq.Lock()
+	if notified, found := sq.lgtmExpired[key]; found && notified.Equal(*labeled) {
+		sq.Unlock()
+		return
+	}
+	if sq.lgtmExpired == nil {
+		sq.lgtmExpired = map[string]time.Time{}
+	}
+	sq.lgtmExpired[key] = *labeled
+	sq.Unlock()
+
+	author := "UNKNOWN"
+	if obj.Issue.User != nil && obj.Issue.User.Login != nil {
+		author = *obj.Issue.User.Login
+	}
+	age = age - age%time.Minute
+	obj.Log().Infof("lgtm has been on the PR for %v without a merge", age)
+	format := lgtmExpiredFmt
+	if sq.LGTMExpiryAction == lgtmExpiryRemove {
+		format = lgtmExpiredRemovedFmt
+		if err := obj.RemoveLabel(sq.LGTMLabel); err != nil {
+			obj.Log().Errorf("unable to remove the %s label: %v", sq.LGTMLabel, err)
+			return
+		}
+	}
+	if err := obj.WriteComment(fmt.Sprintf(format, author, sq.LGTMLabel, age)); err != nil {
+		obj.Log().Errorf("unable to comment about the %s label: %v", sq.LGTMLabel, err)
+	}
+}
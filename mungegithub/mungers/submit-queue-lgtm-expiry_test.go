@@ -0,0 +1,99 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+
+	"github.com/google/go-github/github"
+)
+
+func TestExpireLGTM(t *testing.T) {
+	// NewLGTMEvents last adds lgtm at time.Unix(12, 0)
+	labeled := time.Unix(12, 0)
+	tests := []struct {
+		name          string
+		action        string
+		expectRemoved bool
+	}{
+		{name: "comment", action: lgtmExpiryComment},
+		{name: "remove", action: lgtmExpiryRemove, expectRemoved: true},
+	}
+	for _, test := range tests {
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		comments := []string{}
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			comment := &github.IssueComment{}
+			if err := json.NewDecoder(r.Body).Decode(comment); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			comments = append(comments, *comment.Body)
+			data, _ := json.Marshal(comment)
+			w.Write(data)
+		})
+		removed := false
+		mux.HandleFunc("/repos/o/r/issues/1/labels/"+lgtmLabel, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "DELETE" {
+				t.Errorf("Unexpected method: %s", r.Method)
+			}
+			removed = true
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.MaxLGTMAge = 3 * time.Hour
+		sq.LGTMExpiryAction = test.action
+		clock := sq.clock.(*utilclock.FakeClock)
+
+		clock.SetTime(labeled.Add(2 * time.Hour))
+		sq.expireLGTM(github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents()))
+		if len(comments) != 0 || removed {
+			t.Errorf("%s: expected nothing before the threshold, saw comments %v and removed=%v", test.name, comments, removed)
+		}
+
+		clock.SetTime(labeled.Add(4 * time.Hour))
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		sq.expireLGTM(obj)
+		if removed != test.expectRemoved {
+			t.Errorf("%s: expected removed=%v, saw %v", test.name, test.expectRemoved, removed)
+		}
+		if test.expectRemoved && obj.HasLabel(lgtmLabel) {
+			t.Errorf("%s: expected the PR to lose the %s label", test.name, lgtmLabel)
+		}
+		if len(comments) != 1 || !strings.Contains(comments[0], "4h0m0s") {
+			t.Errorf("%s: expected one comment about the lgtm's age, saw: %v", test.name, comments)
+		}
+
+		// The same lgtm is only acted on once
+		sq.expireLGTM(github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents()))
+		if len(comments) != 1 {
+			t.Errorf("%s: expected no more comments for the same lgtm, saw: %v", test.name, comments)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// lgtmHeadChanged returns true if the PR was force-pushed after the current
+// lgtm label was added. Commit dates are chosen by whoever pushes, so a
+// force-push can keep an old date to get past ModifiedAfterLabeled; github's
+// own record of the push can't be faked.
+func (sq *SubmitQueue) lgtmHeadChanged(obj *github.MungeObject) (bool, bool) {
+	labeled, ok := obj.LabelTime(sq.LGTMLabel)
+	if !ok || labeled == nil {
+		return false, false
+	}
+	return obj.ForcePushedAfter(*labeled)
+}
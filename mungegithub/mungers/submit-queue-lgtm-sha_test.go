@@ -0,0 +1,86 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestLGTMHeadChanged(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	forcePushed := func(at int64) *github.IssueEvent {
+		return &github.IssueEvent{
+			Event:     stringPtr("head_ref_force_pushed"),
+			CreatedAt: timePtr(time.Unix(at, 0)),
+			Actor:     &github.User{Login: stringPtr(someUserName)},
+		}
+	}
+	// The lgtm label is last added at 12, after the commits at 7 to 9
+	tests := []struct {
+		name   string
+		events []*github.IssueEvent
+		valid  bool
+	}{
+		{
+			name:   "never force-pushed",
+			events: NewLGTMEvents(),
+			valid:  true,
+		},
+		{
+			name:   "force-pushed before the lgtm",
+			events: append(NewLGTMEvents(), forcePushed(11)),
+			valid:  true,
+		},
+		{
+			name:   "force-pushed after the lgtm, keeping the commit dates",
+			events: append(NewLGTMEvents(), forcePushed(13)),
+		},
+		{
+			name: "lgtm'd again after the force-push",
+			events: append(github_test.Events([]github_test.LabelTime{
+				{User: "bob", Label: approvedLabel, Time: 20},
+				{User: "bob", Label: lgtmLabel, Time: 30},
+			}), forcePushed(13)),
+			valid: true,
+		},
+	}
+	for _, test := range tests {
+		client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), test.events, Commits(), SuccessStatus(), nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), test.events)
+		if valid := sq.validForMergeExt(obj, false); valid != test.valid {
+			t.Errorf("%s: expected valid=%v but got %v (%q)", test.name, test.valid, valid, sq.prStatus["1"].Reason)
+		}
+		if r, expected := sq.prStatus["1"].Reason, fmt.Sprintf(lgtmHeadChangedFmt, lgtmLabel); !test.valid && r != expected {
+			t.Errorf("%s: expected reason %q but got %q", test.name, expected, r)
+		}
+		server.Close()
+	}
+}
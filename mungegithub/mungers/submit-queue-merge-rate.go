@@ -0,0 +1,70 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// mergeRateInfo is served at /merge-rate to help check the ETAs. MergeRate
+// is the smoothed rate as of the last merge, MergeRateWithTail is what the
+// ETAs use: MergeRate pulled down if a merge is overdue. Both are merges
+// per 24 hours.
+type mergeRateInfo struct {
+	MergeRate         float64
+	MergeRateWithTail float64
+	LastMergeTime     time.Time
+	Time              time.Time
+}
+
+// getMergeRateInfo must be called with sq.Lock() held.
+func (sq *SubmitQueue) getMergeRateInfo() mergeRateInfo {
+	return mergeRateInfo{
+		MergeRate:         sq.mergeRate,
+		MergeRateWithTail: sq.calcMergeRateWithTail(),
+		LastMergeTime:     sq.lastMergeTime,
+		Time:              sq.clock.Now(),
+	}
+}
+
+// recalculateMergeRate recomputes the tail adjusted rate now, rather than
+// waiting for the next loop, and records it in the merge rate history. It
+// doesn't count as a merge, so the raw rate is only re-clamped.
+func (sq *SubmitQueue) recalculateMergeRate() mergeRateInfo {
+	sq.Lock()
+	defer sq.Unlock()
+	sq.mergeRate = sq.clampMergeRate(sq.mergeRate)
+	sq.recordMergeRate()
+	return sq.getMergeRateInfo()
+}
+
+func (sq *SubmitQueue) serveMergeRate(res http.ResponseWriter, req *http.Request) {
+	sq.Lock()
+	data := sq.marshal(sq.getMergeRateInfo())
+	sq.Unlock()
+	sq.serve(data, res, req)
+}
+
+// MergeRateRecalculateHTTP is the admin api to recompute the merge rate.
+func (sq *SubmitQueue) MergeRateRecalculateHTTP(res http.ResponseWriter, req *http.Request) {
+	info := sq.recalculateMergeRate()
+	glog.Infof("Recalculated the merge rate: %v, %v with the tail", info.MergeRate, info.MergeRateWithTail)
+	sq.serve(sq.marshal(info), res, req)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+)
+
+func TestServeMergeRate(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	clock := sq.clock.(*utilclock.FakeClock)
+	clock.SetTime(time.Unix(0, 0))
+	sq.mergeRate = 24
+	sq.lastMergeTime = clock.Now()
+
+	get := func(handler http.HandlerFunc, path string) mergeRateInfo {
+		res := httptest.NewRecorder()
+		handler(res, httptest.NewRequest("GET", path, nil))
+		if res.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 but got %d", path, res.Code)
+		}
+		info := mergeRateInfo{}
+		if err := json.Unmarshal(res.Body.Bytes(), &info); err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		return info
+	}
+
+	info := get(sq.serveMergeRate, "/merge-rate")
+	if info.MergeRate != sq.mergeRate || info.MergeRateWithTail != sq.mergeRate {
+		t.Errorf("expected both rates to be %v before a merge is due, got %+v", sq.mergeRate, info)
+	}
+
+	// A merge is expected every hour, so after 3 the tail pulls the rate down
+	clock.Step(3 * time.Hour)
+	info = get(sq.MergeRateRecalculateHTTP, "/api/merge-rate/recalculate")
+	if info.MergeRate != sq.mergeRate {
+		t.Errorf("expected the raw rate %v, got %v", sq.mergeRate, info.MergeRate)
+	}
+	if expected := calcMergeRate(24, time.Unix(0, 0), clock.Now()); info.MergeRateWithTail != expected || expected >= 24 {
+		t.Errorf("expected the rate with the tail to be %v, got %v", expected, info.MergeRateWithTail)
+	}
+	if !info.LastMergeTime.Equal(time.Unix(0, 0)) {
+		t.Errorf("expected recalculating not to count as a merge, last merge %v", info.LastMergeTime)
+	}
+	history := sq.getMergeRateHistory()
+	if len(history) != 1 || history[0].Rate != info.MergeRateWithTail {
+		t.Errorf("expected the recalculated rate to be recorded, got %v", history)
+	}
+}
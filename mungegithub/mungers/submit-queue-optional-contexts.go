@@ -0,0 +1,44 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"k8s.io/contrib/mungegithub/github"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// withoutUnreported returns the contexts less the OptionalUntilReported ones
+// which nothing has reported on the PR's head commit yet. Unlike
+// MissingContextTimeout, which calls such a context out as missing, this
+// lets the PR through.
+func (sq *SubmitQueue) withoutUnreported(obj *github.MungeObject, contexts []string) []string {
+	if len(sq.OptionalUntilReported) == 0 {
+		return contexts
+	}
+	optional := sets.NewString(sq.OptionalUntilReported...)
+	remaining := []string{}
+	for _, context := range contexts {
+		if optional.Has(context) {
+			if reported, ok := sq.statusBackend().Reported(obj, context); ok && !reported {
+				obj.Log().V(4).Infof("not requiring %q until it reports", context)
+				continue
+			}
+		}
+		remaining = append(remaining, context)
+	}
+	return remaining
+}
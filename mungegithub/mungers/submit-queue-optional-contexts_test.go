@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestOptionalUntilReported(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        *github.CombinedStatus
+		optional      []string
+		expectSuccess bool
+	}{
+		{
+			name:          "never reported blocks by default",
+			status:        github_test.Status("mysha", []string{notRequiredReTestContext1}, nil, nil, nil),
+			expectSuccess: false,
+		},
+		{
+			name:          "never reported doesn't block when optional until reported",
+			status:        github_test.Status("mysha", []string{notRequiredReTestContext1}, nil, nil, nil),
+			optional:      []string{notRequiredReTestContext2},
+			expectSuccess: true,
+		},
+		{
+			name:          "blocks once it has failed",
+			status:        github_test.Status("mysha", []string{notRequiredReTestContext1}, []string{notRequiredReTestContext2}, nil, nil),
+			optional:      []string{notRequiredReTestContext2},
+			expectSuccess: false,
+		},
+		{
+			name:          "blocks while pending",
+			status:        github_test.Status("mysha", []string{notRequiredReTestContext1}, nil, []string{notRequiredReTestContext2}, nil),
+			optional:      []string{notRequiredReTestContext2},
+			expectSuccess: false,
+		},
+		{
+			name:          "other contexts still block",
+			status:        github_test.Status("mysha", []string{notRequiredReTestContext2}, nil, nil, nil),
+			optional:      []string{notRequiredReTestContext2},
+			expectSuccess: false,
+		},
+	}
+	for _, test := range tests {
+		client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), test.status, nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.OptionalUntilReported = test.optional
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		success, ok := sq.contextsSucceeded(obj, sq.RequiredStatusContexts)
+		server.Close()
+		if !ok || success != test.expectSuccess {
+			t.Errorf("%s: expected success=%v but got %v (ok=%v)", test.name, test.expectSuccess, success, ok)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+
+	"k8s.io/contrib/mungegithub/github"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+const overrideCommand = "OVERRIDE"
+
+// contextOverrides are the required contexts treated as passing for one
+// commit of a PR, and who asked for each.
+type contextOverrides struct {
+	sha      string
+	contexts map[string]string
+}
+
+// overrideCommand handles "@bot override <context>", which treats the
+// context as passing until the PR is pushed to.
+func (sq *SubmitQueue) overrideCommand(obj *github.MungeObject, user string, cmd *c.Command) string {
+	context := cmd.Arguments
+	required := sets.NewString(sq.RequiredStatusContexts...)
+	required.Insert(sq.retestContexts(obj)...)
+	if !required.Has(context) {
+		return fmt.Sprintf("@%s %q is not a context this PR needs, so there is nothing to override.", user, context)
+	}
+	sha, _, ok := obj.GetHeadAndBase()
+	if !ok {
+		return fmt.Sprintf("@%s unable to find the head of this PR, please try again later.", user)
+	}
+
+	sq.overrideContext(obj, sha, context, user)
+	return fmt.Sprintf("%q will be treated as passing for %s at the request of @%s. It must pass again after the next push.", context, sha, user)
+}
+
+// overrideContext treats context as passing for obj's commit sha, crediting
+// the override to by.
+func (sq *SubmitQueue) overrideContext(obj *github.MungeObject, sha, context, by string) {
+	key := sq.prKey(obj)
+	sq.Lock()
+	if sq.overrides == nil {
+		sq.overrides = map[string]*contextOverrides{}
+	}
+	overrides, ok := sq.overrides[key]
+	if !ok || overrides.sha != sha {
+		overrides = &contextOverrides{sha: sha, contexts: map[string]string{}}
+		sq.overrides[key] = overrides
+	}
+	overrides.contexts[context] = by
+	sq.Unlock()
+
+	obj.Log().Infof("%s overrode %q for %s", by, context, sha)
+}
+
+// withoutOverrides returns the contexts which have not been overridden for
+// the PR's head commit. Overrides for older commits are forgotten.
+func (sq *SubmitQueue) withoutOverrides(obj *github.MungeObject, contexts []string) []string {
+	key := sq.prKey(obj)
+	sha, _, gotSHA := obj.GetHeadAndBase()
+
+	sq.Lock()
+	overrides, ok := sq.overrides[key]
+	if ok && (!gotSHA || sha != overrides.sha) {
+		obj.Log().Infof("dropping the overrides for %s since the PR has changed", overrides.sha)
+		delete(sq.overrides, key)
+		ok = false
+	}
+	overridden := map[string]string{}
+	if ok {
+		for context, user := range overrides.contexts {
+			overridden[context] = user
+		}
+	}
+	sq.Unlock()
+	if len(overridden) == 0 {
+		return contexts
+	}
+
+	remaining := []string{}
+	for _, context := range contexts {
+		if user, ok := overridden[context]; ok {
+			obj.Log().V(4).Infof("treating %q as passing, overridden by %s", context, user)
+			continue
+		}
+		remaining = append(remaining, context)
+	}
+	return remaining
+}
+
+// contextsSucceeded is StatusBackend.IsSuccess, except that overridden
+// contexts, and OptionalUntilReported ones which haven't reported, always
+// pass.
+func (sq *SubmitQueue) contextsSucceeded(obj *github.MungeObject, contexts []string) (bool, bool) {
+	contexts = sq.withoutUnreported(obj, sq.withoutOverrides(obj, contexts))
+	if len(contexts) == 0 {
+		return true, true
+	}
+	return sq.statusBackend().IsSuccess(obj, contexts)
+}
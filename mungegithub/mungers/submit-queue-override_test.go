@@ -0,0 +1,83 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+)
+
+func TestOverrideCommand(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), RetestFailStatus(), nil, nil)
+	defer server.Close()
+	mux.HandleFunc("/repos/o/r/commits/pushedsha/status", func(w http.ResponseWriter, r *http.Request) {
+		status := RetestFailStatus()
+		status.SHA = stringPtr("pushedsha")
+		data, _ := json.Marshal(status)
+		w.Write(data)
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	sq.githubConfig = config
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	override := func(context string) string {
+		return sq.overrideCommand(obj, "alice", &c.Command{Name: overrideCommand, Arguments: context})
+	}
+
+	if !sqCommands[overrideCommand].authorized {
+		t.Errorf("expected override to need authorization")
+	}
+	if sq.validForMerge(obj) {
+		t.Fatalf("expected the failing %s to block the PR", requiredReTestContext2)
+	}
+
+	if reply := override("not-a-context"); !strings.HasPrefix(reply, `@alice "not-a-context" is not a context`) {
+		t.Errorf("unexpected reply %q", reply)
+	}
+	if reply := override(requiredReTestContext2); !strings.Contains(reply, "passing for mysha at the request of @alice") {
+		t.Errorf("unexpected reply %q", reply)
+	}
+	if !sq.validForMerge(obj) {
+		t.Errorf("expected the override to let the PR merge, got %q", sq.prStatus["1"].Reason)
+	}
+	if contexts := sq.withoutOverrides(obj, sq.RequiredRetestContexts); len(contexts) != 1 || contexts[0] != requiredReTestContext1 {
+		t.Errorf("expected only %s to still be retested but got %v", requiredReTestContext1, contexts)
+	}
+
+	// A push makes the context count again, and forgets the override
+	pushed := ValidPR()
+	pushed.Head.SHA = stringPtr("pushedsha")
+	obj = github_util.TestObject(config, LGTMApprovedIssue(), pushed, Commits(), NewLGTMEvents())
+	if sq.validForMerge(obj) {
+		t.Errorf("expected the override not to apply after a push")
+	}
+	if _, ok := sq.overrides["1"]; ok {
+		t.Errorf("expected the override to be dropped but got %v", sq.overrides["1"])
+	}
+}
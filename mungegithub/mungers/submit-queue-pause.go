@@ -0,0 +1,105 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"net/http"
+
+	"k8s.io/contrib/mungegithub/github"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+
+	"github.com/golang/glog"
+)
+
+const (
+	pauseCommand  = "PAUSE"
+	resumeCommand = "RESUME"
+
+	queuePaused = "The queue is paused. Nothing will be merged until it is resumed."
+)
+
+func (sq *SubmitQueue) isPaused() bool {
+	sq.Lock()
+	defer sq.Unlock()
+	return sq.paused
+}
+
+// setPaused pauses or resumes the queue on behalf of user.
+func (sq *SubmitQueue) setPaused(paused bool, user string) {
+	sq.Lock()
+	defer sq.Unlock()
+	sq.paused = paused
+	sq.pausedBy = ""
+	if paused {
+		sq.pausedBy = user
+	}
+	glog.Infof("AUDIT: queue paused=%v by %s", paused, user)
+}
+
+// pauseCommand handles "@bot pause" and "@bot resume", a comment on any PR
+// pausing or resuming the whole queue.
+func (sq *SubmitQueue) pauseCommand(obj *github.MungeObject, user string, cmd *c.Command) string {
+	if cmd.Name == resumeCommand {
+		sq.setPaused(false, user)
+		return fmt.Sprintf("Resumed the queue at the request of @%s.", user)
+	}
+	sq.setPaused(true, user)
+	return fmt.Sprintf("Paused the queue at the request of @%s. Nothing will merge until someone says `@%s resume`.", user, botName)
+}
+
+// reportPaused keeps the status of obj, which is queued, in step with the
+// pause. The PR being tested reports its own status.
+func (sq *SubmitQueue) reportPaused(obj *github.MungeObject) {
+	sq.Lock()
+	paused := sq.paused
+	waiting := sq.onQueue(obj) && !sq.runningLocked(obj)
+	sq.Unlock()
+	if !waiting {
+		return
+	}
+	if paused {
+		sq.SetMergeStatus(obj, queuePaused)
+		return
+	}
+	if description, ok := sq.statusBackend().Description(obj); ok && description == queuePaused {
+		sq.SetMergeStatus(obj, ghE2EQueued)
+	}
+}
+
+// PauseHTTP pauses the queue for "/api/pause" and resumes it for
+// "/api/resume". Either way, and for "/api/paused", it says whether the
+// queue is paused.
+func (sq *SubmitQueue) PauseHTTP(res http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/api/pause":
+		sq.setPaused(true, "admin")
+	case "/api/resume":
+		sq.setPaused(false, "admin")
+	case "/api/paused":
+	default:
+		http.NotFound(res, req)
+		return
+	}
+	sq.Lock()
+	data := sq.marshal(struct {
+		Paused   bool
+		PausedBy string `json:",omitempty"`
+	}{sq.paused, sq.pausedBy})
+	sq.Unlock()
+	sq.serve(data, res, req)
+}
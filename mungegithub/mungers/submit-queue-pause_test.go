@@ -0,0 +1,126 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+
+	"github.com/google/go-github/github"
+)
+
+// lastStatusBackend reports the last description it was asked to set.
+type lastStatusBackend struct {
+	recordingBackend
+}
+
+func (b *lastStatusBackend) Description(obj *github_util.MungeObject) (string, bool) {
+	if len(b.descriptions) == 0 {
+		return "", false
+	}
+	return b.descriptions[len(b.descriptions)-1], true
+}
+
+func TestPauseHTTP(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	for _, test := range []struct {
+		path   string
+		paused bool
+	}{
+		{"/api/paused", false},
+		{"/api/pause", true},
+		{"/api/paused", true},
+		{"/api/resume", false},
+	} {
+		req, err := http.NewRequest("POST", test.path, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		res := httptest.NewRecorder()
+		sq.PauseHTTP(res, req)
+		out := struct{ Paused bool }{}
+		if err := json.Unmarshal(res.Body.Bytes(), &out); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.path, err)
+		}
+		if out.Paused != test.paused || sq.isPaused() != test.paused {
+			t.Errorf("%s: expected paused=%v but got %v", test.path, test.paused, out.Paused)
+		}
+	}
+}
+
+func TestPauseBlocksMerges(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), MasterCommit(), nil)
+	defer server.Close()
+	merges := 0
+	mux.HandleFunc("/repos/o/r/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+		merges++
+		data, _ := json.Marshal(github.PullRequestMergeResult{})
+		w.Write(data)
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	backend := &lastStatusBackend{}
+	sq.backend = backend
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	other := github_util.TestObject(config, github_test.Issue(someUserName, 2, nil, true), ValidPR(), Commits(), NewLGTMEvents())
+	sq.enqueue(obj)
+	sq.enqueue(other)
+
+	sq.pauseCommand(obj, "admin", &c.Command{Name: pauseCommand})
+	sq.reportPaused(other)
+	if reason := sq.prStatus["2"].Reason; reason != queuePaused || !sq.onQueue(other) {
+		t.Errorf("expected a queued PR to stay queued as %q but got %q", queuePaused, reason)
+	}
+
+	// The run for obj finished after the pause
+	sq.githubE2ERunning = obj
+	sq.interruptedObj = newInterruptedObject(obj)
+	if sq.doGithubE2EAndMerge(sq.selectPullRequest()) {
+		t.Errorf("expected the tested PR to be kept on the queue")
+	}
+	if merges != 0 || sq.prStatus["1"].Reason != queuePaused {
+		t.Errorf("expected no merge and %q but got %d merges and %q", queuePaused, merges, sq.prStatus["1"].Reason)
+	}
+	if sq.interruptedObj == nil {
+		t.Errorf("expected the result of the run to be kept for the resume")
+	}
+
+	sq.pauseCommand(obj, "admin", &c.Command{Name: resumeCommand})
+	sq.reportPaused(other)
+	if reason := sq.prStatus["2"].Reason; reason != ghE2EQueued {
+		t.Errorf("expected %q after the resume but got %q", ghE2EQueued, reason)
+	}
+	if !sq.doGithubE2EAndMerge(sq.selectPullRequest()) || merges != 1 {
+		t.Errorf("expected the tested PR to merge without another run after the resume, got %d merges", merges)
+	}
+	// Both passes reused the finished run
+	if sq.retestsAvoided != 2 {
+		t.Errorf("expected 2 retests to be avoided, got %d", sq.retestsAvoided)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+)
+
+const (
+	priorityCommand = "PRIORITY"
+
+	priorityLabelPrefix = "priority/"
+)
+
+// Same as the labels obj.Priority() reads
+var priorityLabelRE = regexp.MustCompile(`^priority/[pP]\d+$`)
+
+// parsePriority turns "P1" or "p1" into 1. Only the tiers the queue sorts
+// by, P0 through the priority of unlabeled PRs, are allowed.
+func parsePriority(arg string) (int, bool) {
+	if len(arg) < 2 || (arg[0] != 'P' && arg[0] != 'p') {
+		return 0, false
+	}
+	prio, err := strconv.Atoi(arg[1:])
+	if err != nil || prio < 0 || prio > defaultMergePriority {
+		return 0, false
+	}
+	return prio, true
+}
+
+// priorityCommand handles "@bot priority P1", which gives the PR the
+// priority/P1 label in place of any other priority label.
+func (sq *SubmitQueue) priorityCommand(obj *github.MungeObject, user string, cmd *c.Command) string {
+	prio, ok := parsePriority(cmd.Arguments)
+	if !ok {
+		return fmt.Sprintf("@%s %q is not a priority. Use one of P0 through P%d, like `@%s priority P1`.", user, cmd.Arguments, defaultMergePriority, botName)
+	}
+	want := fmt.Sprintf("%sP%d", priorityLabelPrefix, prio)
+
+	has := false
+	for _, label := range github.GetLabelsWithPrefix(obj.Issue.Labels, priorityLabelPrefix) {
+		if label == want {
+			has = true
+			continue
+		}
+		if !priorityLabelRE.MatchString(label) {
+			continue
+		}
+		if err := obj.RemoveLabel(label); err != nil {
+			return fmt.Sprintf("@%s unable to remove the %s label, please try again later.", user, label)
+		}
+	}
+	if !has {
+		if err := obj.AddLabel(want); err != nil {
+			return fmt.Sprintf("@%s unable to add the %s label, please try again later.", user, want)
+		}
+	}
+	obj.Log().Infof("%s set the priority to P%d", user, prio)
+	return fmt.Sprintf("Set the priority to %s at the request of @%s.", strings.TrimPrefix(want, priorityLabelPrefix), user)
+}
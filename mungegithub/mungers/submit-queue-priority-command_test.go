@@ -0,0 +1,170 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestPriorityCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		user     string
+		labels   []string
+		expected []string
+		reply    string
+		priority int
+		added    []string
+		removed  []string
+	}{
+		{
+			name:     "set a priority",
+			body:     "priority P1",
+			user:     "alice",
+			labels:   []string{lgtmLabel},
+			expected: []string{lgtmLabel, "priority/P1"},
+			reply:    "Set the priority to P1 at the request of @alice.",
+			priority: 1,
+			added:    []string{"priority/P1"},
+			removed:  []string{},
+		},
+		{
+			name:     "replaces the other priority labels",
+			body:     "priority p0",
+			user:     "alice",
+			labels:   []string{"priority/P2", "priority/p3", "priority/awaiting-more-evidence"},
+			expected: []string{"priority/P0", "priority/awaiting-more-evidence"},
+			reply:    "Set the priority to P0",
+			priority: 0,
+			added:    []string{"priority/P0"},
+			removed:  []string{"priority/P2", "priority/p3"},
+		},
+		{
+			name:     "already has it",
+			body:     "priority P2",
+			user:     "alice",
+			labels:   []string{"priority/P2"},
+			expected: []string{"priority/P2"},
+			reply:    "Set the priority to P2",
+			priority: 2,
+			added:    []string{},
+			removed:  []string{},
+		},
+		{
+			name:     "not a priority the queue uses",
+			body:     "priority P7",
+			user:     "alice",
+			labels:   []string{"priority/P2"},
+			expected: []string{"priority/P2"},
+			reply:    `@alice "P7" is not a priority.`,
+			priority: 2,
+			added:    []string{},
+			removed:  []string{},
+		},
+		{
+			name:     "garbage",
+			body:     "priority urgent",
+			user:     "alice",
+			expected: []string{},
+			reply:    `@alice "urgent" is not a priority.`,
+			priority: defaultMergePriority,
+			added:    []string{},
+			removed:  []string{},
+		},
+		{
+			name:     "unauthorized",
+			body:     "priority P0",
+			user:     "mallory",
+			labels:   []string{"priority/P2"},
+			expected: []string{"priority/P2"},
+			reply:    "@mallory you are not authorized to use `priority`.",
+			priority: 2,
+			added:    []string{},
+			removed:  []string{},
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(someUserName, 1, test.labels, true)
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), nil, nil, nil, nil, nil)
+		replies := []string{}
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				c := new(github.IssueComment)
+				json.NewDecoder(r.Body).Decode(c)
+				replies = append(replies, *c.Body)
+				data, _ := json.Marshal(c)
+				w.Write(data)
+				return
+			}
+			data, _ := json.Marshal([]*github.IssueComment{github_test.IssueComment(1, "@"+botName+" "+test.body, test.user, 10)})
+			w.Write(data)
+		})
+		// What github is told, rather than the local copy of the labels
+		added, removed := []string{}, []string{}
+		mux.HandleFunc("/repos/o/r/issues/1/labels", func(w http.ResponseWriter, r *http.Request) {
+			labels := []string{}
+			json.NewDecoder(r.Body).Decode(&labels)
+			added = append(added, labels...)
+			data, _ := json.Marshal([]github.Label{})
+			w.Write(data)
+		})
+		mux.HandleFunc("/repos/o/r/issues/1/labels/", func(w http.ResponseWriter, r *http.Request) {
+			removed = append(removed, strings.TrimPrefix(r.URL.Path, "/repos/o/r/issues/1/labels/"))
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		sq.githubConfig = config
+		sq.CommandWhitelist = []string{"alice"}
+		obj := github_util.TestObject(config, issue, ValidPR(), nil, nil)
+
+		sq.handleCommands(obj)
+
+		labels := []string{}
+		for _, l := range obj.Issue.Labels {
+			labels = append(labels, *l.Name)
+		}
+		sort.Strings(labels)
+		if !reflect.DeepEqual(labels, test.expected) {
+			t.Errorf("%s: expected labels %v but got %v", test.name, test.expected, labels)
+		}
+		if len(replies) != 1 || !strings.HasPrefix(replies[0], test.reply) {
+			t.Errorf("%s: expected a reply starting %q but got %q", test.name, test.reply, replies)
+		}
+		if prio := priority(obj); prio != test.priority {
+			t.Errorf("%s: expected the queue to see priority %d but got %d", test.name, test.priority, prio)
+		}
+		sort.Strings(removed)
+		if !reflect.DeepEqual(added, test.added) || !reflect.DeepEqual(removed, test.removed) {
+			t.Errorf("%s: expected to add %v and remove %v but added %v and removed %v", test.name, test.added, test.removed, added, removed)
+		}
+		server.Close()
+	}
+}
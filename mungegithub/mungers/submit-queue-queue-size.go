@@ -0,0 +1,51 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"sort"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+const queueFull = "The queue is full. The PR will be queued when there is room for it."
+
+// admitToQueue decides whether obj, which is not on the queue, may join it.
+// Once MaxQueueSize PRs are queued obj only gets in by taking the place of
+// the PR which would be tested last, if obj sorts ahead of it. The running
+// PR is never displaced. If a PR was displaced it is removed from the queue
+// and returned, so the caller can report it as queueFull; it will be tried
+// again on a later pass like obj would have been.
+// sq.Lock() must be held.
+func (sq *SubmitQueue) admitToQueue(obj *github.MungeObject) (bool, *github.MungeObject) {
+	if sq.MaxQueueSize <= 0 || len(sq.githubE2EQueue) < sq.MaxQueueSize {
+		return true, nil
+	}
+	prs := []*github.MungeObject{obj}
+	for _, queued := range sq.githubE2EQueue {
+		if !sq.runningLocked(queued) {
+			prs = append(prs, queued)
+		}
+	}
+	sort.Sort(queueSorter{prs, sq.lgtmTimeCache, sq.effectivePriority})
+	last := prs[len(prs)-1]
+	if last == obj {
+		return false, nil
+	}
+	sq.deleteQueueItem(last)
+	return true, last
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+)
+
+func TestMaxQueueSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		arrivals []int
+		running  int
+		queued   []string
+		full     []string
+	}{
+		{
+			name:     "lowest priority arrives first",
+			arrivals: []int{3, 1, 2},
+			queued:   []string{"1", "2"},
+			full:     []string{"3"},
+		},
+		{
+			name:     "lowest priority arrives last",
+			arrivals: []int{1, 2, 3},
+			queued:   []string{"1", "2"},
+			full:     []string{"3"},
+		},
+		{
+			name:     "the running PR isn't bumped",
+			arrivals: []int{3, 2, 1},
+			running:  3,
+			queued:   []string{"1", "3"},
+			full:     []string{"2"},
+		},
+	}
+	for _, test := range tests {
+		client, server, _ := github_test.InitServer(t, nil, nil, nil, nil, SuccessStatus(), nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.DryRun = true
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		sq.MaxQueueSize = 2
+
+		for _, num := range test.arrivals {
+			// PR n is priority/Pn
+			issue := github_test.Issue(someUserName, num, []string{claYesLabel, lgtmLabel, approvedLabel, "priority/P" + strconv.Itoa(num)}, true)
+			obj := github_util.TestObject(config, issue, ValidPR(), Commits(), NewLGTMEvents())
+			sq.enqueue(obj)
+			if num == test.running {
+				sq.githubE2ERunning = obj
+			}
+		}
+
+		queued := []string{}
+		for key := range sq.githubE2EQueue {
+			queued = append(queued, key)
+		}
+		sort.Strings(queued)
+		if !reflect.DeepEqual(queued, test.queued) {
+			t.Errorf("%s: expected %v to be queued but got %v", test.name, test.queued, queued)
+		}
+		for _, key := range test.full {
+			if reason := sq.prStatus[key].Reason; reason != queueFull {
+				t.Errorf("%s: expected #%s to be reported as %q but got %q", test.name, key, queueFull, reason)
+			}
+		}
+		server.Close()
+	}
+}
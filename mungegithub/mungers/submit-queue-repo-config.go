@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/util/yaml"
+
+	"github.com/golang/glog"
+)
+
+// repoQueueConfig is the --repo-config-file. Settings which are left out
+// keep the values of the matching flags. An empty list is a setting: an
+// empty requiredContexts means no contexts are required.
+type repoQueueConfig struct {
+	RequiredContexts       []string `json:"requiredContexts,omitempty" yaml:"requiredContexts,omitempty"`
+	RequiredRetestContexts []string `json:"requiredRetestContexts,omitempty" yaml:"requiredRetestContexts,omitempty"`
+	DoNotMergeMilestones   []string `json:"doNotMergeMilestones,omitempty" yaml:"doNotMergeMilestones,omitempty"`
+}
+
+// refreshRepoConfig reads the --repo-config-file from the repo's default
+// branch, if it is time to, and applies it. If the file is missing or
+// can't be decoded the flags' settings are put back.
+func (sq *SubmitQueue) refreshRepoConfig() {
+	if sq.RepoConfigFile == "" || sq.githubConfig == nil {
+		return
+	}
+	sq.Lock()
+	now := sq.clock.Now()
+	if !sq.repoConfigRead.IsZero() && now.Sub(sq.repoConfigRead) < sq.RepoConfigRefresh {
+		sq.Unlock()
+		return
+	}
+	sq.repoConfigRead = now
+	if sq.repoConfigFlags == nil {
+		sq.repoConfigFlags = &repoQueueConfig{
+			RequiredContexts:       sq.RequiredStatusContexts,
+			RequiredRetestContexts: sq.RequiredRetestContexts,
+			DoNotMergeMilestones:   sq.DoNotMergeMilestones,
+		}
+	}
+	flags := *sq.repoConfigFlags
+	sq.Unlock()
+
+	config, err := sq.loadRepoConfig(flags)
+	if err != nil {
+		glog.Infof("Using the flags' settings: %v", err)
+		config = flags
+	}
+
+	sq.Lock()
+	defer sq.Unlock()
+	sq.RequiredStatusContexts = config.RequiredContexts
+	sq.RequiredRetestContexts = config.RequiredRetestContexts
+	sq.DoNotMergeMilestones = config.DoNotMergeMilestones
+}
+
+// loadRepoConfig fetches and decodes the --repo-config-file, filling in
+// whatever it leaves out from flags.
+func (sq *SubmitQueue) loadRepoConfig(flags repoQueueConfig) (repoQueueConfig, error) {
+	contents, err := sq.githubConfig.GetFileContents(sq.RepoConfigFile, "")
+	if err != nil {
+		return repoQueueConfig{}, err
+	}
+	config := repoQueueConfig{}
+	if err := yaml.NewYAMLToJSONDecoder(strings.NewReader(contents)).Decode(&config); err != nil {
+		return repoQueueConfig{}, fmt.Errorf("unable to decode --repo-config-file %s: %v", sq.RepoConfigFile, err)
+	}
+	if config.RequiredContexts == nil {
+		config.RequiredContexts = flags.RequiredContexts
+	}
+	if config.RequiredRetestContexts == nil {
+		config.RequiredRetestContexts = flags.RequiredRetestContexts
+	}
+	if config.DoNotMergeMilestones == nil {
+		config.DoNotMergeMilestones = flags.DoNotMergeMilestones
+	}
+	return config, nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+)
+
+func TestRepoConfig(t *testing.T) {
+	client, server, mux := github_test.InitServer(t, nil, nil, nil, nil, nil, nil, nil)
+	defer server.Close()
+
+	// file is what the repo has, "" for no file at all
+	file := ""
+	fetches := 0
+	mux.HandleFunc("/repos/o/r/contents/.github/submit-queue.yaml", func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if file == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := json.Marshal(map[string]string{
+			"type":     "file",
+			"encoding": "base64",
+			"content":  base64.StdEncoding.EncodeToString([]byte(file)),
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		w.Write(data)
+	})
+
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+	sq := getTestSQ(false, config, server)
+	sq.githubConfig = config
+	clock := sq.clock.(*utilclock.FakeClock)
+	sq.RepoConfigFile = ".github/submit-queue.yaml"
+	sq.RepoConfigRefresh = 10 * time.Minute
+
+	flagContexts := sq.RequiredStatusContexts
+	flagRetest := sq.RequiredRetestContexts
+	flagMilestones := sq.DoNotMergeMilestones
+
+	tests := []struct {
+		name       string
+		file       string
+		contexts   []string
+		retest     []string
+		milestones []string
+	}{
+		{
+			name:       "no file",
+			contexts:   flagContexts,
+			retest:     flagRetest,
+			milestones: flagMilestones,
+		},
+		{
+			name:       "yaml",
+			file:       "requiredContexts:\n- repo-ctx\nrequiredRetestContexts: []\n",
+			contexts:   []string{"repo-ctx"},
+			retest:     []string{},
+			milestones: flagMilestones,
+		},
+		{
+			name:       "json",
+			file:       `{"requiredRetestContexts": ["repo-retest"], "doNotMergeMilestones": ["v2"]}`,
+			contexts:   flagContexts,
+			retest:     []string{"repo-retest"},
+			milestones: []string{"v2"},
+		},
+		{
+			name:       "invalid file",
+			file:       "requiredContexts: [",
+			contexts:   flagContexts,
+			retest:     flagRetest,
+			milestones: flagMilestones,
+		},
+	}
+	for _, test := range tests {
+		file = test.file
+		clock.Step(sq.RepoConfigRefresh)
+		sq.refreshRepoConfig()
+		if !reflect.DeepEqual(sq.RequiredStatusContexts, test.contexts) {
+			t.Errorf("%s: expected the required contexts %v, got %v", test.name, test.contexts, sq.RequiredStatusContexts)
+		}
+		if !reflect.DeepEqual(sq.RequiredRetestContexts, test.retest) {
+			t.Errorf("%s: expected the retest contexts %v, got %v", test.name, test.retest, sq.RequiredRetestContexts)
+		}
+		if !reflect.DeepEqual(sq.DoNotMergeMilestones, test.milestones) {
+			t.Errorf("%s: expected the milestones %v, got %v", test.name, test.milestones, sq.DoNotMergeMilestones)
+		}
+	}
+
+	// The file isn't read again until RepoConfigRefresh has passed
+	file = "requiredContexts: [later]"
+	before := fetches
+	sq.refreshRepoConfig()
+	if fetches != before || !reflect.DeepEqual(sq.RequiredStatusContexts, flagContexts) {
+		t.Errorf("expected the file not to be read again yet, got %d fetches and %v", fetches-before, sq.RequiredStatusContexts)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+)
+
+const (
+	allowRetestsCommand = "ALLOW-RETESTS"
+
+	retestLimitReached = "Reached the daily limit of github e2e retests."
+)
+
+// retestCount is how many github e2e retests the queue has asked for on a PR
+// since the last reset, and which admin, if any, lifted the limit until the
+// next one.
+type retestCount struct {
+	since     time.Time
+	count     int
+	allowedBy string
+	notified  bool
+}
+
+// retestWindowStart is the last RetestLimitResetHour, in UTC, at or before
+// now.
+func (sq *SubmitQueue) retestWindowStart(now time.Time) time.Time {
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), sq.RetestLimitResetHour, 0, 0, 0, time.UTC)
+	if start.After(now) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// retestCountLocked returns obj's count for the current day, starting a new
+// one if the reset boundary has passed. sq.Lock() must be held.
+func (sq *SubmitQueue) retestCountLocked(obj *github.MungeObject) *retestCount {
+	if sq.retestCounts == nil {
+		sq.retestCounts = map[string]*retestCount{}
+	}
+	since := sq.retestWindowStart(sq.clock.Now())
+	key := sq.prKey(obj)
+	count, ok := sq.retestCounts[key]
+	if !ok || !count.since.Equal(since) {
+		count = &retestCount{since: since}
+		sq.retestCounts[key] = count
+	}
+	return count
+}
+
+// recordRetest counts a github e2e retest the queue asked for on obj.
+func (sq *SubmitQueue) recordRetest(obj *github.MungeObject) {
+	if sq.MaxRetestsPerDay <= 0 {
+		return
+	}
+	sq.Lock()
+	defer sq.Unlock()
+	sq.retestCountLocked(obj).count++
+}
+
+// manualRetests is how many comments, since since, people have written on
+// obj asking the E2EBotName for a retest themselves.
+func (sq *SubmitQueue) manualRetests(obj *github.MungeObject, since time.Time) int {
+	comments, ok := obj.ListComments()
+	if !ok {
+		return 0
+	}
+	bot, phrase := sq.e2eTrigger()
+	prefix := fmt.Sprintf("@%s %s", bot, phrase)
+	n := 0
+	for _, comment := range c.FilterComments(comments, c.HumanActor()) {
+		if comment.Body == nil || comment.CreatedAt == nil || comment.CreatedAt.Before(since) {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(*comment.Body), prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// atRetestLimit returns true if obj has had MaxRetestsPerDay retests, by the
+// queue or by hand, since the last reset and no admin has allowed more.
+func (sq *SubmitQueue) atRetestLimit(obj *github.MungeObject) bool {
+	if sq.MaxRetestsPerDay <= 0 {
+		return false
+	}
+	sq.Lock()
+	count := *sq.retestCountLocked(obj)
+	sq.Unlock()
+	if count.allowedBy != "" {
+		return false
+	}
+	return count.count+sq.manualRetests(obj, count.since) >= sq.MaxRetestsPerDay
+}
+
+// heldAtRetestLimit returns true if obj must stay out of the queue because it
+// is at the retest limit. The first time this happens each day the author is
+// told why.
+func (sq *SubmitQueue) heldAtRetestLimit(obj *github.MungeObject) bool {
+	if !sq.atRetestLimit(obj) {
+		return false
+	}
+	sq.SetMergeStatus(obj, retestLimitReached)
+
+	sq.Lock()
+	count := sq.retestCountLocked(obj)
+	notify := !count.notified
+	count.notified = true
+	reset := count.since.AddDate(0, 0, 1)
+	sq.Unlock()
+	if !notify {
+		return true
+	}
+	body := fmt.Sprintf("This PR has been retested %d times today, the most allowed. It will be retested again after %s, or sooner if one of %q comments `@%s %s`.",
+		sq.MaxRetestsPerDay, reset.Format(time.RFC1123), sq.WhitelistAdmins, botName, strings.ToLower(allowRetestsCommand))
+	if err := obj.WriteComment(body); err != nil {
+		obj.Log().Errorf("unable to write retest limit comment: %v", err)
+	}
+	return true
+}
+
+// allowRetestsCommand handles "@bot allow-retests", which lifts the retest
+// limit for the PR until the next reset.
+func (sq *SubmitQueue) allowRetestsCommand(obj *github.MungeObject, user string, cmd *c.Command) string {
+	if sq.MaxRetestsPerDay <= 0 {
+		return fmt.Sprintf("@%s retests aren't limited, so there is nothing to allow.", user)
+	}
+	sq.Lock()
+	count := sq.retestCountLocked(obj)
+	count.allowedBy = user
+	reset := count.since.AddDate(0, 0, 1)
+	sq.Unlock()
+	return fmt.Sprintf("This PR may be retested as often as needed until %s, at the request of @%s.", reset.Format(time.RFC1123), user)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+
+	"github.com/google/go-github/github"
+)
+
+func TestRetestLimit(t *testing.T) {
+	resetTime := time.Date(2017, time.June, 1, 8, 0, 0, 0, time.UTC)
+	manual := "@" + jenkinsBotName + " " + e2eTriggerPhrase
+	comments := []*github.IssueComment{
+		// Before the reset, so it doesn't count
+		github_test.IssueComment(1, manual, "alice", resetTime.Add(-time.Hour).Unix()),
+		github_test.IssueComment(2, manual, "alice", resetTime.Add(time.Hour).Unix()),
+		// The queue's own retests are counted as they are made
+		github_test.IssueComment(3, manual+" [submit-queue is verifying that this PR is safe to merge]", botName, resetTime.Add(time.Hour).Unix()),
+		github_test.IssueComment(4, "@"+jenkinsBotName+" please test this later", "alice", resetTime.Add(time.Hour).Unix()),
+	}
+	replies := []string{}
+	sq, obj, done := commandTestSQ(t, comments, &replies)
+	defer done()
+	sq.MaxRetestsPerDay = 3
+	sq.RetestLimitResetHour = 8
+	clock := sq.clock.(*utilclock.FakeClock)
+	clock.SetTime(resetTime.Add(2 * time.Hour))
+
+	sq.recordRetest(obj)
+	if sq.atRetestLimit(obj) {
+		t.Errorf("expected 2 retests to be under the limit")
+	}
+	sq.recordRetest(obj)
+	if !sq.atRetestLimit(obj) {
+		t.Errorf("expected 3 retests to reach the limit")
+	}
+
+	if !sq.heldAtRetestLimit(obj) {
+		t.Errorf("expected the PR to be held out of the queue")
+	}
+	if !sq.heldAtRetestLimit(obj) {
+		t.Errorf("expected the PR to still be held out of the queue")
+	}
+	if len(replies) != 1 || !strings.HasPrefix(replies[0], "This PR has been retested 3 times today") {
+		t.Errorf("expected one comment about the limit but got %q", replies)
+	}
+	if reason := sq.prStatus["1"].Reason; reason != retestLimitReached {
+		t.Errorf("expected reason %q but got %q", retestLimitReached, reason)
+	}
+
+	// Just before the reset the limit still holds
+	clock.SetTime(resetTime.Add(24*time.Hour - time.Second))
+	if !sq.atRetestLimit(obj) {
+		t.Errorf("expected the limit to hold until the reset")
+	}
+
+	// After it everything starts over
+	clock.SetTime(resetTime.Add(24 * time.Hour))
+	if sq.atRetestLimit(obj) {
+		t.Errorf("expected the limit to have been reset")
+	}
+	for i := 0; i < 3; i++ {
+		sq.recordRetest(obj)
+	}
+	if !sq.heldAtRetestLimit(obj) {
+		t.Errorf("expected the PR to be held again")
+	}
+	if len(replies) != 2 {
+		t.Errorf("expected another comment about the limit the next day but got %q", replies)
+	}
+}
+
+func TestAllowRetestsCommand(t *testing.T) {
+	resetTime := time.Date(2017, time.June, 1, 0, 0, 0, 0, time.UTC)
+	comments := []*github.IssueComment{
+		github_test.IssueComment(1, "@"+botName+" allow-retests", "alice", resetTime.Add(time.Hour).Unix()),
+	}
+	replies := []string{}
+	sq, obj, done := commandTestSQ(t, comments, &replies)
+	defer done()
+	sq.MaxRetestsPerDay = 1
+	sq.WhitelistAdmins = []string{"alice"}
+	clock := sq.clock.(*utilclock.FakeClock)
+	clock.SetTime(resetTime.Add(2 * time.Hour))
+
+	if !sqCommands[allowRetestsCommand].adminOnly {
+		t.Errorf("expected allow-retests to be admin only")
+	}
+	sq.recordRetest(obj)
+	if !sq.atRetestLimit(obj) {
+		t.Fatalf("expected the PR to be at the limit")
+	}
+
+	sq.handleCommands(obj)
+	if len(replies) != 1 || !strings.Contains(replies[0], "at the request of @alice") {
+		t.Errorf("unexpected replies %q", replies)
+	}
+	sq.recordRetest(obj)
+	if sq.atRetestLimit(obj) {
+		t.Errorf("expected the admin to have lifted the limit")
+	}
+
+	// Only until the next reset
+	clock.SetTime(resetTime.Add(24 * time.Hour))
+	sq.recordRetest(obj)
+	if !sq.atRetestLimit(obj) {
+		t.Errorf("expected the limit to apply again after the reset")
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// revertMergePriority sorts revert PRs ahead of even P0 PRs, since they are
+// usually fixing a broken branch which everything else is waiting on.
+const revertMergePriority = -1
+
+// isRevert returns true if obj's title starts with one of RevertTitlePrefixes
+// or it has the RevertLabel.
+func (sq *SubmitQueue) isRevert(obj *github.MungeObject) bool {
+	if sq.RevertLabel != "" && obj.HasLabel(sq.RevertLabel) {
+		return true
+	}
+	if obj.Issue.Title == nil {
+		return false
+	}
+	title := strings.TrimSpace(*obj.Issue.Title)
+	for _, prefix := range sq.RevertTitlePrefixes {
+		if strings.HasPrefix(title, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// revertSkipsRetest returns true if obj is a revert PR which is merged
+// without a github e2e run, because RevertSkipRetest is set.
+func (sq *SubmitQueue) revertSkipsRetest(obj *github.MungeObject) bool {
+	return sq.RevertSkipRetest && sq.isRevert(obj)
+}
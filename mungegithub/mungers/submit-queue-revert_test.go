@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestRevertQueueOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes []string
+		label    string
+		title    string
+		labels   []string
+		expected []string
+	}{
+		{
+			name:     "revert title sorts ahead of P0",
+			prefixes: []string{"Revert"},
+			title:    `Revert "Break everything"`,
+			expected: []string{"3", "2"},
+		},
+		{
+			name:     "revert label sorts ahead of P0",
+			label:    "kind/revert",
+			title:    "Undo the breakage",
+			labels:   []string{"kind/revert"},
+			expected: []string{"3", "2"},
+		},
+		{
+			name:     "detection disabled",
+			title:    `Revert "Break everything"`,
+			expected: []string{"2", "3"},
+		},
+		{
+			name:     "title doesn't start with the prefix",
+			prefixes: []string{"Revert"},
+			title:    "Fix the Revert button",
+			expected: []string{"2", "3"},
+		},
+	}
+	for _, test := range tests {
+		issueToEvents := map[int][]github_test.LabelTime{
+			2: {{User: "me", Label: lgtmLabel, Time: 1}},
+			3: {{User: "me", Label: lgtmLabel, Time: 2}},
+		}
+		client, server, mux := github_test.InitServer(t, nil, nil, github_test.MultiIssueEvents(issueToEvents, "labeled"), nil, nil, nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		sq.RevertTitlePrefixes = test.prefixes
+		sq.RevertLabel = test.label
+
+		p0 := github_test.Issue(someUserName, 2, []string{"priority/P0"}, true)
+		revert := github_test.Issue(someUserName, 3, append([]string{"priority/P3"}, test.labels...), true)
+		revert.Title = &test.title
+		for _, issue := range []*github.Issue{p0, revert} {
+			github_test.ServeIssue(t, mux, issue)
+			obj, err := config.GetObject(*issue.Number)
+			if err != nil {
+				t.Fatalf("%s: unable to get issue: %v", test.name, err)
+			}
+			sq.githubE2EQueue[strconv.Itoa(*issue.Number)] = obj
+		}
+
+		if actual := sq.orderedE2EQueue(); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected queue %v but got %v", test.name, test.expected, actual)
+		}
+		server.Close()
+	}
+}
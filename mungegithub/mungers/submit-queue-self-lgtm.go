@@ -0,0 +1,40 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// selfLGTM returns true if the PR's current lgtm label was added by its own
+// author, unless AllowSelfLGTM is set. Only the last time the label was added
+// matters, since that is the one the merge is judged against.
+func (sq *SubmitQueue) selfLGTM(obj *github.MungeObject) (bool, bool) {
+	if sq.AllowSelfLGTM {
+		return false, true
+	}
+	if obj.Issue.User == nil || obj.Issue.User.Login == nil {
+		return false, false
+	}
+	creator, ok := obj.LabelCreator(sq.LGTMLabel)
+	if !ok {
+		return false, false
+	}
+	return strings.EqualFold(creator, *obj.Issue.User.Login), true
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+)
+
+func TestSelfLGTM(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name      string
+		lgtmUser  string
+		allowSelf bool
+		expected  bool
+	}{
+		{
+			name:     "external lgtm",
+			lgtmUser: "bob",
+			expected: true,
+		},
+		{
+			name:     "self lgtm",
+			lgtmUser: someUserName,
+			expected: false,
+		},
+		{
+			name:      "self lgtm allowed",
+			lgtmUser:  someUserName,
+			allowSelf: true,
+			expected:  true,
+		},
+	}
+	for _, test := range tests {
+		events := github_test.Events([]github_test.LabelTime{
+			{User: "bob", Label: approvedLabel, Time: 20},
+			{User: test.lgtmUser, Label: lgtmLabel, Time: 20},
+		})
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), nil, Commits(), SuccessStatus(), nil, nil)
+		mux.HandleFunc("/repos/o/r/issues/1/events", func(w http.ResponseWriter, r *http.Request) {
+			data, _ := json.Marshal(events)
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		sq.AllowSelfLGTM = test.allowSelf
+
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), events)
+		if valid := sq.validForMergeExt(obj, false); valid != test.expected {
+			t.Errorf("%s: expected valid %v but got %v, %q", test.name, test.expected, valid, sq.prStatus["1"].Reason)
+		}
+		if expected := fmt.Sprintf(noLGTMFmt, lgtmLabel); !test.expected && sq.prStatus["1"].Reason != expected {
+			t.Errorf("%s: expected reason %q but got %q", test.name, expected, sq.prStatus["1"].Reason)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Shutdown stops the queue from taking new PRs or starting merges, then waits
+// up to timeout for merges already talking to github to finish. If
+// ShutdownStateFile is set the queue as it was left is written there. It
+// returns an error if a merge was still in flight when the timeout expired.
+func (sq *SubmitQueue) Shutdown(timeout time.Duration) error {
+	sq.Lock()
+	sq.shuttingDown = true
+	sq.Unlock()
+	glog.Infof("Shutting down the submit queue, waiting up to %v for in-flight merges", timeout)
+
+	drained := make(chan struct{})
+	go func() {
+		sq.merging.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-time.After(timeout):
+		err = fmt.Errorf("merges were still in progress after %v", timeout)
+	}
+
+	if sq.ShutdownStateFile != "" {
+		if werr := ioutil.WriteFile(sq.ShutdownStateFile, sq.getGithubE2EStatus(), 0644); werr != nil {
+			glog.Errorf("Unable to save the queue to %s: %v", sq.ShutdownStateFile, werr)
+		}
+	}
+	return err
+}
+
+// isShuttingDown returns true once Shutdown has been called.
+func (sq *SubmitQueue) isShuttingDown() bool {
+	sq.Lock()
+	defer sq.Unlock()
+	return sq.shuttingDown
+}
+
+// startMerge records that a merge is about to be sent to github. It returns
+// false if the queue is shutting down and the merge must not start. Every
+// successful call must be followed by a call to sq.merging.Done().
+func (sq *SubmitQueue) startMerge() bool {
+	sq.Lock()
+	defer sq.Unlock()
+	if sq.shuttingDown {
+		return false
+	}
+	sq.merging.Add(1)
+	return true
+}
+
+// shutdownOnSignal drains the queue and exits when the process is sent
+// SIGTERM, as happens when it is redeployed.
+func (sq *SubmitQueue) shutdownOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	<-signals
+	if err := sq.Shutdown(sq.ShutdownTimeout); err != nil {
+		glog.Errorf("Unclean shutdown: %v", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestShutdownWaitsForMerge(t *testing.T) {
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	mux.HandleFunc("/repos/o/r/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		data, _ := json.Marshal(github.PullRequestMergeResult{})
+		w.Write(data)
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	dir, err := ioutil.TempDir("", "submit-queue")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sq := getTestSQ(false, config, server)
+	sq.ShutdownStateFile = filepath.Join(dir, "queue.json")
+	queued := github_util.TestObject(config, github_test.Issue(someUserName, 2, nil, true), ValidPR(), Commits(), NewLGTMEvents())
+	sq.githubE2EQueue["2"] = queued
+
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	done := make(chan bool)
+	go func() {
+		done <- sq.mergePullRequest(obj, merged, "")
+	}()
+	<-entered
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- sq.Shutdown(time.Minute)
+	}()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v while a merge was in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if !<-done {
+		t.Errorf("expected the in-flight merge to finish")
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Nothing new is merged or queued
+	if sq.mergePullRequest(obj, merged, "") {
+		t.Errorf("expected no merges after shutdown")
+	}
+	delete(sq.githubE2EQueue, "2")
+	sq.Munge(github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents()))
+	if len(sq.githubE2EQueue) != 0 {
+		t.Errorf("expected nothing to be queued after shutdown but got %v", sq.githubE2EQueue)
+	}
+
+	data, err := ioutil.ReadFile(sq.ShutdownStateFile)
+	if err != nil {
+		t.Fatalf("queue was not saved: %v", err)
+	}
+	status := e2eQueueStatus{}
+	if err := json.Unmarshal(data, &status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(status.E2EQueue) != 1 || status.E2EQueue[0].Number != 2 {
+		t.Errorf("expected #2 to be saved as queued but got %v", status.E2EQueue)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	if !sq.startMerge() {
+		t.Fatalf("expected a merge to be allowed to start")
+	}
+	defer sq.merging.Done()
+	if err := sq.Shutdown(10 * time.Millisecond); err == nil {
+		t.Errorf("expected an error when a merge doesn't finish in time")
+	}
+	if sq.startMerge() {
+		t.Errorf("expected no merges to start after shutdown")
+	}
+}
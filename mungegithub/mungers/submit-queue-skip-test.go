@@ -0,0 +1,150 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+)
+
+const (
+	skipTestCommand   = "SKIP-TEST"
+	unskipTestCommand = "UNSKIP-TEST"
+
+	// skipTestOverrider is who the overrides recorded for runs which only
+	// failed skipped tests are credited to.
+	skipTestOverrider = "skip-test"
+)
+
+// Matches the arguments of "@k8s-merge-robot skip-test #123 <test name>"
+var skipTestRegex = regexp.MustCompile(`^((?:[\w.-]+/[\w.-]+)?#\d+)\s+(.+)$`)
+
+// skippedTest is a flaky test whose failures don't block merges until
+// expires, and the issue tracking the flake.
+type skippedTest struct {
+	issue   string
+	user    string
+	expires time.Time
+}
+
+// skipTestCommand handles "@bot skip-test #123 <test name>", which ignores
+// the test's failures in github e2e runs, of every PR, for SkipTestDuration.
+// "@bot unskip-test <test name>" lifts it early.
+func (sq *SubmitQueue) skipTestCommand(obj *github.MungeObject, user string, cmd *c.Command) string {
+	if cmd.Name == unskipTestCommand {
+		test := cmd.Arguments
+		sq.Lock()
+		_, found := sq.skippedTests[test]
+		delete(sq.skippedTests, test)
+		sq.Unlock()
+		if !found {
+			return fmt.Sprintf("@%s %q is not being skipped.", user, test)
+		}
+		obj.Log().Infof("%s stopped skipping %q", user, test)
+		return fmt.Sprintf("Failures of %q block merges again at the request of @%s.", test, user)
+	}
+
+	match := skipTestRegex.FindStringSubmatch(cmd.Arguments)
+	if match == nil {
+		return fmt.Sprintf("@%s please give the issue tracking the flake and the test, like `@%s skip-test #123 <test name>`.", user, botName)
+	}
+	issue, test := match[1], strings.TrimSpace(match[2])
+
+	sq.Lock()
+	if sq.skippedTests == nil {
+		sq.skippedTests = map[string]skippedTest{}
+	}
+	expires := sq.clock.Now().Add(sq.SkipTestDuration)
+	sq.skippedTests[test] = skippedTest{issue: issue, user: user, expires: expires}
+	sq.Unlock()
+
+	obj.Log().Infof("%s is skipping %q for %s", user, test, issue)
+	return fmt.Sprintf("Failures of %q will not block merges until %s, or `@%s unskip-test %s`, at the request of @%s. The flake is tracked in %s.", test, expires.UTC().Format(time.RFC1123), botName, test, user, issue)
+}
+
+// isSkippedTest returns true if failures of the test, named "name {classname}"
+// in the junit results, are being ignored. Expired skips are forgotten.
+func (sq *SubmitQueue) isSkippedTest(test string) bool {
+	sq.Lock()
+	defer sq.Unlock()
+	now := sq.clock.Now()
+	for name, skipped := range sq.skippedTests {
+		if !now.Before(skipped.expires) {
+			delete(sq.skippedTests, name)
+			continue
+		}
+		if test == name || strings.HasPrefix(test, name+" {") {
+			return true
+		}
+	}
+	return false
+}
+
+// onlySkippedTestFailures returns true if every failed context's build,
+// found from its target URL as for infraFailure, only failed skipped tests.
+// The contexts are then overridden for the PR's head commit, so they count
+// as passing from here on.
+func (sq *SubmitQueue) onlySkippedTestFailures(obj *github.MungeObject, contexts []string) bool {
+	sq.Lock()
+	skipping := len(sq.skippedTests) > 0
+	sq.Unlock()
+	if !skipping {
+		return false
+	}
+	sha, _, ok := obj.GetHeadAndBase()
+	if !ok {
+		return false
+	}
+	failed := []string{}
+	for _, context := range contexts {
+		if success, ok := sq.statusBackend().IsSuccess(obj, []string{context}); ok && success {
+			continue
+		}
+		url, ok := sq.statusBackend().TargetURL(obj, context)
+		if !ok || url == "" {
+			return false
+		}
+		job, number, ok := jobAndBuild(url)
+		if !ok {
+			return false
+		}
+		tests, err := sq.e2e.FailedTests(job, number)
+		if err != nil {
+			obj.Log().With("job", job).With("build", number).Errorf("unable to find the failed tests: %v", err)
+			return false
+		}
+		for _, test := range tests {
+			if !sq.isSkippedTest(test) {
+				obj.Log().Infof("%s/%d failed %q, which isn't being skipped", job, number, test)
+				return false
+			}
+		}
+		failed = append(failed, context)
+	}
+	if len(failed) == 0 {
+		return false
+	}
+	for _, context := range failed {
+		sq.overrideContext(obj, sha, context, skipTestOverrider)
+	}
+	return true
+}
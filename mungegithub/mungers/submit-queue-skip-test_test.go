@@ -0,0 +1,159 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	fake_e2e "k8s.io/contrib/mungegithub/mungers/e2e/fake"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+
+	"github.com/google/go-github/github"
+)
+
+func TestSkipTestCommand(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	sq.SkipTestDuration = time.Hour
+	clock := sq.clock.(*utilclock.FakeClock)
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	command := func(name, args string) string {
+		return sq.skipTestCommand(obj, "alice", &c.Command{Name: name, Arguments: args})
+	}
+
+	if !sqCommands[skipTestCommand].authorized || !sqCommands[unskipTestCommand].authorized {
+		t.Errorf("expected skip-test and unskip-test to need authorization")
+	}
+	if reply := command(skipTestCommand, "TestFlaky"); !strings.HasPrefix(reply, "@alice please give the issue") {
+		t.Errorf("expected a skip without an issue to be refused, got %q", reply)
+	}
+	if reply := command(skipTestCommand, "#123 TestFlaky should work"); !strings.Contains(reply, "tracked in #123") {
+		t.Errorf("unexpected reply %q", reply)
+	}
+	if !sq.isSkippedTest("TestFlaky should work {e2e}") {
+		t.Errorf("expected the test to be skipped")
+	}
+	if sq.isSkippedTest("TestFlaky {e2e}") {
+		t.Errorf("expected only the named test to be skipped")
+	}
+
+	// The skip expires
+	clock.Step(time.Hour)
+	if sq.isSkippedTest("TestFlaky should work {e2e}") {
+		t.Errorf("expected the skip to have expired")
+	}
+	if len(sq.skippedTests) != 0 {
+		t.Errorf("expected the expired skip to be forgotten, got %v", sq.skippedTests)
+	}
+
+	// Or is lifted
+	command(skipTestCommand, "o/r#123 TestFlaky")
+	if reply := command(unskipTestCommand, "TestFlaky"); !strings.HasPrefix(reply, "Failures of \"TestFlaky\" block merges again") {
+		t.Errorf("unexpected reply %q", reply)
+	}
+	if sq.isSkippedTest("TestFlaky {e2e}") {
+		t.Errorf("expected the skip to be lifted")
+	}
+	if reply := command(unskipTestCommand, "TestFlaky"); !strings.Contains(reply, "is not being skipped") {
+		t.Errorf("unexpected reply %q", reply)
+	}
+}
+
+func TestSkippedTestFailures(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name    string
+		skip    []string
+		elapsed time.Duration
+		failed  map[string][]string
+		failure bool
+	}{
+		{
+			name:   "only skipped tests failed",
+			skip:   []string{"TestFlaky"},
+			failed: map[string][]string{requiredReTestContext2: {"TestFlaky {e2e}"}},
+		},
+		{
+			name:    "another test failed",
+			skip:    []string{"TestFlaky"},
+			failed:  map[string][]string{requiredReTestContext2: {"TestFlaky {e2e}", "TestBroken {e2e}"}},
+			failure: true,
+		},
+		{
+			name:    "skip expired",
+			skip:    []string{"TestFlaky"},
+			elapsed: 2 * time.Hour,
+			failed:  map[string][]string{requiredReTestContext2: {"TestFlaky {e2e}"}},
+			failure: true,
+		},
+		{
+			name:    "nothing skipped",
+			failed:  map[string][]string{requiredReTestContext2: {"TestFlaky {e2e}"}},
+			failure: true,
+		},
+	}
+	for _, test := range tests {
+		ciStatus := SuccessStatus()
+		for i := range ciStatus.Statuses {
+			ciStatus.Statuses[i].TargetURL = stringPtr(fmt.Sprintf("https://gubernator/build/bucket/pr-logs/pull/1/%s/5/", *ciStatus.Statuses[i].Context))
+		}
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), ciStatus, nil, nil)
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			c := new(github.IssueComment)
+			json.NewDecoder(r.Body).Decode(c)
+			go fakeRunGithubE2ESuccess(ciStatus, true, false)
+			w.Write([]byte("{}"))
+		})
+		mux.HandleFunc("/repos/o/r/statuses/mysha", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.BaseWaitTime = time.Millisecond
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		sq.SkipTestDuration = time.Hour
+		sq.e2e.(*fake_e2e.FakeE2ETester).FailedTestResults = test.failed
+
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		for _, name := range test.skip {
+			sq.skipTestCommand(obj, "alice", &c.Command{Name: skipTestCommand, Arguments: "#123 " + name})
+		}
+		sq.clock.(*utilclock.FakeClock).Step(test.elapsed)
+
+		sq.githubE2EQueue["1"] = obj
+		if failed := sq.retestPR(obj); failed != test.failure {
+			t.Errorf("%s: expected failed=%v but got %v (%q)", test.name, test.failure, failed, sq.prStatus["1"].Reason)
+		}
+		if valid := sq.validForMerge(obj); valid == test.failure {
+			t.Errorf("%s: expected valid=%v after the run but got %v (%q)", test.name, !test.failure, valid, sq.prStatus["1"].Reason)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+
+	"github.com/golang/glog"
+)
+
+const (
+	slackTimeout         = 10 * time.Second
+	slackSummaryInterval = 24 * time.Hour
+)
+
+// slackNotifier posts messages to a Slack incoming webhook.
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newSlackNotifier(url string) *slackNotifier {
+	return &slackNotifier{
+		url:    url,
+		client: &http.Client{Timeout: slackTimeout},
+	}
+}
+
+// Notify posts text to the channel the webhook is for.
+func (s *slackNotifier) Notify(text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack returned %s", resp.Status)
+	}
+	return nil
+}
+
+// notifySlack posts text to Slack if --slack-webhook-url is set.
+func (sq *SubmitQueue) notifySlack(text string) {
+	if sq.slack == nil {
+		return
+	}
+	if err := sq.slack.Notify(text); err != nil {
+		glog.Errorf("Unable to post to slack: %v", err)
+	}
+}
+
+// recordSlackMerge remembers the PR for the next daily summary. sq.Lock()
+// must be held.
+func (sq *SubmitQueue) recordSlackMerge(obj *github.MungeObject) {
+	if sq.slack == nil {
+		return
+	}
+	sq.slackMerges = append(sq.slackMerges, fmt.Sprintf("%s#%d", obj.Repo(), obj.Number()))
+}
+
+// sendSlackSummary posts the PRs merged since the last summary, once a day.
+func (sq *SubmitQueue) sendSlackSummary() {
+	if sq.slack == nil {
+		return
+	}
+	sq.Lock()
+	now := sq.clock.Now()
+	if sq.lastSlackSummary.IsZero() {
+		sq.lastSlackSummary = now
+	}
+	if now.Sub(sq.lastSlackSummary) < slackSummaryInterval {
+		sq.Unlock()
+		return
+	}
+	merged := sq.slackMerges
+	sq.slackMerges = nil
+	sq.lastSlackSummary = now
+	sq.Unlock()
+
+	text := "The submit queue merged no PRs in the last day."
+	if len(merged) > 0 {
+		text = fmt.Sprintf("The submit queue merged %d PRs in the last day: %s", len(merged), strings.Join(merged, ", "))
+	}
+	sq.notifySlack(text)
+}
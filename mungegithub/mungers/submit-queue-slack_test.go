@@ -0,0 +1,84 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+)
+
+func TestSlackNotifications(t *testing.T) {
+	posted := []string{}
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("unable to decode the slack message: %v", err)
+		}
+		posted = append(posted, msg["text"])
+	}))
+	defer slack.Close()
+
+	sq := getTestSQ(false, nil, nil)
+	if sq.slack != nil {
+		t.Fatalf("expected slack to be off by default")
+	}
+	sq.notifySlack("nobody is listening")
+	sq.slack = newSlackNotifier(slack.URL)
+	clock := sq.clock.(*utilclock.FakeClock)
+
+	sq.e2eStable(false)
+	sq.setEmergencyMergeStop(true)
+	sq.e2eStable(false)
+	sq.e2eStable(false)
+	sq.setEmergencyMergeStop(false)
+	sq.e2eStable(false)
+	if expected := []string{e2eFailure, e2eRecover}; !reflect.DeepEqual(posted, expected) {
+		t.Errorf("expected only the transitions %v to be posted but got %v", expected, posted)
+	}
+
+	posted = []string{}
+	config := &github_util.Config{Org: "o", Project: "r"}
+	clock.SetTime(time.Date(2016, 10, 5, 10, 0, 0, 0, time.UTC))
+	sq.sendSlackSummary()
+	sq.Lock()
+	sq.recordSlackMerge(github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents()))
+	sq.Unlock()
+	clock.Step(23 * time.Hour)
+	sq.sendSlackSummary()
+	if len(posted) != 0 {
+		t.Errorf("expected no summary within a day but got %v", posted)
+	}
+	clock.Step(time.Hour)
+	sq.sendSlackSummary()
+	clock.Step(24 * time.Hour)
+	sq.sendSlackSummary()
+	expected := []string{
+		"The submit queue merged 1 PRs in the last day: o/r#1",
+		"The submit queue merged no PRs in the last day.",
+	}
+	if !reflect.DeepEqual(posted, expected) {
+		t.Errorf("expected the summaries %v but got %v", expected, posted)
+	}
+}
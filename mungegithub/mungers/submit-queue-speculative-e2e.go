@@ -0,0 +1,118 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// speculativeRun is a github e2e run requested for a PR which was only
+// waiting for lgtm.
+type speculativeRun struct {
+	headSHA   string
+	baseSHA   string
+	requested time.Time
+}
+
+// maybeStartSpeculativeE2E asks for a github e2e run of obj if
+// SpeculativeE2E is set and lgtm is all obj is missing, so the result may be
+// ready by the time it is lgtm'd. Only one run is requested per head commit;
+// if lgtm never comes the result just sits on the commit.
+func (sq *SubmitQueue) maybeStartSpeculativeE2E(obj *github.MungeObject) {
+	if !sq.SpeculativeE2E || sq.ReviewMode {
+		return
+	}
+	key := sq.prKey(obj)
+	sq.Lock()
+	reason := sq.prStatus[key].Reason
+	sq.Unlock()
+	if reason != fmt.Sprintf(noLGTMFmt, sq.LGTMLabel) {
+		return
+	}
+	if len(sq.withoutOverrides(obj, sq.retestContexts(obj))) == 0 {
+		return
+	}
+	head, baseRef, ok := obj.GetHeadAndBase()
+	if !ok {
+		return
+	}
+	base, ok := obj.GetSHAFromRef(baseRef)
+	if !ok {
+		return
+	}
+
+	sq.Lock()
+	if run, found := sq.speculativeRuns[key]; found && run.headSHA == head {
+		sq.Unlock()
+		return
+	}
+	sq.Unlock()
+
+	if sq.atRetestLimit(obj) {
+		return
+	}
+	if err := obj.WriteRepeatableComment(sq.retestBody(obj)); err != nil {
+		obj.Log().Errorf("unable to request a speculative github e2e run: %v", err)
+		return
+	}
+	sq.recordRetest(obj)
+	obj.Log().Infof("requested a speculative github e2e run at %s", head)
+
+	sq.Lock()
+	defer sq.Unlock()
+	if sq.speculativeRuns == nil {
+		sq.speculativeRuns = map[string]speculativeRun{}
+	}
+	sq.speculativeRuns[key] = speculativeRun{headSHA: head, baseSHA: base, requested: sq.clock.Now()}
+}
+
+// speculativeResultReady returns true if a speculative github e2e run of
+// obj, at its current head and base, finished and passed, so it doesn't need
+// another one. obj must still have been lgtm'd after its last push to be
+// merged, which validForMerge() checks.
+func (sq *SubmitQueue) speculativeResultReady(obj *github.MungeObject) bool {
+	key := sq.prKey(obj)
+	sq.Lock()
+	run, found := sq.speculativeRuns[key]
+	sq.Unlock()
+	if !found {
+		return false
+	}
+	head, baseRef, ok := obj.GetHeadAndBase()
+	if !ok || head != run.headSHA {
+		return false
+	}
+	if base, ok := obj.GetSHAFromRef(baseRef); !ok || base != run.baseSHA {
+		return false
+	}
+
+	contexts := sq.withoutOverrides(obj, sq.retestContexts(obj))
+	if success, ok := sq.statusBackend().IsSuccess(obj, contexts); !ok || !success {
+		return false
+	}
+	// Passing from before the run was requested isn't a result of the run
+	for _, context := range contexts {
+		updated, ok := sq.statusBackend().UpdatedAt(obj, context)
+		if !ok || updated == nil || !updated.After(run.requested) {
+			return false
+		}
+	}
+	return true
+}
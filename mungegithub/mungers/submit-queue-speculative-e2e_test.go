@@ -0,0 +1,133 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+
+	"github.com/google/go-github/github"
+)
+
+func TestSpeculativeE2E(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	ciStatus := SuccessStatus()
+	setRetestTimes := func(updated time.Time) {
+		for i := range ciStatus.Statuses {
+			if context := *ciStatus.Statuses[i].Context; context == requiredReTestContext1 || context == requiredReTestContext2 {
+				ciStatus.Statuses[i].UpdatedAt = &updated
+			}
+		}
+	}
+	setRetestTimes(time.Unix(50, 0))
+
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), ciStatus, MasterCommit(), nil)
+	defer server.Close()
+	retests := 0
+	mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Write([]byte("[]"))
+			return
+		}
+		c := new(github.IssueComment)
+		json.NewDecoder(r.Body).Decode(c)
+		if strings.HasPrefix(*c.Body, "@"+jenkinsBotName) {
+			retests++
+		}
+		w.Write([]byte("{}"))
+	})
+	merges := 0
+	mux.HandleFunc("/repos/o/r/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+		merges++
+		data, _ := json.Marshal(github.PullRequestMergeResult{})
+		w.Write(data)
+	})
+	mux.HandleFunc("/repos/o/r/statuses/mysha", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	sq.SpeculativeE2E = true
+	sq.clock.(*utilclock.FakeClock).SetTime(time.Unix(100, 0))
+
+	// Only waiting for lgtm
+	obj := github_util.TestObject(config, NoLGTMIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	sq.Munge(obj)
+	sq.Munge(obj)
+	if retests != 1 {
+		t.Fatalf("expected one speculative run to be requested but got %d", retests)
+	}
+	if sq.speculativeResultReady(obj) {
+		t.Errorf("expected results from before the run was requested not to count")
+	}
+
+	// The run passed
+	setRetestTimes(time.Unix(200, 0))
+	if !sq.speculativeResultReady(obj) {
+		t.Errorf("expected the speculative result to be ready")
+	}
+
+	// lgtm'd, so it merges without another run
+	lgtmd := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	sq.githubE2EQueue["1"] = lgtmd
+	if !sq.doGithubE2EAndMerge(sq.selectPullRequest()) || merges != 1 {
+		t.Errorf("expected the PR to merge, got %d merges and %q", merges, sq.prStatus["1"].Reason)
+	}
+	if retests != 1 || sq.retestsAvoided != 1 {
+		t.Errorf("expected the speculative run to be used, got %d runs and %d avoided", retests, sq.retestsAvoided)
+	}
+	if _, found := sq.speculativeRuns["1"]; found {
+		t.Errorf("expected the speculative run to be forgotten after the merge")
+	}
+}
+
+func TestSpeculativeE2EChangedHead(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), MasterCommit(), nil)
+	defer server.Close()
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+	sq := getTestSQ(false, config, server)
+	sq.SpeculativeE2E = true
+
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	sq.speculativeRuns = map[string]speculativeRun{
+		"1": {headSHA: "oldsha", baseSHA: "mastersha", requested: time.Unix(0, 0)},
+	}
+	if sq.speculativeResultReady(obj) {
+		t.Errorf("expected a run of an older head not to be used")
+	}
+	sq.speculativeRuns["1"] = speculativeRun{headSHA: "mysha", baseSHA: "oldmastersha", requested: time.Unix(0, 0)}
+	if sq.speculativeResultReady(obj) {
+		t.Errorf("expected a run against an older base not to be used")
+	}
+}
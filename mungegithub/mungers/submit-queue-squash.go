@@ -0,0 +1,77 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// defaultSquashStripLines are the lines of a PR body left out of its squash
+// commit: checklist items, and commands for the bots like "/assign @me" or
+// "@k8s-bot test this".
+var defaultSquashStripLines = []string{
+	`^\s*[-*] \[[ xX]\] `,
+	`^/[a-z-]+(\s|$)`,
+	`^@(` + regexp.QuoteMeta(botName) + `|` + regexp.QuoteMeta(jenkinsBotName) + `)\s`,
+}
+
+var blankLinesRE = regexp.MustCompile(`\n{3,}`)
+
+func parseSquashStripLines(patterns []string) ([]*regexp.Regexp, error) {
+	out := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --squash-strip-lines %q: %v", pattern, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// squashCommitMessage is the title and message of the commit when obj is
+// squashed: the PR's title followed by its number, as github does, and its
+// body without the lines which match one of the squashStripLines.
+func (sq *SubmitQueue) squashCommitMessage(obj *github.MungeObject) (string, string) {
+	title := fmt.Sprintf("(#%d)", *obj.Issue.Number)
+	if obj.Issue.Title != nil {
+		title = *obj.Issue.Title + " " + title
+	}
+	if obj.Issue.Body == nil {
+		return title, ""
+	}
+
+	kept := []string{}
+	for _, line := range strings.Split(github.CleanIssueBody(*obj.Issue.Body), "\n") {
+		strip := false
+		for _, re := range sq.squashStripLines {
+			if re.MatchString(line) {
+				strip = true
+				break
+			}
+		}
+		if !strip {
+			kept = append(kept, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	message := blankLinesRE.ReplaceAllString(strings.Join(kept, "\n"), "\n\n")
+	return title, strings.TrimSpace(message)
+}
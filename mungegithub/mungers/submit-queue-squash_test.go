@@ -0,0 +1,139 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+const samplePRBody = `Makes the queue squash commit messages.
+
+<!-- Thanks for sending a pull request! -->
+Checklist:
+- [x] Tests added
+- [ ] Docs updated
+* [X] Release note
+
+/assign @reviewer
+/kind feature
+@k8s-bot test this
+
+**Release note**:
+` + "```release-note\nNONE\n```"
+
+func TestSquashCommitMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		strip    []string
+		body     *string
+		title    string
+		expected string
+	}{
+		{
+			name:  "default rules",
+			strip: defaultSquashStripLines,
+			body:  stringPtr(samplePRBody),
+			title: "My issue title (#1)",
+			expected: `Makes the queue squash commit messages.
+
+Checklist:
+
+**Release note**:
+` + "```release-note\nNONE\n```",
+		},
+		{
+			name:  "no rules",
+			body:  stringPtr("Some text\n- [x] Tests added"),
+			title: "My issue title (#1)",
+			expected: `Some text
+- [x] Tests added`,
+		},
+		{
+			name:     "custom rules",
+			strip:    []string{`^\*\*Release note`, "^```", `^NONE$`},
+			body:     stringPtr("Some text\n\n**Release note**:\n```release-note\nNONE\n```"),
+			title:    "My issue title (#1)",
+			expected: `Some text`,
+		},
+		{
+			name:  "no body",
+			strip: defaultSquashStripLines,
+			title: "My issue title (#1)",
+		},
+	}
+	for _, test := range tests {
+		sq := getTestSQ(false, nil, nil)
+		var err error
+		if sq.squashStripLines, err = parseSquashStripLines(test.strip); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		issue := LGTMApprovedIssue()
+		issue.Body = test.body
+		obj := github_util.TestObject(nil, issue, ValidPR(), Commits(), NewLGTMEvents())
+		title, message := sq.squashCommitMessage(obj)
+		if title != test.title {
+			t.Errorf("%s: expected the title %q but got %q", test.name, test.title, title)
+		}
+		if message != test.expected {
+			t.Errorf("%s: expected the message %q but got %q", test.name, test.expected, message)
+		}
+	}
+
+	if _, err := parseSquashStripLines([]string{"("}); err == nil {
+		t.Errorf("expected an invalid regexp to be rejected")
+	}
+}
+
+func TestSquashMergeSendsMessage(t *testing.T) {
+	issue := LGTMApprovedIssue()
+	issue.Body = stringPtr("Some text\n- [ ] Docs updated")
+	client, server, mux := github_test.InitServer(t, issue, ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	body := map[string]interface{}{}
+	mux.HandleFunc("/repos/o/r/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		data, _ := json.Marshal(github.PullRequestMergeResult{})
+		w.Write(data)
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	sq.MergeMethod = "squash"
+	sq.squashStripLines, _ = parseSquashStripLines(defaultSquashStripLines)
+	obj := github_util.TestObject(config, issue, ValidPR(), Commits(), NewLGTMEvents())
+	if !sq.mergePullRequest(obj, merged, "") {
+		t.Fatalf("merge failed")
+	}
+	if title := body["commit_title"]; title != "My issue title (#1)" {
+		t.Errorf("expected the PR's title but got %v", title)
+	}
+	if message := body["commit_message"]; message != "Some text" {
+		t.Errorf("expected the cleaned up body but got %v", message)
+	}
+}
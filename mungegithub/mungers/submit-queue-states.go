@@ -0,0 +1,131 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultReasonState is the github state reported for a reason unless
+// --reason-states says otherwise.
+func defaultReasonState(reason string) string {
+	switch reason {
+	case merged, mergedByHand, mergedSkippedRetest, mergedBatch, mergedTrain, emergencyMerged:
+		return "success"
+	case e2eFailure, staleCI, ghE2EQueued, ghE2EWaitingStart, ghE2ERunning, retryingE2E, retryingInfra, queuePaused:
+		return "success"
+	case unknown:
+		return "failure"
+	default:
+		return "pending"
+	}
+}
+
+// reasonNames maps the names --reason-states accepts, which are those of the
+// reason constants, to the reason text. Reasons which mention a label use
+// the label the queue is configured with.
+func (sq *SubmitQueue) reasonNames() map[string]string {
+	return map[string]string{
+		"unknown":                 unknown,
+		"noCLA":                   sq.noCLAReason(),
+		"noLGTM":                  fmt.Sprintf(noLGTMFmt, sq.LGTMLabel),
+		"noApproved":              noApproved,
+		"lgtmEarly":               fmt.Sprintf(lgtmEarlyFmt, sq.LGTMLabel),
+		"lgtmHeadChanged":         fmt.Sprintf(lgtmHeadChangedFmt, sq.LGTMLabel),
+		"approvedEarly":           approvedEarly,
+		"unmergeable":             unmergeable,
+		"undeterminedMergability": undeterminedMergability,
+		"noMerge":                 fmt.Sprintf(noMergeFmt, sq.DoNotMergeLabel),
+		"ciFailure":               ciFailure,
+		"ciPending":               ciPending,
+		"e2eFailure":              e2eFailure,
+		"e2eRecover":              e2eRecover,
+		"merged":                  merged,
+		"mergedSkippedRetest":     mergedSkippedRetest,
+		"mergedBatch":             mergedBatch,
+		"mergedTrain":             mergedTrain,
+		"mergedByHand":            mergedByHand,
+		"emergencyMerged":         emergencyMerged,
+		"mergeConflict":           mergeConflict,
+		"ghE2EQueued":             ghE2EQueued,
+		"ghE2EWaitingStart":       ghE2EWaitingStart,
+		"ghE2ERunning":            ghE2ERunning,
+		"ghE2EFailed":             ghE2EFailed,
+		"retryingE2E":             retryingE2E,
+		"retryingInfra":           retryingInfra,
+		"ghE2EAborted":            ghE2EAborted,
+		"e2eTimeout":              e2eTimeout,
+		"unmergeableMilestone":    unmergeableMilestone,
+		"headCommitChanged":       headCommitChanged,
+		"baseBranchMoved":         baseBranchMoved,
+		"cooling":                 cooling,
+		"noTrackerTicket":         noTrackerTicket,
+		"trackerNotReady":         trackerNotReady,
+		"needsMoreApprovals":      needsMoreApprovals,
+		"wip":                     wip,
+		"wrongBranch":             wrongBranch,
+		"outsideMergeWindow":      outsideMergeWindow,
+		"missingContext":          missingContext,
+		"missingRequiredLabel":    missingRequiredLabel,
+		"baseBranchRed":           baseBranchRed,
+		"blockedByDependency":     blockedByDependency,
+		"queueFull":               queueFull,
+		"queuePaused":             queuePaused,
+		"blockedAuthor":           blockedAuthor,
+		"staleCI":                 staleCI,
+	}
+}
+
+// parseReasonStates turns "ciFailure=failure" style entries into a map from
+// reason text to the github state to report for it.
+func (sq *SubmitQueue) parseReasonStates(specs []string) (map[string]string, error) {
+	names := sq.reasonNames()
+	states := map[string]string{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid reason state %q, expected something like ciFailure=failure", spec)
+		}
+		reason, ok := names[parts[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown reason %q in reason state %q", parts[0], spec)
+		}
+		switch parts[1] {
+		case "success", "pending", "failure":
+		default:
+			return nil, fmt.Errorf("invalid state in reason state %q, must be success, pending or failure", spec)
+		}
+		states[reason] = parts[1]
+	}
+	return states, nil
+}
+
+// reasonToState returns the github state to report for reason. Reasons like
+// ciFailureFmt, which add ": <context>", use the state of the reason before
+// the colon.
+func (sq *SubmitQueue) reasonToState(reason string) string {
+	if state, ok := sq.reasonStates[reason]; ok {
+		return state
+	}
+	if i := strings.Index(reason, ": "); i >= 0 {
+		if state, ok := sq.reasonStates[reason[:i]]; ok {
+			return state
+		}
+	}
+	return defaultReasonState(reason)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestParseReasonStates(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	sq.LGTMLabel = "looks-good"
+	states, err := sq.parseReasonStates([]string{"ciFailure=failure", "noLGTM=failure", "merged=pending"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		ciFailure:                            "failure",
+		fmt.Sprintf(noLGTMFmt, "looks-good"): "failure",
+		merged:                               "pending",
+	}
+	if len(states) != len(expected) {
+		t.Errorf("expected %v but got %v", expected, states)
+	}
+	for reason, state := range expected {
+		if states[reason] != state {
+			t.Errorf("expected %q to be %s but got %q", reason, state, states[reason])
+		}
+	}
+
+	for _, bad := range []string{"ciFailure", "notAReason=failure", "ciFailure=red"} {
+		if _, err := sq.parseReasonStates([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestReasonStatesPosted(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), nil, nil, nil)
+	defer server.Close()
+	posted := ""
+	mux.HandleFunc("/repos/o/r/statuses/mysha", func(w http.ResponseWriter, r *http.Request) {
+		status := github.RepoStatus{}
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		posted = *status.State
+		data, _ := json.Marshal(status)
+		w.Write(data)
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	states, err := sq.parseReasonStates([]string{"ciFailure=failure"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+
+	tests := []struct {
+		reason   string
+		expected string
+	}{
+		{reason: fmt.Sprintf(ciFailureFmt, requiredReTestContext1), expected: "pending"},
+		{reason: merged, expected: "success"},
+	}
+	for _, test := range tests {
+		sq.SetMergeStatus(obj, test.reason)
+		if posted != test.expected {
+			t.Errorf("default %q: expected %s but posted %q", test.reason, test.expected, posted)
+		}
+	}
+
+	sq.reasonStates = states
+	tests[0].expected = "failure"
+	for _, test := range tests {
+		sq.SetMergeStatus(obj, test.reason)
+		if posted != test.expected {
+			t.Errorf("overridden %q: expected %s but posted %q", test.reason, test.expected, posted)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// StatusBackend is how the submit queue reads CI results for a PR and
+// reports its own state on the PR.
+type StatusBackend interface {
+	// IsSuccess returns true if all of the contexts are passing. The
+	// second return value is false if the results could not be read.
+	IsSuccess(obj *github.MungeObject, contexts []string) (bool, bool)
+	// State returns the combined state of the contexts, the way
+	// github.MungeObject.GetStatusState does: "incomplete" if any is
+	// missing, otherwise "pending", "error", "failure" or "success".
+	State(obj *github.MungeObject, contexts []string) (string, bool)
+	// TargetURL returns the link the context reported, usually to its
+	// build, or "" if it has none.
+	TargetURL(obj *github.MungeObject, context string) (string, bool)
+	// UpdatedAt returns when the context was last reported.
+	UpdatedAt(obj *github.MungeObject, context string) (*time.Time, bool)
+	// Reported returns true if anything has been reported for the context
+	// on the PR's head commit, whether or not it passed.
+	Reported(obj *github.MungeObject, context string) (bool, bool)
+	// Description returns what the queue last reported for the PR.
+	Description(obj *github.MungeObject) (string, bool)
+	// Set reports the queue's state ("success", "failure" or "pending")
+	// and description for the PR.
+	Set(obj *github.MungeObject, state, url, description string) bool
+}
+
+// commitStatusBackend uses the github commit statuses API.
+type commitStatusBackend struct{}
+
+func (commitStatusBackend) IsSuccess(obj *github.MungeObject, contexts []string) (bool, bool) {
+	return obj.IsStatusSuccess(contexts)
+}
+
+func (commitStatusBackend) State(obj *github.MungeObject, contexts []string) (string, bool) {
+	return obj.GetStatusState(contexts)
+}
+
+func (commitStatusBackend) TargetURL(obj *github.MungeObject, context string) (string, bool) {
+	status, ok := obj.GetStatus(context)
+	if !ok || status == nil || status.TargetURL == nil {
+		return "", ok
+	}
+	return *status.TargetURL, true
+}
+
+func (commitStatusBackend) UpdatedAt(obj *github.MungeObject, context string) (*time.Time, bool) {
+	return obj.GetStatusTime(context)
+}
+
+func (commitStatusBackend) Reported(obj *github.MungeObject, context string) (bool, bool) {
+	status, ok := obj.GetStatus(context)
+	return status != nil, ok
+}
+
+func (commitStatusBackend) Description(obj *github.MungeObject) (string, bool) {
+	status, ok := obj.GetStatus(sqContext)
+	if !ok || status == nil || status.Description == nil {
+		return "", false
+	}
+	return *status.Description, true
+}
+
+func (commitStatusBackend) Set(obj *github.MungeObject, state, url, description string) bool {
+	return obj.SetStatus(state, url, description, sqContext)
+}
+
+// checkRunBackend uses the github checks API. CI systems which still report
+// commit statuses keep working: a context with no check run of the same
+// name is read from the commit statuses instead.
+type checkRunBackend struct{}
+
+// latestCheckRuns returns the newest check run with each name.
+func latestCheckRuns(obj *github.MungeObject) (map[string]*github.CheckRun, bool) {
+	runs, ok := obj.ListCheckRuns()
+	if !ok {
+		return nil, false
+	}
+	latest := map[string]*github.CheckRun{}
+	for _, run := range runs {
+		if run.Name == nil || run.ID == nil {
+			continue
+		}
+		// Reruns get new, larger, IDs
+		if prev, found := latest[*run.Name]; !found || *prev.ID < *run.ID {
+			latest[*run.Name] = run
+		}
+	}
+	return latest, true
+}
+
+// checkRunState is the commit status state equivalent to run's.
+func checkRunState(run *github.CheckRun) string {
+	switch {
+	case run.Status == nil || *run.Status != "completed":
+		return "pending"
+	case run.Conclusion != nil && *run.Conclusion == "success":
+		return "success"
+	default:
+		return "failure"
+	}
+}
+
+func (b checkRunBackend) IsSuccess(obj *github.MungeObject, contexts []string) (bool, bool) {
+	state, ok := b.State(obj, contexts)
+	return ok && state == "success", ok
+}
+
+func (checkRunBackend) State(obj *github.MungeObject, contexts []string) (string, bool) {
+	if len(contexts) == 0 {
+		return "success", true
+	}
+	latest, ok := latestCheckRuns(obj)
+	if !ok {
+		return "failure", false
+	}
+
+	states := sets.NewString()
+	fallback := []string{}
+	for _, context := range contexts {
+		run, found := latest[context]
+		if !found {
+			fallback = append(fallback, context)
+			continue
+		}
+		states.Insert(checkRunState(run))
+	}
+	if len(fallback) > 0 {
+		state, ok := obj.GetStatusState(fallback)
+		if !ok {
+			return "failure", false
+		}
+		states.Insert(state)
+	}
+	for _, state := range []string{"incomplete", "pending", "error", "failure"} {
+		if states.Has(state) {
+			return state, true
+		}
+	}
+	return "success", true
+}
+
+func (checkRunBackend) TargetURL(obj *github.MungeObject, context string) (string, bool) {
+	latest, ok := latestCheckRuns(obj)
+	if !ok {
+		return "", false
+	}
+	run, found := latest[context]
+	if !found {
+		return commitStatusBackend{}.TargetURL(obj, context)
+	}
+	if run.DetailsURL == nil {
+		return "", true
+	}
+	return *run.DetailsURL, true
+}
+
+func (checkRunBackend) UpdatedAt(obj *github.MungeObject, context string) (*time.Time, bool) {
+	latest, ok := latestCheckRuns(obj)
+	if !ok {
+		return nil, false
+	}
+	run, found := latest[context]
+	if !found {
+		return commitStatusBackend{}.UpdatedAt(obj, context)
+	}
+	if run.CompletedAt != nil {
+		return run.CompletedAt, true
+	}
+	return run.StartedAt, run.StartedAt != nil
+}
+
+func (checkRunBackend) Reported(obj *github.MungeObject, context string) (bool, bool) {
+	runs, ok := obj.ListCheckRuns()
+	if !ok {
+		return false, false
+	}
+	for _, run := range runs {
+		if run.Name != nil && *run.Name == context {
+			return true, true
+		}
+	}
+	return commitStatusBackend{}.Reported(obj, context)
+}
+
+func (checkRunBackend) Description(obj *github.MungeObject) (string, bool) {
+	runs, ok := obj.ListCheckRuns()
+	if !ok {
+		return "", false
+	}
+	for _, run := range runs {
+		if run.Name != nil && *run.Name == sqContext && run.Output != nil && run.Output.Title != nil {
+			return *run.Output.Title, true
+		}
+	}
+	return "", false
+}
+
+func (checkRunBackend) Set(obj *github.MungeObject, state, url, description string) bool {
+	switch state {
+	case "success", "failure":
+		return obj.SetCheckRun(sqContext, "completed", state, url, description)
+	default:
+		return obj.SetCheckRun(sqContext, "in_progress", "", url, description)
+	}
+}
+
+// statusBackend returns the backend the queue was configured with, defaulting
+// to commit statuses.
+func (sq *SubmitQueue) statusBackend() StatusBackend {
+	if sq.backend == nil {
+		return commitStatusBackend{}
+	}
+	return sq.backend
+}
+
+// waitForPending waits for the contexts to start running, reading them from
+// the configured backend. It returns false if it timed out or was aborted.
+func (sq *SubmitQueue) waitForPending(obj *github.MungeObject, contexts []string, abort <-chan struct{}) bool {
+	return obj.WaitForPendingStateOrAbort(sq.stateFunc(obj, contexts), abort)
+}
+
+// waitForNotPending waits for the contexts to finish running, reading them
+// from the configured backend. It returns false if it timed out or was
+// aborted.
+func (sq *SubmitQueue) waitForNotPending(obj *github.MungeObject, contexts []string, abort <-chan struct{}) bool {
+	return obj.WaitForNotPendingStateOrAbort(sq.stateFunc(obj, contexts), abort)
+}
+
+func (sq *SubmitQueue) stateFunc(obj *github.MungeObject, contexts []string) github.StateFunc {
+	backend := sq.statusBackend()
+	return func() (string, bool) {
+		return backend.State(obj, contexts)
+	}
+}
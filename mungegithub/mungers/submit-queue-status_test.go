@@ -0,0 +1,241 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+)
+
+func checkRun(id int, name, status, conclusion string) *github_util.CheckRun {
+	run := &github_util.CheckRun{
+		ID:     intPtr(id),
+		Name:   stringPtr(name),
+		Status: stringPtr(status),
+	}
+	if conclusion != "" {
+		run.Conclusion = stringPtr(conclusion)
+	}
+	return run
+}
+
+func TestCheckRunBackendIsSuccess(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name     string
+		runs     []*github_util.CheckRun
+		contexts []string
+		success  bool
+	}{
+		{
+			name:     "check run passed",
+			runs:     []*github_util.CheckRun{checkRun(1, "unit", "completed", "success")},
+			contexts: []string{"unit"},
+			success:  true,
+		},
+		{
+			name:     "check run failed",
+			runs:     []*github_util.CheckRun{checkRun(1, "unit", "completed", "failure")},
+			contexts: []string{"unit"},
+			success:  false,
+		},
+		{
+			name:     "check run still running",
+			runs:     []*github_util.CheckRun{checkRun(1, "unit", "in_progress", "")},
+			contexts: []string{"unit"},
+			success:  false,
+		},
+		{
+			name: "rerun passed",
+			runs: []*github_util.CheckRun{
+				checkRun(2, "unit", "completed", "success"),
+				checkRun(1, "unit", "completed", "failure"),
+			},
+			contexts: []string{"unit"},
+			success:  true,
+		},
+		{
+			name:     "falls back to commit status",
+			runs:     []*github_util.CheckRun{checkRun(1, "unit", "completed", "success")},
+			contexts: []string{"unit", requiredReTestContext1},
+			success:  true,
+		},
+		{
+			name:     "missing everywhere",
+			runs:     []*github_util.CheckRun{checkRun(1, "unit", "completed", "success")},
+			contexts: []string{"unit", "e2e"},
+			success:  false,
+		},
+	}
+	for _, test := range tests {
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), nil, nil, SuccessStatus(), nil, nil)
+		runs := test.runs
+		mux.HandleFunc("/repos/o/r/commits/mysha/check-runs", func(w http.ResponseWriter, r *http.Request) {
+			data, err := json.Marshal(map[string]interface{}{"total_count": len(runs), "check_runs": runs})
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), nil, nil)
+
+		success, ok := checkRunBackend{}.IsSuccess(obj, test.contexts)
+		if !ok || success != test.success {
+			t.Errorf("%s: expected success=%v but got %v (ok=%v)", test.name, test.success, success, ok)
+		}
+		server.Close()
+	}
+}
+
+func TestCheckRunBackendSet(t *testing.T) {
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), nil, nil, nil, nil, nil)
+	defer server.Close()
+
+	runs := []*github_util.CheckRun{}
+	mux.HandleFunc("/repos/o/r/commits/mysha/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := json.Marshal(map[string]interface{}{"total_count": len(runs), "check_runs": runs})
+		w.Write(data)
+	})
+	methods := []string{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		run := &github_util.CheckRun{}
+		json.NewDecoder(r.Body).Decode(run)
+		run.ID = intPtr(7)
+		runs = []*github_util.CheckRun{run}
+		data, _ := json.Marshal(run)
+		w.Write(data)
+	}
+	mux.HandleFunc("/repos/o/r/check-runs", handler)
+	mux.HandleFunc("/repos/o/r/check-runs/7", handler)
+
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), nil, nil)
+
+	backend := checkRunBackend{}
+	if !backend.Set(obj, "pending", "http://example.com", ghE2EQueued) {
+		t.Fatalf("unable to create check run")
+	}
+	if *runs[0].Status != "in_progress" || runs[0].Conclusion != nil {
+		t.Errorf("unexpected pending check run: %v/%v", *runs[0].Status, runs[0].Conclusion)
+	}
+	if description, ok := backend.Description(obj); !ok || description != ghE2EQueued {
+		t.Errorf("expected description %q but got %q", ghE2EQueued, description)
+	}
+
+	if !backend.Set(obj, "success", "http://example.com", merged) {
+		t.Fatalf("unable to update check run")
+	}
+	if *runs[0].Status != "completed" || *runs[0].Conclusion != "success" {
+		t.Errorf("unexpected completed check run: %v/%v", *runs[0].Status, runs[0].Conclusion)
+	}
+	if len(methods) != 2 || methods[0] != "POST" || methods[1] != "PATCH" {
+		t.Errorf("expected a POST then a PATCH but got %v", methods)
+	}
+}
+
+// checkRunTestObject returns an object for a PR whose check runs are *runs,
+// and counts the times they are listed in *lists.
+func checkRunTestObject(t *testing.T, runs *[]*github_util.CheckRun, lists *int) (*github_util.MungeObject, func()) {
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), nil, nil, SuccessStatus(), nil, nil)
+	mux.HandleFunc("/repos/o/r/commits/mysha/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		*lists++
+		data, err := json.Marshal(map[string]interface{}{"total_count": len(*runs), "check_runs": *runs})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		w.Write(data)
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+	return github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), nil, nil), server.Close
+}
+
+func TestCheckRunBackendState(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	completed := time.Unix(100, 0)
+	passed := checkRun(1, "unit", "completed", "success")
+	passed.DetailsURL = stringPtr("https://ci.example.com/job/unit/12/")
+	passed.CompletedAt = &completed
+	runs := []*github_util.CheckRun{passed, checkRun(2, "e2e", "queued", "")}
+	lists := 0
+	obj, done := checkRunTestObject(t, &runs, &lists)
+	defer done()
+	backend := checkRunBackend{}
+
+	if state, ok := backend.State(obj, []string{"unit", "e2e"}); !ok || state != "pending" {
+		t.Errorf("expected pending but got %q", state)
+	}
+	if state, ok := backend.State(obj, []string{"unit", "lint"}); !ok || state != "incomplete" {
+		t.Errorf("expected incomplete but got %q", state)
+	}
+	if url, ok := backend.TargetURL(obj, "unit"); !ok || url != *passed.DetailsURL {
+		t.Errorf("expected the details URL but got %q", url)
+	}
+	// The test's commit statuses have no target URL
+	if url, ok := backend.TargetURL(obj, requiredReTestContext1); !ok || url != "" {
+		t.Errorf("expected the commit status's empty target URL but got %q (ok=%v)", url, ok)
+	}
+	if updated, ok := backend.UpdatedAt(obj, "unit"); !ok || updated == nil || !updated.Equal(completed) {
+		t.Errorf("expected the completion time but got %v", updated)
+	}
+
+	sq := getTestSQ(false, nil, nil)
+	sq.backend = backend
+	if !sq.waitForPending(obj, []string{"e2e"}, nil) {
+		t.Errorf("expected the queued check run to count as pending")
+	}
+	runs[1] = checkRun(2, "e2e", "completed", "failure")
+	if !sq.waitForNotPending(obj, []string{"e2e"}, nil) {
+		t.Errorf("expected the completed check run to count as not pending")
+	}
+}
+
+func TestCheckRunsCached(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(time.Minute)
+	defer github_util.SetCombinedStatusLifetime(1)
+
+	runs := []*github_util.CheckRun{checkRun(1, "unit", "completed", "success")}
+	lists := 0
+	obj, done := checkRunTestObject(t, &runs, &lists)
+	defer done()
+	backend := checkRunBackend{}
+
+	for i := 0; i < 3; i++ {
+		backend.IsSuccess(obj, []string{"unit"})
+		backend.Description(obj)
+	}
+	if lists != 1 {
+		t.Errorf("expected the check runs to be listed once but got %d", lists)
+	}
+}
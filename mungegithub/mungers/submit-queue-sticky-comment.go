@@ -0,0 +1,163 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+
+	githubapi "github.com/google/go-github/github"
+)
+
+const (
+	stickyStatusMarker = "<!-- submit-queue-status -->"
+	stickyStatusPrefix = "**Submit queue status:** "
+
+	checkMark = ":heavy_check_mark:"
+	crossMark = ":x:"
+)
+
+var stickyStatusRE = regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(stickyStatusPrefix) + `(.*)$`)
+
+// stickyComment is a single bot comment on a PR, found again by its hidden
+// marker, which is edited in place rather than posted anew.
+type stickyComment struct {
+	marker string
+}
+
+// find returns the latest bot comment on obj with the marker, or nil.
+func (s stickyComment) find(obj *github.MungeObject) (*githubapi.IssueComment, bool) {
+	comments, ok := obj.ListComments()
+	if !ok {
+		return nil, false
+	}
+	var found *githubapi.IssueComment
+	for _, comment := range comments {
+		if !validComment(comment) || !mergeBotComment(comment) {
+			continue
+		}
+		if strings.Contains(*comment.Body, s.marker) {
+			found = comment
+		}
+	}
+	return found, true
+}
+
+// update makes body, followed by the marker, the contents of the sticky
+// comment, posting it if there isn't one yet.
+func (s stickyComment) update(obj *github.MungeObject, body string) error {
+	body = body + "\n\n" + s.marker
+	comment, ok := s.find(obj)
+	if !ok {
+		return fmt.Errorf("unable to list the comments on %d", obj.Number())
+	}
+	if comment == nil {
+		return obj.WriteComment(body)
+	}
+	if *comment.Body == body {
+		return nil
+	}
+	return obj.EditComment(comment, body)
+}
+
+// stickyCommentBackend reports the queue's state as a checklist in a sticky
+// comment rather than as a status. CI results are still read from the
+// embedded StatusBackend.
+type stickyCommentBackend struct {
+	StatusBackend
+	sq      *SubmitQueue
+	comment stickyComment
+}
+
+func newStickyCommentBackend(sq *SubmitQueue, backend StatusBackend) *stickyCommentBackend {
+	return &stickyCommentBackend{
+		StatusBackend: backend,
+		sq:            sq,
+		comment:       stickyComment{marker: stickyStatusMarker},
+	}
+}
+
+func (b *stickyCommentBackend) Description(obj *github.MungeObject) (string, bool) {
+	comment, ok := b.comment.find(obj)
+	if !ok || comment == nil {
+		return "", false
+	}
+	match := stickyStatusRE.FindStringSubmatch(*comment.Body)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+func (b *stickyCommentBackend) Set(obj *github.MungeObject, state, url, description string) bool {
+	if err := b.comment.update(obj, b.sq.stickyStatusBody(obj, description)); err != nil {
+		obj.Log().Errorf("unable to update the status comment: %v", err)
+		return false
+	}
+	return true
+}
+
+// checklistItem is one of the things a PR needs to merge.
+type checklistItem struct {
+	name string
+	done bool
+}
+
+// stickyStatusBody is the checklist of what obj needs to merge, and where it
+// is in the queue.
+func (sq *SubmitQueue) stickyStatusBody(obj *github.MungeObject, description string) string {
+	ci, ok := sq.contextsSucceeded(obj, sq.RequiredStatusContexts)
+	ci = ci && ok
+	e2e, ok := sq.contextsSucceeded(obj, sq.retestContexts(obj))
+	e2e = e2e && ok
+	approval := checklistItem{"LGTM", obj.HasLabel(sq.LGTMLabel)}
+	if sq.ReviewMode {
+		approved, ok := sq.hasApprovingReviews(obj)
+		approval = checklistItem{"Approving reviews", approved && ok}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s%s\n\n", stickyStatusPrefix, description)
+	for _, item := range []checklistItem{
+		{"CLA", sq.hasCLA(obj)},
+		approval,
+		{"CI", ci},
+		{"e2e", e2e},
+	} {
+		mark := crossMark
+		if item.done {
+			mark = checkMark
+		}
+		fmt.Fprintf(&buf, "- %s %s\n", mark, item.name)
+	}
+
+	sq.Lock()
+	queue := sq.orderedE2EQueue()
+	sq.Unlock()
+	key := sq.prKey(obj)
+	for i, k := range queue {
+		if k == key {
+			fmt.Fprintf(&buf, "\nQueue position: %d of %d\n", i+1, len(queue))
+			break
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+// commentServer serves the comments on PR 1, recording posts and edits.
+type commentServer struct {
+	comments []*github.IssueComment
+	posts    int
+	edits    int
+}
+
+func (s *commentServer) install(t *testing.T, mux *http.ServeMux) {
+	mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			data, _ := json.Marshal(s.comments)
+			w.Write(data)
+		case "POST":
+			comment := &github.IssueComment{}
+			if err := json.NewDecoder(r.Body).Decode(comment); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			s.posts++
+			comment.ID = intPtr(100 + s.posts)
+			comment.User = &github.User{Login: stringPtr(botName)}
+			comment.CreatedAt = timePtr(time.Unix(int64(100+s.posts), 0))
+			s.comments = append(s.comments, comment)
+			data, _ := json.Marshal(comment)
+			w.Write(data)
+		default:
+			t.Errorf("Unexpected method: %s", r.Method)
+		}
+	})
+	mux.HandleFunc("/repos/o/r/issues/comments/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("Unexpected method: %s", r.Method)
+		}
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/repos/o/r/issues/comments/"))
+		patch := &github.IssueComment{}
+		if err := json.NewDecoder(r.Body).Decode(patch); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		for _, comment := range s.comments {
+			if *comment.ID == id {
+				s.edits++
+				comment.Body = patch.Body
+				data, _ := json.Marshal(comment)
+				w.Write(data)
+				return
+			}
+		}
+		t.Errorf("edit of unknown comment %d", id)
+	})
+}
+
+func TestStickyStatusComment(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  []*github.IssueComment
+		posts     int
+		edits     int
+		editedID  int
+		untouched string
+	}{
+		{
+			name:  "first status is posted, later ones edit it",
+			posts: 1,
+			edits: 1,
+		},
+		{
+			name: "a marked comment is edited",
+			existing: []*github.IssueComment{
+				{ID: intPtr(1), User: &github.User{Login: stringPtr(botName)}, CreatedAt: timePtr(time.Unix(1, 0)), Body: stringPtr("old\n\n" + stickyStatusMarker)},
+				{ID: intPtr(2), User: &github.User{Login: stringPtr(botName)}, CreatedAt: timePtr(time.Unix(2, 0)), Body: stringPtr("something else")},
+			},
+			edits:     2,
+			editedID:  1,
+			untouched: "something else",
+		},
+		{
+			name: "a marked comment by someone else is left alone",
+			existing: []*github.IssueComment{
+				{ID: intPtr(1), User: &github.User{Login: stringPtr("bob")}, CreatedAt: timePtr(time.Unix(1, 0)), Body: stringPtr("quoted\n\n" + stickyStatusMarker)},
+			},
+			posts:     1,
+			edits:     1,
+			untouched: "quoted\n\n" + stickyStatusMarker,
+		},
+	}
+	for _, test := range tests {
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		comments := &commentServer{comments: test.existing}
+		comments.install(t, mux)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		backend := newStickyCommentBackend(sq, commitStatusBackend{})
+		sq.backend = backend
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		sq.enqueue(obj)
+		sq.SetMergeStatus(obj, ghE2ERunning)
+		server.Close()
+
+		if comments.posts != test.posts || comments.edits != test.edits {
+			t.Errorf("%s: expected %d posts and %d edits, saw %d and %d", test.name, test.posts, test.edits, comments.posts, comments.edits)
+		}
+		marked := 0
+		for _, comment := range comments.comments {
+			body := *comment.Body
+			if test.untouched != "" && strings.HasPrefix(body, test.untouched) {
+				if body != test.untouched {
+					t.Errorf("%s: expected comment %d to be left alone, saw: %q", test.name, *comment.ID, body)
+				}
+				continue
+			}
+			if !strings.Contains(body, stickyStatusMarker) {
+				continue
+			}
+			marked++
+			if test.editedID != 0 && *comment.ID != test.editedID {
+				t.Errorf("%s: expected comment %d to be edited, saw: %d", test.name, test.editedID, *comment.ID)
+			}
+			for _, expected := range []string{stickyStatusPrefix + ghE2ERunning, checkMark + " LGTM", "Queue position: 1 of 1"} {
+				if !strings.Contains(body, expected) {
+					t.Errorf("%s: expected the status comment to contain %q, saw: %q", test.name, expected, body)
+				}
+			}
+		}
+		if marked != 1 {
+			t.Errorf("%s: expected one status comment, saw %d: %v", test.name, marked, comments.comments)
+		}
+		if description, ok := backend.Description(obj); !ok || description != ghE2ERunning {
+			t.Errorf("%s: expected the description %q, saw %q", test.name, ghE2ERunning, description)
+		}
+	}
+}
+
+func TestStickyStatusCommentRefresh(t *testing.T) {
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	comments := &commentServer{}
+	comments.install(t, mux)
+	mux.HandleFunc("/repos/o/r/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := json.Marshal([]*github_util.PullRequestReview{review("bob", "APPROVED", 10)})
+		w.Write(data)
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	sq.ReviewMode = true
+	sq.ApprovingReviewsRequired = 2
+	sq.backend = newStickyCommentBackend(sq, commitStatusBackend{})
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	sq.enqueue(obj)
+	sq.SetMergeStatus(obj, ghE2EQueued)
+
+	// The same reason, but another PR jumped ahead in the queue
+	ahead := github_util.TestObject(config, github_test.Issue(someUserName, 2, []string{retestNotRequiredLabel}, true), ValidPR(), Commits(), NewLGTMEvents())
+	sq.githubE2EQueue[sq.prKey(ahead)] = ahead
+	sq.SetMergeStatus(obj, ghE2EQueued)
+	if comments.posts != 1 || comments.edits != 1 {
+		t.Fatalf("expected the comment to be posted and then edited, saw %d posts and %d edits", comments.posts, comments.edits)
+	}
+	body := *comments.comments[0].Body
+	for _, expected := range []string{"Queue position: 2 of 2", crossMark + " Approving reviews"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected the status comment to contain %q, saw: %q", expected, body)
+		}
+	}
+	if strings.Contains(body, "LGTM") {
+		t.Errorf("expected no LGTM item in review mode, saw: %q", body)
+	}
+
+	// Nothing changed, so nothing is edited
+	sq.SetMergeStatus(obj, ghE2EQueued)
+	if comments.edits != 1 {
+		t.Errorf("expected an unchanged comment not to be edited, saw %d edits", comments.edits)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+
+	"k8s.io/contrib/mungegithub/github"
+	"k8s.io/kubernetes/pkg/util/yaml"
+)
+
+const (
+	queuedTemplate  = "queued"
+	ejectedTemplate = "ejected"
+	retestTemplate  = "retest"
+
+	defaultQueuedTemplate  = queueCommentPrefix + " at position {{.Position}}. Estimated time to merge: {{.ETA}}."
+	defaultEjectedTemplate = "@{{.Author}} " + ejectionCommentPrefix + "{{.Reason}}.\n\n{{.Details}}It will be queued again automatically once this is fixed."
+	defaultRetestTemplate  = "@{{.Bot}} {{.Phrase}} [submit-queue is verifying that this PR is safe to merge]"
+)
+
+// commentTemplateConfig is the --comment-templates file. Each entry is a
+// text/template executed with a commentData. Entries which are left out
+// keep the default wording.
+type commentTemplateConfig struct {
+	Queued  string `json:"queued,omitempty" yaml:"queued,omitempty"`
+	Ejected string `json:"ejected,omitempty" yaml:"ejected,omitempty"`
+	Retest  string `json:"retest,omitempty" yaml:"retest,omitempty"`
+}
+
+// commentData is what the comment templates can refer to. Fields which
+// don't apply to a comment are empty.
+type commentData struct {
+	// Number and Author are the PR's, in every comment.
+	Number int
+	Author string
+	// Position is where the PR is in the queue, starting from 1, and ETA
+	// roughly when it will merge, in the queued comment.
+	Position int
+	ETA      string
+	// Reason is why the PR left the queue, BuildURL the first failed build
+	// if there is one and Details a list of all of the failed builds, in
+	// the ejected comment.
+	Reason   string
+	BuildURL string
+	Details  string
+	// Bot is the --e2e-bot-name and Phrase the --e2e-trigger-phrase, in
+	// the retest comment.
+	Bot    string
+	Phrase string
+}
+
+// sampleCommentData is used to check that templates can be executed.
+var sampleCommentData = commentData{
+	Number:   1234,
+	Author:   "author",
+	Position: 2,
+	ETA:      "1h0m0s",
+	Reason:   "the tests which are rerun before merging failed",
+	BuildURL: "https://example.com/build/1",
+	Details:  "* context: https://example.com/build/1\n\n",
+	Bot:      jenkinsBotName,
+	Phrase:   e2eTriggerPhrase,
+}
+
+var defaultCommentTemplates = mustParseCommentTemplates(commentTemplateConfig{})
+
+// commentTemplates are the parsed templates, keyed by queuedTemplate and
+// friends. queuedPrefix is the text every queued comment starts with, so
+// the old ones can be recognised as stale.
+type commentTemplates struct {
+	templates    map[string]*template.Template
+	queuedPrefix string
+}
+
+func parseCommentTemplates(config commentTemplateConfig) (*commentTemplates, error) {
+	texts := map[string]string{
+		queuedTemplate:  config.Queued,
+		ejectedTemplate: config.Ejected,
+		retestTemplate:  config.Retest,
+	}
+	defaults := map[string]string{
+		queuedTemplate:  defaultQueuedTemplate,
+		ejectedTemplate: defaultEjectedTemplate,
+		retestTemplate:  defaultRetestTemplate,
+	}
+	out := &commentTemplates{templates: map[string]*template.Template{}}
+	for name, text := range texts {
+		if text == "" {
+			text = defaults[name]
+		}
+		t, err := template.New(name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s comment template: %v", name, err)
+		}
+		if err := t.Execute(ioutil.Discard, sampleCommentData); err != nil {
+			return nil, fmt.Errorf("invalid %s comment template: %v", name, err)
+		}
+		out.templates[name] = t
+		if name == queuedTemplate {
+			out.queuedPrefix = text
+			if i := strings.Index(text, "{{"); i >= 0 {
+				out.queuedPrefix = text[:i]
+			}
+			if strings.TrimSpace(out.queuedPrefix) == "" {
+				return nil, fmt.Errorf("the %s comment template must start with some text, not %q", name, text)
+			}
+		}
+	}
+	return out, nil
+}
+
+func mustParseCommentTemplates(config commentTemplateConfig) *commentTemplates {
+	templates, err := parseCommentTemplates(config)
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
+// loadCommentTemplates reads the templates from the YAML or JSON file at
+// path. An empty path means the defaults.
+func loadCommentTemplates(path string) (*commentTemplates, error) {
+	if path == "" {
+		return defaultCommentTemplates, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read --comment-templates: %v", err)
+	}
+	defer file.Close()
+	config := commentTemplateConfig{}
+	if err := yaml.NewYAMLToJSONDecoder(file).Decode(&config); err != nil {
+		return nil, fmt.Errorf("unable to decode --comment-templates %s: %v", path, err)
+	}
+	return parseCommentTemplates(config)
+}
+
+func (sq *SubmitQueue) templates() *commentTemplates {
+	if sq.commentTemplates == nil {
+		return defaultCommentTemplates
+	}
+	return sq.commentTemplates
+}
+
+// renderComment executes the named template for obj, filling in the PR's
+// number and author.
+func (sq *SubmitQueue) renderComment(obj *github.MungeObject, name string, data commentData) string {
+	data.Number = *obj.Issue.Number
+	if obj.Issue.User != nil && obj.Issue.User.Login != nil {
+		data.Author = *obj.Issue.User.Login
+	}
+	var out bytes.Buffer
+	if err := sq.templates().templates[name].Execute(&out, data); err != nil {
+		// The templates were checked when they were loaded, so this is
+		// unlikely, but the default can't fail.
+		obj.Log().Errorf("unable to execute the %s comment template, using the default: %v", name, err)
+		out.Reset()
+		defaultCommentTemplates.templates[name].Execute(&out, data)
+	}
+	return out.String()
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+
+	"github.com/google/go-github/github"
+)
+
+func TestDefaultCommentTemplates(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	obj := github_util.TestObject(nil, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+
+	if got, expected := sq.renderComment(obj, queuedTemplate, commentData{Position: 3, ETA: "2h0m0s"}), fmt.Sprintf(queueCommentFmt, 3, "2h0m0s"); got != expected {
+		t.Errorf("expected the queued comment %q but got %q", expected, got)
+	}
+	if got := sq.retestBody(obj); got != retestBody {
+		t.Errorf("expected the retest comment %q but got %q", retestBody, got)
+	}
+	expected := "@" + *obj.Issue.User.Login + " " + ejectionCommentPrefix + "it broke.\n\n* a: b\n\nIt will be queued again automatically once this is fixed."
+	if got := sq.renderComment(obj, ejectedTemplate, commentData{Reason: "it broke", Details: "* a: b\n\n"}); got != expected {
+		t.Errorf("expected the ejected comment %q but got %q", expected, got)
+	}
+}
+
+func TestLoadCommentTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "comment-templates")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		file     string
+		err      bool
+		queued   string
+		ejected  string
+		retest   string
+		isQueued string
+	}{
+		{
+			name: "every variable",
+			file: `queued: "Queued #{{.Number}} for {{.Author}}: {{.Position}}, {{.ETA}}"
+ejected: "Dropped #{{.Number}}: {{.Reason}} {{.BuildURL}}\n{{.Details}}"
+retest: "@{{.Bot}} {{.Phrase}} for #{{.Number}}"
+`,
+			queued:   "Queued #1 for alice: 2, 1h0m0s",
+			ejected:  "Dropped #1: it broke https://example.com/1\n* ctx: https://example.com/1\n\n",
+			retest:   "@bot please for #1",
+			isQueued: "Queued #1 for bob: 9, 4h0m0s",
+		},
+		{
+			name:     "only some templates",
+			file:     `queued: "Sit tight, you're number {{.Position}}"`,
+			queued:   "Sit tight, you're number 2",
+			ejected:  "@alice " + ejectionCommentPrefix + "it broke.\n\n* ctx: https://example.com/1\n\nIt will be queued again automatically once this is fixed.",
+			retest:   "@bot please [submit-queue is verifying that this PR is safe to merge]",
+			isQueued: "Sit tight, you're number 10",
+		},
+		{
+			name: "unknown variable",
+			file: `retest: "@{{.Bot}} {{.Pharse}}"`,
+			err:  true,
+		},
+		{
+			name: "bad syntax",
+			file: `ejected: "{{.Reason"`,
+			err:  true,
+		},
+		{
+			name: "queued starting with a variable",
+			file: `queued: "{{.Position}} is your position"`,
+			err:  true,
+		},
+	}
+	for i, test := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("templates-%d.yaml", i))
+		if err := ioutil.WriteFile(path, []byte(test.file), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		templates, err := loadCommentTemplates(path)
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error=%v but got %v", test.name, test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		sq := getTestSQ(false, nil, nil)
+		sq.commentTemplates = templates
+		sq.E2EBotName = "bot"
+		sq.E2ETriggerPhrase = "please"
+		issue := LGTMApprovedIssue()
+		issue.User = &github.User{Login: stringPtr("alice")}
+		obj := github_util.TestObject(nil, issue, ValidPR(), Commits(), NewLGTMEvents())
+
+		if got := sq.renderComment(obj, queuedTemplate, commentData{Position: 2, ETA: "1h0m0s"}); got != test.queued {
+			t.Errorf("%s: expected the queued comment %q but got %q", test.name, test.queued, got)
+		}
+		ejected := commentData{Reason: "it broke", BuildURL: "https://example.com/1", Details: "* ctx: https://example.com/1\n\n"}
+		if got := sq.renderComment(obj, ejectedTemplate, ejected); got != test.ejected {
+			t.Errorf("%s: expected the ejected comment %q but got %q", test.name, test.ejected, got)
+		}
+		if got := sq.retestBody(obj); got != test.retest {
+			t.Errorf("%s: expected the retest comment %q but got %q", test.name, test.retest, got)
+		}
+		// Old queued comments must still be recognised
+		if prefix := sq.templates().queuedPrefix; !strings.HasPrefix(test.isQueued, prefix) {
+			t.Errorf("%s: expected %q to be recognised as a queued comment, the prefix is %q", test.name, test.isQueued, prefix)
+		}
+	}
+
+	if _, err := loadCommentTemplates(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
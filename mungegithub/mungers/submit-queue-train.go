@@ -0,0 +1,270 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/contrib/mungegithub/github"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/golang/glog"
+)
+
+// A merge train tests the top PRs in the queue together instead of one at a
+// time. The PRs are merged, in queue order, into a temporary branch cut from
+// the head of their base branch. CI is expected to test pushes to branches
+// named mergeTrainBranchPrefix* and to report the retest contexts on the
+// final merge commit. If they pass every PR is merged. If they fail the train
+// is split in half and each half is tried again, until the PRs which broke
+// it are found and dropped from the queue.
+const (
+	mergeTrainBranchPrefix = "submit-queue-train-"
+	// How long to wait for CI to report on a train before giving up on it
+	// and going back to testing PRs one at a time.
+	mergeTrainTimeout = 2 * time.Hour
+)
+
+// doMergeTrain runs a merge train from the top of the queue. It returns
+// false if there weren't enough PRs to make a train, or if the train could
+// not be run, in which case the top PR should be tested on its own.
+func (sq *SubmitQueue) doMergeTrain() bool {
+	cars := sq.selectMergeTrain()
+	if len(cars) < 2 {
+		return false
+	}
+	defer func() {
+		sq.Lock()
+		sq.githubE2ERunning = nil
+		sq.Unlock()
+	}()
+
+	config := sq.repoConfig(cars[0])
+	base, _ := cars[0].Branch()
+	if err := sq.runMergeTrain(config, base, cars); err != nil {
+		glog.Errorf("Unable to run merge train: %v", err)
+		return false
+	}
+	return true
+}
+
+// selectMergeTrain returns up to TrainSize PRs from the top of the queue
+// which are still valid for merge. The train stops at the first PR which is
+// for another repo or branch, or which would merge without a retest anyway.
+func (sq *SubmitQueue) selectMergeTrain() []*github.MungeObject {
+	if sq.TrainSize < 2 {
+		return nil
+	}
+	sq.Lock()
+	candidates := []*github.MungeObject{}
+	for _, key := range sq.orderedE2EQueue() {
+		candidates = append(candidates, sq.githubE2EQueue[key])
+	}
+	sq.Unlock()
+
+	cars := []*github.MungeObject{}
+	repo, base := "", ""
+	for _, obj := range candidates {
+		if len(cars) == sq.TrainSize {
+			break
+		}
+		if obj.HasLabel(retestNotRequiredLabel) || obj.HasLabel(retestNotRequiredDocsOnlyLabel) {
+			break
+		}
+		branch, ok := obj.Branch()
+		if !ok {
+			break
+		}
+		if len(cars) == 0 {
+			repo, base = obj.Repo(), branch
+		} else if obj.Repo() != repo || branch != base {
+			break
+		}
+		if !obj.Refresh() {
+			obj.Log().Errorf("unknown err")
+			sq.SetMergeStatus(obj, unknown)
+			sq.dropFromQueue(obj)
+			continue
+		}
+		if !sq.validForMerge(obj) {
+			sq.dropFromQueue(obj)
+			continue
+		}
+		cars = append(cars, obj)
+	}
+	if len(cars) > 0 {
+		sq.Lock()
+		sq.githubE2ERunning = cars[0]
+		sq.Unlock()
+	}
+	return cars
+}
+
+// runMergeTrain tests the cars together and merges them if they pass. If they
+// fail the train is bisected. An error means CI or github could not be used
+// and the untried cars have been left on the queue.
+func (sq *SubmitQueue) runMergeTrain(config *github.Config, base string, cars []*github.MungeObject) error {
+	passed, baseSHA, err := sq.testMergeTrain(config, base, cars)
+	if err != nil {
+		return err
+	}
+	if passed {
+		sq.mergeTrainCars(config, base, baseSHA, cars)
+		return nil
+	}
+	if len(cars) == 1 {
+		sq.SetMergeStatus(cars[0], ghE2EFailed)
+		sq.dropFromQueue(cars[0])
+		return nil
+	}
+	mid := len(cars) / 2
+	if err := sq.runMergeTrain(config, base, cars[:mid]); err != nil {
+		return err
+	}
+	return sq.runMergeTrain(config, base, cars[mid:])
+}
+
+// testMergeTrain builds the train's branch and waits for CI to report on
+// it. If two cars can't be merged together the train fails. It also returns
+// the commit of base the train was built on, or "" if nothing was tested.
+func (sq *SubmitQueue) testMergeTrain(config *github.Config, base string, cars []*github.MungeObject) (bool, string, error) {
+	contexts := sets.NewString()
+	for _, obj := range cars {
+		contexts.Insert(sq.retestContexts(obj)...)
+	}
+	if contexts.Len() == 0 {
+		return true, "", nil
+	}
+
+	baseSHA, err := config.GetBranchHead(base)
+	if err != nil {
+		return false, "", err
+	}
+	head := baseSHA
+	name := fmt.Sprintf("%s%d", mergeTrainBranchPrefix, sq.clock.Now().UnixNano())
+	if err := config.CreateBranch(name, head); err != nil {
+		return false, "", err
+	}
+	defer config.DeleteBranch(name)
+
+	for _, obj := range cars {
+		sha, _, ok := obj.GetHeadAndBase()
+		if !ok {
+			return false, "", fmt.Errorf("unable to get the head of PR %d", obj.Number())
+		}
+		head, err = config.MergeIntoBranch(name, sha, fmt.Sprintf("Merge PR #%d into the merge train", obj.Number()))
+		if err != nil {
+			obj.Log().Infof("Merge train %s failed: PR doesn't merge with the PRs before it", name)
+			return false, "", nil
+		}
+	}
+
+	for _, obj := range cars {
+		sq.SetMergeStatus(obj, ghE2ERunning)
+	}
+	deadline := sq.clock.Now().Add(mergeTrainTimeout)
+	for {
+		state, err := config.GetRefStatusState(head, contexts.List())
+		if err != nil {
+			return false, "", err
+		}
+		switch state {
+		case "success":
+			return true, baseSHA, nil
+		case "failure", "error":
+			glog.Infof("Merge train %s failed with PRs %s", name, trainNumbers(cars))
+			return false, "", nil
+		}
+		if sq.clock.Now().After(deadline) {
+			return false, "", fmt.Errorf("timed out waiting for %v on %s", contexts.List(), name)
+		}
+		time.Sleep(sq.githubE2EPollInterval())
+	}
+}
+
+// mergeTrainCars merges the cars of a train which passed. It stops at the
+// first car which can no longer be merged as it was tested, since the cars
+// after it were only tested on top of it. Unless baseSHA is "", each car is
+// only merged while base is at baseSHA or the commit the car before it made.
+func (sq *SubmitQueue) mergeTrainCars(config *github.Config, base, baseSHA string, cars []*github.MungeObject) {
+	sq.mergeLock.Lock()
+	defer sq.mergeLock.Unlock()
+
+	extra := fmt.Sprintf(" (merge train with PRs %s)", trainNumbers(cars))
+	for i, obj := range cars {
+		if baseSHA != "" {
+			if head, err := config.GetBranchHead(base); err != nil || head != baseSHA {
+				obj.Log().With("base", base).With("tested", baseSHA).Infof("Base branch moved since the merge train was tested. Do not merge.")
+				// They stay queued to be tested again on the new base
+				for _, left := range cars[i:] {
+					sq.SetMergeStatus(left, baseBranchMoved)
+				}
+				return
+			}
+		}
+		sha, _, _ := obj.GetHeadAndBase()
+		if !obj.Refresh() {
+			obj.Log().Errorf("unknown err")
+			sq.SetMergeStatus(obj, unknown)
+			return
+		}
+		if !sq.validForMerge(obj) {
+			return
+		}
+		if newSha, _, ok := obj.GetHeadAndBase(); !ok || newSha != sha {
+			obj.Log().Errorf("Changed while running the merge train. Do not merge.")
+			sq.SetMergeStatus(obj, headCommitChanged)
+			return
+		}
+		if !sq.e2eStable(true) {
+			sq.SetMergeStatus(obj, e2eFailure)
+			return
+		}
+		if !sq.mergePullRequest(obj, mergedTrain, extra) {
+			return
+		}
+		sq.dropFromQueue(obj)
+		if baseSHA != "" {
+			// The next car was tested on top of this one
+			var ok bool
+			if baseSHA, ok = obj.MergeCommitSHA(); !ok {
+				return
+			}
+		}
+	}
+}
+
+// dropFromQueue removes a PR the queue is done with.
+func (sq *SubmitQueue) dropFromQueue(obj *github.MungeObject) {
+	sq.Lock()
+	defer sq.Unlock()
+	if sq.runningLocked(obj) {
+		sq.githubE2ERunning = nil
+	}
+	sq.deleteQueueItem(obj)
+}
+
+func trainNumbers(cars []*github.MungeObject) string {
+	numbers := []string{}
+	for _, obj := range cars {
+		numbers = append(numbers, strconv.Itoa(obj.Number()))
+	}
+	return strings.Join(numbers, ", ")
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/google/go-github/github"
+)
+
+// fakeTrainRepo is just enough of github for merge trains. Every commit
+// knows which PR heads were merged into it, and CI fails any commit which
+// includes one of the bad heads.
+type fakeTrainRepo struct {
+	sync.Mutex
+	t        *testing.T
+	prs      map[int]*github.PullRequest
+	issues   map[int]*github.Issue
+	bad      sets.String
+	tips     map[string]string
+	contents map[string]sets.String
+	created  int
+	deleted  int
+	merged   sets.Int
+	// If set, master moves here when CI is first asked about a train
+	pushDuringTest string
+}
+
+func (f *fakeTrainRepo) write(w http.ResponseWriter, thing interface{}) {
+	data, err := json.Marshal(thing)
+	if err != nil {
+		f.t.Errorf("unexpected error: %v", err)
+	}
+	w.Write(data)
+}
+
+func (f *fakeTrainRepo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/repos/o/r/"), "/")
+	switch {
+	case parts[0] == "branches":
+		f.write(w, github.Branch{Name: stringPtr(parts[1]), Commit: &github.Commit{SHA: stringPtr(f.tips[parts[1]])}})
+	case parts[0] == "git" && r.Method == "POST":
+		ref := github.Reference{}
+		body := struct{ Ref, SHA string }{}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.tips[strings.TrimPrefix(body.Ref, "refs/heads/")] = body.SHA
+		f.created++
+		ref.Ref = &body.Ref
+		f.write(w, ref)
+	case parts[0] == "git" && r.Method == "DELETE":
+		delete(f.tips, parts[len(parts)-1])
+		f.deleted++
+		w.WriteHeader(http.StatusNoContent)
+	case parts[0] == "merges":
+		req := github.RepositoryMergeRequest{}
+		json.NewDecoder(r.Body).Decode(&req)
+		sha := fmt.Sprintf("train%d", len(f.contents))
+		f.contents[sha] = sets.NewString(*req.Head).Union(f.contents[f.tips[*req.Base]])
+		f.tips[*req.Base] = sha
+		f.write(w, github.RepositoryCommit{SHA: &sha})
+	case parts[0] == "commits":
+		if f.pushDuringTest != "" && strings.HasPrefix(parts[1], "train") {
+			f.tips["master"] = f.pushDuringTest
+			f.pushDuringTest = ""
+		}
+		sha := parts[1]
+		included, ok := f.contents[sha]
+		if ok && included.HasAny(f.bad.List()...) {
+			f.write(w, github_test.Status(sha, nil, []string{requiredReTestContext1, requiredReTestContext2}, nil, nil))
+			return
+		}
+		f.write(w, github_test.Status(sha, []string{requiredReTestContext1, requiredReTestContext2, notRequiredReTestContext1, notRequiredReTestContext2}, nil, nil, nil))
+	case parts[0] == "statuses":
+		status := github.RepoStatus{}
+		json.NewDecoder(r.Body).Decode(&status)
+		f.write(w, status)
+	case parts[0] == "issues" && len(parts) > 2 && parts[2] == "events":
+		f.write(w, NewLGTMEvents())
+	case parts[0] == "issues" && len(parts) > 2:
+		f.write(w, []github.IssueComment{})
+	case parts[0] == "issues":
+		n, _ := strconv.Atoi(parts[1])
+		f.write(w, f.issues[n])
+	case parts[0] == "pulls" && len(parts) > 2 && parts[2] == "commits":
+		f.write(w, Commits())
+	case parts[0] == "pulls" && len(parts) > 2:
+		n, _ := strconv.Atoi(parts[1])
+		f.merged.Insert(n)
+		sha := fmt.Sprintf("merged%d", n)
+		f.tips["master"] = sha
+		f.write(w, github.PullRequestMergeResult{SHA: &sha})
+	case parts[0] == "pulls":
+		n, _ := strconv.Atoi(parts[1])
+		f.write(w, f.prs[n])
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}
+}
+
+func runTestMergeTrain(t *testing.T, numbers []int, bad []int) (*fakeTrainRepo, *SubmitQueue) {
+	return runTestMergeTrainPushing(t, numbers, bad, "")
+}
+
+// runTestMergeTrainPushing is runTestMergeTrain with master moving to push
+// while the first train is tested.
+func runTestMergeTrainPushing(t *testing.T, numbers []int, bad []int, push string) (*fakeTrainRepo, *SubmitQueue) {
+	client, server, mux := github_test.InitServer(t, nil, nil, nil, nil, nil, nil, nil)
+	defer server.Close()
+	repo := &fakeTrainRepo{
+		t:        t,
+		prs:      map[int]*github.PullRequest{},
+		issues:   map[int]*github.Issue{},
+		bad:      sets.NewString(),
+		tips:     map[string]string{"master": "mastersha"},
+		contents: map[string]sets.String{},
+		merged:   sets.NewInt(),
+
+		pushDuringTest: push,
+	}
+	mux.Handle("/repos/o/r/", repo)
+
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	sq.githubConfig = config
+	sq.githubE2EPollTime = time.Millisecond
+	sq.TrainSize = len(numbers)
+	for _, n := range numbers {
+		pr := ValidPR()
+		pr.Number = intPtr(n)
+		pr.Head.SHA = stringPtr(fmt.Sprintf("sha%d", n))
+		issue := github_test.Issue(someUserName, n, []string{claYesLabel, lgtmLabel, approvedLabel}, true)
+		repo.prs[n] = pr
+		repo.issues[n] = issue
+		obj := github_util.TestObject(config, issue, pr, Commits(), NewLGTMEvents())
+		sq.githubE2EQueue[sq.prKey(obj)] = obj
+	}
+	for _, n := range bad {
+		repo.bad.Insert(fmt.Sprintf("sha%d", n))
+	}
+
+	if !sq.doMergeTrain() {
+		t.Fatalf("expected the merge train to run")
+	}
+	return repo, sq
+}
+
+func TestMergeTrainAllPass(t *testing.T) {
+	repo, sq := runTestMergeTrain(t, []int{1, 2, 3, 4}, nil)
+	if !repo.merged.Equal(sets.NewInt(1, 2, 3, 4)) {
+		t.Errorf("expected every PR to merge but merged %v", repo.merged.List())
+	}
+	if repo.created != 1 || repo.deleted != 1 {
+		t.Errorf("expected one train branch to be created and deleted, got %d and %d", repo.created, repo.deleted)
+	}
+	if len(sq.githubE2EQueue) != 0 {
+		t.Errorf("expected the queue to be empty but got %v", sq.githubE2EQueue)
+	}
+	for _, n := range []string{"1", "2", "3", "4"} {
+		if reason := sq.prStatus[n].Reason; reason != mergedTrain {
+			t.Errorf("expected %s to be %q but got %q", n, mergedTrain, reason)
+		}
+	}
+}
+
+func TestMergeTrainBisectsFailures(t *testing.T) {
+	repo, sq := runTestMergeTrain(t, []int{1, 2, 3, 4}, []int{3})
+	if !repo.merged.Equal(sets.NewInt(1, 2, 4)) {
+		t.Errorf("expected all but #3 to merge but merged %v", repo.merged.List())
+	}
+	if repo.created < 3 || repo.created != repo.deleted {
+		t.Errorf("expected the train to be bisected and every branch deleted, created %d and deleted %d", repo.created, repo.deleted)
+	}
+	if len(sq.githubE2EQueue) != 0 {
+		t.Errorf("expected the queue to be empty but got %v", sq.githubE2EQueue)
+	}
+	if reason := sq.prStatus["3"].Reason; reason != ghE2EFailed {
+		t.Errorf("expected #3 to be %q but got %q", ghE2EFailed, reason)
+	}
+}
+
+func TestMergeTrainBaseMoved(t *testing.T) {
+	repo, sq := runTestMergeTrainPushing(t, []int{1, 2, 3}, nil, "pushedsha")
+	if repo.merged.Len() != 0 {
+		t.Errorf("expected nothing to merge onto a base the train wasn't tested on but merged %v", repo.merged.List())
+	}
+	if len(sq.githubE2EQueue) != 3 {
+		t.Errorf("expected every PR to stay queued but got %v", sq.githubE2EQueue)
+	}
+	for _, n := range []string{"1", "2", "3"} {
+		if reason := sq.prStatus[n].Reason; reason != baseBranchMoved {
+			t.Errorf("expected %s to be %q but got %q", n, baseBranchMoved, reason)
+		}
+	}
+}
+
+func TestMergeTrainNeedsTwoPRs(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	sq.TrainSize = 4
+	if sq.doMergeTrain() {
+		t.Errorf("expected no merge train with an empty queue")
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"k8s.io/contrib/mungegithub/github"
+)
+
+// transientFailure returns true if we couldn't find out about obj because
+// of a github error worth retrying, like a 502 or being rate limited. Then
+// the PR should be left as it is, on the queue or not, until the next loop
+// rather than get a status like "unknown failure".
+func (sq *SubmitQueue) transientFailure(obj *github.MungeObject) bool {
+	err := obj.LastError()
+	if !github.IsRetryable(err) {
+		return false
+	}
+	obj.Log().Infof("Will retry next loop after a transient github error: %v", err)
+	return true
+}
+
+// setErrorStatus sets a reason which says something about obj couldn't be
+// found out, unless that was because of a transient github error. Then obj
+// keeps its status and its place on the queue until the next loop.
+func (sq *SubmitQueue) setErrorStatus(obj *github.MungeObject, reason string) {
+	if sq.transientFailure(obj) {
+		return
+	}
+	sq.SetMergeStatus(obj, reason)
+}
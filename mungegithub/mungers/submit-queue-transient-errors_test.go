@@ -0,0 +1,108 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"net/http"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+)
+
+func TestTransientErrorsKeepPRQueued(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         int
+		expectQueued bool
+	}{
+		{name: "server error", code: http.StatusBadGateway, expectQueued: true},
+		{name: "not found", code: http.StatusNotFound, expectQueued: false},
+	}
+	for _, test := range tests {
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), nil, NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		mux.HandleFunc("/repos/o/r/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.code)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), nil, nil, nil)
+		sq.githubE2EQueue["1"] = obj
+		if sq.validForMerge(obj) {
+			t.Errorf("%s: expected the PR not to be valid without being able to fetch it", test.name)
+		}
+
+		_, queued := sq.githubE2EQueue["1"]
+		if queued != test.expectQueued {
+			t.Errorf("%s: expected queued=%v, got %v", test.name, test.expectQueued, queued)
+		}
+		status, reported := sq.prStatus["1"]
+		if reported == test.expectQueued {
+			t.Errorf("%s: expected a status only for a permanent error, got %v", test.name, status)
+		}
+		if reported && status.Reason != unknown {
+			t.Errorf("%s: expected %q, got %q", test.name, unknown, status.Reason)
+		}
+		server.Close()
+	}
+}
+
+func TestTransientErrorsDuringE2EKeepPRQueued(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         int
+		expectQueued bool
+	}{
+		{name: "server error", code: http.StatusBadGateway, expectQueued: true},
+		{name: "not found", code: http.StatusNotFound, expectQueued: false},
+	}
+	for _, test := range tests {
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), nil, NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		// Refresh fails to fetch the PR
+		mux.HandleFunc("/repos/o/r/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.code)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		sq.githubE2EQueue["1"] = obj
+		sq.githubE2ERunning = obj
+		// As handleGithubE2EAndMerge does
+		if sq.doGithubE2EAndMerge(obj) {
+			sq.Lock()
+			sq.githubE2ERunning = nil
+			sq.deleteQueueItem(obj)
+			sq.Unlock()
+		}
+
+		if _, queued := sq.githubE2EQueue["1"]; queued != test.expectQueued {
+			t.Errorf("%s: expected queued=%v, got %v", test.name, test.expectQueued, queued)
+		}
+		if _, reported := sq.prStatus["1"]; reported == test.expectQueued {
+			t.Errorf("%s: expected a status only for a permanent error, got %v", test.name, sq.prStatus["1"])
+		}
+		server.Close()
+	}
+}
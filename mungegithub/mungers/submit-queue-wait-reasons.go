@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// waitReasonTime is how long PRs have spent, all told, with a Reason. It is
+// served at /wait-reasons for capacity planning.
+type waitReasonTime struct {
+	Reason  string
+	Seconds float64
+}
+
+// waitReasonKey is the reason with any detail after ": ", like the name of
+// the failing context, left off so those are counted together.
+func waitReasonKey(reason string) string {
+	if i := strings.Index(reason, ": "); i >= 0 {
+		return reason[:i]
+	}
+	return reason
+}
+
+// accountWaitReason adds the time since prev was recorded to prev's reason,
+// as the PR has had that reason until now. sq.Lock() must be held.
+func (sq *SubmitQueue) accountWaitReason(prev submitStatus, now time.Time) {
+	elapsed := now.Sub(prev.Time)
+	if prev.Time.IsZero() || elapsed <= 0 {
+		return
+	}
+	if sq.waitReasonTimes == nil {
+		sq.waitReasonTimes = map[string]time.Duration{}
+	}
+	sq.waitReasonTimes[waitReasonKey(prev.Reason)] += elapsed
+}
+
+// getWaitReasonTimes returns the accumulated time for every reason, longest
+// first.
+func (sq *SubmitQueue) getWaitReasonTimes() []waitReasonTime {
+	sq.Lock()
+	defer sq.Unlock()
+	out := []waitReasonTime{}
+	for reason, total := range sq.waitReasonTimes {
+		out = append(out, waitReasonTime{Reason: reason, Seconds: total.Seconds()})
+	}
+	sort.Sort(byWaitTime(out))
+	return out
+}
+
+type byWaitTime []waitReasonTime
+
+func (s byWaitTime) Len() int      { return len(s) }
+func (s byWaitTime) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byWaitTime) Less(i, j int) bool {
+	if s[i].Seconds != s[j].Seconds {
+		return s[i].Seconds > s[j].Seconds
+	}
+	return s[i].Reason < s[j].Reason
+}
+
+func (sq *SubmitQueue) serveWaitReasons(res http.ResponseWriter, req *http.Request) {
+	sq.serve(sq.marshal(sq.getWaitReasonTimes()), res, req)
+}
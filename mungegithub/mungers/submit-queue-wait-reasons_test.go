@@ -0,0 +1,87 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+	utilclock "k8s.io/kubernetes/pkg/util/clock"
+)
+
+func TestWaitReasonTimes(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	clock := sq.clock.(*utilclock.FakeClock)
+	clock.SetTime(time.Unix(0, 0))
+
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	one := github_util.TestObject(config, github_test.Issue(someUserName, 1, nil, true), ValidPR(), nil, nil)
+	two := github_util.TestObject(config, github_test.Issue(someUserName, 2, nil, true), ValidPR(), nil, nil)
+	record := func(obj *github_util.MungeObject, reason string) {
+		sq.recordMergeStatus(obj, submitStatus{
+			Time:              clock.Now(),
+			statusPullRequest: *objToStatusPullRequest(obj),
+			Reason:            reason,
+		})
+	}
+
+	record(one, fmt.Sprintf(noLGTMFmt, lgtmLabel))
+	record(two, fmt.Sprintf(ciFailureFmt, "foo"))
+	clock.Step(time.Hour)
+	record(one, fmt.Sprintf(noLGTMFmt, lgtmLabel))
+	record(two, fmt.Sprintf(ciFailureFmt, "bar"))
+	clock.Step(30 * time.Minute)
+	// A new munge loop
+	sq.lastPRStatus = sq.prStatus
+	sq.prStatus = map[string]submitStatus{}
+	record(one, ghE2EQueued)
+	record(two, ghE2EQueued)
+	clock.Step(2 * time.Hour)
+	record(one, ghE2ERunning)
+	clock.Step(10 * time.Minute)
+	record(one, merged)
+	// Merged PRs aren't waiting for anything
+	clock.Step(time.Hour)
+
+	expected := []waitReasonTime{
+		{Reason: ghE2EQueued, Seconds: (2 * time.Hour).Seconds()},
+		{Reason: fmt.Sprintf(noLGTMFmt, lgtmLabel), Seconds: (90 * time.Minute).Seconds()},
+		{Reason: ciFailure, Seconds: (90 * time.Minute).Seconds()},
+		{Reason: ghE2ERunning, Seconds: (10 * time.Minute).Seconds()},
+	}
+	if times := sq.getWaitReasonTimes(); !reflect.DeepEqual(times, expected) {
+		t.Errorf("expected %v but got %v", expected, times)
+	}
+
+	res := httptest.NewRecorder()
+	sq.serveWaitReasons(res, httptest.NewRequest("GET", "/wait-reasons", nil))
+	served := []waitReasonTime{}
+	if err := json.Unmarshal(res.Body.Bytes(), &served); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(served, expected) {
+		t.Errorf("expected %v to be served but got %v", expected, served)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+const whitelistCommand = "WHITELIST"
+
+// whitelistChanges are the users added to and removed from the
+// --command-whitelist with the whitelist command. They are what's saved in
+// the WhitelistFile, so that the flag can still be changed later.
+type whitelistChanges struct {
+	Added   []string
+	Removed []string
+}
+
+// commandWhitelist returns a copy of the CommandWhitelist.
+func (sq *SubmitQueue) commandWhitelist() []string {
+	sq.Lock()
+	defer sq.Unlock()
+	return append([]string{}, sq.CommandWhitelist...)
+}
+
+// isWhitelistAdmin returns true if the user may change the CommandWhitelist.
+func (sq *SubmitQueue) isWhitelistAdmin(user string) bool {
+	for _, admin := range sq.WhitelistAdmins {
+		if strings.EqualFold(admin, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadWhitelist applies the changes saved in the WhitelistFile to the
+// CommandWhitelist from the flag. A missing file means nothing has changed.
+func (sq *SubmitQueue) loadWhitelist() error {
+	if sq.WhitelistFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(sq.WhitelistFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	changes := whitelistChanges{}
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return fmt.Errorf("unable to parse %s: %v", sq.WhitelistFile, err)
+	}
+	sq.Lock()
+	defer sq.Unlock()
+	sq.whitelistChanges = changes
+	sq.CommandWhitelist = applyWhitelistChanges(sq.CommandWhitelist, changes)
+	return nil
+}
+
+func applyWhitelistChanges(whitelist []string, changes whitelistChanges) []string {
+	users := sets.NewString()
+	for _, u := range whitelist {
+		users.Insert(strings.ToLower(u))
+	}
+	for _, u := range changes.Added {
+		users.Insert(strings.ToLower(u))
+	}
+	for _, u := range changes.Removed {
+		users.Delete(strings.ToLower(u))
+	}
+	return users.List()
+}
+
+// changeWhitelist adds or removes the user and saves the change.
+func (sq *SubmitQueue) changeWhitelist(user string, add bool) error {
+	user = strings.ToLower(user)
+	sq.Lock()
+	added := sets.NewString(sq.whitelistChanges.Added...)
+	removed := sets.NewString(sq.whitelistChanges.Removed...)
+	if add {
+		added.Insert(user)
+		removed.Delete(user)
+	} else {
+		added.Delete(user)
+		removed.Insert(user)
+	}
+	sq.whitelistChanges = whitelistChanges{Added: added.List(), Removed: removed.List()}
+	sq.CommandWhitelist = applyWhitelistChanges(sq.CommandWhitelist, sq.whitelistChanges)
+	data := sq.marshal(sq.whitelistChanges)
+	sq.Unlock()
+
+	if sq.WhitelistFile == "" {
+		return nil
+	}
+	return ioutil.WriteFile(sq.WhitelistFile, data, 0644)
+}
+
+// whitelistCommand handles "@bot whitelist add @user" and
+// "@bot whitelist remove @user".
+func (sq *SubmitQueue) whitelistCommand(obj *github.MungeObject, user string, cmd *c.Command) string {
+	args := strings.Fields(cmd.Arguments)
+	if len(args) != 2 || (args[0] != "add" && args[0] != "remove") {
+		return fmt.Sprintf("@%s usage: `@%s whitelist add @user` or `@%s whitelist remove @user`.", user, botName, botName)
+	}
+	add := args[0] == "add"
+	target := strings.TrimPrefix(args[1], "@")
+	if err := sq.changeWhitelist(target, add); err != nil {
+		obj.Log().Errorf("unable to save the whitelist to %s: %v", sq.WhitelistFile, err)
+		return fmt.Sprintf("@%s the whitelist was changed but could not be saved, so the change will be lost on restart.", user)
+	}
+	if add {
+		obj.Log().Infof("%s added %s to the command whitelist", user, target)
+		return fmt.Sprintf("Added @%s to the command whitelist at the request of @%s.", target, user)
+	}
+	obj.Log().Infof("%s removed %s from the command whitelist", user, target)
+	return fmt.Sprintf("Removed @%s from the command whitelist at the request of @%s.", target, user)
+}
+
+func (sq *SubmitQueue) serveWhitelist(res http.ResponseWriter, req *http.Request) {
+	whitelist := sq.commandWhitelist()
+	sort.Strings(whitelist)
+	data := sq.marshal(struct {
+		Whitelist []string
+		Admins    []string
+	}{whitelist, sq.WhitelistAdmins})
+	sq.serve(data, res, req)
+}
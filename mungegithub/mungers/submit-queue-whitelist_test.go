@@ -0,0 +1,134 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestWhitelistCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "whitelist")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "whitelist.json")
+
+	tests := []struct {
+		name      string
+		comment   string
+		user      string
+		reply     string
+		whitelist []string
+	}{
+		{
+			name:      "only admins",
+			comment:   "@" + botName + " whitelist add @bob",
+			user:      "alice",
+			reply:     "@alice you are not authorized",
+			whitelist: []string{"alice"},
+		},
+		{
+			name:      "add",
+			comment:   "@" + botName + " whitelist add @bob",
+			user:      "root",
+			reply:     "Added @bob to the command whitelist at the request of @root.",
+			whitelist: []string{"alice", "bob"},
+		},
+		{
+			name:      "remove",
+			comment:   "@" + botName + " whitelist remove @alice",
+			user:      "root",
+			reply:     "Removed @alice from the command whitelist at the request of @root.",
+			whitelist: []string{"bob"},
+		},
+		{
+			name:      "bad usage",
+			comment:   "@" + botName + " whitelist bob",
+			user:      "root",
+			reply:     "@root usage:",
+			whitelist: []string{"bob"},
+		},
+	}
+	for _, test := range tests {
+		replies := []string{}
+		sq, obj, done := commandTestSQ(t, []*github.IssueComment{github_test.IssueComment(1, test.comment, test.user, 10)}, &replies)
+		sq.WhitelistAdmins = []string{"root"}
+		sq.WhitelistFile = file
+		if err := sq.loadWhitelist(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		sq.handleCommands(obj)
+		if len(replies) != 1 || !strings.HasPrefix(replies[0], test.reply) {
+			t.Errorf("%s: expected reply %q but got %q", test.name, test.reply, replies)
+		}
+		if whitelist := sq.commandWhitelist(); !reflect.DeepEqual(whitelist, test.whitelist) {
+			t.Errorf("%s: expected the whitelist %v but got %v", test.name, test.whitelist, whitelist)
+		}
+
+		// The queue reports the same thing
+		res := httptest.NewRecorder()
+		sq.serveWhitelist(res, httptest.NewRequest("GET", "/whitelist", nil))
+		served := struct{ Whitelist []string }{}
+		if err := json.Unmarshal(res.Body.Bytes(), &served); err != nil {
+			t.Errorf("%s: unable to decode /whitelist: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(served.Whitelist, test.whitelist) {
+			t.Errorf("%s: expected /whitelist to serve %v but got %v", test.name, test.whitelist, served.Whitelist)
+		}
+		done()
+	}
+}
+
+func TestWhitelistedUserCanRequeue(t *testing.T) {
+	comments := []*github.IssueComment{
+		github_test.IssueComment(1, "@"+botName+" requeue", "bob", 10),
+	}
+	replies := []string{}
+	sq, obj, done := commandTestSQ(t, comments, &replies)
+	defer done()
+	sq.lastPRStatus["1"] = submitStatus{Reason: ciFailure}
+
+	sq.handleCommands(obj)
+	if len(replies) != 1 || !strings.HasPrefix(replies[0], "@bob you are not authorized") {
+		t.Fatalf("expected bob to be refused before being whitelisted but got %q", replies)
+	}
+
+	if err := sq.changeWhitelist("Bob", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	replies = []string{}
+	sq.handleCommands(obj)
+	if len(replies) != 1 || !strings.HasPrefix(replies[0], "Requeued at the request of @bob") {
+		t.Errorf("expected bob's requeue to work once whitelisted but got %q", replies)
+	}
+	if _, cached := sq.lastPRStatus["1"]; cached {
+		t.Errorf("expected the PR to be requeued")
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"k8s.io/contrib/mungegithub/github"
+	c "k8s.io/contrib/mungegithub/mungers/matchers/comment"
+)
+
+const (
+	whyCommand = "WHY"
+)
+
+// whyReason runs the checks Munge would and returns the reason the queue
+// gives for obj, along with whether it is ready to merge.
+func (sq *SubmitQueue) whyReason(obj *github.MungeObject) (string, bool) {
+	valid := sq.validForMerge(obj) && !sq.heldAfterE2ETimeout(obj)
+
+	key := sq.prKey(obj)
+	sq.Lock()
+	defer sq.Unlock()
+	if valid && !sq.onQueue(obj) {
+		// Munge queues it right after the commands are handled.
+		return ghE2EQueued, true
+	}
+	return sq.prStatus[key].Reason, valid
+}
+
+// whyRequirements lists which of the main merge requirements obj meets, as
+// a markdown checklist.
+func (sq *SubmitQueue) whyRequirements(obj *github.MungeObject) string {
+	var out bytes.Buffer
+	check := func(met bool, requirement string) {
+		box := " "
+		if met {
+			box = "x"
+		}
+		fmt.Fprintf(&out, "- [%s] %s\n", box, requirement)
+	}
+
+	if sq.CLAContext != "" {
+		check(sq.hasCLA(obj), fmt.Sprintf("The %q context has passed", sq.CLAContext))
+	} else {
+		check(sq.hasCLA(obj), fmt.Sprintf("The CLA has been signed (the %q label)", sq.CLALabel))
+	}
+
+	if sq.ReviewMode {
+		approved, _ := sq.hasApprovingReviews(obj)
+		check(approved, fmt.Sprintf("At least %d approving reviews since the last commit", sq.ApprovingReviewsRequired))
+	} else {
+		lgtm := obj.HasLabel(sq.LGTMLabel)
+		if lgtm {
+			after, ok := obj.ModifiedAfterLabeled(sq.LGTMLabel)
+			lgtm = ok && !after
+		}
+		check(lgtm, fmt.Sprintf("The %q label, added after the last commit", sq.LGTMLabel))
+		if sq.ApprovingReviewsRequired > 1 {
+			approvers, _ := sq.commentApprovers(obj)
+			check(approvers.Len() >= sq.ApprovingReviewsRequired, fmt.Sprintf("\"/lgtm\" from %d different people since the last commit, it has %d", sq.ApprovingReviewsRequired, approvers.Len()))
+		}
+	}
+
+	if len(sq.RequiredStatusContexts) > 0 {
+		success, ok := sq.contextsSucceeded(obj, sq.RequiredStatusContexts)
+		check(ok && success, fmt.Sprintf("CI has passed: %s", strings.Join(sq.RequiredStatusContexts, ", ")))
+	}
+	if contexts := sq.retestContexts(obj); len(contexts) > 0 {
+		success, ok := sq.contextsSucceeded(obj, contexts)
+		check(ok && success, fmt.Sprintf("Github e2e has passed: %s", strings.Join(contexts, ", ")))
+	}
+	return out.String()
+}
+
+// whyCommand explains why the PR isn't merging, to anybody who asks.
+func (sq *SubmitQueue) whyCommand(obj *github.MungeObject, user string, cmd *c.Command) string {
+	reason, valid := sq.whyReason(obj)
+	summary := fmt.Sprintf("@%s this PR is not merging because: %s", user, reason)
+	if valid {
+		summary = fmt.Sprintf("@%s this PR meets the merge requirements. Its status in the queue is: %s", user, reason)
+	}
+	return summary + "\n\n" + sq.whyRequirements(obj)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	github_util "k8s.io/contrib/mungegithub/github"
+	github_test "k8s.io/contrib/mungegithub/github/testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestWhyCommand(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name   string
+		labels []string
+		valid  bool
+		reason string
+		unmet  string
+	}{
+		{
+			name:   "ready to merge",
+			labels: []string{claYesLabel, lgtmLabel, approvedLabel},
+			valid:  true,
+			reason: ghE2EQueued,
+		},
+		{
+			name:   "missing lgtm",
+			labels: []string{claYesLabel, approvedLabel},
+			reason: fmt.Sprintf(noLGTMFmt, lgtmLabel),
+			unmet:  fmt.Sprintf("- [ ] The %q label", lgtmLabel),
+		},
+		{
+			name:   "do not merge",
+			labels: []string{claYesLabel, lgtmLabel, approvedLabel, doNotMergeLabel},
+			reason: fmt.Sprintf(noMergeFmt, doNotMergeLabel),
+		},
+	}
+	for _, test := range tests {
+		replies := []string{}
+		comments := []*github.IssueComment{
+			// Anybody may ask
+			github_test.IssueComment(1, "@"+botName+" why", "mallory", 10),
+		}
+		sq, obj, done := commandTestSQ(t, comments, &replies)
+		obj.Issue.Labels = github_test.Issue(someUserName, 1, test.labels, true).Labels
+
+		sq.handleCommands(obj)
+
+		if len(replies) != 1 {
+			t.Errorf("%s: expected one reply but got %q", test.name, replies)
+			done()
+			continue
+		}
+		reply := replies[0]
+		if !strings.HasPrefix(reply, "@mallory ") || !strings.Contains(reply, test.reason) {
+			t.Errorf("%s: expected the reply to give the reason %q but got %q", test.name, test.reason, reply)
+		}
+		if valid := strings.Contains(reply, "meets the merge requirements"); valid != test.valid {
+			t.Errorf("%s: expected valid=%v in the reply but got %q", test.name, test.valid, reply)
+		}
+		if !strings.Contains(reply, fmt.Sprintf("- [x] The CLA has been signed (the %q label)", claYesLabel)) {
+			t.Errorf("%s: expected the CLA to be listed as met but got %q", test.name, reply)
+		}
+		if test.unmet != "" && !strings.Contains(reply, test.unmet) {
+			t.Errorf("%s: expected %q in the reply but got %q", test.name, test.unmet, reply)
+		}
+
+		// The reply must agree with what the queue decides itself.
+		valid := sq.validForMerge(obj)
+		if valid != test.valid || (!valid && sq.prStatus["1"].Reason != test.reason) {
+			t.Errorf("%s: the queue decided valid=%v reason=%q, but the reply was %q", test.name, valid, sq.prStatus["1"].Reason, reply)
+		}
+		done()
+	}
+}
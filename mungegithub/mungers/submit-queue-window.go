@@ -0,0 +1,158 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// mergeWindowRange allows merges from start until end (minutes since
+// midnight) on the days from firstDay through lastDay. The days may wrap
+// around the end of the week, e.g. Fri-Mon.
+type mergeWindowRange struct {
+	firstDay time.Weekday
+	lastDay  time.Weekday
+	start    int
+	end      int
+}
+
+// mergeWindow is the set of times the submit queue may merge PRs. A nil
+// mergeWindow allows merges at any time.
+type mergeWindow struct {
+	location *time.Location
+	ranges   []mergeWindowRange
+}
+
+// parseMergeWindow builds a mergeWindow from specs like "Mon-Fri 09:00-17:00"
+// or "Sat 10:00-12:00", interpreted in the given IANA timezone. It returns
+// nil if there are no specs.
+func parseMergeWindow(specs []string, timezone string) (*mergeWindow, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merge window timezone %q: %v", timezone, err)
+	}
+
+	w := &mergeWindow{location: location}
+	for _, spec := range specs {
+		fields := strings.Fields(spec)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid merge window %q, expected \"DAY[-DAY] HH:MM-HH:MM\"", spec)
+		}
+		var r mergeWindowRange
+		if r.firstDay, r.lastDay, err = parseDayRange(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid merge window %q: %v", spec, err)
+		}
+		if r.start, r.end, err = parseTimeRange(fields[1]); err != nil {
+			return nil, fmt.Errorf("invalid merge window %q: %v", spec, err)
+		}
+		w.ranges = append(w.ranges, r)
+	}
+	return w, nil
+}
+
+func parseDayRange(s string) (time.Weekday, time.Weekday, error) {
+	parts := strings.SplitN(s, "-", 2)
+	first, ok := weekdays[strings.ToLower(parts[0])]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown day %q", parts[0])
+	}
+	if len(parts) == 1 {
+		return first, first, nil
+	}
+	last, ok := weekdays[strings.ToLower(parts[1])]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown day %q", parts[1])
+	}
+	return first, last, nil
+}
+
+func parseTimeRange(s string) (int, int, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected a time range like 09:00-17:00, got %q", s)
+	}
+	start, err := parseMinutes(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseMinutes(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("end of %q is not after its start", s)
+	}
+	return start, end, nil
+}
+
+// parseMinutes turns "HH:MM" into minutes since midnight. "24:00" is allowed
+// so a range can run to the end of the day.
+func parseMinutes(s string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hour, &minute); err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hour*60 + minute, nil
+}
+
+func (r mergeWindowRange) containsDay(day time.Weekday) bool {
+	if r.firstDay <= r.lastDay {
+		return r.firstDay <= day && day <= r.lastDay
+	}
+	return day >= r.firstDay || day <= r.lastDay
+}
+
+// contains returns true if merges are allowed at time t.
+func (w *mergeWindow) contains(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	t = t.In(w.location)
+	minutes := t.Hour()*60 + t.Minute()
+	for _, r := range w.ranges {
+		if r.containsDay(t.Weekday()) && r.start <= minutes && minutes < r.end {
+			return true
+		}
+	}
+	return false
+}
+
+// inMergeWindow returns true if the queue's merge window is open right now.
+func (sq *SubmitQueue) inMergeWindow() bool {
+	return sq.mergeWindow.contains(sq.clock.Now())
+}
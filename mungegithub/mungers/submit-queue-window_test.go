@@ -0,0 +1,113 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mungers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeWindowContains(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	tests := []struct {
+		name     string
+		specs    []string
+		timezone string
+		time     time.Time
+		contains bool
+	}{
+		{
+			name:     "no window",
+			time:     time.Date(2016, 10, 8, 3, 0, 0, 0, time.UTC),
+			contains: true,
+		},
+		{
+			name:     "weekday inside hours",
+			specs:    []string{"Mon-Fri 09:00-17:00"},
+			time:     time.Date(2016, 10, 5, 9, 0, 0, 0, time.UTC),
+			contains: true,
+		},
+		{
+			name:  "weekday at end of hours",
+			specs: []string{"Mon-Fri 09:00-17:00"},
+			time:  time.Date(2016, 10, 5, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "weekend",
+			specs: []string{"Mon-Fri 09:00-17:00"},
+			time:  time.Date(2016, 10, 8, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "second range",
+			specs:    []string{"Mon-Fri 09:00-17:00", "Sat 10:00-12:00"},
+			time:     time.Date(2016, 10, 8, 11, 0, 0, 0, time.UTC),
+			contains: true,
+		},
+		{
+			name:     "wraps around the week",
+			specs:    []string{"Fri-Mon 00:00-24:00"},
+			time:     time.Date(2016, 10, 9, 23, 59, 0, 0, time.UTC),
+			contains: true,
+		},
+		{
+			name:     "timezone",
+			specs:    []string{"Mon-Fri 09:00-17:00"},
+			timezone: "America/Los_Angeles",
+			time:     time.Date(2016, 10, 5, 9, 30, 0, 0, la),
+			contains: true,
+		},
+		{
+			name:     "timezone converts utc",
+			specs:    []string{"Mon-Fri 09:00-17:00"},
+			timezone: "America/Los_Angeles",
+			// 2am in Los Angeles
+			time: time.Date(2016, 10, 5, 9, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, test := range tests {
+		w, err := parseMergeWindow(test.specs, test.timezone)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if contains := w.contains(test.time); contains != test.contains {
+			t.Errorf("%s: expected contains=%v but got %v", test.name, test.contains, contains)
+		}
+	}
+}
+
+func TestParseMergeWindowErrors(t *testing.T) {
+	tests := []struct {
+		specs    []string
+		timezone string
+	}{
+		{specs: []string{"Mon-Fri"}},
+		{specs: []string{"Moonday 09:00-17:00"}},
+		{specs: []string{"Mon-Fri 17:00-09:00"}},
+		{specs: []string{"Mon-Fri 09:00-25:00"}},
+		{specs: []string{"Mon-Fri 9-17"}},
+		{specs: []string{"Mon-Fri 09:00-17:00"}, timezone: "Nowhere/Special"},
+	}
+	for _, test := range tests {
+		if _, err := parseMergeWindow(test.specs, test.timezone); err == nil {
+			t.Errorf("%q (%q): expected an error", test.specs, test.timezone)
+		}
+	}
+}
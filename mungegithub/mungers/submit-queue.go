@@ -21,7 +21,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"math/rand"
 	"net/http"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -30,6 +32,7 @@ import (
 	"time"
 
 	utilclock "k8s.io/kubernetes/pkg/util/clock"
+	"k8s.io/kubernetes/pkg/util/sets"
 
 	"k8s.io/contrib/mungegithub/admin"
 	"k8s.io/contrib/mungegithub/features"
@@ -38,6 +41,7 @@ import (
 	fake_e2e "k8s.io/contrib/mungegithub/mungers/e2e/fake"
 	"k8s.io/contrib/mungegithub/mungers/mungerutil"
 	"k8s.io/contrib/mungegithub/mungers/shield"
+	"k8s.io/contrib/mungegithub/mungers/tracker"
 	"k8s.io/contrib/test-utils/utils"
 
 	"github.com/NYTimes/gziphandler"
@@ -60,23 +64,47 @@ const (
 	cncfClaNoLabel                 = "cncf-cla: no"
 	claHumanLabel                  = "cla: human-approved"
 	sqContext                      = "Submit Queue"
+	mergeMethodMergeLabel          = "merge-method: merge"
+	mergeMethodSquashLabel         = "merge-method: squash"
+	mergeMethodRebaseLabel         = "merge-method: rebase"
 
 	retestNotRequiredMergePriority = -1 // used for retestNotRequiredLabel
 	defaultMergePriority           = 3  // when an issue is unlabeled
 
 	githubE2EPollTime = 30 * time.Second
+
+	// How many times one github e2e run is retried after infrastructure
+	// failures, on top of --e2e-retries.
+	maxInfraRetries = 3
+
+	// maxStatusHistory is how many of the latest statuses are kept for
+	// /history and /pr-history.
+	maxStatusHistory = 128
+
+	defaultMergeRateRetention = 7 * 24 * time.Hour
+	defaultHealthRetention    = 24 * time.Hour
+
+	queueCommentPrefix = "This PR has been added to the submit queue"
+	queueCommentFmt    = queueCommentPrefix + " at position %d. Estimated time to merge: %s."
 )
 
 var (
 	_ = fmt.Print
 	// This MUST cause a RETEST of everything in the sq.RequiredRetestContexts
-	retestBody = fmt.Sprintf("@%s test this [submit-queue is verifying that this PR is safe to merge]", jenkinsBotName)
+	retestBody = fmt.Sprintf(retestBodyFmt, jenkinsBotName, e2eTriggerPhrase)
+)
+
+const (
+	e2eTriggerPhrase = "test this"
+	retestBodyFmt    = "@%s %s [submit-queue is verifying that this PR is safe to merge]"
 )
 
 type submitStatus struct {
 	Time time.Time
 	statusPullRequest
-	Reason string
+	Reason   string
+	Since    time.Time // when the PR first got this Reason
+	Priority int
 }
 
 type statusPullRequest struct {
@@ -89,6 +117,7 @@ type statusPullRequest struct {
 	Deletions int
 	ExtraInfo []string
 	BaseRef   string
+	Repo      string
 }
 
 type e2eQueueStatus struct {
@@ -101,15 +130,51 @@ type submitQueueStatus struct {
 	PRStatus map[string]submitStatus
 }
 
+// blockedPR is one entry of the /blocked-prs API. Dashboards consume it so
+// the field names must not change.
+type blockedPR struct {
+	Number             int
+	URL                string
+	Title              string
+	Login              string
+	Reason             string
+	Priority           int
+	Since              time.Time
+	TimeInStateSeconds int64
+	// QueuePosition is the 0 based position in the e2e queue, -1 if the PR
+	// is not queued.
+	QueuePosition int
+	// Changed is true if Reason is different from the previous munge
+	// loop, which was PreviousReason.
+	Changed        bool
+	PreviousReason string
+}
+
 // Information about the e2e test health. Call updateHealth on the SubmitQueue
 // at roughly constant intervals to keep this up to date. The mergeable fraction
 // of time for the queue as a whole and the individual jobs will then be
-// NumStable[PerJob] / TotalLoops.
+// NumStable[PerJob] / TotalLoops. WeightedStable is the fraction of the jobs
+// which were stable, weighted by JobWeights, averaged over the loops.
 type submitQueueHealth struct {
 	TotalLoops       int
 	NumStable        int
 	NumStablePerJob  map[string]int
+	WeightedStable   float64
+	JobWeights       map[string]float64 `json:",omitempty"`
 	MergePossibleNow bool
+	Repos            map[string]repoHealth
+}
+
+// repoHealth is the state of the queue for one of the repos it handles.
+// MergePossibleNow is false if the queue as a whole is blocked or any of
+// RedBaseBranches, the branches failing BaseBranchContexts in the last loop,
+// are in the repo.
+type repoHealth struct {
+	Queued              int
+	MergesSinceRestart  int
+	AllowedBaseBranches []string `json:",omitempty"`
+	RedBaseBranches     []string `json:",omitempty"`
+	MergePossibleNow    bool
 }
 
 // Generate health information using a queue of healthRecords. The bools are
@@ -120,6 +185,12 @@ type healthRecord struct {
 	Jobs    map[string]bool
 }
 
+// mergeRateSample is the merge rate, in merges per 24 hours, at Time.
+type mergeRateSample struct {
+	Time time.Time
+	Rate float64
+}
+
 // information about the sq itself including how fast things are merging and
 // how long since the last merge
 type submitQueueStats struct {
@@ -193,19 +264,117 @@ var (
 	}
 )
 
+// eligibleRecord tracks when a PR first became eligible to merge. lastModified
+// is the PR's LastModifiedTime at that point, so we can notice new commits.
+type eligibleRecord struct {
+	since        time.Time
+	lastModified time.Time
+	loop         int32 // the value of loopStarts when it was last checked
+}
+
+// e2eRetryRecord is how many github e2e retries a PR has left at sha.
+type e2eRetryRecord struct {
+	sha       string
+	remaining int
+}
+
 // SubmitQueue will merge PR which meet a set of requirements.
-//  PR must have LGTM after the last commit
-//  PR must have passed all github CI checks
-//  The google internal jenkins instance must be passing the BlockingJobNames e2e tests
+//
+//	PR must have LGTM after the last commit
+//	PR must have passed all github CI checks
+//	The google internal jenkins instance must be passing the BlockingJobNames e2e tests
 type SubmitQueue struct {
 	githubConfig        *github.Config
 	BlockingJobNames    []string
 	NonBlockingJobNames []string
 	PresubmitJobNames   []string
 	WeakStableJobNames  []string
+	WeakSuccessResults  []string
+	JobPollConcurrency  int
+
+	// After a weak stable job fails without a test failure, this many of
+	// the builds before it must have passed.
+	WeakStablePassesRequired int
+	WeakStableBuildsChecked  int
+
+	// E2EMatrixFile is a YAML or JSON list of jobs which are run for each
+	// cloud and version, every cell of which must pass like the
+	// BlockingJobNames unless it is optional.
+	E2EMatrixFile string
+
+	// MaxBuildAge, if set, is how old the latest build of a blocking job
+	// may be before its results are too stale to merge against.
+	MaxBuildAge time.Duration
+
+	// InfraFailurePatterns are regexps for the build logs of github e2e
+	// failures which weren't the PR's fault. Those runs are retried instead
+	// of dropping the PR.
+	InfraFailurePatterns []string
+
+	// InfraTestPatterns are regexps for the names of JUnit tests which
+	// check the test infrastructure. Their failures in WeakStableJobNames
+	// are weak ones, only failing the job if the builds before it did.
+	InfraTestPatterns []string
+
+	// GithubE2EPollJitter is the fraction of githubE2EPollTime by which
+	// each wait is randomly lengthened or shortened, so that several queues
+	// don't poll github at the same instants.
+	GithubE2EPollJitter float64
 
 	GateApproved bool
 
+	// If ReviewMode is true, PRs need ApprovingReviewsRequired github review
+	// approvals after the last commit instead of the lgtm label. Otherwise
+	// an ApprovingReviewsRequired above 1 means that many different people
+	// must also have commented "/lgtm" since the last commit.
+	ReviewMode               bool
+	ApprovingReviewsRequired int
+
+	// AllowedBaseBranches are the branches PRs may be merged into. If empty
+	// the PRs of each repo may merge into its default branch, found at
+	// startup and kept in defaultBranches by "org/project".
+	AllowedBaseBranches []string
+	defaultBranches     map[string]string
+
+	// PRs which are github drafts or whose title starts with one of
+	// WIPPrefixes are not merged.
+	WIPPrefixes []string
+
+	// MinQueueTime is how long a PR must be eligible to merge before the
+	// queue will merge it. Pushing a new commit starts the wait over.
+	MinQueueTime time.Duration
+
+	// MergeWindow limits merges to times like "Mon-Fri 09:00-17:00" in
+	// MergeWindowTimezone. If empty merges may happen at any time.
+	MergeWindow         []string
+	MergeWindowTimezone string
+	mergeWindow         *mergeWindow
+
+	// If TrackerURL is set, PRs must reference a ticket in the external
+	// tracker which is in one of TrackerReadyStates.
+	TrackerURL         string
+	TrackerReadyStates []string
+	tracker            tracker.Tracker
+
+	// If UseChecks is true, report the queue's state with the github checks
+	// API rather than commit statuses.
+	UseChecks bool
+	backend   StatusBackend
+
+	// If StickyStatusComment is true, the queue's state is reported as a
+	// checklist in one comment on the PR which is edited as it changes.
+	StickyStatusComment bool
+
+	// The labels which mark a PR as covered by a CLA, approved for merge
+	// and blocked from merging.
+	CLALabel        string
+	LGTMLabel       string
+	DoNotMergeLabel string
+
+	// If CLAContext is set, the CLA is checked with that github status
+	// context instead of CLALabel.
+	CLAContext string
+
 	// If FakeE2E is true, don't try to connect to JenkinsHost, all jobs are passing.
 	FakeE2E bool
 
@@ -213,16 +382,195 @@ type SubmitQueue struct {
 	RequiredStatusContexts []string
 	DoNotMergeMilestones   []string
 
+	// A required context which hasn't been reported this long after the
+	// last commit is called out as missing rather than just not green.
+	MissingContextTimeout time.Duration
+
+	// OptionalUntilReported are required contexts, like newly added CI,
+	// which only block a PR once they have reported on its head commit.
+	OptionalUntilReported []string
+
+	// If BaseBranchContexts is set, nothing merges into a branch while any
+	// of these contexts is failing on the branch's head.
+	BaseBranchContexts []string
+	baseBranchStates   map[repoBranch]string
+
+	// E2ERetries is how many times a failed github e2e run is retried for
+	// the same head commit before the PR is dropped from the queue.
+	E2ERetries int
+	e2eRetries map[string]e2eRetryRecord // protected by sync.Mutex
+
+	// A github e2e run which takes longer than MaxE2EDuration, measured
+	// from the retest comment, is abandoned. The PR is kept out of the
+	// queue until it is pushed to or requeued, unless RequeueAfterE2ETimeout
+	// is set, in which case it goes to the back of the queue.
+	MaxE2EDuration         time.Duration
+	RequeueAfterE2ETimeout bool
+	e2eStarted             time.Time         // protected by sync.Mutex
+	e2eTimedOut            map[string]string // prKey() to SHA, protected by sync.Mutex
+
+	// E2EProgressInterval, if set, is how often the status of the PR being
+	// tested is updated with how long its github e2e run has taken.
+	E2EProgressInterval time.Duration
+	e2eProgress         string // last progress reported, protected by sync.Mutex
+
+	// SpeculativeE2E starts the github e2e run of a PR which only needs
+	// lgtm, so that a result is ready when it is lgtm'd.
+	SpeculativeE2E  bool
+	speculativeRuns map[string]speculativeRun // protected by sync.Mutex
+
+	// AllowSelfLGTM counts the lgtm label even when the PR's author added
+	// it themselves.
+	AllowSelfLGTM bool
+
+	// MaxLGTMAge, if set, is how long a PR may have the lgtm label without
+	// being able to merge before the LGTMExpiryAction, "comment" or
+	// "remove", is taken.
+	MaxLGTMAge       time.Duration
+	LGTMExpiryAction string
+	lgtmExpired      map[string]time.Time // lgtm time acted on, protected by sync.Mutex
+
+	// A required context must have been failing for CIFailureGrace before
+	// it is reported as a ciFailure, so a hiccup which recovers by itself
+	// doesn't bother anybody.
+	CIFailureGrace time.Duration
+	ciFailures     map[string]ciFailures // protected by sync.Mutex
+
+	// With IgnoreBaseBranchFailures, a github e2e run whose failed tests
+	// all failed in the latest run of the job's BaseBranchJobs entry, like
+	// "pull-kubernetes-e2e-gce=ci-kubernetes-e2e-gce", counts as a pass.
+	IgnoreBaseBranchFailures bool
+	BaseBranchJobs           []string
+	baseBranchJobs           map[string]string
+
+	// Failures of the tests given to the skip-test command are ignored in
+	// github e2e runs for SkipTestDuration.
+	SkipTestDuration time.Duration
+	skippedTests     map[string]skippedTest // protected by sync.Mutex
+
+	// MaxRetestsPerDay, if set, caps the github e2e retests of a PR, asked
+	// for by the queue or by hand, between one RetestLimitResetHour (UTC)
+	// and the next. Only an admin can let a PR past it early.
+	MaxRetestsPerDay     int
+	RetestLimitResetHour int
+	retestCounts         map[string]*retestCount // protected by sync.Mutex
+
+	// MergeDeployments are "org/project=environment" entries. Each merge
+	// into one of these repos is followed by a github deployment of the
+	// merge commit to the environment.
+	MergeDeployments []string
+	mergeDeployments map[string]string
+
+	// CommandWhitelist are users, in addition to those with push access,
+	// who may give the bot privileged commands like requeue.
+	CommandWhitelist []string
+	overrides        map[string]*contextOverrides // keyed by prKey(), protected by sync.Mutex
+
+	// WhitelistAdmins may add users to and remove them from the
+	// CommandWhitelist with the whitelist command. The changes are saved in
+	// WhitelistFile, if set, and applied on top of --command-whitelist at
+	// startup.
+	WhitelistAdmins  []string
+	WhitelistFile    string
+	whitelistChanges whitelistChanges // protected by sync.Mutex
+
+	// A PR with EmergencyMergeLabel, added by one of EmergencyMergeAdmins,
+	// is merged as soon as it is otherwise valid, without the github e2e
+	// tests.
+	EmergencyMergeLabel  string
+	EmergencyMergeAdmins []string
+
 	RequiredRetestContexts []string
 	RetestBody             string
+	QueueComment           bool
+	EjectionComment        bool
 	Metadata               submitQueueMetadata
 	AdminPort              int
 
+	// ETABuckets like "1h,4h,1d" label queued PRs with how long they should
+	// take to merge, as merge-eta/under-1h through merge-eta/over-1d.
+	ETABuckets []string
+	etaBuckets []time.Duration
+
+	// E2EBotName is the account, and E2ETriggerPhrase what it is told, to
+	// re-run the github e2e tests, as in "@k8s-bot test this".
+	E2EBotName       string
+	E2ETriggerPhrase string
+
+	// CommentTemplatesFile is a YAML file of text/templates for the queued,
+	// ejected and retest comments, see commentData for what they can use.
+	CommentTemplatesFile string
+	commentTemplates     *commentTemplates
+
+	// E2ELabelContexts like "area/gpu=+gpu-e2e" or "docs=-integration"
+	// add to or remove from RequiredRetestContexts for PRs with the label.
+	E2ELabelContexts []string
+	e2eLabelContexts map[string]e2eOverride
+
+	// FairnessQuotas like "P0=5" let one PR from a lower priority tier ahead
+	// after that many PRs from the tier have merged in a row. Tiers without a
+	// quota, and the default of no quotas, use strict priority order.
+	FairnessQuotas []string
+	fairnessQuotas map[int]int
+	mergeStreaks   map[int]int // protected by sync.Mutex
+
+	// JobWeights like "ci-kubernetes-e2e-gce=5" make a job count for more,
+	// or less, than the default of 1 in the health's WeightedStable.
+	JobWeights []string
+	jobWeights map[string]float64
+
+	// PriorityAgingInterval, if set, raises a PR's place in the queue by a
+	// priority tier, as far as P0, for each interval it has been queued.
+	PriorityAgingInterval time.Duration
+	queuedSince           map[string]time.Time // protected by sync.Mutex
+
+	// MaxQueueSize, if set, caps how many PRs are queued at once. Beyond it
+	// only PRs which sort ahead of a queued one get in, bumping that one.
+	MaxQueueSize int
+
+	// RevertTitlePrefixes and RevertLabel mark revert PRs, which are sorted
+	// ahead of even P0 PRs. With RevertSkipRetest they also merge without a
+	// github e2e run, like PRs with retestNotRequiredLabel.
+	RevertTitlePrefixes []string
+	RevertLabel         string
+	RevertSkipRetest    bool
+
+	// MergeMethod is how PRs are merged unless a merge-method label says
+	// otherwise. One of "merge", "squash" or "rebase".
+	MergeMethod string
+
+	// SquashStripLines are regexps matching the lines of a PR's body which
+	// are left out of the commit message when it is squashed.
+	SquashStripLines []string
+	squashStripLines []*regexp.Regexp
+
+	// ReasonStates like "ciFailure=failure" change the github state which
+	// is reported along with a reason.
+	ReasonStates []string
+	reasonStates map[string]string
+
+	// BlockingLabels like "needs-rebase=PR needs a rebase" keep PRs with
+	// the label from merging, as DoNotMergeLabel does, reporting the reason.
+	BlockingLabels []string
+	blockingLabels map[string]string
+
+	// RequiredLabels, like an approved-for-merge label from a release
+	// manager, must all be on a PR before it can merge.
+	RequiredLabels []string
+
+	// BlockedUsers never have their PRs merged by the queue, whatever
+	// labels or commands the PRs have.
+	BlockedUsers []string
+
 	sync.Mutex
 	lastPRStatus  map[string]submitStatus
 	prStatus      map[string]submitStatus // protected by sync.Mutex
 	statusHistory []submitStatus          // protected by sync.Mutex
 
+	// waitReasonTimes is how long PRs have had each reason, without any
+	// detail after ": ". protected by sync.Mutex
+	waitReasonTimes map[string]time.Duration
+
 	clock         utilclock.Clock
 	startTime     time.Time // when the queue started (duh)
 	lastMergeTime time.Time
@@ -230,10 +578,17 @@ type SubmitQueue struct {
 	mergeRate     float64 // per 24 hours
 	loopStarts    int32   // if > 1, then we must have made a complete pass.
 
-	githubE2ERunning  *github.MungeObject         // protect by sync.Mutex!
-	githubE2EQueue    map[int]*github.MungeObject // protected by sync.Mutex!
+	githubE2ERunning  *github.MungeObject            // protect by sync.Mutex!
+	githubE2EQueue    map[string]*github.MungeObject // keyed by prKey(), protected by sync.Mutex!
 	githubE2EPollTime time.Duration
 	lgtmTimeCache     *mungerutil.LabelTimeCache
+	eligibleTimes     map[string]eligibleRecord  // protected by sync.Mutex
+	dependencies      map[string]*prDependencies // keyed by prKey(), protected by sync.Mutex
+	runningSHA        string                     // head of githubE2ERunning when its e2e run started
+	abortE2E          chan struct{}              // closed to abort the running github e2e run
+	e2eStatusLock     sync.Mutex                 // held while a github e2e run or its abort sets a status
+	ejectionReasons   map[string]string          // protected by sync.Mutex
+	repoMerges        map[string]int             // protected by sync.Mutex
 
 	lastE2EStable bool // was e2e stable last time they were checked, protect by sync.Mutex
 	e2e           e2e.E2ETester
@@ -249,9 +604,54 @@ type SubmitQueue struct {
 
 	health        submitQueueHealth
 	healthHistory []healthRecord
+	// HealthRetention is how long entries are kept in healthHistory.
+	HealthRetention time.Duration
+
+	// MergeRateRetention is how long samples of the merge rate are kept in
+	// mergeRateHistory.
+	MergeRateRetention time.Duration
+	mergeRateHistory   []mergeRateSample
+
+	// MinMergeRate and MaxMergeRate, if set, bound the merge rate, in
+	// merges per day, so a long idle spell or a burst of merges doesn't
+	// throw the ETAs out.
+	MinMergeRate float64
+	MaxMergeRate float64
+
+	// RepoConfigFile, if set, is a YAML or JSON file in the repo whose
+	// settings replace the matching flags. It is read again every
+	// RepoConfigRefresh; while it is missing or invalid the flags apply.
+	RepoConfigFile    string
+	RepoConfigRefresh time.Duration
+	repoConfigFlags   *repoQueueConfig // protected by sync.Mutex
+	repoConfigRead    time.Time        // protected by sync.Mutex
 
 	emergencyMergeStopFlag int32
 
+	// paused, set by the pause command, keeps new github e2e runs from
+	// starting and anything from merging until the queue is resumed.
+	paused   bool   // protected by sync.Mutex
+	pausedBy string // protected by sync.Mutex
+
+	// ShutdownTimeout is how long Shutdown waits for in-flight merges, and
+	// ShutdownStateFile, if set, is where it saves the queue.
+	ShutdownTimeout   time.Duration
+	ShutdownStateFile string
+	shuttingDown      bool           // protected by sync.Mutex
+	merging           sync.WaitGroup // merges which have been sent to github
+
+	// SlackWebhookURL, if set, is a Slack incoming webhook which is told
+	// when the queue is blocked or unblocked, and gets a daily summary of
+	// merges.
+	SlackWebhookURL  string
+	slack            *slackNotifier
+	slackMerges      []string  // protected by sync.Mutex
+	lastSlackSummary time.Time // protected by sync.Mutex
+
+	// TrainSize is how many PRs from the top of the queue are tested
+	// together in a merge train. Less than 2 turns merge trains off.
+	TrainSize int
+
 	features *features.Features
 
 	mergeLock   sync.Mutex // acquired when attempting to merge a specific PR
@@ -273,7 +673,7 @@ func init() {
 		lastE2EStable:  true,
 		prStatus:       map[string]submitStatus{},
 		lastPRStatus:   map[string]submitStatus{},
-		githubE2EQueue: map[int]*github.MungeObject{},
+		githubE2EQueue: map[string]*github.MungeObject{},
 	}
 	RegisterMungerOrDie(sq)
 	RegisterStaleComments(sq)
@@ -282,6 +682,9 @@ func init() {
 // Name is the name usable in --pr-mungers
 func (sq *SubmitQueue) Name() string { return "submit-queue" }
 
+// HandlesExtraRepos is true as the queue merges the PRs of --extra-repos too
+func (sq *SubmitQueue) HandlesExtraRepos() bool { return true }
+
 // RequiredFeatures is a slice of 'features' that must be provided
 func (sq *SubmitQueue) RequiredFeatures() []string {
 	return []string{features.GCSFeature, features.TestOptionsFeature, features.BranchProtectionFeature}
@@ -315,6 +718,122 @@ func (sq *SubmitQueue) EmergencyStopHTTP(res http.ResponseWriter, req *http.Requ
 	sq.serve(sq.marshal(struct{ EmergencyInProgress bool }{sq.emergencyMergeStop()}), res, req)
 }
 
+// requeueAll forgets the status of every PR and takes them off the queue, so
+// the next loop evaluates them all from scratch. A PR whose e2e tests are
+// running is left alone. It returns how many PRs were taken off the queue.
+func (sq *SubmitQueue) requeueAll() int {
+	sq.Lock()
+	defer sq.Unlock()
+	prStatus := map[string]submitStatus{}
+	lastPRStatus := map[string]submitStatus{}
+	requeued := 0
+	for key, obj := range sq.githubE2EQueue {
+		if sq.runningLocked(obj) {
+			if status, ok := sq.prStatus[key]; ok {
+				prStatus[key] = status
+			}
+			if status, ok := sq.lastPRStatus[key]; ok {
+				lastPRStatus[key] = status
+			}
+			continue
+		}
+		sq.deleteQueueItem(obj)
+		requeued++
+	}
+	sq.prStatus = prStatus
+	sq.lastPRStatus = lastPRStatus
+	sq.e2eTimedOut = nil
+	return requeued
+}
+
+// RequeueAllHTTP calls requeueAll, for use after changing a setting which
+// affects every PR.
+func (sq *SubmitQueue) RequeueAllHTTP(res http.ResponseWriter, req *http.Request) {
+	requeued := sq.requeueAll()
+	glog.Infof("Requeued all PRs, %d were taken off the queue", requeued)
+	sq.serve(sq.marshal(struct{ Requeued int }{requeued}), res, req)
+}
+
+// defaultRequeueFailedReasons are the reasons requeueFailed looks for if it
+// isn't given any: those a flaky or broken CI can cause.
+var defaultRequeueFailedReasons = []string{"ciFailure", "ghE2EFailed", "e2eTimeout"}
+
+// requeueFailed forgets the status of every PR whose reason has been one of
+// reasons, as named by reasonNames(), for no longer than window, so the
+// next loop evaluates them from scratch. It returns the PRs, sorted.
+func (sq *SubmitQueue) requeueFailed(window time.Duration, reasons []string) ([]string, error) {
+	names := sq.reasonNames()
+	texts := []string{}
+	for _, reason := range reasons {
+		text, ok := names[reason]
+		if !ok {
+			return nil, fmt.Errorf("unknown reason %q", reason)
+		}
+		texts = append(texts, text)
+	}
+
+	sq.Lock()
+	defer sq.Unlock()
+	cutoff := sq.clock.Now().Add(-window)
+	failed := func(status submitStatus) bool {
+		if status.Since.Before(cutoff) {
+			return false
+		}
+		for _, text := range texts {
+			// ciFailure and friends have the context appended
+			if strings.HasPrefix(status.Reason, text) {
+				return true
+			}
+		}
+		return false
+	}
+	keys := sets.NewString()
+	for _, statuses := range []map[string]submitStatus{sq.lastPRStatus, sq.prStatus} {
+		for key, status := range statuses {
+			if failed(status) {
+				keys.Insert(key)
+			} else {
+				// The newer status wins
+				keys.Delete(key)
+			}
+		}
+	}
+	for _, key := range keys.List() {
+		delete(sq.prStatus, key)
+		delete(sq.lastPRStatus, key)
+		delete(sq.e2eTimedOut, key)
+		if obj, ok := sq.githubE2EQueue[key]; ok && !sq.runningLocked(obj) {
+			sq.deleteQueueItem(obj)
+		}
+	}
+	return keys.List(), nil
+}
+
+// RequeueFailedHTTP calls requeueFailed, for use after a CI outage. The
+// window parameter, default an hour, limits it to recent failures and the
+// reasons parameter, a comma separated list of reason names, to those.
+func (sq *SubmitQueue) RequeueFailedHTTP(res http.ResponseWriter, req *http.Request) {
+	window := time.Hour
+	if w := req.URL.Query().Get("window"); w != "" {
+		var err error
+		if window, err = time.ParseDuration(w); err != nil {
+			http.Error(res, fmt.Sprintf("invalid window %q: %v", w, err), http.StatusBadRequest)
+			return
+		}
+	}
+	reasons := defaultRequeueFailedReasons
+	if r := req.URL.Query().Get("reasons"); r != "" {
+		reasons = cleanStringSlice(strings.Split(r, ","))
+	}
+	requeued, err := sq.requeueFailed(window, reasons)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	glog.Infof("Requeued PRs which failed in the last %v with %v: %v", window, reasons, requeued)
+	sq.serve(sq.marshal(struct{ Requeued []string }{requeued}), res, req)
+}
+
 func round(num float64) int {
 	return int(num + math.Copysign(0.5, num))
 }
@@ -342,15 +861,19 @@ func getSmoothFactor(dur time.Duration) float64 {
 }
 
 // This calculates an exponentially smoothed merge Rate based on the formula
-//   newRate = (1-smooth)oldRate + smooth*newRate
+//
+//	newRate = (1-smooth)oldRate + smooth*newRate
+//
 // Which is really great and simple for constant time series data. But of course
 // ours isn't time series data so I vary the smoothing factor based on how long
 // its been since the last entry. See the comments on the `getSmoothFactor` for
 // a discussion of why.
-//    This whole thing was dreamed up by eparis one weekend via a combination
-//    of guess-and-test and intuition. Someone who knows about this stuff
-//    is likely to laugh at the naivete. Point him to where someone intelligent
-//    has thought about this stuff and he will gladly do something smart.
+//
+//	This whole thing was dreamed up by eparis one weekend via a combination
+//	of guess-and-test and intuition. Someone who knows about this stuff
+//	is likely to laugh at the naivete. Point him to where someone intelligent
+//	has thought about this stuff and he will gladly do something smart.
+//
 // Merges that took less than 5 minutes are ignored completely for the rate
 // calculation.
 func calcMergeRate(oldRate float64, last, now time.Time) float64 {
@@ -375,7 +898,7 @@ func calcMergeRate(oldRate float64, last, now time.Time) float64 {
 // Updates merge stats. Should be called once for every merge.
 func (sq *SubmitQueue) updateMergeRate() {
 	now := sq.clock.Now()
-	sq.mergeRate = calcMergeRate(sq.mergeRate, sq.lastMergeTime, now)
+	sq.mergeRate = sq.clampMergeRate(calcMergeRate(sq.mergeRate, sq.lastMergeTime, now))
 
 	// Update stats
 	promMetrics.MergeCount.Inc()
@@ -405,7 +928,66 @@ func (sq *SubmitQueue) calcMergeRateWithTail() float64 {
 	}
 
 	// Pretend as though a merge happened right now to pull down the rate
-	return calcMergeRate(sq.mergeRate, sq.lastMergeTime, now)
+	return sq.clampMergeRate(calcMergeRate(sq.mergeRate, sq.lastMergeTime, now))
+}
+
+// clampMergeRate keeps rate within MinMergeRate and MaxMergeRate, where
+// they are set.
+func (sq *SubmitQueue) clampMergeRate(rate float64) float64 {
+	if sq.MinMergeRate > 0 && rate < sq.MinMergeRate {
+		return sq.MinMergeRate
+	}
+	if sq.MaxMergeRate > 0 && rate > sq.MaxMergeRate {
+		return sq.MaxMergeRate
+	}
+	return rate
+}
+
+func (sq *SubmitQueue) mergeRateRetention() time.Duration {
+	if sq.MergeRateRetention <= 0 {
+		return defaultMergeRateRetention
+	}
+	return sq.MergeRateRetention
+}
+
+// recordMergeRate adds the current merge rate to mergeRateHistory, dropping
+// samples older than the retention. sq.Lock() must be held.
+func (sq *SubmitQueue) recordMergeRate() {
+	now := sq.clock.Now()
+	retention := sq.mergeRateRetention()
+	for len(sq.mergeRateHistory) > 0 && now.Sub(sq.mergeRateHistory[0].Time) > retention {
+		sq.mergeRateHistory = sq.mergeRateHistory[1:]
+	}
+	sq.mergeRateHistory = append(sq.mergeRateHistory, mergeRateSample{
+		Time: now,
+		Rate: sq.calcMergeRateWithTail(),
+	})
+}
+
+// getMergeRateHistory returns a copy of the merge rate samples, oldest first.
+func (sq *SubmitQueue) getMergeRateHistory() []mergeRateSample {
+	sq.Lock()
+	defer sq.Unlock()
+	return append([]mergeRateSample{}, sq.mergeRateHistory...)
+}
+
+// estimateTimeToMerge returns how long we expect it to take before the PR
+// at index in orderedE2EQueue() merges given mergeRate merges per day. It
+// returns false if no estimate can be made because nothing is merging.
+func (sq *SubmitQueue) estimateTimeToMerge(index int, mergeRate float64) (time.Duration, bool) {
+	if mergeRate <= 0 {
+		return 0, false
+	}
+	perMerge := time.Duration(24 / mergeRate * float64(time.Hour))
+	return time.Duration(index+1) * perMerge, true
+}
+
+// formatTimeToMerge renders an estimate from estimateTimeToMerge for humans.
+func formatTimeToMerge(eta time.Duration, ok bool) string {
+	if !ok {
+		return "unknown"
+	}
+	return ((eta / time.Minute) * time.Minute).String()
 }
 
 // Given a string slice with a single empty value this function will return a empty slice.
@@ -422,7 +1004,11 @@ func cleanStringSlice(in []string) []string {
 func (sq *SubmitQueue) Initialize(config *github.Config, features *features.Features) error {
 	sq.features = features
 	sq.RequiredRetestContexts = features.TestOptions.RequiredRetestContexts
-	return sq.internalInitialize(config, features, "")
+	if err := sq.internalInitialize(config, features, ""); err != nil {
+		return err
+	}
+	go sq.shutdownOnSignal()
+	return nil
 }
 
 // internalInitialize will initialize the munger.
@@ -439,10 +1025,166 @@ func (sq *SubmitQueue) internalInitialize(config *github.Config, features *featu
 	sq.RequiredStatusContexts = cleanStringSlice(sq.RequiredStatusContexts)
 	sq.RequiredRetestContexts = cleanStringSlice(sq.RequiredRetestContexts)
 	sq.DoNotMergeMilestones = cleanStringSlice(sq.DoNotMergeMilestones)
+	sq.TrackerReadyStates = cleanStringSlice(sq.TrackerReadyStates)
+	sq.CommandWhitelist = cleanStringSlice(sq.CommandWhitelist)
+	sq.WIPPrefixes = cleanStringSlice(sq.WIPPrefixes)
+	sq.AllowedBaseBranches = cleanStringSlice(sq.AllowedBaseBranches)
+	sq.MergeWindow = cleanStringSlice(sq.MergeWindow)
+	sq.FairnessQuotas = cleanStringSlice(sq.FairnessQuotas)
+	sq.JobWeights = cleanStringSlice(sq.JobWeights)
+	sq.ETABuckets = cleanStringSlice(sq.ETABuckets)
+	sq.E2ELabelContexts = cleanStringSlice(sq.E2ELabelContexts)
+	sq.ReasonStates = cleanStringSlice(sq.ReasonStates)
+	sq.BlockingLabels = cleanStringSlice(sq.BlockingLabels)
+	sq.RequiredLabels = cleanStringSlice(sq.RequiredLabels)
+	sq.BlockedUsers = cleanStringSlice(sq.BlockedUsers)
+	sq.BaseBranchContexts = cleanStringSlice(sq.BaseBranchContexts)
+	sq.BaseBranchJobs = cleanStringSlice(sq.BaseBranchJobs)
+	sq.MergeDeployments = cleanStringSlice(sq.MergeDeployments)
+	sq.RevertTitlePrefixes = cleanStringSlice(sq.RevertTitlePrefixes)
+	sq.EmergencyMergeAdmins = cleanStringSlice(sq.EmergencyMergeAdmins)
+	sq.WhitelistAdmins = cleanStringSlice(sq.WhitelistAdmins)
+	sq.InfraFailurePatterns = cleanStringSlice(sq.InfraFailurePatterns)
+	sq.InfraTestPatterns = cleanStringSlice(sq.InfraTestPatterns)
+	sq.SquashStripLines = cleanStringSlice(sq.SquashStripLines)
+	sq.OptionalUntilReported = cleanStringSlice(sq.OptionalUntilReported)
 	sq.Metadata.RepoPullUrl = fmt.Sprintf("https://github.com/%s/%s/pulls/", config.Org, config.Project)
 	sq.Metadata.ProjectName = strings.Title(config.Project)
 	sq.githubConfig = config
 
+	if err := sq.loadWhitelist(); err != nil {
+		return err
+	}
+
+	if len(sq.AllowedBaseBranches) == 0 {
+		sq.defaultBranches = map[string]string{}
+		for _, repo := range config.Repos() {
+			branch, err := repo.DefaultBranch()
+			if err != nil {
+				glog.Errorf("Unable to find the default branch of %s, only merging into master: %v", repo.FullName(), err)
+				branch = "master"
+			}
+			sq.defaultBranches[repo.FullName()] = branch
+		}
+	}
+
+	if sq.CLALabel == "" {
+		sq.CLALabel = claYesLabel
+	}
+	if sq.LGTMLabel == "" {
+		sq.LGTMLabel = lgtmLabel
+	}
+	if sq.DoNotMergeLabel == "" {
+		sq.DoNotMergeLabel = doNotMergeLabel
+	}
+
+	quotas, err := parseFairnessQuotas(sq.FairnessQuotas)
+	if err != nil {
+		return err
+	}
+	sq.fairnessQuotas = quotas
+
+	weights, err := parseJobWeights(sq.JobWeights)
+	if err != nil {
+		return err
+	}
+	sq.jobWeights = weights
+
+	buckets, err := parseETABuckets(sq.ETABuckets)
+	if err != nil {
+		return err
+	}
+	sq.etaBuckets = buckets
+
+	overrides, err := parseE2ELabelContexts(sq.E2ELabelContexts)
+	if err != nil {
+		return err
+	}
+	sq.e2eLabelContexts = overrides
+
+	states, err := sq.parseReasonStates(sq.ReasonStates)
+	if err != nil {
+		return err
+	}
+	sq.reasonStates = states
+
+	blocking, err := parseBlockingLabels(sq.BlockingLabels)
+	if err != nil {
+		return err
+	}
+	sq.blockingLabels = blocking
+
+	templates, err := loadCommentTemplates(sq.CommentTemplatesFile)
+	if err != nil {
+		return err
+	}
+	sq.commentTemplates = templates
+
+	baseJobs, err := parseBaseBranchJobs(sq.BaseBranchJobs)
+	if err != nil {
+		return err
+	}
+	if sq.IgnoreBaseBranchFailures && len(baseJobs) == 0 {
+		return fmt.Errorf("--ignore-base-branch-failures needs --base-branch-jobs")
+	}
+	sq.baseBranchJobs = baseJobs
+
+	if sq.RetestLimitResetHour < 0 || sq.RetestLimitResetHour > 23 {
+		return fmt.Errorf("--retest-limit-reset-hour must be from 0 to 23, not %d", sq.RetestLimitResetHour)
+	}
+
+	deployments, err := parseMergeDeployments(sq.MergeDeployments)
+	if err != nil {
+		return err
+	}
+	sq.mergeDeployments = deployments
+
+	window, err := parseMergeWindow(sq.MergeWindow, sq.MergeWindowTimezone)
+	if err != nil {
+		return err
+	}
+	sq.mergeWindow = window
+
+	switch sq.MergeMethod {
+	case "", "merge", "squash", "rebase":
+	default:
+		return fmt.Errorf("unknown merge method %q", sq.MergeMethod)
+	}
+
+	switch sq.LGTMExpiryAction {
+	case "", lgtmExpiryComment, lgtmExpiryRemove:
+	default:
+		return fmt.Errorf("unknown lgtm expiry action %q", sq.LGTMExpiryAction)
+	}
+
+	stripLines, err := parseSquashStripLines(sq.SquashStripLines)
+	if err != nil {
+		return err
+	}
+	sq.squashStripLines = stripLines
+
+	infraPatterns := []*regexp.Regexp{}
+	for _, pattern := range sq.InfraFailurePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid --infra-failure-patterns %q: %v", pattern, err)
+		}
+		infraPatterns = append(infraPatterns, re)
+	}
+	infraTests := []*regexp.Regexp{}
+	for _, pattern := range sq.InfraTestPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid --infra-test-patterns %q: %v", pattern, err)
+		}
+		infraTests = append(infraTests, re)
+	}
+
+	matrix, err := e2e.LoadMatrix(sq.E2EMatrixFile)
+	if err != nil {
+		return err
+	}
+
 	// TODO: This is not how injection for tests should work.
 	if sq.FakeE2E {
 		sq.e2e = &fake_e2e.FakeE2ETester{
@@ -461,15 +1203,41 @@ func (sq *SubmitQueue) internalInitialize(config *github.Config, features *featu
 		}
 
 		sq.e2e = (&e2e.RealE2ETester{
-			BlockingJobNames:     sq.BlockingJobNames,
-			NonBlockingJobNames:  sq.NonBlockingJobNames,
-			WeakStableJobNames:   sq.WeakStableJobNames,
-			BuildStatus:          map[string]e2e.BuildInfo{},
-			GoogleGCSBucketUtils: gcs,
+			BlockingJobNames:         sq.BlockingJobNames,
+			NonBlockingJobNames:      sq.NonBlockingJobNames,
+			WeakStableJobNames:       sq.WeakStableJobNames,
+			Matrix:                   matrix,
+			MaxBuildAge:              sq.MaxBuildAge,
+			Clock:                    sq.clock,
+			WeakSuccessResults:       sq.WeakSuccessResults,
+			WeakStablePassesRequired: sq.WeakStablePassesRequired,
+			WeakStableBuildsChecked:  sq.WeakStableBuildsChecked,
+			MaxConcurrency:           sq.JobPollConcurrency,
+			InfraFailurePatterns:     infraPatterns,
+			InfraTestPatterns:        infraTests,
+			BuildStatus:              map[string]e2e.BuildInfo{},
+			GoogleGCSBucketUtils:     gcs,
 		}).Init(admin.Mux)
 	}
 
-	sq.lgtmTimeCache = mungerutil.NewLabelTimeCache(lgtmLabel)
+	sq.lgtmTimeCache = mungerutil.NewLabelTimeCache(sq.LGTMLabel)
+
+	if sq.UseChecks {
+		sq.backend = checkRunBackend{}
+	} else {
+		sq.backend = commitStatusBackend{}
+	}
+	if sq.StickyStatusComment {
+		sq.backend = newStickyCommentBackend(sq, sq.backend)
+	}
+
+	if sq.SlackWebhookURL != "" {
+		sq.slack = newSlackNotifier(sq.SlackWebhookURL)
+	}
+
+	if sq.TrackerURL != "" && sq.tracker == nil {
+		sq.tracker = &tracker.JiraTracker{URL: sq.TrackerURL}
+	}
 
 	if len(config.Address) > 0 {
 		if len(config.WWWRoot) > 0 {
@@ -477,26 +1245,40 @@ func (sq *SubmitQueue) internalInitialize(config *github.Config, features *featu
 		}
 		http.Handle("/prometheus", promhttp.Handler())
 		http.Handle("/prs", gziphandler.GzipHandler(http.HandlerFunc(sq.servePRs)))
+		http.Handle("/blocked-prs", gziphandler.GzipHandler(http.HandlerFunc(sq.serveBlockedPRs)))
+		http.Handle("/pr-times", gziphandler.GzipHandler(http.HandlerFunc(sq.servePRTimes)))
+		http.Handle("/pr-history", gziphandler.GzipHandler(http.HandlerFunc(sq.servePRHistory)))
 		http.Handle("/history", gziphandler.GzipHandler(http.HandlerFunc(sq.serveHistory)))
 		http.Handle("/github-e2e-queue", gziphandler.GzipHandler(http.HandlerFunc(sq.serveGithubE2EStatus)))
 		http.Handle("/google-internal-ci", gziphandler.GzipHandler(http.HandlerFunc(sq.serveGoogleInternalStatus)))
 		http.Handle("/merge-info", gziphandler.GzipHandler(http.HandlerFunc(sq.serveMergeInfo)))
 		http.Handle("/priority-info", gziphandler.GzipHandler(http.HandlerFunc(sq.servePriorityInfo)))
+		http.Handle("/merge-rate", gziphandler.GzipHandler(http.HandlerFunc(sq.serveMergeRate)))
+		http.Handle("/merge-rate-history", gziphandler.GzipHandler(http.HandlerFunc(sq.serveMergeRateHistory)))
+		http.Handle("/wait-reasons", gziphandler.GzipHandler(http.HandlerFunc(sq.serveWaitReasons)))
 		http.Handle("/health", gziphandler.GzipHandler(http.HandlerFunc(sq.serveHealth)))
 		http.Handle("/health.svg", gziphandler.GzipHandler(http.HandlerFunc(sq.serveHealthSVG)))
 		http.Handle("/sq-stats", gziphandler.GzipHandler(http.HandlerFunc(sq.serveSQStats)))
 		http.Handle("/flakes", gziphandler.GzipHandler(http.HandlerFunc(sq.serveFlakes)))
 		http.Handle("/metadata", gziphandler.GzipHandler(http.HandlerFunc(sq.serveMetadata)))
+		http.Handle("/whitelist", gziphandler.GzipHandler(http.HandlerFunc(sq.serveWhitelist)))
+		http.Handle("/config", gziphandler.GzipHandler(http.HandlerFunc(sq.serveConfig)))
 		if sq.BatchURL != "" {
 			http.Handle("/batch", gziphandler.GzipHandler(http.HandlerFunc(sq.serveBatch)))
 		}
 		config.ServeDebugStats("/stats")
-		go http.ListenAndServe(config.Address, nil)
+		config.ListenAndServe()
 	}
 
 	admin.Mux.HandleFunc("/api/emergency/stop", sq.EmergencyStopHTTP)
 	admin.Mux.HandleFunc("/api/emergency/resume", sq.EmergencyStopHTTP)
 	admin.Mux.HandleFunc("/api/emergency/status", sq.EmergencyStopHTTP)
+	admin.Mux.HandleFunc("/api/requeue-all", sq.RequeueAllHTTP)
+	admin.Mux.HandleFunc("/api/requeue-failed", sq.RequeueFailedHTTP)
+	admin.Mux.HandleFunc("/api/pause", sq.PauseHTTP)
+	admin.Mux.HandleFunc("/api/resume", sq.PauseHTTP)
+	admin.Mux.HandleFunc("/api/paused", sq.PauseHTTP)
+	admin.Mux.HandleFunc("/api/merge-rate/recalculate", sq.MergeRateRecalculateHTTP)
 
 	if sq.githubE2EPollTime == 0 {
 		sq.githubE2EPollTime = githubE2EPollTime
@@ -521,8 +1303,11 @@ func (sq *SubmitQueue) internalInitialize(config *github.Config, features *featu
 func (sq *SubmitQueue) EachLoop() error {
 	sq.Lock()
 	sq.updateHealth()
+	sq.recordMergeRate()
 	sq.lastPRStatus = sq.prStatus
 	sq.prStatus = map[string]submitStatus{}
+	sq.baseBranchStates = map[repoBranch]string{}
+	sq.pruneEligibleTimesLocked()
 	promMetrics.OpenPRs.Set(float64(len(sq.lastPRStatus)))
 	promMetrics.QueuedPRs.Set(float64(len(sq.githubE2EQueue)))
 
@@ -532,6 +1317,9 @@ func (sq *SubmitQueue) EachLoop() error {
 	}
 	sq.Unlock()
 
+	sq.refreshRepoConfig()
+	sq.resolveDependencies()
+	sq.sendSlackSummary()
 	for _, obj := range objs {
 		obj.Refresh()
 		// This should recheck it and clean up the queue, we don't care about the result
@@ -546,12 +1334,53 @@ func (sq *SubmitQueue) EachLoop() error {
 func (sq *SubmitQueue) AddFlags(cmd *cobra.Command, config *github.Config) {
 	cmd.Flags().StringSliceVar(&sq.NonBlockingJobNames, "nonblocking-jenkins-jobs", []string{}, "Comma separated list of jobs that don't block merges, but will have status reported and issues filed.")
 	cmd.Flags().StringSliceVar(&sq.BlockingJobNames, "jenkins-jobs", []string{}, "Comma separated list of jobs in Jenkins that should block merges if failing.")
+	cmd.Flags().StringVar(&sq.E2EMatrixFile, "e2e-matrix-file", "", "YAML or JSON list of {job, clouds, versions, optional} jobs which block merges unless every cell, a job named like foo-gce-1.8, is passing. Cells like gce-1.8 in optional are only reported")
+	cmd.Flags().DurationVar(&sq.MaxBuildAge, "max-build-age", 0, "If set, merges are held while the latest build of a blocking job finished longer ago than this, since the job may be stuck. 0 disables")
 	cmd.Flags().StringSliceVar(&sq.PresubmitJobNames, "presubmit-jobs", []string{""}, "Comma separated list of jobs in Jenkins that run presubmit and should have issues filed for flakes.")
 	cmd.Flags().StringSliceVar(&sq.WeakStableJobNames, "weak-stable-jobs",
 		[]string{},
 		"Comma separated list of jobs in Jenkins to use for stability testing that needs only weak success")
+	cmd.Flags().IntVar(&sq.WeakStablePassesRequired, "weak-stable-passes-required", 1, "When the latest build of a --weak-stable-jobs job fails without a failed test, how many of the --weak-stable-builds-checked builds before it must have passed")
+	cmd.Flags().IntVar(&sq.WeakStableBuildsChecked, "weak-stable-builds-checked", 2, "How many builds before a weakly failed build of a --weak-stable-jobs job are checked. Builds which can't be read count as failures")
+	cmd.Flags().StringSliceVar(&sq.InfraFailurePatterns, "infra-failure-patterns", []string{}, "Comma separated list of regexps matching the build logs of github e2e failures caused by the test infrastructure, which are retried instead of dropping the PR")
+	cmd.Flags().StringSliceVar(&sq.InfraTestPatterns, "infra-test-patterns", []string{}, "Comma separated list of regexps matching the JUnit names, like \"name {classname}\", of tests which check the test infrastructure. Their failures in --weak-stable-jobs are treated like failures without a failed test.")
+	cmd.Flags().StringSliceVar(&sq.WeakSuccessResults, "weak-stable-success-results", []string{"SUCCESS"}, "Comma separated list of Jenkins results, like UNSTABLE, which count as a pass for --weak-stable-jobs")
+	cmd.Flags().Float64Var(&sq.GithubE2EPollJitter, "github-e2e-poll-jitter", 0, "Fraction, from 0 to 1, of the time between checks of the github e2e queue by which each check is randomly moved")
+	cmd.Flags().IntVar(&sq.JobPollConcurrency, "job-poll-concurrency", 8, "Number of jobs whose results are fetched at the same time")
 	cmd.Flags().StringSliceVar(&sq.RequiredStatusContexts, "required-contexts", []string{}, "Comma separate list of status contexts required for a PR to be considered ok to merge")
+	cmd.Flags().DurationVar(&sq.CIFailureGrace, "ci-failure-grace", 0, "How long a required context must keep failing before the PR is reported as failing CI. 0 reports it at once.")
+	cmd.Flags().DurationVar(&sq.MissingContextTimeout, "missing-context-timeout", 0, "If a required context hasn't been reported this long after a PR's last commit, say it is missing instead of failing. 0 disables.")
+	cmd.Flags().StringSliceVar(&sq.OptionalUntilReported, "optional-until-reported-contexts", []string{}, "Comma separated list of required contexts which don't block a PR until they have reported on its head commit, for CI which is new and hasn't run on older PRs")
+	cmd.Flags().DurationVar(&sq.PriorityAgingInterval, "priority-aging-interval", 0, "If set, a queued PR is sorted one priority higher, as far as P0, for each interval it has been waiting. 0 disables aging")
+	cmd.Flags().IntVar(&sq.MaxQueueSize, "max-queue-size", 0, "If set, at most this many PRs are queued for the github e2e run. Lower priority PRs wait outside the queue until there is room. 0 is unlimited")
+	cmd.Flags().StringSliceVar(&sq.RevertTitlePrefixes, "revert-title-prefixes", []string{}, "Comma separated list of title prefixes, like \"Revert\", marking revert PRs, which are sorted ahead of even P0 PRs")
+	cmd.Flags().StringVar(&sq.RevertLabel, "revert-label", "", "If set, PRs with this label are treated as reverts, whatever their title")
+	cmd.Flags().BoolVar(&sq.RevertSkipRetest, "revert-skip-retest", false, "Merge revert PRs without re-running the github e2e tests")
+	cmd.Flags().BoolVar(&sq.SpeculativeE2E, "speculative-e2e", false, "Start the github e2e tests of PRs which only need lgtm, so they can merge without waiting for them once lgtm'd")
+	cmd.Flags().IntVar(&sq.E2ERetries, "e2e-retries", 0, "How many times to retry a failed github e2e run for the same commit before dropping the PR from the queue")
+	cmd.Flags().DurationVar(&sq.E2EProgressInterval, "e2e-progress-interval", 0, "If set, the status of the PR being tested says how long its github e2e run has taken, updated in steps of this long. 0 disables")
+	cmd.Flags().DurationVar(&sq.MaxE2EDuration, "max-e2e-duration", 0, "If set, a github e2e run still going this long after the retest comment is abandoned. 0 waits for as long as github e2e waits")
+	cmd.Flags().BoolVar(&sq.RequeueAfterE2ETimeout, "requeue-after-e2e-timeout", false, "Put PRs whose github e2e run hit --max-e2e-duration at the back of the queue, instead of leaving them out until they are pushed to or requeued")
+	cmd.Flags().DurationVar(&sq.SkipTestDuration, "skip-test-duration", 7*24*time.Hour, "How long failures of a test given to the skip-test command are ignored in github e2e runs")
+	cmd.Flags().IntVar(&sq.MaxRetestsPerDay, "max-retests-per-day", 0, "If set, a PR which has had this many github e2e retests, by the queue or by hand, since the last --retest-limit-reset-hour is held out of the queue until the next one or until an admin allows more. 0 is unlimited")
+	cmd.Flags().IntVar(&sq.RetestLimitResetHour, "retest-limit-reset-hour", 0, "Hour of the day, in UTC, at which the --max-retests-per-day counts start over")
+	cmd.Flags().BoolVar(&sq.IgnoreBaseBranchFailures, "ignore-base-branch-failures", false, "Let PRs merge when every test which failed in their github e2e run also failed in the latest run of the --base-branch-jobs job")
+	cmd.Flags().StringSliceVar(&sq.BaseBranchJobs, "base-branch-jobs", []string{}, "Comma separated list like \"pull-kubernetes-e2e-gce=ci-kubernetes-e2e-gce\" of the jobs which run each github e2e job's tests against the base branch, for --ignore-base-branch-failures")
+	cmd.Flags().StringVar(&sq.E2EBotName, "e2e-bot-name", jenkinsBotName, "Account which is mentioned to re-run the github e2e tests")
+	cmd.Flags().StringVar(&sq.E2ETriggerPhrase, "e2e-trigger-phrase", e2eTriggerPhrase, "What the --e2e-bot-name is told to re-run the github e2e tests")
+	cmd.Flags().StringVar(&sq.CommentTemplatesFile, "comment-templates", "", "YAML file with 'queued', 'ejected' and 'retest' text/templates which change the wording of the bot's comments. Missing ones keep the default")
 	cmd.Flags().StringVar(&sq.RetestBody, "retest-body", retestBody, "message which, when posted to the PR, will cause ALL `required-retest-contexts` to be re-tested")
+	cmd.Flags().BoolVar(&sq.UseChecks, "use-checks", false, "Read CI results from, and report the queue's state as, github check runs instead of commit statuses")
+	cmd.Flags().BoolVar(&sq.StickyStatusComment, "sticky-status-comment", false, "Report the queue's state as a checklist in a single comment on the PR, edited in place, instead of as a status")
+	cmd.Flags().DurationVar(&sq.MaxLGTMAge, "max-lgtm-age", 0, "If set, act on PRs which have had the lgtm label for this long without being able to merge. 0 disables")
+	cmd.Flags().BoolVar(&sq.AllowSelfLGTM, "allow-self-lgtm", false, "Count the lgtm label when it was added by the PR's own author")
+	cmd.Flags().StringVar(&sq.LGTMExpiryAction, "lgtm-expiry-action", lgtmExpiryComment, "What to do about a PR past --max-lgtm-age: comment, or remove to also take away the lgtm label")
+	cmd.Flags().StringSliceVar(&sq.MergeDeployments, "merge-deployments", []string{}, "Comma separated list of org/project=environment. After a PR in one of these repos merges, a github deployment of the merge commit to the environment is created")
+	cmd.Flags().StringVar(&sq.MergeMethod, "merge-method", "merge", fmt.Sprintf("How to merge PRs: merge, squash or rebase. Overridden by the %q, %q and %q labels.", mergeMethodMergeLabel, mergeMethodSquashLabel, mergeMethodRebaseLabel))
+	cmd.Flags().StringSliceVar(&sq.SquashStripLines, "squash-strip-lines", defaultSquashStripLines, "Comma separated list of regexps matching the lines of a PR's body, like checklist items, which are left out of the commit message when it is squashed")
+	cmd.Flags().BoolVar(&sq.QueueComment, "queue-comment", false, "If true, comment on PRs with their queue position and estimated time to merge when they are queued")
+	cmd.Flags().StringSliceVar(&sq.ETABuckets, "merge-eta-buckets", []string{}, "Comma separated boundaries like \"1h,4h,1d\" to label queued PRs with their estimated time to merge, as merge-eta/under-1h ... merge-eta/over-1d. Empty means no labels.")
+	cmd.Flags().BoolVar(&sq.EjectionComment, "ejection-comment", false, "If true, comment on PRs explaining why they were removed from the queue when CI fails or they need a rebase")
 	cmd.Flags().BoolVar(&sq.FakeE2E, "fake-e2e", false, "Whether to use a fake for testing E2E stability.")
 	cmd.Flags().StringSliceVar(&sq.DoNotMergeMilestones, "do-not-merge-milestones", []string{}, "List of milestones which, when applied, will cause the PR to not be merged")
 	cmd.Flags().IntVar(&sq.AdminPort, "admin-port", 9999, "If non-zero, will serve administrative actions on this port.")
@@ -560,12 +1389,56 @@ func (sq *SubmitQueue) AddFlags(cmd *cobra.Command, config *github.Config) {
 	cmd.Flags().StringVar(&sq.Metadata.ChartUrl, "chart-url", "", "URL to access the submit-queue instance's health charts.")
 	cmd.Flags().StringVar(&sq.BatchURL, "batch-url", "", "Prow data.json URL to read batch results")
 	cmd.Flags().BoolVar(&sq.GateApproved, "gate-approved", false, "Gate on approved label")
+	cmd.Flags().StringSliceVar(&sq.BaseBranchContexts, "base-branch-contexts", []string{}, "Comma separated list of github contexts which must not be failing on the head of a PR's base branch for it to merge")
+	cmd.Flags().StringVar(&sq.CLAContext, "cla-context", "", "If set, a github status context, like cla/linuxfoundation, which must be success instead of the PR having the --cla-label")
+	cmd.Flags().StringVar(&sq.CLALabel, "cla-label", claYesLabel, fmt.Sprintf("Label which shows the PR author signed the CLA. %q and %q are also always accepted.", cncfClaYesLabel, claHumanLabel))
+	cmd.Flags().StringVar(&sq.LGTMLabel, "lgtm-label", lgtmLabel, "Label a PR must have to be merged")
+	cmd.Flags().StringVar(&sq.DoNotMergeLabel, "do-not-merge-label", doNotMergeLabel, "Label which prevents a PR from being merged")
+	cmd.Flags().StringVar(&sq.EmergencyMergeLabel, "emergency-merge-label", emergencyMergeLabel, "Label which, if added by one of the --emergency-merge-admins, merges a PR without waiting for the github e2e tests")
+	cmd.Flags().StringSliceVar(&sq.EmergencyMergeAdmins, "emergency-merge-admins", []string{}, "Comma separated list of users who may use the --emergency-merge-label. The --command-whitelist is not enough")
+	cmd.Flags().StringSliceVar(&sq.CommandWhitelist, "command-whitelist", []string{}, "Comma separated list of users, in addition to those with push access, who may give the bot commands like requeue")
+	cmd.Flags().StringSliceVar(&sq.WhitelistAdmins, "whitelist-admins", []string{}, "Comma separated list of users who may change the --command-whitelist with the whitelist command")
+	cmd.Flags().StringVar(&sq.WhitelistFile, "whitelist-file", "", "If set, changes made with the whitelist command are saved to this file and reloaded on startup")
+	cmd.Flags().BoolVar(&sq.ReviewMode, "review-mode", false, "Require github review approvals instead of the lgtm label")
+	cmd.Flags().IntVar(&sq.ApprovingReviewsRequired, "approving-reviews-required", 1, "Number of approving reviews submitted after the last commit needed when --review-mode is set. Without it, a number above 1 is how many different people must have commented /lgtm since the last commit, as well as the lgtm label being there")
+	cmd.Flags().StringVar(&sq.TrackerURL, "tracker-url", "", "If set, base URL of a Jira instance. PRs must reference a ticket there which is in one of --tracker-ready-states.")
+	cmd.Flags().StringSliceVar(&sq.TrackerReadyStates, "tracker-ready-states", []string{"Ready for Merge"}, "Comma separated list of tracker ticket states which allow a PR to merge")
+	cmd.Flags().StringSliceVar(&sq.AllowedBaseBranches, "allowed-base-branches", []string{}, "Comma separated list of branches PRs may be merged into. Defaults to the repo's default branch.")
+	cmd.Flags().StringSliceVar(&sq.WIPPrefixes, "wip-prefixes", []string{"WIP"}, "Comma separated list of title prefixes which mark a PR as a work in progress that should not be merged")
+	cmd.Flags().StringSliceVar(&sq.E2ELabelContexts, "e2e-label-contexts", []string{}, "Comma separated list like \"area/gpu=+gpu-e2e,kind/docs=-integration\". PRs with the label must also pass (+) or need not pass (-) the github e2e context.")
+	cmd.Flags().StringSliceVar(&sq.BlockingLabels, "blocking-labels", []string{}, "Comma separated list like \"needs-rebase=PR needs a rebase\" of labels which, like --do-not-merge-label, prevent a PR from being merged. The reason is reported as the PR's status.")
+	cmd.Flags().StringSliceVar(&sq.RequiredLabels, "required-labels", []string{}, "Comma separated list of labels which must all be on a PR before it can merge, like an approved-for-merge label from a release manager.")
+	cmd.Flags().StringSliceVar(&sq.BlockedUsers, "blocked-users", []string{}, "Comma separated list of users whose PRs are never merged automatically, even by an emergency merge")
+	cmd.Flags().StringSliceVar(&sq.ReasonStates, "reason-states", []string{}, "Comma separated list like \"ciFailure=failure,cooling=success\" of the github state to report for a reason. Reasons are named after their constants in submit-queue.go.")
+	cmd.Flags().StringSliceVar(&sq.FairnessQuotas, "fairness-quotas", []string{}, "Comma separated list like \"P0=5,P1=5\". After that many PRs of a priority merge in a row, one PR of a lower priority goes next. Unset means strict priority order.")
+	cmd.Flags().StringSliceVar(&sq.JobWeights, "job-weights", []string{}, "Comma separated list like \"ci-kubernetes-e2e-gce=5\" of how much a job counts towards the overall health. Jobs which aren't listed count 1.")
+	cmd.Flags().StringSliceVar(&sq.MergeWindow, "merge-window", []string{}, "Comma separated list of times PRs may be merged, like \"Mon-Fri 09:00-17:00\". Unset means any time.")
+	cmd.Flags().StringVar(&sq.MergeWindowTimezone, "merge-window-timezone", "UTC", "IANA timezone, e.g. America/Los_Angeles, that --merge-window is in")
+	cmd.Flags().DurationVar(&sq.ShutdownTimeout, "shutdown-timeout", time.Minute, "How long to wait for in-flight merges to finish after SIGTERM")
+	cmd.Flags().StringVar(&sq.ShutdownStateFile, "shutdown-state-file", "", "If set, the queue is written to this file as JSON when shutting down")
+	cmd.Flags().StringVar(&sq.SlackWebhookURL, "slack-webhook-url", "", "If set, a Slack incoming webhook URL to tell when the queue is blocked or unblocked, and to send a daily summary of merges to")
+	cmd.Flags().IntVar(&sq.TrainSize, "merge-train-size", 0, "If at least 2, test this many PRs from the top of the queue together on a "+mergeTrainBranchPrefix+"* branch before merging them")
+	cmd.Flags().DurationVar(&sq.HealthRetention, "health-retention", defaultHealthRetention, "How long to keep the history behind /health")
+	cmd.Flags().DurationVar(&sq.MergeRateRetention, "merge-rate-retention", defaultMergeRateRetention, "How long to keep samples of the merge rate for /merge-rate-history")
+	cmd.Flags().Float64Var(&sq.MinMergeRate, "min-merge-rate", 0, "If set, the merge rate, in merges per day, is never taken to be lower than this. 0 is no floor")
+	cmd.Flags().Float64Var(&sq.MaxMergeRate, "max-merge-rate", 0, "If set, the merge rate, in merges per day, is never taken to be higher than this. 0 is no ceiling")
+	cmd.Flags().StringVar(&sq.RepoConfigFile, "repo-config-file", "", "Path in the repo, like .github/submit-queue.yaml, of a YAML or JSON file with 'requiredContexts', 'requiredRetestContexts' and 'doNotMergeMilestones' which replace the matching flags. Without a valid file the flags apply")
+	cmd.Flags().DurationVar(&sq.RepoConfigRefresh, "repo-config-refresh", 10*time.Minute, "How often the --repo-config-file is read again")
+	cmd.Flags().DurationVar(&sq.MinQueueTime, "min-queue-time", 0, "Minimum time a PR must be eligible to merge before it will be merged. Pushing a new commit resets the timer.")
 }
 
 // Hold the lock
+func (sq *SubmitQueue) healthRetention() time.Duration {
+	if sq.HealthRetention <= 0 {
+		return defaultHealthRetention
+	}
+	return sq.HealthRetention
+}
+
 func (sq *SubmitQueue) updateHealth() {
 	// Remove old entries from the front.
-	for len(sq.healthHistory) > 0 && time.Since(sq.healthHistory[0].Time).Hours() > 24.0 {
+	retention := sq.healthRetention()
+	for len(sq.healthHistory) > 0 && time.Since(sq.healthHistory[0].Time) > retention {
 		sq.healthHistory = sq.healthHistory[1:]
 	}
 	// Make the current record
@@ -589,7 +1462,34 @@ func (sq *SubmitQueue) updateHealth() {
 	sq.health.TotalLoops = len(sq.healthHistory)
 	sq.health.NumStable = 0
 	sq.health.NumStablePerJob = map[string]int{}
+	sq.health.WeightedStable = 0
+	sq.health.JobWeights = sq.jobWeights
 	sq.health.MergePossibleNow = stable && !emergencyStop
+	sq.health.Repos = map[string]repoHealth{}
+	if sq.githubConfig != nil {
+		for _, repo := range sq.githubConfig.Repos() {
+			sq.health.Repos[repo.FullName()] = repoHealth{
+				MergesSinceRestart:  sq.repoMerges[repo.FullName()],
+				AllowedBaseBranches: sq.allowedBaseBranches(repo.FullName()),
+				MergePossibleNow:    sq.health.MergePossibleNow,
+			}
+		}
+	}
+	for rb, state := range sq.baseBranchStates {
+		h, ok := sq.health.Repos[rb.repo]
+		if !ok || (state != "failure" && state != "error") {
+			continue
+		}
+		h.RedBaseBranches = append(h.RedBaseBranches, rb.branch)
+		h.MergePossibleNow = false
+		sort.Strings(h.RedBaseBranches)
+		sq.health.Repos[rb.repo] = h
+	}
+	for _, obj := range sq.githubE2EQueue {
+		h := sq.health.Repos[obj.Repo()]
+		h.Queued++
+		sq.health.Repos[obj.Repo()] = h
+	}
 	if sq.health.MergePossibleNow {
 		promMetrics.Blocked.Set(0)
 	} else {
@@ -599,6 +1499,7 @@ func (sq *SubmitQueue) updateHealth() {
 		if record.Overall {
 			sq.health.NumStable += 1
 		}
+		sq.health.WeightedStable += sq.weightedStability(record) / float64(len(sq.healthHistory))
 		for job, stable := range record.Jobs {
 			if _, ok := sq.health.NumStablePerJob[job]; !ok {
 				sq.health.NumStablePerJob[job] = 0
@@ -646,7 +1547,7 @@ func (sq *SubmitQueue) e2eStable(aboutToMerge bool) bool {
 		reason = e2eRecover
 		avatar = "success.png"
 	} else if wentUnstable {
-		reason = e2eFailure
+		reason = sq.e2eFailureReason()
 		avatar = "error.png"
 	}
 	if reason != "" {
@@ -659,12 +1560,22 @@ func (sq *SubmitQueue) e2eStable(aboutToMerge bool) bool {
 			Reason: reason,
 		}
 		sq.Lock()
-		sq.statusHistory = append(sq.statusHistory, submitStatus)
+		sq.appendStatusHistory(submitStatus)
 		sq.Unlock()
+		sq.notifySlack(reason)
 	}
 	return stable
 }
 
+// e2eFailureReason is why e2eStable was false: staleCI if a blocking job
+// has stopped reporting new builds, otherwise e2eFailure.
+func (sq *SubmitQueue) e2eFailureReason() string {
+	if len(sq.e2e.StaleJobs()) > 0 {
+		return staleCI
+	}
+	return e2eFailure
+}
+
 // This serves little purpose other than to show updates every minute in the
 // web UI. Stable() will get called as needed against individual PRs as well.
 func (sq *SubmitQueue) updateGoogleE2ELoop() {
@@ -685,6 +1596,7 @@ func objToStatusPullRequest(obj *github.MungeObject) *statusPullRequest {
 		Title:     *obj.Issue.Title,
 		Login:     *obj.Issue.User.Login,
 		AvatarURL: *obj.Issue.User.AvatarURL,
+		Repo:      obj.Repo(),
 	}
 	pr, ok := obj.GetPR()
 	if !ok {
@@ -726,75 +1638,121 @@ func objToStatusPullRequest(obj *github.MungeObject) *statusPullRequest {
 	return &res
 }
 
-func reasonToState(reason string) string {
-	switch reason {
-	case merged, mergedByHand, mergedSkippedRetest, mergedBatch:
-		return "success"
-	case e2eFailure, ghE2EQueued, ghE2EWaitingStart, ghE2ERunning:
-		return "success"
-	case unknown:
-		return "failure"
-	default:
-		return "pending"
-	}
-}
-
 // SetMergeStatus will set the status given a particular PR. This function should
 // be used instead of manipulating the prStatus directly as sq.Lock() must be
 // called when manipulating that structure
 // `obj` is the active github object
 // `reason` is the new 'status' for this object
 func (sq *SubmitQueue) SetMergeStatus(obj *github.MungeObject, reason string) {
-	glog.V(4).Infof("SubmitQueue not merging %d because %q", *obj.Issue.Number, reason)
+	obj.Log().WithReason(reason).V(4).Infof("SubmitQueue not merging")
+	now := sq.clock.Now()
 	submitStatus := submitStatus{
-		Time:              sq.clock.Now(),
+		Time:              now,
 		statusPullRequest: *objToStatusPullRequest(obj),
 		Reason:            reason,
+		Since:             now,
+		Priority:          priority(obj),
 	}
 
-	status, ok := obj.GetStatus(sqContext)
-	if !ok || status == nil || *status.Description != reason {
-		state := reasonToState(reason)
-		url := fmt.Sprintf("http://submit-queue.k8s.io/#/prs?prDisplay=%d&historyDisplay=%d", *obj.Issue.Number, *obj.Issue.Number)
-		_ = obj.SetStatus(state, url, reason, sqContext)
+	backend := sq.statusBackend()
+	// The sticky comment also shows the checklist and queue position, so it
+	// is rendered every time. It is only edited if the body changed.
+	_, sticky := backend.(*stickyCommentBackend)
+	if description, ok := backend.Description(obj); sticky || !ok || description != reason {
+		state := sq.reasonToState(reason)
+		_ = backend.Set(obj, state, statusURL(obj), reason)
 	}
 
+	if sq.recordMergeStatus(obj, submitStatus) {
+		sq.writeEjectionComment(obj, reason)
+	}
+}
+
+// statusURL is where the status the queue reports on obj links to.
+func statusURL(obj *github.MungeObject) string {
+	return fmt.Sprintf("http://submit-queue.k8s.io/#/prs?prDisplay=%d&historyDisplay=%d", *obj.Issue.Number, *obj.Issue.Number)
+}
+
+// recordMergeStatus saves the PR's new status and removes it from the queue
+// if the status means it no longer belongs there. It returns true if the PR
+// was removed from the queue.
+func (sq *SubmitQueue) recordMergeStatus(obj *github.MungeObject, submitStatus submitStatus) bool {
+	reason := submitStatus.Reason
 	sq.Lock()
 	defer sq.Unlock()
 
 	// If we are currently retesting E2E the normal munge loop might find
 	// that the ci tests are not green. That's normal and expected and we
 	// should just ignore that status update entirely.
-	if sq.githubE2ERunning != nil && *sq.githubE2ERunning.Issue.Number == *obj.Issue.Number && strings.HasPrefix(reason, ciFailure) {
-		return
+	if sq.runningLocked(obj) && strings.HasPrefix(reason, ciFailure) {
+		return false
 	}
 
-	if sq.onQueue(obj) {
-		sq.statusHistory = append(sq.statusHistory, submitStatus)
-		if len(sq.statusHistory) > 128 {
-			sq.statusHistory = sq.statusHistory[1:]
-		}
+	key := sq.prKey(obj)
+	prev, ok := sq.prStatus[key]
+	if !ok {
+		prev, ok = sq.lastPRStatus[key]
+	}
+	if ok && prev.Reason == reason && !prev.Since.IsZero() {
+		submitStatus.Since = prev.Since
+	}
+	if ok {
+		sq.accountWaitReason(prev, submitStatus.Time)
 	}
-	sq.prStatus[strconv.Itoa(*obj.Issue.Number)] = submitStatus
+
+	queued := sq.onQueue(obj)
+	if queued {
+		sq.appendStatusHistory(submitStatus)
+	}
+	sq.prStatus[key] = submitStatus
 	sq.cleanupOldE2E(obj, reason)
+	return queued && !sq.onQueue(obj)
+}
+
+// neverReported returns true if nothing has reported the context on the PR
+// in the MissingContextTimeout since its last commit. That usually means the
+// context name is misconfigured rather than CI not having run yet.
+func (sq *SubmitQueue) neverReported(obj *github.MungeObject, context string) bool {
+	if sq.MissingContextTimeout <= 0 {
+		return false
+	}
+	if reported, ok := sq.statusBackend().Reported(obj, context); !ok || reported {
+		return false
+	}
+	lastModified, ok := obj.LastModifiedTime()
+	if !ok || lastModified == nil {
+		return false
+	}
+	return sq.clock.Since(*lastModified) > sq.MissingContextTimeout
 }
 
 // setContextFailedStatus calls SetMergeStatus after determining a particular github status
 // which is failed.
 func (sq *SubmitQueue) setContextFailedStatus(obj *github.MungeObject, contexts []string) {
 	sort.Strings(contexts)
+	failed := []string{}
 	for i, context := range contexts {
 		contextSlice := contexts[i : i+1]
-		success, ok := obj.IsStatusSuccess(contextSlice)
+		success, ok := sq.contextsSucceeded(obj, contextSlice)
 		if ok && success {
 			continue
 		}
-		failMsg := fmt.Sprintf(ciFailureFmt, context)
-		sq.SetMergeStatus(obj, failMsg)
+		failed = append(failed, context)
+	}
+	if len(failed) == 0 {
+		obj.Log().Errorf("Inside setContextFailedStatus() but none of the status's failed! %v", contexts)
+		sq.SetMergeStatus(obj, ciFailure)
+		return
+	}
+	if sq.neverReported(obj, failed[0]) {
+		sq.SetMergeStatus(obj, fmt.Sprintf(missingContextFmt, failed[0]))
 		return
 	}
-	glog.Errorf("Inside setContextFailedStatus() but none of the status's failed! %d: %v", obj.Number(), contexts)
-	sq.SetMergeStatus(obj, ciFailure)
+	if context, graced := sq.ciFailureToReport(obj, failed); graced {
+		sq.SetMergeStatus(obj, fmt.Sprintf(ciPendingFmt, context))
+	} else {
+		sq.SetMergeStatus(obj, fmt.Sprintf(ciFailureFmt, context))
+	}
 }
 
 // sq.Lock() MUST be held!
@@ -818,6 +1776,15 @@ func (sq *SubmitQueue) marshal(data interface{}) []byte {
 	return b
 }
 
+// appendStatusHistory adds status to the statusHistory, dropping the oldest
+// entry once there are maxStatusHistory. sq.Lock() must be held.
+func (sq *SubmitQueue) appendStatusHistory(status submitStatus) {
+	sq.statusHistory = append(sq.statusHistory, status)
+	if len(sq.statusHistory) > maxStatusHistory {
+		sq.statusHistory = sq.statusHistory[len(sq.statusHistory)-maxStatusHistory:]
+	}
+}
+
 func (sq *SubmitQueue) getQueueHistory() []byte {
 	sq.Lock()
 	defer sq.Unlock()
@@ -839,6 +1806,63 @@ func (sq *SubmitQueue) getQueueStatus() []byte {
 	return sq.marshal(status)
 }
 
+// getBlockedPRs returns a json list of every PR the queue knows about, with
+// why it is not merging and how that changed since the last munge loop.
+func (sq *SubmitQueue) getBlockedPRs() []byte {
+	sq.Lock()
+	defer sq.Unlock()
+	now := sq.clock.Now()
+
+	positions := map[string]int{}
+	for i, key := range sq.orderedE2EQueue() {
+		positions[key] = i
+	}
+
+	keys := map[string]bool{}
+	for key := range sq.lastPRStatus {
+		keys[key] = true
+	}
+	for key := range sq.prStatus {
+		keys[key] = true
+	}
+
+	prs := []blockedPR{}
+	for key := range keys {
+		status, current := sq.prStatus[key]
+		last, hasLast := sq.lastPRStatus[key]
+		if !current {
+			status = last
+		}
+		pr := blockedPR{
+			Number:             status.Number,
+			URL:                status.URL,
+			Title:              status.Title,
+			Login:              status.Login,
+			Reason:             status.Reason,
+			Priority:           status.Priority,
+			Since:              status.Since,
+			TimeInStateSeconds: int64(now.Sub(status.Since).Seconds()),
+			QueuePosition:      -1,
+		}
+		if pos, ok := positions[key]; ok {
+			pr.QueuePosition = pos
+		}
+		if current && hasLast && last.Reason != status.Reason {
+			pr.Changed = true
+			pr.PreviousReason = last.Reason
+		}
+		prs = append(prs, pr)
+	}
+	sort.Sort(blockedPRsByNumber(prs))
+	return sq.marshal(prs)
+}
+
+type blockedPRsByNumber []blockedPR
+
+func (s blockedPRsByNumber) Len() int           { return len(s) }
+func (s blockedPRsByNumber) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s blockedPRsByNumber) Less(i, j int) bool { return s[i].Number < s[j].Number }
+
 func (sq *SubmitQueue) getGithubE2EStatus() []byte {
 	sq.Lock()
 	defer sq.Unlock()
@@ -870,30 +1894,79 @@ func (sq *SubmitQueue) getMetaData() []byte {
 
 const (
 	unknown                 = "unknown failure"
-	noCLA                   = "PR is missing CLA label; needs one of " + claYesLabel + ", " + cncfClaYesLabel + " or " + claHumanLabel
-	noLGTM                  = "PR does not have " + lgtmLabel + " label."
 	noApproved              = "PR does not have " + approvedLabel + " label."
-	lgtmEarly               = "The PR was changed after the " + lgtmLabel + " label was added."
 	approvedEarly           = "The PR was changed after the " + approvedLabel + " label was added."
 	unmergeable             = "PR is unable to be automatically merged. Needs rebase."
 	undeterminedMergability = "Unable to determine is PR is mergeable. Will try again later."
-	noMerge                 = "Will not auto merge because " + doNotMergeLabel + " is present"
 	ciFailure               = "Required Github CI test is not green"
 	ciFailureFmt            = ciFailure + ": %s"
+	ciPending               = "Required Github CI test is not green, waiting in case it recovers"
+	ciPendingFmt            = ciPending + ": %s"
 	e2eFailure              = "The e2e tests are failing. The entire submit queue is blocked."
 	e2eRecover              = "The e2e tests started passing. The submit queue is unblocked."
 	merged                  = "MERGED!"
 	mergedSkippedRetest     = "MERGED! (skipped retest because of label)"
 	mergedBatch             = "MERGED! (batch)"
+	mergedTrain             = "MERGED! (merge train)"
 	mergedByHand            = "MERGED! (by hand outside of submit queue)"
+	emergencyMerged         = "MERGED! (emergency merge, skipped github e2e)"
+	mergeConflict           = "Github refused to merge the PR because it changed or conflicts. Will try again later."
 	ghE2EQueued             = "Queued to run github e2e tests a second time."
 	ghE2EWaitingStart       = "Requested and waiting for github e2e test to start running a second time."
 	ghE2ERunning            = "Running github e2e tests a second time."
 	ghE2EFailed             = "Second github e2e run failed."
+	retryingE2E             = "Second github e2e run failed, retrying."
+	retryingInfra           = "Second github e2e run hit an infrastructure failure, retrying."
+	ghE2EAborted            = "Github e2e run cancelled because the PR was closed or changed."
+	e2eTimeout              = "Github e2e run took too long and was abandoned."
 	unmergeableMilestone    = "Milestone is for a future release and cannot be merged"
 	headCommitChanged       = "This PR has changed since we ran the tests"
+	baseBranchMoved         = "The base branch changed since the PR was tested with others. Will test again."
+	cooling                 = "PR is cooling off in the queue before it can be merged."
+	noTrackerTicket         = "PR does not reference a ticket in the issue tracker."
+	trackerNotReady         = "The PR's tracker ticket is not ready for merge."
+	needsMoreApprovals      = "PR needs approval from more people since the last commit"
+	needsMoreApprovalsFmt   = needsMoreApprovals + ": %d of %d"
+	wip                     = "PR is a work in progress."
+	wrongBranch             = "PR is not for a branch the submit queue merges into."
+	outsideMergeWindow      = "Merges are paused outside of the merge window."
+	missingContext          = "Required Github status has never been reported"
+	missingContextFmt       = missingContext + ": %s"
+	missingRequiredLabel    = "PR is missing required labels"
+	missingRequiredLabelFmt = missingRequiredLabel + ": %s"
+	baseBranchRed           = "The base branch CI is failing. Merges are held until it is green."
+	blockedAuthor           = "PRs by this author are never merged automatically."
+	staleCI                 = "The e2e results are stale. Merges are held until the jobs report new builds."
+
+	// These are the reasons above for when the CLA, lgtm and do-not-merge
+	// labels have been changed from their defaults.
+	noCLAFmt     = "PR is missing CLA label; needs one of %s, " + cncfClaYesLabel + " or " + claHumanLabel
+	noCLAStatus  = "PR's CLA status %s is not success."
+	noLGTMFmt    = "PR does not have %s label."
+	lgtmEarlyFmt = "The PR was changed after the %s label was added."
+	noMergeFmt   = "Will not auto merge because %s is present"
+
+	// The head commit changed, perhaps leaving the commit date as it was
+	lgtmHeadChangedFmt = "The PR's head commit changed after the %s label was added."
 )
 
+// hasCLA checks the CLAContext status if there is one, or else the CLA labels.
+func (sq *SubmitQueue) hasCLA(obj *github.MungeObject) bool {
+	if sq.CLAContext != "" {
+		success, ok := sq.statusBackend().IsSuccess(obj, []string{sq.CLAContext})
+		return ok && success
+	}
+	return obj.HasLabel(sq.CLALabel) || obj.HasLabel(claHumanLabel) || obj.HasLabel(cncfClaYesLabel)
+}
+
+// noCLAReason is the noCLA reason for how the CLA is checked.
+func (sq *SubmitQueue) noCLAReason() string {
+	if sq.CLAContext != "" {
+		return fmt.Sprintf(noCLAStatus, sq.CLAContext)
+	}
+	return fmt.Sprintf(noCLAFmt, sq.CLALabel)
+}
+
 // validForMergeExt is the base logic about what PR can be automatically merged.
 // PRs must pass this logic to be placed on the queue and they must pass this
 // logic a second time to be retested/merged after they get to the top of
@@ -904,6 +1977,25 @@ const (
 //
 // If you update the logic PLEASE PLEASE PLEASE update serveMergeInfo() as well.
 func (sq *SubmitQueue) validForMergeExt(obj *github.MungeObject, checkStatus bool) bool {
+	if !sq.meetsMergeRules(obj, checkStatus) {
+		// Losing eligibility, even briefly, restarts the wait
+		sq.forgetEligibleTime(obj)
+		return false
+	}
+
+	// PR must have been eligible for at least MinQueueTime
+	if cooled, ok := sq.cooledOff(obj); !ok {
+		sq.setErrorStatus(obj, unknown)
+		return false
+	} else if !cooled {
+		sq.SetMergeStatus(obj, cooling)
+		return false
+	}
+	return true
+}
+
+// meetsMergeRules is every check of validForMergeExt but MinQueueTime.
+func (sq *SubmitQueue) meetsMergeRules(obj *github.MungeObject, checkStatus bool) bool {
 	// Can't merge an issue!
 	if !obj.IsPR() {
 		return false
@@ -911,14 +2003,40 @@ func (sq *SubmitQueue) validForMergeExt(obj *github.MungeObject, checkStatus boo
 
 	// Can't merge something already merged.
 	if m, ok := obj.IsMerged(); !ok {
-		glog.Errorf("%d: unknown err", *obj.Issue.Number)
-		sq.SetMergeStatus(obj, unknown)
+		obj.Log().Errorf("unknown err")
+		sq.setErrorStatus(obj, unknown)
 		return false
 	} else if m {
 		sq.SetMergeStatus(obj, mergedByHand)
 		return false
 	}
 
+	// Nothing else about the PR matters if its author is blocked
+	if sq.isBlockedAuthor(obj) {
+		sq.SetMergeStatus(obj, blockedAuthor)
+		return false
+	}
+
+	// Must be for a branch we are willing to merge into
+	if allowedBranches := sq.allowedBaseBranches(obj.Repo()); len(allowedBranches) > 0 {
+		branch, ok := obj.Branch()
+		if !ok {
+			sq.setErrorStatus(obj, unknown)
+			return false
+		}
+		allowed := false
+		for _, b := range allowedBranches {
+			if b == branch {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			sq.SetMergeStatus(obj, wrongBranch)
+			return false
+		}
+	}
+
 	if milestone := obj.Issue.Milestone; true {
 		title := ""
 		// Net set means the empty milestone, ""
@@ -934,14 +2052,14 @@ func (sq *SubmitQueue) validForMergeExt(obj *github.MungeObject, checkStatus boo
 	}
 
 	// Must pass CLA checks
-	if !obj.HasLabel(claYesLabel) && !obj.HasLabel(claHumanLabel) && !obj.HasLabel(cncfClaYesLabel) {
-		sq.SetMergeStatus(obj, noCLA)
+	if !sq.hasCLA(obj) {
+		sq.SetMergeStatus(obj, sq.noCLAReason())
 		return false
 	}
 
 	// Obviously must be mergeable
 	if mergeable, ok := obj.IsMergeable(); !ok {
-		sq.SetMergeStatus(obj, undeterminedMergability)
+		sq.setErrorStatus(obj, undeterminedMergability)
 		return false
 	} else if !mergeable {
 		sq.SetMergeStatus(obj, unmergeable)
@@ -951,31 +2069,78 @@ func (sq *SubmitQueue) validForMergeExt(obj *github.MungeObject, checkStatus boo
 	// Validate the status information for this PR
 	if checkStatus {
 		if len(sq.RequiredStatusContexts) > 0 {
-			if success, ok := obj.IsStatusSuccess(sq.RequiredStatusContexts); !ok || !success {
+			if success, ok := sq.contextsSucceeded(obj, sq.RequiredStatusContexts); !ok || !success {
+				if !ok && sq.transientFailure(obj) {
+					return false
+				}
 				sq.setContextFailedStatus(obj, sq.RequiredStatusContexts)
 				return false
 			}
 		}
-		if len(sq.RequiredRetestContexts) > 0 {
-			if success, ok := obj.IsStatusSuccess(sq.RequiredRetestContexts); !ok || !success {
-				sq.setContextFailedStatus(obj, sq.RequiredRetestContexts)
+		if retestContexts := sq.retestContexts(obj); len(retestContexts) > 0 && !sq.isEmergencyMerge(obj) {
+			if success, ok := sq.contextsSucceeded(obj, retestContexts); !ok || !success {
+				if !ok && sq.transientFailure(obj) {
+					return false
+				}
+				sq.setContextFailedStatus(obj, retestContexts)
 				return false
 			}
 		}
+		sq.forgetCIFailures(obj)
 	}
 
-	if !obj.HasLabel(lgtmLabel) {
-		sq.SetMergeStatus(obj, noLGTM)
-		return false
-	}
-
-	// PR cannot change since LGTM was added
-	if after, ok := obj.ModifiedAfterLabeled(lgtmLabel); !ok {
-		sq.SetMergeStatus(obj, unknown)
-		return false
-	} else if after {
-		sq.SetMergeStatus(obj, lgtmEarly)
-		return false
+	if sq.ReviewMode {
+		// PR must have been approved by reviewers since the last change
+		if approvers, ok := sq.reviewApprovers(obj); !ok {
+			sq.setErrorStatus(obj, unknown)
+			return false
+		} else if approvers.Len() < sq.ApprovingReviewsRequired {
+			sq.SetMergeStatus(obj, fmt.Sprintf(needsMoreApprovalsFmt, approvers.Len(), sq.ApprovingReviewsRequired))
+			return false
+		}
+	} else {
+		if !obj.HasLabel(sq.LGTMLabel) {
+			sq.SetMergeStatus(obj, fmt.Sprintf(noLGTMFmt, sq.LGTMLabel))
+			return false
+		}
+
+		// An author can't lgtm their own PR
+		if self, ok := sq.selfLGTM(obj); !ok {
+			sq.setErrorStatus(obj, unknown)
+			return false
+		} else if self {
+			sq.SetMergeStatus(obj, fmt.Sprintf(noLGTMFmt, sq.LGTMLabel))
+			return false
+		}
+
+		// PR cannot change since LGTM was added
+		if after, ok := obj.ModifiedAfterLabeled(sq.LGTMLabel); !ok {
+			sq.setErrorStatus(obj, unknown)
+			return false
+		} else if after {
+			sq.SetMergeStatus(obj, fmt.Sprintf(lgtmEarlyFmt, sq.LGTMLabel))
+			return false
+		}
+
+		// Nor be force-pushed to since, whatever the commit dates say
+		if changed, ok := sq.lgtmHeadChanged(obj); !ok {
+			sq.setErrorStatus(obj, unknown)
+			return false
+		} else if changed {
+			sq.SetMergeStatus(obj, fmt.Sprintf(lgtmHeadChangedFmt, sq.LGTMLabel))
+			return false
+		}
+
+		// And have enough different people's "/lgtm" since the last commit
+		if sq.ApprovingReviewsRequired > 1 {
+			if approvers, ok := sq.commentApprovers(obj); !ok {
+				sq.setErrorStatus(obj, unknown)
+				return false
+			} else if approvers.Len() < sq.ApprovingReviewsRequired {
+				sq.SetMergeStatus(obj, fmt.Sprintf(needsMoreApprovalsFmt, approvers.Len(), sq.ApprovingReviewsRequired))
+				return false
+			}
+		}
 	}
 
 	if sq.GateApproved {
@@ -985,7 +2150,7 @@ func (sq *SubmitQueue) validForMergeExt(obj *github.MungeObject, checkStatus boo
 		}
 		// PR cannot change since approvedLabel was added
 		if after, ok := obj.ModifiedAfterLabeled(approvedLabel); !ok {
-			sq.SetMergeStatus(obj, unknown)
+			sq.setErrorStatus(obj, unknown)
 			return false
 		} else if after {
 			sq.SetMergeStatus(obj, approvedEarly)
@@ -993,49 +2158,354 @@ func (sq *SubmitQueue) validForMergeExt(obj *github.MungeObject, checkStatus boo
 		}
 	}
 
+	if missing := sq.missingRequiredLabels(obj); len(missing) > 0 {
+		sq.SetMergeStatus(obj, fmt.Sprintf(missingRequiredLabelFmt, strings.Join(missing, ", ")))
+		return false
+	}
+
 	// PR cannot have the label which prevents merging.
-	if obj.HasLabel(doNotMergeLabel) {
-		sq.SetMergeStatus(obj, noMerge)
+	if obj.HasLabel(sq.DoNotMergeLabel) {
+		sq.SetMergeStatus(obj, fmt.Sprintf(noMergeFmt, sq.DoNotMergeLabel))
+		return false
+	}
+	if reason := sq.blockingLabelReason(obj); reason != "" {
+		sq.SetMergeStatus(obj, reason)
+		return false
+	}
+
+	// PR cannot be a work in progress
+	if sq.hasWIPTitle(obj) {
+		sq.SetMergeStatus(obj, wip)
+		return false
+	}
+	if draft, ok := obj.IsDraft(); !ok {
+		sq.setErrorStatus(obj, unknown)
+		return false
+	} else if draft {
+		sq.SetMergeStatus(obj, wip)
+		return false
+	}
+
+	// The linked tracker ticket must be ready
+	if sq.tracker != nil {
+		if reason := sq.trackerReason(obj); reason != "" {
+			sq.SetMergeStatus(obj, reason)
+			return false
+		}
+	}
+
+	// PRs it depends on must merge first
+	if sq.hasDependencies(obj) {
+		sq.SetMergeStatus(obj, blockedByDependency)
+		return false
+	}
+
+	// Nothing merges outside the merge window, but a PR which was already
+	// being tested when the window closed may finish.
+	if !sq.inMergeWindow() && !sq.isRunning(obj) {
+		sq.SetMergeStatus(obj, outsideMergeWindow)
+		return false
+	}
+
+	// Merging onto a broken base branch only makes it harder to fix
+	if red, ok := sq.baseBranchIsRed(obj); !ok {
+		sq.setErrorStatus(obj, unknown)
+		return false
+	} else if red {
+		sq.SetMergeStatus(obj, baseBranchRed)
 		return false
 	}
 
 	return true
 }
 
+// allowedBaseBranches returns the branches the PRs of repo may merge into.
+func (sq *SubmitQueue) allowedBaseBranches(repo string) []string {
+	if len(sq.AllowedBaseBranches) > 0 {
+		return sq.AllowedBaseBranches
+	}
+	if branch, ok := sq.defaultBranches[repo]; ok {
+		return []string{branch}
+	}
+	return nil
+}
+
+// isRunning returns true if obj is the PR being retested right now.
+func (sq *SubmitQueue) isRunning(obj *github.MungeObject) bool {
+	sq.Lock()
+	defer sq.Unlock()
+	return sq.runningLocked(obj)
+}
+
+// runningLocked is isRunning for callers which hold sq.Lock().
+func (sq *SubmitQueue) runningLocked(obj *github.MungeObject) bool {
+	return sq.githubE2ERunning != nil && sq.prKey(sq.githubE2ERunning) == sq.prKey(obj)
+}
+
+// prKey identifies the PR in the queue's maps. PRs in the main repo are
+// keyed by number alone, so the web UI's links keep working; those in
+// --extra-repos are keyed by "org/project#number".
+func (sq *SubmitQueue) prKey(obj *github.MungeObject) string {
+	if sq.githubConfig == nil {
+		return strconv.Itoa(*obj.Issue.Number)
+	}
+	return sq.keyFor(obj.Repo(), *obj.Issue.Number)
+}
+
+// keyFor is prKey for PR num in the "org/project" repo.
+func (sq *SubmitQueue) keyFor(repo string, num int) string {
+	if sq.githubConfig == nil || repo == sq.githubConfig.FullName() {
+		return strconv.Itoa(num)
+	}
+	return repo + "#" + strconv.Itoa(num)
+}
+
+// hasWIPTitle returns true if the PR title starts with one of sq.WIPPrefixes,
+// ignoring case and any leading brackets, so "[WIP] foo" counts.
+func (sq *SubmitQueue) hasWIPTitle(obj *github.MungeObject) bool {
+	if obj.Issue.Title == nil {
+		return false
+	}
+	title := strings.ToUpper(strings.TrimLeft(*obj.Issue.Title, "[( "))
+	for _, prefix := range sq.WIPPrefixes {
+		if strings.HasPrefix(title, strings.ToUpper(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasApprovingReviews returns true if at least sq.ApprovingReviewsRequired
+// reviewers approved the PR no earlier than its last modification.
+func (sq *SubmitQueue) hasApprovingReviews(obj *github.MungeObject) (bool, bool) {
+	approvers, ok := sq.reviewApprovers(obj)
+	if !ok {
+		return false, false
+	}
+	return approvers.Len() >= sq.ApprovingReviewsRequired, true
+}
+
+// reviewApprovers returns the reviewers who approved the PR no earlier than
+// its last modification. Only each reviewer's most recent approval or change
+// request counts, so requesting changes after approving withdraws the
+// approval.
+func (sq *SubmitQueue) reviewApprovers(obj *github.MungeObject) (sets.String, bool) {
+	lastModified, ok := obj.LastModifiedTime()
+	if !ok || lastModified == nil {
+		return nil, false
+	}
+	reviews, ok := obj.ListReviews()
+	if !ok {
+		return nil, false
+	}
+
+	latest := map[string]*github.PullRequestReview{}
+	for _, review := range reviews {
+		if review.User == nil || review.User.Login == nil || review.State == nil || review.SubmittedAt == nil {
+			continue
+		}
+		if *review.State != "APPROVED" && *review.State != "CHANGES_REQUESTED" {
+			continue
+		}
+		login := *review.User.Login
+		if prev, found := latest[login]; found && prev.SubmittedAt.After(*review.SubmittedAt) {
+			continue
+		}
+		latest[login] = review
+	}
+
+	approvers := sets.NewString()
+	for login, review := range latest {
+		if *review.State == "APPROVED" && !lastModified.After(*review.SubmittedAt) {
+			approvers.Insert(strings.ToLower(login))
+		}
+	}
+	return approvers, true
+}
+
+// trackerReason returns the reason the PR is blocked by the external tracker,
+// or "" if the ticket it references is in one of sq.TrackerReadyStates.
+func (sq *SubmitQueue) trackerReason(obj *github.MungeObject) string {
+	title, body := "", ""
+	if obj.Issue.Title != nil {
+		title = *obj.Issue.Title
+	}
+	if obj.Issue.Body != nil {
+		body = *obj.Issue.Body
+	}
+	id := tracker.ParseTicketID(title, body)
+	if id == "" {
+		return noTrackerTicket
+	}
+	state, err := sq.tracker.TicketState(id)
+	if err != nil {
+		obj.Log().Errorf("unable to get state of ticket %s: %v", id, err)
+		return trackerNotReady
+	}
+	for _, ready := range sq.TrackerReadyStates {
+		if strings.EqualFold(state, ready) {
+			return ""
+		}
+	}
+	obj.Log().V(4).Infof("ticket %s is in state %q", id, state)
+	return trackerNotReady
+}
+
+// cooledOff returns true if the PR has been eligible to merge for at least
+// sq.MinQueueTime. The wait starts over if the PR's LastModifiedTime changes
+// or it stops being eligible, see forgetEligibleTime. The second return
+// value is false if the modification time is unknown.
+func (sq *SubmitQueue) cooledOff(obj *github.MungeObject) (bool, bool) {
+	if sq.MinQueueTime == 0 {
+		return true, true
+	}
+	lastModified, ok := obj.LastModifiedTime()
+	if !ok || lastModified == nil {
+		return false, false
+	}
+
+	sq.Lock()
+	defer sq.Unlock()
+	if sq.eligibleTimes == nil {
+		sq.eligibleTimes = map[string]eligibleRecord{}
+	}
+	now := sq.clock.Now()
+	key := sq.prKey(obj)
+	record, found := sq.eligibleTimes[key]
+	if !found || !record.lastModified.Equal(*lastModified) {
+		record = eligibleRecord{since: now, lastModified: *lastModified}
+	}
+	record.loop = atomic.LoadInt32(&sq.loopStarts)
+	sq.eligibleTimes[key] = record
+	return now.Sub(record.since) >= sq.MinQueueTime, true
+}
+
+// forgetEligibleTime drops the time obj became eligible to merge, so its
+// MinQueueTime starts over the next time it is.
+func (sq *SubmitQueue) forgetEligibleTime(obj *github.MungeObject) {
+	sq.Lock()
+	defer sq.Unlock()
+	delete(sq.eligibleTimes, sq.prKey(obj))
+}
+
+// pruneEligibleTimesLocked drops the eligible times of PRs which were not
+// checked during the last loop, like those which were closed. sq.Lock()
+// must be held.
+func (sq *SubmitQueue) pruneEligibleTimesLocked() {
+	loop := atomic.LoadInt32(&sq.loopStarts)
+	for key, record := range sq.eligibleTimes {
+		if record.loop < loop {
+			delete(sq.eligibleTimes, key)
+		}
+	}
+}
+
 func (sq *SubmitQueue) validForMerge(obj *github.MungeObject) bool {
 	return sq.validForMergeExt(obj, true)
 }
 
 // Munge is the workhorse the will actually make updates to the PR
 func (sq *SubmitQueue) Munge(obj *github.MungeObject) {
+	if obj.IsPR() {
+		sq.handleCommands(obj)
+		sq.loadDependencies(obj)
+	}
+	// Whether obj ends up queued or not
+	defer sq.updateETALabel(obj)
+
+	if sq.abortSupersededE2E(obj) || sq.abortTimedOutE2E(obj) {
+		return
+	}
+	sq.reportE2EProgress(obj)
+
 	if !sq.validForMerge(obj) {
+		sq.expireLGTM(obj)
+		sq.maybeStartSpeculativeE2E(obj)
+		return
+	}
+
+	if sq.heldAfterE2ETimeout(obj) || sq.heldAtRetestLimit(obj) {
 		return
 	}
 
+	if sq.maybeEmergencyMerge(obj) {
+		return
+	}
+
+	sq.enqueue(obj)
+	sq.reportPaused(obj)
+}
+
+// enqueue adds obj, which is valid for merge, to the github e2e queue or
+// refreshes the copy already there.
+func (sq *SubmitQueue) enqueue(obj *github.MungeObject) {
 	added := false
+	var bumped *github.MungeObject
+	key := sq.prKey(obj)
 	sq.Lock()
-	if _, ok := sq.githubE2EQueue[*obj.Issue.Number]; !ok {
+	if sq.shuttingDown {
+		sq.Unlock()
+		return
+	}
+	if _, ok := sq.githubE2EQueue[key]; !ok {
+		var admitted bool
+		if admitted, bumped = sq.admitToQueue(obj); !admitted {
+			sq.Unlock()
+			sq.SetMergeStatus(obj, queueFull)
+			return
+		}
 		atomic.AddInt32(&sq.prsAdded, 1)
 		added = true
+		sq.recordQueued(obj)
 	}
 	// Add this most-recent object in place of the existing object. It will
 	// have more up2date information. Even though we explicitly refresh the
 	// PR information before do anything with it, this allow things like the
 	// queue order to change dynamically as labels are added/removed.
-	sq.githubE2EQueue[*obj.Issue.Number] = obj
+	sq.githubE2EQueue[key] = obj
 	sq.Unlock()
+	if bumped != nil {
+		sq.SetMergeStatus(bumped, queueFull)
+	}
 	if added {
 		sq.SetMergeStatus(obj, ghE2EQueued)
+		if sq.QueueComment {
+			sq.writeQueueComment(obj)
+		}
+	}
+}
+
+// writeQueueComment tells the PR author where their PR is in the queue and
+// roughly when it should merge.
+func (sq *SubmitQueue) writeQueueComment(obj *github.MungeObject) {
+	sq.Lock()
+	index := -1
+	key := sq.prKey(obj)
+	for i, k := range sq.orderedE2EQueue() {
+		if k == key {
+			index = i
+			break
+		}
+	}
+	rate := sq.calcMergeRateWithTail()
+	sq.Unlock()
+	if index < 0 {
+		return
 	}
 
-	return
+	eta := formatTimeToMerge(sq.estimateTimeToMerge(index, rate))
+	body := sq.renderComment(obj, queuedTemplate, commentData{Position: index + 1, ETA: eta})
+	if err := obj.WriteComment(body); err != nil {
+		obj.Log().Errorf("unable to write queue comment: %v", err)
+	}
 }
 
 func (sq *SubmitQueue) deleteQueueItem(obj *github.MungeObject) {
 	if sq.onQueue(obj) {
 		atomic.AddInt32(&sq.prsRemoved, 1)
 	}
-	delete(sq.githubE2EQueue, *obj.Issue.Number)
+	delete(sq.githubE2EQueue, sq.prKey(obj))
+	delete(sq.queuedSince, sq.prKey(obj))
 }
 
 // If the PR was put in the github e2e queue previously, but now we don't
@@ -1044,21 +2514,26 @@ func (sq *SubmitQueue) deleteQueueItem(obj *github.MungeObject) {
 func (sq *SubmitQueue) cleanupOldE2E(obj *github.MungeObject, reason string) {
 	switch {
 	case reason == e2eFailure:
+	case reason == staleCI:
 	case reason == ghE2EQueued:
 	case reason == ghE2EWaitingStart:
 	case reason == ghE2ERunning:
+	case reason == retryingE2E:
+	case reason == retryingInfra:
+	case reason == queuePaused:
+	case reason == baseBranchMoved:
 		// Do nothing
 	case strings.HasPrefix(reason, ciFailure):
 		// ciFailure is intersting. If the PR is being actively retested and then the
 		// time based loop finds the same PR it will try to set ciFailure. We should in fact
 		// not ever call this function in this case, but if we do call here, log it.
-		if sq.githubE2ERunning != nil && *sq.githubE2ERunning.Issue.Number == *obj.Issue.Number {
-			glog.Errorf("Trying to clean up %d due to ciFailure while it is being tested")
+		if sq.runningLocked(obj) {
+			obj.Log().Errorf("Trying to clean up due to ciFailure while it is being tested")
 			return
 		}
 		fallthrough
 	default:
-		if sq.githubE2ERunning != nil && *sq.githubE2ERunning.Issue.Number == *obj.Issue.Number {
+		if sq.runningLocked(obj) {
 			sq.githubE2ERunning = nil
 		}
 		sq.deleteQueueItem(obj)
@@ -1083,6 +2558,7 @@ func priority(obj *github.MungeObject) int {
 type queueSorter struct {
 	queue          []*github.MungeObject
 	labelTimeCache *mungerutil.LabelTimeCache
+	priority       func(*github.MungeObject) int
 }
 
 func (s queueSorter) Len() int      { return len(s.queue) }
@@ -1093,8 +2569,8 @@ func (s queueSorter) Less(i, j int) bool {
 	a := s.queue[i]
 	b := s.queue[j]
 
-	aPrio := priority(a)
-	bPrio := priority(b)
+	aPrio := s.priority(a)
+	bPrio := s.priority(b)
 
 	if aPrio < bPrio {
 		return true
@@ -1130,30 +2606,42 @@ func (s queueSorter) Less(i, j int) bool {
 // onQueue just tells if a PR is already on the queue.
 // sq.Lock() must be held
 func (sq *SubmitQueue) onQueue(obj *github.MungeObject) bool {
-	for _, queueObj := range sq.githubE2EQueue {
-		if *queueObj.Issue.Number == *obj.Issue.Number {
-			return true
-		}
-
-	}
-	return false
+	_, ok := sq.githubE2EQueue[sq.prKey(obj)]
+	return ok
 }
 
 // sq.Lock() better held!!!
-func (sq *SubmitQueue) orderedE2EQueue() []int {
+func (sq *SubmitQueue) orderedE2EQueue() []string {
 	prs := []*github.MungeObject{}
 	for _, obj := range sq.githubE2EQueue {
 		prs = append(prs, obj)
 	}
-	sort.Sort(queueSorter{prs, sq.lgtmTimeCache})
+	sort.Sort(queueSorter{prs, sq.lgtmTimeCache, sq.effectivePriority})
 
-	var ordered []int
+	prs = sq.applyFairness(prs)
+	prs = sq.timedOutLast(prs)
+
+	var ordered []string
 	for _, obj := range prs {
-		ordered = append(ordered, *obj.Issue.Number)
+		ordered = append(ordered, sq.prKey(obj))
 	}
 	return ordered
 }
 
+// githubE2EPollInterval returns githubE2EPollTime with GithubE2EPollJitter
+// applied.
+func (sq *SubmitQueue) githubE2EPollInterval() time.Duration {
+	jitter := sq.GithubE2EPollJitter
+	if jitter <= 0 {
+		return sq.githubE2EPollTime
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+	offset := (2*rand.Float64() - 1) * jitter * float64(sq.githubE2EPollTime)
+	return sq.githubE2EPollTime + time.Duration(offset)
+}
+
 // handleGithubE2EAndMerge waits for PRs that are ready to re-run the github
 // e2e tests, runs the test, and then merges if everything was successful.
 func (sq *SubmitQueue) handleGithubE2EAndMerge() {
@@ -1162,8 +2650,12 @@ func (sq *SubmitQueue) handleGithubE2EAndMerge() {
 		l := len(sq.githubE2EQueue)
 		sq.Unlock()
 		// Wait until something is ready to be processed
-		if l == 0 || !sq.e2eStable(false) {
-			time.Sleep(sq.githubE2EPollTime)
+		if l == 0 || sq.isShuttingDown() || sq.isPaused() || !sq.inMergeWindow() || !sq.e2eStable(false) {
+			time.Sleep(sq.githubE2EPollInterval())
+			continue
+		}
+
+		if sq.interruptedObj == nil && sq.doMergeTrain() {
 			continue
 		}
 
@@ -1184,15 +2676,66 @@ func (sq *SubmitQueue) handleGithubE2EAndMerge() {
 }
 
 func (sq *SubmitQueue) mergePullRequest(obj *github.MungeObject, msg, extra string) bool {
-	ok := obj.MergePR("submit-queue" + extra)
-	if !ok {
-		return ok
+	if sq.isPaused() {
+		obj.Log().Infof("not merging because the submit queue is paused")
+		sq.SetMergeStatus(obj, queuePaused)
+		return false
+	}
+	if !sq.startMerge() {
+		obj.Log().Infof("not merging because the submit queue is shutting down")
+		return false
+	}
+	defer sq.merging.Done()
+	method := sq.mergeMethod(obj)
+	title, message := "", ""
+	if method == "squash" {
+		title, message = sq.squashCommitMessage(obj)
+	}
+	if err := obj.TryMergePRWithMessage("submit-queue"+extra, method, title, message); err != nil {
+		if err == github.ErrMergeConflict {
+			sq.SetMergeStatus(obj, mergeConflict)
+		}
+		return false
 	}
 	sq.SetMergeStatus(obj, msg)
 	sq.updateMergeRate()
+	sq.setETALabel(obj, "")
+	sq.deployMerge(obj)
+
+	sq.Lock()
+	key := sq.prKey(obj)
+	delete(sq.eligibleTimes, key)
+	delete(sq.ejectionReasons, key)
+	delete(sq.e2eRetries, key)
+	delete(sq.e2eTimedOut, key)
+	delete(sq.lgtmExpired, key)
+	delete(sq.ciFailures, key)
+	delete(sq.speculativeRuns, key)
+	delete(sq.retestCounts, key)
+	sq.recordMergePriority(sq.effectivePriority(obj))
+	if sq.repoMerges == nil {
+		sq.repoMerges = map[string]int{}
+	}
+	sq.repoMerges[obj.Repo()]++
+	sq.recordSlackMerge(obj)
+	sq.Unlock()
 	return true
 }
 
+// mergeMethod returns how the PR should be merged. A merge-method label
+// overrides sq.MergeMethod; if there are several, squash wins over rebase.
+func (sq *SubmitQueue) mergeMethod(obj *github.MungeObject) string {
+	switch {
+	case obj.HasLabel(mergeMethodSquashLabel):
+		return "squash"
+	case obj.HasLabel(mergeMethodRebaseLabel):
+		return "rebase"
+	case obj.HasLabel(mergeMethodMergeLabel):
+		return "merge"
+	}
+	return sq.MergeMethod
+}
+
 func (sq *SubmitQueue) selectPullRequest() *github.MungeObject {
 	if sq.interruptedObj != nil {
 		return sq.interruptedObj.obj
@@ -1242,16 +2785,19 @@ func (sq *SubmitQueue) doGithubE2EAndMerge(obj *github.MungeObject) bool {
 
 	ok := obj.Refresh()
 	if !ok {
-		glog.Errorf("%d: unknown err", *obj.Issue.Number)
+		if sq.transientFailure(obj) {
+			return false
+		}
+		obj.Log().Errorf("unknown err")
 		sq.SetMergeStatus(obj, unknown)
 		return true
 	}
 
 	if !sq.validForMerge(obj) {
-		return true
+		return !sq.transientFailure(obj)
 	}
 
-	if obj.HasLabel(retestNotRequiredLabel) || obj.HasLabel(retestNotRequiredDocsOnlyLabel) {
+	if obj.HasLabel(retestNotRequiredLabel) || obj.HasLabel(retestNotRequiredDocsOnlyLabel) || sq.revertSkipsRetest(obj) {
 		atomic.AddInt32(&sq.instantMerges, 1)
 		sq.mergePullRequest(obj, mergedSkippedRetest, "")
 		return true
@@ -1259,7 +2805,10 @@ func (sq *SubmitQueue) doGithubE2EAndMerge(obj *github.MungeObject) bool {
 
 	sha, _, ok := obj.GetHeadAndBase()
 	if !ok {
-		glog.Errorf("%d: Unable to get SHA", *obj.Issue.Number)
+		if sq.transientFailure(obj) {
+			return false
+		}
+		obj.Log().Errorf("Unable to get SHA")
 		sq.SetMergeStatus(obj, unknown)
 		return true
 	}
@@ -1269,7 +2818,10 @@ func (sq *SubmitQueue) doGithubE2EAndMerge(obj *github.MungeObject) bool {
 			// Make sure we don't have higher priority first.
 			return false
 		}
-		glog.Infof("Skipping retest since head and base sha match previous attempt!")
+		obj.Log().Infof("Skipping retest since head and base sha match previous attempt!")
+		atomic.AddInt32(&sq.retestsAvoided, 1)
+	} else if sq.speculativeResultReady(obj) {
+		obj.Log().Infof("Skipping retest since a speculative run passed at the same head and base sha")
 		atomic.AddInt32(&sq.retestsAvoided, 1)
 	} else {
 		if sq.retestPR(obj) {
@@ -1278,6 +2830,9 @@ func (sq *SubmitQueue) doGithubE2EAndMerge(obj *github.MungeObject) bool {
 
 		ok := obj.Refresh()
 		if !ok {
+			if sq.transientFailure(obj) {
+				return false
+			}
 			sq.SetMergeStatus(obj, unknown)
 			return true
 		}
@@ -1288,16 +2843,16 @@ func (sq *SubmitQueue) doGithubE2EAndMerge(obj *github.MungeObject) bool {
 
 	// We shouldn't merge if it's not valid anymore
 	if !sq.validForMerge(obj) {
-		glog.Errorf("%d: Not mergeable anymore. Do not merge.", *obj.Issue.Number)
-		return true
+		obj.Log().Errorf("Not mergeable anymore. Do not merge.")
+		return !sq.transientFailure(obj)
 	}
 
 	if newSha, _, ok := obj.GetHeadAndBase(); !ok {
-		glog.Errorf("%d: Unable to get SHA", *obj.Issue.Number)
+		obj.Log().Errorf("Unable to get SHA")
 		sq.SetMergeStatus(obj, unknown)
 		return true
 	} else if newSha != sha {
-		glog.Errorf("%d: Changed while running the test. Do not merge.", *obj.Issue.Number)
+		obj.Log().Errorf("Changed while running the test. Do not merge.")
 		sq.SetMergeStatus(obj, headCommitChanged)
 		return false
 	}
@@ -1306,51 +2861,287 @@ func (sq *SubmitQueue) doGithubE2EAndMerge(obj *github.MungeObject) bool {
 		if sq.validForMerge(obj) {
 			sq.interruptedObj = newInterruptedObject(obj)
 		}
-		sq.SetMergeStatus(obj, e2eFailure)
+		reason := sq.e2eFailureReason()
+		sq.SetMergeStatus(obj, reason)
+		if sq.interruptedObj == nil {
+			// It won't be retried, so it's being dropped from the queue
+			sq.writeEjectionComment(obj, reason)
+		}
 		return true
 	}
 
+	if sq.isPaused() {
+		// Keep the result of the run, so that after the resume it
+		// merges without another one if nothing has changed.
+		sq.interruptedObj = newInterruptedObject(obj)
+		sq.SetMergeStatus(obj, queuePaused)
+		return false
+	}
+
 	sq.mergePullRequest(obj, merged, "")
 	return true
 }
 
 // Returns true if merge status changes, and false otherwise.
 func (sq *SubmitQueue) retestPR(obj *github.MungeObject) bool {
-	if len(sq.RequiredRetestContexts) == 0 {
+	contexts := sq.withoutOverrides(obj, sq.retestContexts(obj))
+	if len(contexts) == 0 {
 		return false
 	}
 
-	if err := obj.WriteComment(retestBody); err != nil {
-		glog.Errorf("%d: unknown err: %v", *obj.Issue.Number, err)
-		sq.SetMergeStatus(obj, unknown)
-		return true
+	abort := sq.startE2ERun(obj)
+	defer sq.finishE2ERun(abort)
+
+	body := sq.retestBody(obj)
+	infraRetries := 0
+	for {
+		if sq.atRetestLimit(obj) {
+			sq.setE2ERunStatus(obj, abort, retestLimitReached)
+			return true
+		}
+		if err := obj.WriteRepeatableComment(body); err != nil {
+			obj.Log().Errorf("unknown err: %v", err)
+			sq.setE2ERunStatus(obj, abort, unknown)
+			return true
+		}
+		sq.recordRetest(obj)
+		sq.Lock()
+		sq.e2eStarted = sq.clock.Now()
+		sq.Unlock()
+
+		// Wait for the retest to start
+		if !sq.setE2ERunStatus(obj, abort, ghE2EWaitingStart) {
+			return true
+		}
+		atomic.AddInt32(&sq.prsTested, 1)
+		done := sq.waitForPending(obj, contexts, abort)
+		if aborted(abort) {
+			return true
+		}
+		if !done {
+			sq.setE2ERunStatus(obj, abort, fmt.Sprintf("Timed out waiting for PR %d to start testing", obj.Number()))
+			return true
+		}
+
+		// Wait for the status to go back to something other than pending
+		if !sq.setE2ERunStatus(obj, abort, ghE2ERunning) {
+			return true
+		}
+		done = sq.waitForNotPending(obj, contexts, abort)
+		if aborted(abort) {
+			return true
+		}
+		if !done {
+			sq.setE2ERunStatus(obj, abort, fmt.Sprintf("Timed out waiting for PR %d to finish testing", obj.Number()))
+			return true
+		}
+
+		// Check if the thing we care about is success
+		if success, ok := sq.statusBackend().IsSuccess(obj, contexts); ok && success {
+			// no action taken.
+			return false
+		}
+		if sq.IgnoreBaseBranchFailures && sq.onlyBaseBranchFailures(obj, contexts) {
+			obj.Log().Infof("github e2e only failed tests which are failing on the base branch")
+			return false
+		}
+		if sq.onlySkippedTestFailures(obj, contexts) {
+			obj.Log().Infof("github e2e only failed tests which are being skipped")
+			return false
+		}
+		if infraRetries < maxInfraRetries && sq.infraFailure(obj, contexts) {
+			infraRetries++
+			obj.Log().Infof("github e2e hit an infrastructure failure, retrying (%d of %d)", infraRetries, maxInfraRetries)
+			sq.setE2ERunStatus(obj, abort, retryingInfra)
+			body = fmt.Sprintf("%s (infrastructure failure, retry %d of %d)", sq.retestBody(obj), infraRetries, maxInfraRetries)
+			continue
+		}
+		remaining, retry := sq.useE2ERetry(obj)
+		if !retry {
+			sq.setE2ERunStatus(obj, abort, ghE2EFailed)
+			return true
+		}
+		obj.Log().Infof("github e2e failed, retrying (%d retries left)", remaining)
+		sq.setE2ERunStatus(obj, abort, retryingE2E)
+		// Say which retry this is, so they can be told apart on the PR
+		body = fmt.Sprintf("%s (retry %d of %d)", sq.retestBody(obj), sq.E2ERetries-remaining, sq.E2ERetries)
 	}
+}
 
-	// Wait for the retest to start
-	sq.SetMergeStatus(obj, ghE2EWaitingStart)
-	atomic.AddInt32(&sq.prsTested, 1)
-	done := obj.WaitForPending(sq.RequiredRetestContexts)
-	if !done {
-		sq.SetMergeStatus(obj, fmt.Sprintf("Timed out waiting for PR %d to start testing", obj.Number()))
-		return true
+// retestBody is the comment which asks the E2EBotName to re-run the github
+// e2e tests for obj.
+func (sq *SubmitQueue) retestBody(obj *github.MungeObject) string {
+	bot, phrase := sq.e2eTrigger()
+	return sq.renderComment(obj, retestTemplate, commentData{Bot: bot, Phrase: phrase})
+}
+
+// e2eTrigger is who is mentioned, and what they are told, to re-run the
+// github e2e tests.
+func (sq *SubmitQueue) e2eTrigger() (bot, phrase string) {
+	bot, phrase = sq.E2EBotName, sq.E2ETriggerPhrase
+	if bot == "" {
+		bot = jenkinsBotName
+	}
+	if phrase == "" {
+		phrase = e2eTriggerPhrase
 	}
+	return bot, phrase
+}
 
-	// Wait for the status to go back to something other than pending
-	sq.SetMergeStatus(obj, ghE2ERunning)
-	done = obj.WaitForNotPending(sq.RequiredRetestContexts)
-	if !done {
-		sq.SetMergeStatus(obj, fmt.Sprintf("Timed out waiting for PR %d to finish testing", obj.Number()))
-		return true
+// startE2ERun remembers the commit whose github e2e run is starting and
+// returns a channel which is closed if the run should be abandoned.
+func (sq *SubmitQueue) startE2ERun(obj *github.MungeObject) chan struct{} {
+	sha, _, _ := obj.GetHeadAndBase()
+	abort := make(chan struct{})
+	sq.Lock()
+	defer sq.Unlock()
+	sq.runningSHA = sha
+	sq.abortE2E = abort
+	sq.e2eStarted = sq.clock.Now()
+	sq.e2eProgress = ""
+	return abort
+}
+
+func (sq *SubmitQueue) finishE2ERun(abort chan struct{}) {
+	sq.Lock()
+	defer sq.Unlock()
+	if sq.abortE2E == abort {
+		sq.abortE2E = nil
+		sq.runningSHA = ""
 	}
+}
+
+// setE2ERunStatus sets obj's status on behalf of the github e2e run which
+// abort belongs to, unless that run has been aborted. It returns false if it
+// was. The aborts set their own status under e2eStatusLock too, so theirs is
+// never overwritten by the run they stopped.
+func (sq *SubmitQueue) setE2ERunStatus(obj *github.MungeObject, abort chan struct{}, reason string) bool {
+	sq.e2eStatusLock.Lock()
+	defer sq.e2eStatusLock.Unlock()
+	if aborted(abort) {
+		return false
+	}
+	sq.SetMergeStatus(obj, reason)
+	return true
+}
 
-	// Check if the thing we care about is success
-	if success, ok := obj.IsStatusSuccess(sq.RequiredRetestContexts); !success || !ok {
-		sq.SetMergeStatus(obj, ghE2EFailed)
+func aborted(abort chan struct{}) bool {
+	select {
+	case <-abort:
 		return true
+	default:
+		return false
 	}
+}
 
-	// no action taken.
-	return false
+// abortSupersededE2E cancels the github e2e run for obj if obj is being
+// tested and has been closed or pushed to since the run began, rather than
+// waiting for a result which can't be used. It returns true if the run was
+// cancelled.
+func (sq *SubmitQueue) abortSupersededE2E(obj *github.MungeObject) bool {
+	closed := obj.Issue.State != nil && *obj.Issue.State == "closed"
+	sha, _, ok := obj.GetHeadAndBase()
+
+	sq.Lock()
+	if sq.abortE2E == nil || !sq.runningLocked(obj) {
+		sq.Unlock()
+		return false
+	}
+	if !closed && (!ok || sha == sq.runningSHA) {
+		sq.Unlock()
+		return false
+	}
+	obj.Log().Infof("cancelling github e2e run at %s, closed=%v head=%s", sq.runningSHA, closed, sha)
+	abortedSHA := sq.cancelE2ERunLocked(obj)
+	sq.Unlock()
+
+	sq.e2e.AbortPR(*obj.Issue.Number, abortedSHA)
+	sq.e2eStatusLock.Lock()
+	defer sq.e2eStatusLock.Unlock()
+	sq.SetMergeStatus(obj, ghE2EAborted)
+	return true
+}
+
+// cancelE2ERunLocked stops the running github e2e run and takes obj off the
+// queue. It returns the SHA which was being tested. sq.Lock() must be held.
+func (sq *SubmitQueue) cancelE2ERunLocked(obj *github.MungeObject) string {
+	close(sq.abortE2E)
+	sq.abortE2E = nil
+	sq.deleteQueueItem(obj)
+	return sq.runningSHA
+}
+
+// infraFailure returns true if every failed context's build, found from the
+// job and build number at the end of its target URL, was classified as an
+// infrastructure failure.
+func (sq *SubmitQueue) infraFailure(obj *github.MungeObject, contexts []string) bool {
+	failed := 0
+	for _, context := range contexts {
+		if success, ok := sq.statusBackend().IsSuccess(obj, []string{context}); ok && success {
+			continue
+		}
+		failed++
+		url, ok := sq.statusBackend().TargetURL(obj, context)
+		if !ok || url == "" {
+			return false
+		}
+		job, number, ok := jobAndBuild(url)
+		if !ok {
+			obj.Log().V(4).Infof("can't find the build of %s in %q", context, url)
+			return false
+		}
+		category, err := sq.e2e.ClassifyBuild(job, number)
+		if err != nil {
+			obj.Log().With("job", job).With("build", number).Errorf("unable to classify the build: %v", err)
+			return false
+		}
+		if category != e2e.InfraFailure {
+			return false
+		}
+	}
+	return failed > 0
+}
+
+// jobAndBuild returns the last two elements of a build's URL, like
+// https://k8s-gubernator.appspot.com/build/bucket/pr-logs/pull/1/<job>/<number>/
+func jobAndBuild(url string) (string, int, bool) {
+	parts := strings.Split(strings.TrimRight(url, "/"), "/")
+	if len(parts) < 2 {
+		return "", 0, false
+	}
+	number, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil || parts[len(parts)-2] == "" {
+		return "", 0, false
+	}
+	return parts[len(parts)-2], number, true
+}
+
+// useE2ERetry uses up one of the PR's github e2e retries, returning how many
+// are left and false if there were none. A new head commit gets a fresh set
+// of sq.E2ERetries.
+func (sq *SubmitQueue) useE2ERetry(obj *github.MungeObject) (int, bool) {
+	sha, _, ok := obj.GetHeadAndBase()
+	if !ok {
+		return 0, false
+	}
+
+	sq.Lock()
+	defer sq.Unlock()
+	if sq.e2eRetries == nil {
+		sq.e2eRetries = map[string]e2eRetryRecord{}
+	}
+	key := sq.prKey(obj)
+	record, found := sq.e2eRetries[key]
+	if !found || record.sha != sha {
+		record = e2eRetryRecord{sha: sha, remaining: sq.E2ERetries}
+	}
+	if record.remaining <= 0 {
+		sq.e2eRetries[key] = record
+		return 0, false
+	}
+	record.remaining--
+	sq.e2eRetries[key] = record
+	return record.remaining, true
 }
 
 func (sq *SubmitQueue) serve(data []byte, res http.ResponseWriter, req *http.Request) {
@@ -1374,6 +3165,11 @@ func (sq *SubmitQueue) servePRs(res http.ResponseWriter, req *http.Request) {
 	sq.serve(data, res, req)
 }
 
+func (sq *SubmitQueue) serveBlockedPRs(res http.ResponseWriter, req *http.Request) {
+	data := sq.getBlockedPRs()
+	sq.serve(data, res, req)
+}
+
 func (sq *SubmitQueue) serveGithubE2EStatus(res http.ResponseWriter, req *http.Request) {
 	data := sq.getGithubE2EStatus()
 	sq.serve(data, res, req)
@@ -1389,6 +3185,11 @@ func (sq *SubmitQueue) serveHealth(res http.ResponseWriter, req *http.Request) {
 	sq.serve(data, res, req)
 }
 
+func (sq *SubmitQueue) serveMergeRateHistory(res http.ResponseWriter, req *http.Request) {
+	data := sq.marshal(sq.getMergeRateHistory())
+	sq.serve(data, res, req)
+}
+
 func (sq *SubmitQueue) serveSQStats(res http.ResponseWriter, req *http.Request) {
 	data := submitQueueStats{
 		Added:              int(atomic.LoadInt32(&sq.prsAdded)),
@@ -1427,7 +3228,16 @@ func (sq *SubmitQueue) serveMergeInfo(res http.ResponseWriter, req *http.Request
 	var out bytes.Buffer
 	out.WriteString("PRs must meet the following set of conditions to be considered for automatic merging by the submit queue.")
 	out.WriteString("<ol>")
-	out.WriteString(fmt.Sprintf("<li>The PR must have the label %q, %q or %q </li>", claYesLabel, cncfClaYesLabel, claHumanLabel))
+	if len(sq.AllowedBaseBranches) > 0 {
+		out.WriteString(fmt.Sprintf("<li>The PR must be for one of the following branches: %q</li>", sq.AllowedBaseBranches))
+	} else if len(sq.defaultBranches) > 0 {
+		out.WriteString("<li>The PR must be for the default branch of its repo</li>")
+	}
+	if sq.CLAContext != "" {
+		out.WriteString(fmt.Sprintf("<li>The PR's %q github status must be green</li>", sq.CLAContext))
+	} else {
+		out.WriteString(fmt.Sprintf("<li>The PR must have the label %q, %q or %q </li>", sq.CLALabel, cncfClaYesLabel, claHumanLabel))
+	}
 	out.WriteString("<li>The PR must be mergeable. aka cannot need a rebase</li>")
 	if len(sq.RequiredStatusContexts) > 0 || len(sq.RequiredRetestContexts) > 0 {
 		out.WriteString("<li>All of the following github statuses must be green")
@@ -1441,17 +3251,51 @@ func (sq *SubmitQueue) serveMergeInfo(res http.ResponseWriter, req *http.Request
 		out.WriteString("</ul>")
 	}
 	out.WriteString(fmt.Sprintf("<li>The PR cannot have any of the following milestones: %q</li>", sq.DoNotMergeMilestones))
-	out.WriteString(fmt.Sprintf(`<li>The PR must have the %q label</li>`, lgtmLabel))
-	out.WriteString(fmt.Sprintf("<li>The PR must not have been updated since the %q label was applied</li>", lgtmLabel))
+	if sq.ReviewMode {
+		out.WriteString(fmt.Sprintf("<li>The PR must have at least %d approving reviews submitted after the last commit</li>", sq.ApprovingReviewsRequired))
+	} else {
+		out.WriteString(fmt.Sprintf(`<li>The PR must have the %q label</li>`, sq.LGTMLabel))
+		out.WriteString(fmt.Sprintf("<li>The PR must not have been updated since the %q label was applied</li>", sq.LGTMLabel))
+		if !sq.AllowSelfLGTM {
+			out.WriteString(fmt.Sprintf("<li>The %q label must not have been applied by the PR's author</li>", sq.LGTMLabel))
+		}
+		if sq.ApprovingReviewsRequired > 1 {
+			out.WriteString(fmt.Sprintf("<li>At least %d different people, other than the author, who are assigned to the PR or may push to the repo must have commented <code>/lgtm</code> since the last commit</li>", sq.ApprovingReviewsRequired))
+		}
+	}
 	if sq.GateApproved {
 		out.WriteString(fmt.Sprintf(`<li>The PR must have the %q label</li>`, approvedLabel))
 		out.WriteString(fmt.Sprintf("<li>The PR must not have been updated since the %q label was applied</li>", approvedLabel))
 	}
-	out.WriteString(fmt.Sprintf("<li>The PR must not have the %q label</li>", doNotMergeLabel))
+	if len(sq.RequiredLabels) > 0 {
+		out.WriteString(fmt.Sprintf("<li>The PR must have all of the labels %q</li>", sq.RequiredLabels))
+	}
+	out.WriteString(fmt.Sprintf("<li>The PR must not have the %q label</li>", sq.DoNotMergeLabel))
+	if len(sq.blockingLabels) > 0 {
+		labels := []string{}
+		for label := range sq.blockingLabels {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		out.WriteString(fmt.Sprintf("<li>The PR must not have any of the labels %q</li>", labels))
+	}
+	out.WriteString(fmt.Sprintf("<li>The PR must not be a draft or have a title starting with any of %q</li>", sq.WIPPrefixes))
+	if sq.TrackerURL != "" {
+		out.WriteString(fmt.Sprintf("<li>The PR must reference a <a href=%s>tracker</a> ticket in one of the following states: %q</li>", sq.TrackerURL, sq.TrackerReadyStates))
+	}
+	if sq.MinQueueTime > 0 {
+		out.WriteString(fmt.Sprintf("<li>The PR must have met all of the above conditions for at least %v without being updated</li>", sq.MinQueueTime))
+	}
 	out.WriteString(`</ol><br>`)
 	out.WriteString("The PR can then be queued to re-test before merge. Once it reaches the top of the queue all of the above conditions must be true but so must the following:")
 	out.WriteString("<ol>")
 	out.WriteString(fmt.Sprintf("<li>All of the <a href=http://submit-queue.k8s.io/#/e2e>continuously running e2e tests</a> must be passing</li>"))
+	if len(sq.MergeWindow) > 0 {
+		out.WriteString(fmt.Sprintf("<li>It must be within the merge window: %q (%s)</li>", sq.MergeWindow, sq.MergeWindowTimezone))
+	}
+	if len(sq.BaseBranchContexts) > 0 {
+		out.WriteString(fmt.Sprintf("<li>None of %q may be failing on the head of the branch the PR merges into</li>", sq.BaseBranchContexts))
+	}
 	if len(sq.RequiredRetestContexts) > 0 {
 		out.WriteString("<li>All of the following tests must pass a second time")
 		out.WriteString("<ul>")
@@ -1461,6 +3305,22 @@ func (sq *SubmitQueue) serveMergeInfo(res http.ResponseWriter, req *http.Request
 		out.WriteString("</ul>")
 		out.WriteString(fmt.Sprintf("Unless the %q or %q label is present</li>", retestNotRequiredLabel, retestNotRequiredDocsOnlyLabel))
 	}
+	if sq.RevertSkipRetest && (len(sq.RevertTitlePrefixes) > 0 || sq.RevertLabel != "") {
+		out.WriteString(fmt.Sprintf("<li>Revert PRs, titled starting with one of %q or with the %q label, merge without the tests being run a second time</li>", sq.RevertTitlePrefixes, sq.RevertLabel))
+	}
+	if sq.IgnoreBaseBranchFailures {
+		out.WriteString("<li>Tests which are also failing in the latest run against the branch the PR merges into don't count as failures</li>")
+	}
+	if sq.MaxRetestsPerDay > 0 {
+		out.WriteString(fmt.Sprintf("<li>The PR must have had fewer than %d retests since %02d:00 UTC, unless one of %q has commented <code>@%s %s</code></li>", sq.MaxRetestsPerDay, sq.RetestLimitResetHour, sq.WhitelistAdmins, botName, strings.ToLower(allowRetestsCommand)))
+	}
+	out.WriteString(fmt.Sprintf("<li>Tests which are being skipped with <code>@%s skip-test</code> don't count as failures</li>", botName))
+	if len(sq.E2ELabelContexts) > 0 {
+		out.WriteString(fmt.Sprintf("<li>PRs with some labels must pass more of these tests or can skip some of them: %q</li>", sq.E2ELabelContexts))
+	}
+	if len(sq.EmergencyMergeAdmins) > 0 {
+		out.WriteString(fmt.Sprintf("<li>A PR with the %q label, added by one of %q, is merged without any github e2e tests, as soon as it meets the conditions above</li>", sq.EmergencyMergeLabel, sq.EmergencyMergeAdmins))
+	}
 	out.WriteString("</ol>")
 	out.WriteString("And then the PR will be merged!!")
 	res.Write(out.Bytes())
@@ -1492,6 +3352,18 @@ func (sq *SubmitQueue) servePriorityInfo(res http.ResponseWriter, req *http.Requ
     </ul>
   </li>
 </ol> `))
+	if sq.MaxQueueSize > 0 {
+		res.Write([]byte(fmt.Sprintf(`At most %d PRs are queued. When the queue is full a PR only gets in ahead of the PR which would be tested last, which waits outside the queue until there is room. `, sq.MaxQueueSize)))
+	}
+	if len(sq.RevertTitlePrefixes) > 0 || sq.RevertLabel != "" {
+		res.Write([]byte(fmt.Sprintf(`A revert PR, titled starting with one of %q or with the %q label, comes before even P0. `, sq.RevertTitlePrefixes, sq.RevertLabel)))
+	}
+	if sq.PriorityAgingInterval > 0 {
+		res.Write([]byte(fmt.Sprintf(`A PR's priority goes up by one, as far as P0, for every %v it has been in the queue. `, sq.PriorityAgingInterval)))
+	}
+	if len(sq.FairnessQuotas) > 0 {
+		res.Write([]byte(fmt.Sprintf(`So that lower priorities aren't starved, once the following number of PRs of a priority have merged in a row the first PR of the next lower priority goes next: %q`, sq.FairnessQuotas)))
+	}
 }
 
 func (sq *SubmitQueue) getHealthSVG() []byte {
@@ -1533,16 +3405,37 @@ func (sq *SubmitQueue) isStaleComment(obj *github.MungeObject, comment *githubap
 	if !mergeBotComment(comment) {
 		return false
 	}
-	if *comment.Body != retestBody {
+	if strings.HasPrefix(*comment.Body, sq.templates().queuedPrefix) {
+		// Only the most recent queue position is interesting
+		return sq.newerQueueComment(obj, comment)
+	}
+	// Retries have "(retry N of M)" appended
+	if !strings.HasPrefix(*comment.Body, sq.retestBody(obj)) {
 		return false
 	}
 	stale := commentBeforeLastCI(obj, comment, sq.RequiredRetestContexts)
 	if stale {
-		glog.V(6).Infof("Found stale SubmitQueue safe to merge comment")
+		obj.Log().V(6).Infof("Found stale SubmitQueue safe to merge comment")
 	}
 	return stale
 }
 
+func (sq *SubmitQueue) newerQueueComment(obj *github.MungeObject, comment *githubapi.IssueComment) bool {
+	comments, ok := obj.ListComments()
+	if !ok {
+		return false
+	}
+	for _, other := range comments {
+		if !validComment(other) || !mergeBotComment(other) || !strings.HasPrefix(*other.Body, sq.templates().queuedPrefix) {
+			continue
+		}
+		if other.CreatedAt.After(*comment.CreatedAt) {
+			return true
+		}
+	}
+	return false
+}
+
 // StaleComments returns a slice of stale comments
 func (sq *SubmitQueue) StaleComments(obj *github.MungeObject, comments []*githubapi.IssueComment) []*githubapi.IssueComment {
 	return forEachCommentTest(obj, comments, sq.isStaleComment)
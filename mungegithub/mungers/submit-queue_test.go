@@ -21,9 +21,12 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -35,6 +38,7 @@ import (
 	"k8s.io/contrib/mungegithub/mungers/e2e"
 	fake_e2e "k8s.io/contrib/mungegithub/mungers/e2e/fake"
 	"k8s.io/contrib/mungegithub/mungers/mungerutil"
+	fake_tracker "k8s.io/contrib/mungegithub/mungers/tracker/fake"
 	"k8s.io/contrib/test-utils/utils"
 
 	"github.com/golang/glog"
@@ -72,6 +76,12 @@ func UndeterminedMergeablePR() *github.PullRequest {
 	return github_test.PullRequest(someUserName, false, false, false)
 }
 
+func ReleaseBranchPR() *github.PullRequest {
+	pr := ValidPR()
+	pr.Base.Ref = stringPtr("release-1.2")
+	return pr
+}
+
 func MasterCommit() *github.RepositoryCommit {
 	masterSHA := "mastersha"
 	return &github.RepositoryCommit{
@@ -193,8 +203,12 @@ func FailGCS() utils.FinishedFile {
 }
 
 func getJUnit(testsNo int, failuresNo int) []byte {
-	return []byte(fmt.Sprintf("%v\n<testsuite tests=\"%v\" failures=\"%v\" time=\"1234\">\n</testsuite>",
-		e2e.ExpectedXMLHeader, testsNo, failuresNo))
+	failures := ""
+	for i := 0; i < failuresNo; i++ {
+		failures += fmt.Sprintf("<testcase name=\"test%v\"><failure>failed</failure></testcase>\n", i)
+	}
+	return []byte(fmt.Sprintf("%v\n<testsuite tests=\"%v\" failures=\"%v\" time=\"1234\">\n%v</testsuite>",
+		e2e.ExpectedXMLHeader, testsNo, failuresNo, failures))
 }
 
 func getTestSQ(startThreads bool, config *github_util.Config, server *httptest.Server) *SubmitQueue {
@@ -206,7 +220,7 @@ func getTestSQ(startThreads bool, config *github_util.Config, server *httptest.S
 	sq.RequiredRetestContexts = []string{requiredReTestContext1, requiredReTestContext2}
 	sq.BlockingJobNames = []string{"foo"}
 	sq.WeakStableJobNames = []string{"bar"}
-	sq.githubE2EQueue = map[int]*github_util.MungeObject{}
+	sq.githubE2EQueue = map[string]*github_util.MungeObject{}
 	sq.githubE2EPollTime = 50 * time.Millisecond
 
 	sq.clock = utilclock.NewFakeClock(time.Time{})
@@ -214,6 +228,9 @@ func getTestSQ(startThreads bool, config *github_util.Config, server *httptest.S
 	sq.lastE2EStable = true
 	sq.prStatus = map[string]submitStatus{}
 	sq.lastPRStatus = map[string]submitStatus{}
+	sq.CLALabel = claYesLabel
+	sq.LGTMLabel = lgtmLabel
+	sq.DoNotMergeLabel = doNotMergeLabel
 	sq.lgtmTimeCache = mungerutil.NewLabelTimeCache(lgtmLabel)
 
 	sq.startTime = sq.clock.Now()
@@ -349,16 +366,16 @@ func TestQueueOrder(t *testing.T) {
 			if err != nil {
 				t.Fatalf("%d:%q unable to get issue: %v", testNum, test.name, err)
 			}
-			sq.githubE2EQueue[issueNum] = obj
+			sq.githubE2EQueue[strconv.Itoa(issueNum)] = obj
 		}
 		actual := sq.orderedE2EQueue()
 		if len(actual) != len(test.expected) {
 			t.Fatalf("%d:%q len(actual):%v != len(expected):%v", testNum, test.name, actual, test.expected)
 		}
 		for i, a := range actual {
-			e := test.expected[i]
+			e := strconv.Itoa(test.expected[i])
 			if a != e {
-				t.Errorf("%d:%q a[%d]:%d != e[%d]:%d", testNum, test.name, i, a, i, e)
+				t.Errorf("%d:%q a[%d]:%s != e[%d]:%s", testNum, test.name, i, a, i, e)
 			}
 		}
 		server.Close()
@@ -420,6 +437,617 @@ func TestValidateLGTMAfterPush(t *testing.T) {
 	}
 }
 
+func review(user, state string, submitted int64) *github_util.PullRequestReview {
+	submittedAt := time.Unix(submitted, 0)
+	return &github_util.PullRequestReview{
+		User:        &github.User{Login: stringPtr(user)},
+		State:       stringPtr(state),
+		SubmittedAt: &submittedAt,
+	}
+}
+
+func TestReviewMode(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name       string
+		reviews    []*github_util.PullRequestReview
+		required   int
+		shouldPass bool
+	}{
+		{
+			name:       "approved after push",
+			reviews:    []*github_util.PullRequestReview{review("bob", "APPROVED", 10)},
+			required:   1,
+			shouldPass: true,
+		},
+		{
+			name:       "approved before push",
+			reviews:    []*github_util.PullRequestReview{review("bob", "APPROVED", 8)},
+			required:   1,
+			shouldPass: false,
+		},
+		{
+			name:       "approved at push",
+			reviews:    []*github_util.PullRequestReview{review("bob", "APPROVED", 9)},
+			required:   1,
+			shouldPass: true,
+		},
+		{
+			name: "changes requested after approval",
+			reviews: []*github_util.PullRequestReview{
+				review("bob", "APPROVED", 10),
+				review("bob", "CHANGES_REQUESTED", 11),
+			},
+			required:   1,
+			shouldPass: false,
+		},
+		{
+			name: "comment after approval",
+			reviews: []*github_util.PullRequestReview{
+				review("bob", "APPROVED", 10),
+				review("bob", "COMMENTED", 11),
+			},
+			required:   1,
+			shouldPass: true,
+		},
+		{
+			name:       "not enough approvals",
+			reviews:    []*github_util.PullRequestReview{review("bob", "APPROVED", 10), review("bob", "APPROVED", 11)},
+			required:   2,
+			shouldPass: false,
+		},
+		{
+			name:       "enough approvals",
+			reviews:    []*github_util.PullRequestReview{review("bob", "APPROVED", 10), review("alice", "APPROVED", 11)},
+			required:   2,
+			shouldPass: true,
+		},
+	}
+	for _, test := range tests {
+		issue := OnlyApprovedIssue()
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		reviews := test.reviews
+		mux.HandleFunc("/repos/o/r/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+			data, err := json.Marshal(reviews)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.ReviewMode = true
+		sq.ApprovingReviewsRequired = test.required
+
+		obj := github_util.TestObject(config, issue, ValidPR(), Commits(), NewLGTMEvents())
+		if valid := sq.validForMerge(obj); valid != test.shouldPass {
+			t.Errorf("%s: expected valid=%v but got %v (%q)", test.name, test.shouldPass, valid, sq.prStatus["1"].Reason)
+		}
+		if !test.shouldPass && !strings.HasPrefix(sq.prStatus["1"].Reason, needsMoreApprovals) {
+			t.Errorf("%s: expected reason %q but got %q", test.name, needsMoreApprovals, sq.prStatus["1"].Reason)
+		}
+		server.Close()
+	}
+}
+
+func TestWIP(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name  string
+		title string
+		draft bool
+		valid bool
+	}{
+		{name: "normal", title: "Fix the thing", valid: true},
+		{name: "prefix", title: "WIP: Fix the thing", valid: false},
+		{name: "bracketed prefix", title: "[wip] Fix the thing", valid: false},
+		{name: "not a prefix", title: "Fix the WIP thing", valid: true},
+		{name: "draft", title: "Fix the thing", draft: true, valid: false},
+	}
+	for _, test := range tests {
+		issue := LGTMApprovedIssue()
+		issue.Title = stringPtr(test.title)
+		client, server, mux := github_test.InitServer(t, issue, nil, NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		draft := test.draft
+		fetches := 0
+		mux.HandleFunc("/repos/o/r/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+			fetches++
+			data, err := json.Marshal(struct {
+				*github.PullRequest
+				Draft bool `json:"draft"`
+			}{ValidPR(), draft})
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.WIPPrefixes = []string{"WIP"}
+
+		obj := github_util.TestObject(config, issue, ValidPR(), Commits(), NewLGTMEvents())
+		if valid := sq.validForMerge(obj); valid != test.valid {
+			t.Errorf("%s: expected valid=%v but got %v (%q)", test.name, test.valid, valid, sq.prStatus["1"].Reason)
+		}
+		// The draft flag is cached on the object, not fetched per check
+		sq.validForMerge(obj)
+		if fetches > 1 {
+			t.Errorf("%s: expected the PR to be fetched at most once but got %d", test.name, fetches)
+		}
+		if !test.valid {
+			if r := sq.prStatus["1"].Reason; r != wip {
+				t.Errorf("%s: expected reason %q but got %q", test.name, wip, r)
+			}
+			// Shedding the prefix and the draft state makes it eligible again
+			issue.Title = stringPtr("Fix the thing")
+			draft = false
+			obj.Refresh()
+			if !sq.validForMerge(obj) {
+				t.Errorf("%s: expected PR to be valid once no longer WIP but got %q", test.name, sq.prStatus["1"].Reason)
+			}
+		}
+		server.Close()
+	}
+}
+
+func TestMinQueueTime(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	sq.MinQueueTime = time.Hour
+	clock := sq.clock.(*utilclock.FakeClock)
+
+	checkReason := func(step string, obj *github_util.MungeObject, shouldPass bool, reason string) {
+		if valid := sq.validForMerge(obj); valid != shouldPass {
+			t.Errorf("%s: expected valid=%v but got %v", step, shouldPass, valid)
+		}
+		if reason == "" {
+			return
+		}
+		if r := sq.prStatus["1"].Reason; r != reason {
+			t.Errorf("%s: expected reason %q but got %q", step, reason, r)
+		}
+	}
+
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	checkReason("eligible", obj, false, cooling)
+
+	clock.Step(59 * time.Minute)
+	checkReason("still cooling", obj, false, cooling)
+
+	clock.Step(time.Minute)
+	checkReason("cooled", obj, true, "")
+
+	// A new commit (modified at time.Unix(10)) starts the timer over.
+	obj = github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), github_test.Commits(3, 8), NewLGTMEvents())
+	checkReason("new commit", obj, false, cooling)
+
+	clock.Step(time.Hour)
+	checkReason("cooled again", obj, true, "")
+
+	// Losing eligibility, here to the do-not-merge label, starts it over
+	// once the PR is eligible again.
+	held := LGTMApprovedIssue()
+	held.Labels = append(held.Labels, github.Label{Name: stringPtr(doNotMergeLabel)})
+	heldObj := github_util.TestObject(config, held, ValidPR(), github_test.Commits(3, 8), NewLGTMEvents())
+	checkReason("held", heldObj, false, fmt.Sprintf(noMergeFmt, doNotMergeLabel))
+	checkReason("eligible again", obj, false, cooling)
+	clock.Step(time.Hour)
+	checkReason("cooled after regaining", obj, true, "")
+
+	// PRs which aren't checked for a whole loop, like closed ones, are
+	// forgotten.
+	sq.EachLoop()
+	if _, ok := sq.eligibleTimes["1"]; !ok {
+		t.Errorf("PR checked during the last loop was forgotten")
+	}
+	sq.EachLoop()
+	if _, ok := sq.eligibleTimes["1"]; ok {
+		t.Errorf("expected the PR's eligible time to be dropped after a loop without it")
+	}
+}
+
+func TestMergeWindow(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	window, err := parseMergeWindow([]string{"Mon-Fri 09:00-17:00"}, "UTC")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sq.mergeWindow = window
+	clock := sq.clock.(*utilclock.FakeClock)
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+
+	// Wednesday morning
+	clock.SetTime(time.Date(2016, 10, 5, 10, 0, 0, 0, time.UTC))
+	if !sq.validForMerge(obj) {
+		t.Errorf("expected PR to be mergeable inside the window, got %q", sq.prStatus["1"].Reason)
+	}
+
+	// Wednesday night
+	clock.SetTime(time.Date(2016, 10, 5, 22, 0, 0, 0, time.UTC))
+	if sq.validForMerge(obj) {
+		t.Errorf("expected PR not to be mergeable outside the window")
+	}
+	if r := sq.prStatus["1"].Reason; r != outsideMergeWindow {
+		t.Errorf("expected reason %q but got %q", outsideMergeWindow, r)
+	}
+
+	// A PR already being tested is allowed to finish
+	sq.githubE2ERunning = obj
+	if !sq.validForMerge(obj) {
+		t.Errorf("expected running PR to be mergeable outside the window, got %q", sq.prStatus["1"].Reason)
+	}
+}
+
+func TestBaseBranchRed(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	const baseContext = "ci/master"
+	tests := []struct {
+		name   string
+		base   *github.CombinedStatus
+		reason string
+	}{
+		{
+			name: "green base branch",
+			base: github_test.Status("master", []string{baseContext}, nil, nil, nil),
+		},
+		{
+			name:   "red base branch",
+			base:   github_test.Status("master", nil, []string{baseContext}, nil, nil),
+			reason: baseBranchRed,
+		},
+		{
+			name:   "base branch erroring",
+			base:   github_test.Status("master", nil, nil, nil, []string{baseContext}),
+			reason: baseBranchRed,
+		},
+		{
+			name: "base branch still running",
+			base: github_test.Status("master", nil, nil, []string{baseContext}, nil),
+		},
+		{
+			name: "other contexts don't matter",
+			base: github_test.Status("master", []string{baseContext}, []string{"ci/other"}, nil, nil),
+		},
+	}
+	for _, test := range tests {
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		fetches := 0
+		mux.HandleFunc("/repos/o/r/commits/master/status", func(w http.ResponseWriter, r *http.Request) {
+			fetches++
+			data, _ := json.Marshal(test.base)
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.githubConfig = config
+		sq.BaseBranchContexts = []string{baseContext}
+		sq.EachLoop()
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+
+		valid := sq.validForMerge(obj)
+		if valid != (test.reason == "") {
+			t.Errorf("%s: expected valid=%v but got %v, %q", test.name, test.reason == "", valid, sq.prStatus["1"].Reason)
+		}
+		if test.reason != "" && sq.prStatus["1"].Reason != test.reason {
+			t.Errorf("%s: expected reason %q but got %q", test.name, test.reason, sq.prStatus["1"].Reason)
+		}
+		sq.validForMerge(obj)
+		if fetches != 1 {
+			t.Errorf("%s: expected the base branch status to be fetched once per loop but it was fetched %d times", test.name, fetches)
+		}
+		server.Close()
+	}
+}
+
+func TestServeBlockedPRs(t *testing.T) {
+	client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	clock := sq.clock.(*utilclock.FakeClock)
+	start := clock.Now()
+	clock.Step(time.Hour)
+
+	status := func(num int, reason string) submitStatus {
+		return submitStatus{
+			statusPullRequest: statusPullRequest{Number: num},
+			Reason:            reason,
+			Since:             start,
+			Priority:          3,
+		}
+	}
+	sq.lastPRStatus["1"] = status(1, ghE2EQueued)
+	sq.lastPRStatus["2"] = status(2, ciFailure)
+	sq.lastPRStatus["3"] = status(3, fmt.Sprintf(noLGTMFmt, lgtmLabel))
+	sq.prStatus["1"] = status(1, ghE2EQueued)
+	sq.prStatus["2"] = status(2, fmt.Sprintf(noLGTMFmt, lgtmLabel))
+	sq.githubE2EQueue["1"] = github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+
+	res := httptest.NewRecorder()
+	sq.serveBlockedPRs(res, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("unexpected response code %d", res.Code)
+	}
+	prs := []blockedPR{}
+	if err := json.Unmarshal(res.Body.Bytes(), &prs); err != nil {
+		t.Fatalf("unable to decode response %q: %v", res.Body.String(), err)
+	}
+
+	expected := []blockedPR{
+		{Number: 1, Reason: ghE2EQueued, Priority: 3, QueuePosition: 0},
+		{Number: 2, Reason: fmt.Sprintf(noLGTMFmt, lgtmLabel), Priority: 3, QueuePosition: -1, Changed: true, PreviousReason: ciFailure},
+		{Number: 3, Reason: fmt.Sprintf(noLGTMFmt, lgtmLabel), Priority: 3, QueuePosition: -1},
+	}
+	if len(prs) != len(expected) {
+		t.Fatalf("expected %d PRs but got %d: %v", len(expected), len(prs), prs)
+	}
+	for i, pr := range prs {
+		if pr.TimeInStateSeconds != 3600 || !pr.Since.Equal(start) {
+			t.Errorf("%d: expected an hour in state since %v but got %ds since %v", pr.Number, start, pr.TimeInStateSeconds, pr.Since)
+		}
+		pr.Since = time.Time{}
+		pr.TimeInStateSeconds = 0
+		if !reflect.DeepEqual(pr, expected[i]) {
+			t.Errorf("expected %#v but got %#v", expected[i], pr)
+		}
+	}
+}
+
+func TestCustomLabels(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	const (
+		customCLA  = "cla/signed"
+		customLGTM = "ship-it"
+		customDNM  = "hold"
+	)
+	events := github_test.Events([]github_test.LabelTime{
+		{User: "bob", Label: approvedLabel, Time: 20},
+		{User: "bob", Label: customLGTM, Time: 10},
+	})
+
+	tests := []struct {
+		name   string
+		labels []string
+		reason string
+	}{
+		{
+			name:   "custom labels merge",
+			labels: []string{customCLA, customLGTM, approvedLabel},
+		},
+		{
+			name:   "missing custom CLA label",
+			labels: []string{claYesLabel, customLGTM, approvedLabel},
+			reason: fmt.Sprintf(noCLAFmt, customCLA),
+		},
+		{
+			name:   "default lgtm label ignored",
+			labels: []string{customCLA, lgtmLabel, approvedLabel},
+			reason: fmt.Sprintf(noLGTMFmt, customLGTM),
+		},
+		{
+			name:   "custom do-not-merge label",
+			labels: []string{customCLA, customLGTM, approvedLabel, customDNM},
+			reason: fmt.Sprintf(noMergeFmt, customDNM),
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(someUserName, 1, test.labels, true)
+		client, server, _ := github_test.InitServer(t, issue, ValidPR(), events, Commits(), SuccessStatus(), nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.CLALabel = customCLA
+		sq.LGTMLabel = customLGTM
+		sq.DoNotMergeLabel = customDNM
+		obj := github_util.TestObject(config, issue, ValidPR(), Commits(), events)
+
+		valid := sq.validForMerge(obj)
+		if test.reason == "" {
+			if !valid {
+				t.Errorf("%s: expected PR to be mergeable, got %q", test.name, sq.prStatus["1"].Reason)
+			}
+		} else if r := sq.prStatus["1"].Reason; valid || r != test.reason {
+			t.Errorf("%s: expected reason %q but got %q (valid=%v)", test.name, test.reason, r, valid)
+		}
+		server.Close()
+	}
+}
+
+func TestCLAContext(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	const claContext = "cla/linuxfoundation"
+	contexts := []string{requiredReTestContext1, requiredReTestContext2, notRequiredReTestContext1, notRequiredReTestContext2}
+	tests := []struct {
+		name   string
+		issue  *github.Issue
+		status *github.CombinedStatus
+		reason string
+	}{
+		{
+			name:   "CLA status passing without the label",
+			issue:  github_test.Issue(someUserName, 1, []string{lgtmLabel, approvedLabel}, true),
+			status: github_test.Status("mysha", append(contexts, claContext), nil, nil, nil),
+		},
+		{
+			name:   "CLA status failing with the label",
+			issue:  LGTMApprovedIssue(),
+			status: github_test.Status("mysha", contexts, []string{claContext}, nil, nil),
+			reason: fmt.Sprintf(noCLAStatus, claContext),
+		},
+		{
+			name:   "CLA status missing",
+			issue:  LGTMApprovedIssue(),
+			status: SuccessStatus(),
+			reason: fmt.Sprintf(noCLAStatus, claContext),
+		},
+	}
+	for _, test := range tests {
+		client, server, _ := github_test.InitServer(t, test.issue, ValidPR(), NewLGTMEvents(), Commits(), test.status, nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.CLAContext = claContext
+		if reason := sq.reasonNames()["noCLA"]; reason != fmt.Sprintf(noCLAStatus, claContext) {
+			t.Errorf("%s: unexpected noCLA reason %q", test.name, reason)
+		}
+		obj := github_util.TestObject(config, test.issue, ValidPR(), Commits(), NewLGTMEvents())
+
+		valid := sq.validForMerge(obj)
+		if test.reason == "" {
+			if !valid {
+				t.Errorf("%s: expected PR to be mergeable, got %q", test.name, sq.prStatus["1"].Reason)
+			}
+		} else if r := sq.prStatus["1"].Reason; valid || r != test.reason {
+			t.Errorf("%s: expected reason %q but got %q (valid=%v)", test.name, test.reason, r, valid)
+		}
+		server.Close()
+	}
+}
+
+func TestMissingContext(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+
+	sq := getTestSQ(false, config, server)
+	sq.RequiredStatusContexts = append(sq.RequiredStatusContexts, "never-reported")
+	sq.MissingContextTimeout = time.Hour
+	clock := sq.clock.(*utilclock.FakeClock)
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+
+	// Commits() were last modified at time.Unix(9)
+	clock.SetTime(time.Unix(9, 0).Add(30 * time.Minute))
+	sq.validForMerge(obj)
+	if r, expected := sq.prStatus["1"].Reason, fmt.Sprintf(ciFailureFmt, "never-reported"); r != expected {
+		t.Errorf("expected reason %q before the timeout but got %q", expected, r)
+	}
+
+	clock.Step(time.Hour)
+	sq.validForMerge(obj)
+	if r, expected := sq.prStatus["1"].Reason, fmt.Sprintf(missingContextFmt, "never-reported"); r != expected {
+		t.Errorf("expected reason %q after the timeout but got %q", expected, r)
+	}
+}
+
+func TestTrackerGate(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name  string
+		title string
+		body  string
+		valid bool
+		// reason is only checked if the PR is not valid
+		reason string
+	}{
+		{
+			name:  "ready ticket in title",
+			title: "ABC-1: fix the thing",
+			valid: true,
+		},
+		{
+			name:  "ready ticket in body",
+			title: "fix the thing",
+			body:  "Fixes ABC-1",
+			valid: true,
+		},
+		{
+			name:   "not ready ticket",
+			title:  "ABC-2: fix the other thing",
+			reason: trackerNotReady,
+		},
+		{
+			name:   "unknown ticket",
+			title:  "ABC-3: fix the other thing",
+			reason: trackerNotReady,
+		},
+		{
+			name:   "no ticket",
+			title:  "fix the thing",
+			reason: noTrackerTicket,
+		},
+	}
+	for _, test := range tests {
+		issue := LGTMApprovedIssue()
+		issue.Title = stringPtr(test.title)
+		issue.Body = stringPtr(test.body)
+		client, server, _ := github_test.InitServer(t, issue, ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.TrackerReadyStates = []string{"Ready for Merge"}
+		sq.tracker = &fake_tracker.FakeTracker{
+			States: map[string]string{
+				"ABC-1": "Ready for Merge",
+				"ABC-2": "In Progress",
+			},
+		}
+
+		obj := github_util.TestObject(config, issue, ValidPR(), Commits(), NewLGTMEvents())
+		if valid := sq.validForMerge(obj); valid != test.valid {
+			t.Errorf("%s: expected valid=%v but got %v", test.name, test.valid, valid)
+		}
+		if !test.valid {
+			if r := sq.prStatus["1"].Reason; r != test.reason {
+				t.Errorf("%s: expected reason %q but got %q", test.name, test.reason, r)
+			}
+		}
+		server.Close()
+	}
+}
+
 func setStatus(status *github.RepoStatus, success bool) {
 	if success {
 		status.State = stringPtr("success")
@@ -495,7 +1123,11 @@ func TestSubmitQueue(t *testing.T) {
 		state            string // what the github status context should be for the PR HEAD
 
 		emergencyMergeStop bool
+		paused             bool
 		isMerged           bool
+		weakPassesRequired int
+		maxBuildAge        time.Duration
+		infraTestPatterns  []string
 
 		imHeadSHA      string
 		imBaseSHA      string
@@ -531,7 +1163,7 @@ func TestSubmitQueue(t *testing.T) {
 			weakResults:     map[int]utils.FinishedFile{LastBuildNumber(): SuccessGCS()},
 			retest1Pass:     true,
 			retest2Pass:     true,
-			reason:          noLGTM,
+			reason:          fmt.Sprintf(noLGTMFmt, lgtmLabel),
 			state:           "pending",
 			isMerged:        false,
 		},
@@ -553,10 +1185,9 @@ func TestSubmitQueue(t *testing.T) {
 			reason:             e2eFailure,
 			state:              "success",
 		},
-		// Should pass without running tests because we had a previous run.
-		// TODO: Add a proper test to make sure we don't shuffle queue when we can just merge a PR
+		// Should not be tested or merged while the queue is paused
 		{
-			name:            "Test1+prevsuccess",
+			name:            "Test1+paused",
 			pr:              ValidPR(),
 			issue:           LGTMApprovedIssue(),
 			events:          NewLGTMEvents(),
@@ -567,32 +1198,69 @@ func TestSubmitQueue(t *testing.T) {
 			weakResults:     map[int]utils.FinishedFile{LastBuildNumber(): SuccessGCS()},
 			retest1Pass:     true,
 			retest2Pass:     true,
-			reason:          merged,
+			paused:          true,
+			isMerged:        false,
+			reason:          queuePaused,
 			state:           "success",
-			isMerged:        true,
-			retestsAvoided:  1,
-			imHeadSHA:       "mysha", // Set by ValidPR
-			imBaseSHA:       "mastersha",
-			masterCommit:    MasterCommit(),
 		},
-		// Should list as 'merged' but the merge should happen before it gets e2e tested
-		// and we should bail early instead of waiting for a test that will never come.
+		// The e2e job passed, but its latest build is long finished
 		{
-			name:            "Test2",
+			name:            "Test1+staleCI",
 			pr:              ValidPR(),
 			issue:           LGTMApprovedIssue(),
 			events:          NewLGTMEvents(),
-			commits:         Commits(),
+			commits:         Commits(), // Modified at time.Unix(7), 8, and 9
 			ciStatus:        SuccessStatus(),
 			lastBuildNumber: LastBuildNumber(),
-			gcsResult:       SuccessGCS(),
+			gcsResult:       utils.FinishedFile{Result: "SUCCESS", Timestamp: 1234},
 			weakResults:     map[int]utils.FinishedFile{LastBuildNumber(): SuccessGCS()},
-			// The test should never run, but if it does, make sure it fails
-			mergeAfterQueued: true,
-			reason:           mergedByHand,
-			state:            "success",
+			retest1Pass:     true,
+			retest2Pass:     true,
+			maxBuildAge:     time.Hour,
+			isMerged:        false,
+			reason:          staleCI,
+			state:           "success",
 		},
-		// Should merge even though retest1Pass would have failed before of `retestNotRequiredLabel`
+		// Should pass without running tests because we had a previous run.
+		// TODO: Add a proper test to make sure we don't shuffle queue when we can just merge a PR
+		{
+			name:            "Test1+prevsuccess",
+			pr:              ValidPR(),
+			issue:           LGTMApprovedIssue(),
+			events:          NewLGTMEvents(),
+			commits:         Commits(), // Modified at time.Unix(7), 8, and 9
+			ciStatus:        SuccessStatus(),
+			lastBuildNumber: LastBuildNumber(),
+			gcsResult:       SuccessGCS(),
+			weakResults:     map[int]utils.FinishedFile{LastBuildNumber(): SuccessGCS()},
+			retest1Pass:     true,
+			retest2Pass:     true,
+			reason:          merged,
+			state:           "success",
+			isMerged:        true,
+			retestsAvoided:  1,
+			imHeadSHA:       "mysha", // Set by ValidPR
+			imBaseSHA:       "mastersha",
+			masterCommit:    MasterCommit(),
+		},
+		// Should list as 'merged' but the merge should happen before it gets e2e tested
+		// and we should bail early instead of waiting for a test that will never come.
+		{
+			name:            "Test2",
+			pr:              ValidPR(),
+			issue:           LGTMApprovedIssue(),
+			events:          NewLGTMEvents(),
+			commits:         Commits(),
+			ciStatus:        SuccessStatus(),
+			lastBuildNumber: LastBuildNumber(),
+			gcsResult:       SuccessGCS(),
+			weakResults:     map[int]utils.FinishedFile{LastBuildNumber(): SuccessGCS()},
+			// The test should never run, but if it does, make sure it fails
+			mergeAfterQueued: true,
+			reason:           mergedByHand,
+			state:            "success",
+		},
+		// Should merge even though retest1Pass would have failed before of `retestNotRequiredLabel`
 		{
 			name:            "merge because of retestNotRequired",
 			pr:              ValidPR(),
@@ -638,7 +1306,7 @@ func TestSubmitQueue(t *testing.T) {
 			name:   "Test7",
 			pr:     ValidPR(),
 			issue:  NoCLAIssue(),
-			reason: noCLA,
+			reason: fmt.Sprintf(noCLAFmt, claYesLabel),
 			state:  "pending",
 			// To avoid false errors in logs
 			lastBuildNumber: LastBuildNumber(),
@@ -663,7 +1331,7 @@ func TestSubmitQueue(t *testing.T) {
 			pr:       ValidPR(),
 			issue:    NoLGTMIssue(),
 			ciStatus: SuccessStatus(),
-			reason:   noLGTM,
+			reason:   fmt.Sprintf(noLGTMFmt, lgtmLabel),
 			state:    "pending",
 			// To avoid false errors in logs
 			lastBuildNumber: LastBuildNumber(),
@@ -691,7 +1359,7 @@ func TestSubmitQueue(t *testing.T) {
 			ciStatus: SuccessStatus(),
 			events:   OldLGTMEvents(),
 			commits:  Commits(), // Modified at time.Unix(7), 8, and 9
-			reason:   lgtmEarly,
+			reason:   fmt.Sprintf(lgtmEarlyFmt, lgtmLabel),
 			state:    "pending",
 		},
 		// Fail because jenkins instances are failing (whole submit queue blocks)
@@ -795,7 +1463,23 @@ func TestSubmitQueue(t *testing.T) {
 			weakResults:     map[int]utils.FinishedFile{LastBuildNumber(): SuccessGCS()},
 			retest1Pass:     true,
 			retest2Pass:     true,
-			reason:          noMerge,
+			reason:          fmt.Sprintf(noMergeFmt, doNotMergeLabel),
+			state:           "pending",
+		},
+		// Should fail because the PR is not for the default branch
+		{
+			name:            "Fail because PR is for a release branch",
+			pr:              ReleaseBranchPR(),
+			issue:           LGTMApprovedIssue(),
+			events:          NewLGTMEvents(),
+			commits:         Commits(), // Modified at time.Unix(7), 8, and 9
+			ciStatus:        SuccessStatus(),
+			lastBuildNumber: LastBuildNumber(),
+			gcsResult:       SuccessGCS(),
+			weakResults:     map[int]utils.FinishedFile{LastBuildNumber(): SuccessGCS()},
+			retest1Pass:     true,
+			retest2Pass:     true,
+			reason:          wrongBranch,
 			state:           "pending",
 		},
 		// Should fail because the 'do-not-merge-milestone' is set.
@@ -855,83 +1539,194 @@ func TestSubmitQueue(t *testing.T) {
 			state:    "pending",
 		},
 
-		// // Should pass even though last 'weakStable' build failed, as it wasn't "strong" failure
-		// // and because previous two builds succeeded.
-		// {
-		// 	name:            "Test20",
-		// 	pr:              ValidPR(),
-		// 	issue:           LGTMIssue(),
-		// 	events:          NewLGTMEvents(),
-		// 	commits:         Commits(), // Modified at time.Unix(7), 8, and 9
-		// 	ciStatus:        SuccessStatus(),
-		// 	lastBuildNumber: LastBuildNumber(),
-		// 	gcsResult:       SuccessGCS(),
-		// 	weakResults: map[int]utils.FinishedFile{
-		// 		LastBuildNumber():     FailGCS(),
-		// 		LastBuildNumber() - 1: SuccessGCS(),
-		// 		LastBuildNumber() - 2: SuccessGCS(),
-		// 	},
-		// 	gcsJunit: map[string][]byte{
-		// 		"junit_01.xml": getJUnit(5, 0),
-		// 		"junit_02.xml": getJUnit(6, 0),
-		// 		"junit_03.xml": getJUnit(7, 0),
-		// 	},
-		// 	retest1Pass:  true,
-		// 	retest2Pass: true,
-		// 	reason:   merged,
-		// 	state:    "success",
-		// },
-		// // Should fail because the failure of the weakStable job is a strong failure.
-		// {
-		// 	name:            "Test21",
-		// 	pr:              ValidPR(),
-		// 	issue:           LGTMIssue(),
-		// 	events:          NewLGTMEvents(),
-		// 	commits:         Commits(), // Modified at time.Unix(7), 8, and 9
-		// 	ciStatus:        SuccessStatus(),
-		// 	lastBuildNumber: LastBuildNumber(),
-		// 	gcsResult:       SuccessGCS(),
-		// 	weakResults: map[int]utils.FinishedFile{
-		// 		LastBuildNumber():     FailGCS(),
-		// 		LastBuildNumber() - 1: SuccessGCS(),
-		// 		LastBuildNumber() - 2: SuccessGCS(),
-		// 	},
-		// 	gcsJunit: map[string][]byte{
-		// 		"junit_01.xml": getJUnit(5, 0),
-		// 		"junit_02.xml": getJUnit(6, 1),
-		// 		"junit_03.xml": getJUnit(7, 0),
-		// 	},
-		// 	retest1Pass:  true,
-		// 	retest2Pass: true,
-		// 	reason:   e2eFailure,
-		// 	state:    "success",
-		// },
-		// // Should fail even though weakStable job weakly failed, because there was another failure in
-		// // previous two runs.
-		// {
-		// 	name:            "Test22",
-		// 	pr:              ValidPR(),
-		// 	issue:           LGTMIssue(),
-		// 	events:          NewLGTMEvents(),
-		// 	commits:         Commits(), // Modified at time.Unix(7), 8, and 9
-		// 	ciStatus:        SuccessStatus(),
-		// 	lastBuildNumber: LastBuildNumber(),
-		// 	gcsResult:       SuccessGCS(),
-		// 	weakResults: map[int]utils.FinishedFile{
-		// 		LastBuildNumber():     FailGCS(),
-		// 		LastBuildNumber() - 1: SuccessGCS(),
-		// 		LastBuildNumber() - 2: FailGCS(),
-		// 	},
-		// 	gcsJunit: map[string][]byte{
-		// 		"junit_01.xml": getJUnit(5, 0),
-		// 		"junit_02.xml": getJUnit(6, 0),
-		// 		"junit_03.xml": getJUnit(7, 0),
-		// 	},
-		// 	retest1Pass:  true,
-		// 	retest2Pass: true,
-		// 	reason:   e2eFailure,
-		// 	state:    "success",
-		// },
+		// Should pass even though last 'weakStable' build failed, as it wasn't "strong" failure
+		// and because previous two builds succeeded.
+		{
+			name:            "Test20",
+			pr:              ValidPR(),
+			issue:           LGTMApprovedIssue(),
+			events:          NewLGTMEvents(),
+			commits:         Commits(), // Modified at time.Unix(7), 8, and 9
+			ciStatus:        SuccessStatus(),
+			lastBuildNumber: LastBuildNumber(),
+			gcsResult:       SuccessGCS(),
+			weakResults: map[int]utils.FinishedFile{
+				LastBuildNumber():     FailGCS(),
+				LastBuildNumber() - 1: SuccessGCS(),
+				LastBuildNumber() - 2: SuccessGCS(),
+			},
+			gcsJunit: map[string][]byte{
+				"junit_01.xml": getJUnit(5, 0),
+				"junit_02.xml": getJUnit(6, 0),
+				"junit_03.xml": getJUnit(7, 0),
+			},
+			retest1Pass: true,
+			retest2Pass: true,
+			reason:      merged,
+			state:       "success",
+			isMerged:    true,
+		},
+		// Should fail because the failure of the weakStable job is a strong failure.
+		{
+			name:            "Test21",
+			pr:              ValidPR(),
+			issue:           LGTMApprovedIssue(),
+			events:          NewLGTMEvents(),
+			commits:         Commits(), // Modified at time.Unix(7), 8, and 9
+			ciStatus:        SuccessStatus(),
+			lastBuildNumber: LastBuildNumber(),
+			gcsResult:       SuccessGCS(),
+			weakResults: map[int]utils.FinishedFile{
+				LastBuildNumber():     FailGCS(),
+				LastBuildNumber() - 1: SuccessGCS(),
+				LastBuildNumber() - 2: SuccessGCS(),
+			},
+			gcsJunit: map[string][]byte{
+				"junit_01.xml": getJUnit(5, 0),
+				"junit_02.xml": getJUnit(6, 1),
+				"junit_03.xml": getJUnit(7, 0),
+			},
+			retest1Pass: true,
+			retest2Pass: true,
+			reason:      e2eFailure,
+			state:       "success",
+			isMerged:    false,
+		},
+		// Should pass because the only failed test in the weakStable job checks the infra,
+		// so the failure is weak and the previous runs passed.
+		{
+			name:            "Test21+infraTest",
+			pr:              ValidPR(),
+			issue:           LGTMApprovedIssue(),
+			events:          NewLGTMEvents(),
+			commits:         Commits(), // Modified at time.Unix(7), 8, and 9
+			ciStatus:        SuccessStatus(),
+			lastBuildNumber: LastBuildNumber(),
+			gcsResult:       SuccessGCS(),
+			weakResults: map[int]utils.FinishedFile{
+				LastBuildNumber():     FailGCS(),
+				LastBuildNumber() - 1: SuccessGCS(),
+				LastBuildNumber() - 2: SuccessGCS(),
+			},
+			gcsJunit: map[string][]byte{
+				"junit_01.xml": getJUnit(5, 0),
+				"junit_02.xml": getJUnit(6, 1),
+				"junit_03.xml": getJUnit(7, 0),
+			},
+			retest1Pass:       true,
+			retest2Pass:       true,
+			reason:            merged,
+			state:             "success",
+			isMerged:          true,
+			infraTestPatterns: []string{`^test0 \{`},
+		},
+		// Should fail because the infra patterns don't match the failed test.
+		{
+			name:            "Test21+otherInfraTest",
+			pr:              ValidPR(),
+			issue:           LGTMApprovedIssue(),
+			events:          NewLGTMEvents(),
+			commits:         Commits(), // Modified at time.Unix(7), 8, and 9
+			ciStatus:        SuccessStatus(),
+			lastBuildNumber: LastBuildNumber(),
+			gcsResult:       SuccessGCS(),
+			weakResults: map[int]utils.FinishedFile{
+				LastBuildNumber():     FailGCS(),
+				LastBuildNumber() - 1: SuccessGCS(),
+				LastBuildNumber() - 2: SuccessGCS(),
+			},
+			gcsJunit: map[string][]byte{
+				"junit_01.xml": getJUnit(5, 0),
+				"junit_02.xml": getJUnit(6, 1),
+				"junit_03.xml": getJUnit(7, 0),
+			},
+			retest1Pass:       true,
+			retest2Pass:       true,
+			reason:            e2eFailure,
+			state:             "success",
+			isMerged:          false,
+			infraTestPatterns: []string{`^cluster-up `},
+		},
+		// Should fail even though weakStable job weakly failed, because both of the previous
+		// two runs must have passed.
+		{
+			name:            "Test22",
+			pr:              ValidPR(),
+			issue:           LGTMApprovedIssue(),
+			events:          NewLGTMEvents(),
+			commits:         Commits(), // Modified at time.Unix(7), 8, and 9
+			ciStatus:        SuccessStatus(),
+			lastBuildNumber: LastBuildNumber(),
+			gcsResult:       SuccessGCS(),
+			weakResults: map[int]utils.FinishedFile{
+				LastBuildNumber():     FailGCS(),
+				LastBuildNumber() - 1: SuccessGCS(),
+				LastBuildNumber() - 2: FailGCS(),
+			},
+			gcsJunit: map[string][]byte{
+				"junit_01.xml": getJUnit(5, 0),
+				"junit_02.xml": getJUnit(6, 0),
+				"junit_03.xml": getJUnit(7, 0),
+			},
+			retest1Pass: true,
+			retest2Pass: true,
+			reason:      e2eFailure,
+			state:       "success",
+			isMerged:    false,
+
+			weakPassesRequired: 2,
+		},
+		// Should pass because by default only one of the previous two runs must have passed.
+		{
+			name:            "Test22+onePass",
+			pr:              ValidPR(),
+			issue:           LGTMApprovedIssue(),
+			events:          NewLGTMEvents(),
+			commits:         Commits(), // Modified at time.Unix(7), 8, and 9
+			ciStatus:        SuccessStatus(),
+			lastBuildNumber: LastBuildNumber(),
+			gcsResult:       SuccessGCS(),
+			weakResults: map[int]utils.FinishedFile{
+				LastBuildNumber():     FailGCS(),
+				LastBuildNumber() - 1: SuccessGCS(),
+				LastBuildNumber() - 2: FailGCS(),
+			},
+			gcsJunit: map[string][]byte{
+				"junit_01.xml": getJUnit(5, 0),
+				"junit_02.xml": getJUnit(6, 0),
+				"junit_03.xml": getJUnit(7, 0),
+			},
+			retest1Pass: true,
+			retest2Pass: true,
+			reason:      merged,
+			state:       "success",
+			isMerged:    true,
+		},
+		// Should fail because a previous build which can't be found counts as a failure.
+		{
+			name:            "Test22+missing",
+			pr:              ValidPR(),
+			issue:           LGTMApprovedIssue(),
+			events:          NewLGTMEvents(),
+			commits:         Commits(), // Modified at time.Unix(7), 8, and 9
+			ciStatus:        SuccessStatus(),
+			lastBuildNumber: LastBuildNumber(),
+			gcsResult:       SuccessGCS(),
+			weakResults: map[int]utils.FinishedFile{
+				LastBuildNumber():     FailGCS(),
+				LastBuildNumber() - 1: FailGCS(),
+			},
+			gcsJunit: map[string][]byte{
+				"junit_01.xml": getJUnit(5, 0),
+				"junit_02.xml": getJUnit(6, 0),
+				"junit_03.xml": getJUnit(7, 0),
+			},
+			retest1Pass: true,
+			retest2Pass: true,
+			reason:      e2eFailure,
+			state:       "success",
+			isMerged:    false,
+		},
 	}
 	for testNum := range tests {
 		test := &tests[testNum]
@@ -1009,6 +1804,20 @@ func TestSubmitQueue(t *testing.T) {
 				w.Write(data)
 			})
 		}
+		if len(test.gcsJunit) > 0 {
+			mux.HandleFunc("/storage/v1/b/bucket/o", func(w http.ResponseWriter, r *http.Request) {
+				prefix := r.URL.Query().Get("prefix")
+				items := []string{}
+				for junitFile := range test.gcsJunit {
+					name := fmt.Sprintf("logs/bar/%v/artifacts/%v", test.lastBuildNumber, junitFile)
+					if strings.HasPrefix(name, prefix) {
+						items = append(items, fmt.Sprintf(`{"name":%q}`, name))
+					}
+				}
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintf(w, `{"items":[%s]}`, strings.Join(items, ","))
+			})
+		}
 		for junitFile, xml := range test.gcsJunit {
 			path = fmt.Sprintf("/bucket/logs/bar/%v/artifacts/%v", test.lastBuildNumber, junitFile)
 			// workaround go for loop semantics
@@ -1087,6 +1896,16 @@ func TestSubmitQueue(t *testing.T) {
 
 		sq := getTestSQ(true, config, server)
 		sq.setEmergencyMergeStop(test.emergencyMergeStop)
+		sq.setPaused(test.paused, "test")
+		sq.e2e.(*e2e.RealE2ETester).WeakStablePassesRequired = test.weakPassesRequired
+		for _, pattern := range test.infraTestPatterns {
+			sq.e2e.(*e2e.RealE2ETester).InfraTestPatterns = append(sq.e2e.(*e2e.RealE2ETester).InfraTestPatterns, regexp.MustCompile(pattern))
+		}
+		if test.maxBuildAge != 0 {
+			// The fake clock starts at the zero time, before any build
+			sq.e2e.(*e2e.RealE2ETester).MaxBuildAge = test.maxBuildAge
+			sq.e2e.(*e2e.RealE2ETester).Clock = utilclock.RealClock{}
+		}
 
 		obj := github_util.TestObject(config, test.issue, test.pr, test.commits, test.events)
 		if test.imBaseSHA != "" && test.imHeadSHA != "" {
@@ -1327,6 +2146,312 @@ func TestCalcMergeRateWithTail(t *testing.T) {
 	}
 }
 
+func TestMergeRateBounds(t *testing.T) {
+	// A long idle spell is floored
+	sq := getTestSQ(false, nil, nil)
+	sq.MinMergeRate = 2
+	sq.mergeRate = 24
+	clock := sq.clock.(*utilclock.FakeClock)
+	clock.Step(1024 * time.Hour)
+	if rate := sq.calcMergeRateWithTail(); rate != 2 {
+		t.Errorf("expected the rate to be floored at 2 but got %v", rate)
+	}
+	sq.updateMergeRate()
+	if sq.mergeRate != 2 {
+		t.Errorf("expected the rate to be floored at 2 after a merge but got %v", sq.mergeRate)
+	}
+
+	// A burst of merges is capped
+	sq = getTestSQ(false, nil, nil)
+	sq.MaxMergeRate = 48
+	sq.mergeRate = 24
+	clock = sq.clock.(*utilclock.FakeClock)
+	for i := 0; i < 20; i++ {
+		clock.Step(6 * time.Minute)
+		sq.updateMergeRate()
+	}
+	if sq.mergeRate != 48 {
+		t.Errorf("expected the rate to be capped at 48 but got %v", sq.mergeRate)
+	}
+
+	// Within the bounds nothing changes
+	sq = getTestSQ(false, nil, nil)
+	sq.MinMergeRate = 2
+	sq.MaxMergeRate = 48
+	sq.mergeRate = 24
+	if rate := sq.calcMergeRateWithTail(); rate != 24 {
+		t.Errorf("expected the rate to stay at 24 but got %v", rate)
+	}
+}
+
+func TestMergeMethod(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   []string
+		method   string
+		expected string
+	}{
+		{name: "default", expected: ""},
+		{name: "configured", method: "rebase", expected: "rebase"},
+		{name: "squash label", labels: []string{mergeMethodSquashLabel}, method: "merge", expected: "squash"},
+		{name: "merge label", labels: []string{mergeMethodMergeLabel}, method: "squash", expected: "merge"},
+		{name: "squash beats rebase", labels: []string{mergeMethodRebaseLabel, mergeMethodSquashLabel}, expected: "squash"},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(someUserName, 1, append([]string{claYesLabel, lgtmLabel, approvedLabel}, test.labels...), true)
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		body := map[string]interface{}{}
+		mux.HandleFunc("/repos/o/r/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "PUT" {
+				t.Errorf("Unexpected method: %s", r.Method)
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			data, _ := json.Marshal(github.PullRequestMergeResult{})
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.MergeMethod = test.method
+
+		obj := github_util.TestObject(config, issue, ValidPR(), Commits(), NewLGTMEvents())
+		if !sq.mergePullRequest(obj, merged, "") {
+			t.Errorf("%s: merge failed", test.name)
+		}
+		method, found := body["merge_method"]
+		if test.expected == "" {
+			if found {
+				t.Errorf("%s: expected no merge_method but got %v", test.name, method)
+			}
+		} else if method != test.expected {
+			t.Errorf("%s: expected merge_method %q but got %v", test.name, test.expected, method)
+		}
+		if _, found := body["commit_message"]; !found {
+			t.Errorf("%s: merge request is missing the commit message: %v", test.name, body)
+		}
+		server.Close()
+	}
+}
+
+func TestMergeRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []int
+		headMoves bool
+		attempts  int
+		merged    bool
+		reason    string
+	}{
+		{
+			name:      "conflict then success",
+			responses: []int{http.StatusConflict, http.StatusOK},
+			attempts:  2,
+			merged:    true,
+			reason:    merged,
+		},
+		{
+			name:      "server errors then success",
+			responses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			attempts:  3,
+			merged:    true,
+			reason:    merged,
+		},
+		{
+			name:      "conflict every time",
+			responses: []int{http.StatusConflict, http.StatusConflict, http.StatusConflict, http.StatusConflict, http.StatusOK},
+			attempts:  4,
+			reason:    mergeConflict,
+		},
+		{
+			name:      "not retryable",
+			responses: []int{http.StatusMethodNotAllowed, http.StatusOK},
+			attempts:  1,
+		},
+		{
+			name:      "head moved",
+			responses: []int{http.StatusConflict, http.StatusOK},
+			headMoves: true,
+			attempts:  1,
+			reason:    mergeConflict,
+		},
+	}
+	for _, test := range tests {
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), nil, NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		attempts := 0
+		mux.HandleFunc("/repos/o/r/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+			code := test.responses[attempts]
+			attempts++
+			w.WriteHeader(code)
+			if code != http.StatusOK {
+				w.Write([]byte(`{"message": "nope"}`))
+				return
+			}
+			data, _ := json.Marshal(github.PullRequestMergeResult{})
+			w.Write(data)
+		})
+		mux.HandleFunc("/repos/o/r/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+			pr := ValidPR()
+			if test.headMoves && attempts > 0 {
+				pr.Head.SHA = stringPtr("newsha")
+			}
+			data, _ := json.Marshal(pr)
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.BaseWaitTime = time.Millisecond
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		if ok := sq.mergePullRequest(obj, merged, ""); ok != test.merged {
+			t.Errorf("%s: expected merged=%v but got %v", test.name, test.merged, ok)
+		}
+		if attempts != test.attempts {
+			t.Errorf("%s: expected %d merge attempts but got %d", test.name, test.attempts, attempts)
+		}
+		if r := sq.prStatus["1"].Reason; r != test.reason {
+			t.Errorf("%s: expected reason %q but got %q", test.name, test.reason, r)
+		}
+		server.Close()
+	}
+}
+
+func TestEmergencyMerge(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name    string
+		admins  []string
+		applier string
+		status  *github.CombinedStatus
+		merged  bool
+		reason  string
+	}{
+		{
+			name:    "admin",
+			admins:  []string{"alice"},
+			applier: "alice",
+			status:  SuccessStatus(),
+			merged:  true,
+			reason:  emergencyMerged,
+		},
+		{
+			name:    "admin skips failing e2e",
+			admins:  []string{"alice"},
+			applier: "alice",
+			status:  RetestFailStatus(),
+			merged:  true,
+			reason:  emergencyMerged,
+		},
+		{
+			name:    "admin still needs unit tests",
+			admins:  []string{"alice"},
+			applier: "alice",
+			status:  github_test.Status("mysha", []string{requiredReTestContext1, requiredReTestContext2}, []string{notRequiredReTestContext1}, nil, nil),
+			reason:  fmt.Sprintf(ciFailureFmt, notRequiredReTestContext1),
+		},
+		{
+			name:    "not an admin",
+			admins:  []string{"alice"},
+			applier: "bob",
+			status:  SuccessStatus(),
+			reason:  ghE2EQueued,
+		},
+		{
+			name:    "not an admin with failing e2e",
+			admins:  []string{"alice"},
+			applier: "bob",
+			status:  RetestFailStatus(),
+			reason:  fmt.Sprintf(ciFailureFmt, requiredReTestContext2),
+		},
+		{
+			name:    "no admins",
+			applier: "alice",
+			status:  SuccessStatus(),
+			reason:  ghE2EQueued,
+		},
+	}
+	for _, test := range tests {
+		issue := github_test.Issue(someUserName, 1, []string{claYesLabel, lgtmLabel, approvedLabel, emergencyMergeLabel}, true)
+		events := append(NewLGTMEvents(), github_test.Events([]github_test.LabelTime{{User: test.applier, Label: emergencyMergeLabel, Time: 30}})...)
+		client, server, mux := github_test.InitServer(t, issue, ValidPR(), events, Commits(), test.status, nil, nil)
+		merged := false
+		mux.HandleFunc("/repos/o/r/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+			merged = true
+			data, _ := json.Marshal(github.PullRequestMergeResult{})
+			w.Write(data)
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.SetClient(client)
+
+		sq := getTestSQ(false, config, server)
+		sq.EmergencyMergeLabel = emergencyMergeLabel
+		sq.EmergencyMergeAdmins = test.admins
+		sq.Munge(github_util.TestObject(config, issue, ValidPR(), Commits(), events))
+
+		if merged != test.merged {
+			t.Errorf("%s: expected merged=%v but got %v", test.name, test.merged, merged)
+		}
+		if r := sq.prStatus["1"].Reason; r != test.reason {
+			t.Errorf("%s: expected reason %q but got %q", test.name, test.reason, r)
+		}
+		if queued := sq.onQueue(github_util.TestObject(config, issue, ValidPR(), Commits(), events)); queued != (test.reason == ghE2EQueued) {
+			t.Errorf("%s: expected queued=%v but got %v", test.name, test.reason == ghE2EQueued, queued)
+		}
+		server.Close()
+	}
+}
+
+func TestEstimateTimeToMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		index     int
+		mergeRate float64
+		expected  string
+	}{
+		{name: "0One", index: 0, mergeRate: 0, expected: "unknown"},
+		{name: "negative", index: 3, mergeRate: -1, expected: "unknown"},
+		{name: "head of queue", index: 0, mergeRate: 24, expected: "1h0m0s"},
+		{name: "third in queue", index: 2, mergeRate: 12, expected: "6h0m0s"},
+		{name: "rounded", index: 0, mergeRate: 7, expected: "3h25m0s"},
+	}
+	sq := getTestSQ(false, nil, nil)
+	for _, test := range tests {
+		if eta := formatTimeToMerge(sq.estimateTimeToMerge(test.index, test.mergeRate)); eta != test.expected {
+			t.Errorf("%s: expected %q but got %q", test.name, test.expected, eta)
+		}
+	}
+}
+
+func TestQueueComment(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	replies := []string{}
+	sq, obj, done := commandTestSQ(t, []*github.IssueComment{}, &replies)
+	defer done()
+	sq.QueueComment = true
+	sq.mergeRate = 24
+	sq.lastMergeTime = sq.clock.Now()
+
+	sq.Munge(obj)
+	// Already queued, so no second comment
+	sq.Munge(obj)
+
+	expected := fmt.Sprintf(queueCommentFmt, 1, "1h0m0s")
+	if len(replies) != 1 || replies[0] != expected {
+		t.Errorf("expected the comment %q but got %q", expected, replies)
+	}
+}
+
 func TestHealth(t *testing.T) {
 	sq := getTestSQ(false, nil, nil)
 	sq.updateHealth()
@@ -1350,6 +2475,24 @@ func TestHealth(t *testing.T) {
 	}
 }
 
+func TestHealthRetention(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	sq.HealthRetention = 7 * 24 * time.Hour
+	sq.updateHealth()
+	sq.updateHealth()
+	sq.updateHealth()
+	sq.healthHistory[0].Time = time.Now().AddDate(0, 0, -8)
+	sq.healthHistory[1].Time = time.Now().AddDate(0, 0, -3)
+	sq.healthHistory[2].Time = time.Now().AddDate(0, 0, -2)
+	sq.updateHealth()
+	if len(sq.healthHistory) != 3 {
+		t.Errorf("expected entries newer than a week to be kept: %v", sq.healthHistory)
+	}
+	if sq.health.TotalLoops != 3 {
+		t.Errorf("expected health to be computed over 3 loops but got %d", sq.health.TotalLoops)
+	}
+}
+
 func TestHealthSVG(t *testing.T) {
 	sq := getTestSQ(false, nil, nil)
 	e2e := sq.e2e.(*fake_e2e.FakeE2ETester)
@@ -1372,3 +2515,520 @@ func TestHealthSVG(t *testing.T) {
 		}
 	}
 }
+
+func TestMultiRepoQueues(t *testing.T) {
+	client, server, _ := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.ExtraRepos = []string{"o/r2"}
+	config.SetClient(client)
+	repos := config.Repos()
+
+	sq := getTestSQ(false, config, server)
+	sq.githubConfig = config
+
+	// The same PR number in both repos
+	primary := github_util.TestObject(repos[0], LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	other := github_util.TestObject(repos[1], LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	if sq.prKey(primary) != "1" || sq.prKey(other) != "o/r2#1" {
+		t.Fatalf("unexpected keys %q and %q", sq.prKey(primary), sq.prKey(other))
+	}
+	sq.githubE2EQueue[sq.prKey(primary)] = primary
+	sq.githubE2EQueue[sq.prKey(other)] = other
+	if queue := sq.orderedE2EQueue(); len(queue) != 2 {
+		t.Fatalf("expected both PRs to be queued but got %v", queue)
+	}
+
+	sq.githubE2ERunning = primary
+	if sq.isRunning(other) {
+		t.Errorf("PR in the extra repo looks like it is running")
+	}
+
+	if !sq.recordMergeStatus(other, submitStatus{Reason: fmt.Sprintf(noLGTMFmt, lgtmLabel)}) {
+		t.Errorf("expected the extra repo's PR to leave the queue")
+	}
+	if !sq.onQueue(primary) || sq.onQueue(other) {
+		t.Errorf("removing one repo's PR changed the other's: %v", sq.orderedE2EQueue())
+	}
+	if _, ok := sq.prStatus["1"]; ok {
+		t.Errorf("status of the extra repo's PR was recorded for the primary repo")
+	}
+	if r := sq.prStatus["o/r2#1"].Reason; r != fmt.Sprintf(noLGTMFmt, lgtmLabel) {
+		t.Errorf("expected reason %q but got %q", fmt.Sprintf(noLGTMFmt, lgtmLabel), r)
+	}
+
+	// Each repo merges into its own default branch, and a red base
+	// branch only holds the repo it is in.
+	sq.defaultBranches = map[string]string{"o/r": "master", "o/r2": "main"}
+	sq.baseBranchStates = map[repoBranch]string{
+		{repo: "o/r", branch: "master"}: "success",
+		{repo: "o/r2", branch: "main"}:  "failure",
+	}
+	sq.updateHealth()
+	if !sq.health.MergePossibleNow {
+		t.Fatalf("expected merges to be possible")
+	}
+	expected := map[string]repoHealth{
+		"o/r":  {Queued: 1, AllowedBaseBranches: []string{"master"}, MergePossibleNow: true},
+		"o/r2": {AllowedBaseBranches: []string{"main"}, RedBaseBranches: []string{"main"}},
+	}
+	if !reflect.DeepEqual(sq.health.Repos, expected) {
+		t.Errorf("expected repo health %v but got %v", expected, sq.health.Repos)
+	}
+
+	pr := ValidPR()
+	pr.Base.Ref = stringPtr("main")
+	onMain := github_util.TestObject(repos[1], LGTMApprovedIssue(), pr, Commits(), NewLGTMEvents())
+	sq.validForMergeExt(onMain, false)
+	if r := sq.prStatus["o/r2#1"].Reason; r == wrongBranch {
+		t.Errorf("PR for the default branch of o/r2 was treated as the wrong branch")
+	}
+	onMaster := github_util.TestObject(repos[1], LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	sq.validForMergeExt(onMaster, false)
+	if r := sq.prStatus["o/r2#1"].Reason; r != wrongBranch {
+		t.Errorf("expected %q for a PR into master of o/r2 but got %q", wrongBranch, r)
+	}
+}
+
+func TestE2ERetries(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name     string
+		retries  int
+		results  []bool
+		failed   bool
+		attempts int
+		infra    bool // failures are classified as infrastructure failures
+	}{
+		{
+			name:     "no retries",
+			results:  []bool{false},
+			failed:   true,
+			attempts: 1,
+		},
+		{
+			name:     "passes on retry",
+			retries:  2,
+			results:  []bool{false, true},
+			attempts: 2,
+		},
+		{
+			name:     "retries exhausted",
+			retries:  1,
+			results:  []bool{false, false},
+			failed:   true,
+			attempts: 2,
+		},
+		{
+			name:     "infrastructure failure retried",
+			results:  []bool{false, true},
+			attempts: 2,
+			infra:    true,
+		},
+		{
+			name:     "infrastructure retries exhausted",
+			results:  []bool{false},
+			failed:   true,
+			attempts: 1 + maxInfraRetries,
+			infra:    true,
+		},
+	}
+	for _, test := range tests {
+		ciStatus := SuccessStatus()
+		for i := range ciStatus.Statuses {
+			ciStatus.Statuses[i].TargetURL = stringPtr(fmt.Sprintf("https://gubernator/build/bucket/pr-logs/pull/1/%s/5/", *ciStatus.Statuses[i].Context))
+		}
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), ciStatus, nil, nil)
+		bodies := []string{}
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			c := new(github.IssueComment)
+			json.NewDecoder(r.Body).Decode(c)
+			pass := test.results[len(bodies)%len(test.results)]
+			bodies = append(bodies, *c.Body)
+			go fakeRunGithubE2ESuccess(ciStatus, pass, pass)
+			w.Write([]byte("{}"))
+		})
+		mux.HandleFunc("/repos/o/r/statuses/mysha", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.BaseWaitTime = time.Millisecond
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		sq.E2ERetries = test.retries
+		if test.infra {
+			sq.e2e.(*fake_e2e.FakeE2ETester).Categories = map[string]e2e.ResultCategory{
+				requiredReTestContext1: e2e.InfraFailure,
+				requiredReTestContext2: e2e.InfraFailure,
+			}
+		}
+
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		sq.githubE2EQueue["1"] = obj
+		if failed := sq.retestPR(obj); failed != test.failed {
+			t.Errorf("%s: expected failed=%v but got %v (%q)", test.name, test.failed, failed, sq.prStatus["1"].Reason)
+		}
+		if len(bodies) != test.attempts {
+			t.Errorf("%s: expected %d e2e runs but got %d: %v", test.name, test.attempts, len(bodies), bodies)
+		}
+		if test.failed && sq.prStatus["1"].Reason != ghE2EFailed {
+			t.Errorf("%s: expected reason %q but got %q", test.name, ghE2EFailed, sq.prStatus["1"].Reason)
+		}
+		retried := false
+		for _, status := range sq.statusHistory {
+			retried = retried || status.Reason == retryingE2E || status.Reason == retryingInfra
+		}
+		if retried != (test.attempts > 1) {
+			t.Errorf("%s: expected retrying=%v in %v", test.name, test.attempts > 1, sq.statusHistory)
+		}
+		server.Close()
+	}
+}
+
+func TestUseE2ERetry(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	sq.E2ERetries = 1
+	obj := github_util.TestObject(nil, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+
+	if remaining, ok := sq.useE2ERetry(obj); !ok || remaining != 0 {
+		t.Errorf("expected a retry with none left, got %d/%v", remaining, ok)
+	}
+	if _, ok := sq.useE2ERetry(obj); ok {
+		t.Errorf("expected the retries for the commit to be used up")
+	}
+
+	// A push gets a fresh set of retries
+	pr := ValidPR()
+	pr.Head.SHA = stringPtr("newsha")
+	obj = github_util.TestObject(nil, LGTMApprovedIssue(), pr, Commits(), NewLGTMEvents())
+	if _, ok := sq.useE2ERetry(obj); !ok {
+		t.Errorf("expected a retry after a new commit")
+	}
+}
+
+func TestAbortSupersededE2E(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	tests := []struct {
+		name    string
+		sha     string
+		state   string
+		aborted bool
+	}{
+		{
+			name: "unchanged",
+			sha:  "mysha",
+		},
+		{
+			name:    "new commit",
+			sha:     "newsha",
+			aborted: true,
+		},
+		{
+			name:    "closed",
+			sha:     "mysha",
+			state:   "closed",
+			aborted: true,
+		},
+	}
+	for _, test := range tests {
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+		// The retest is requested but never starts
+		mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		})
+		// The abort lands while the run is still setting its waiting
+		// status, which must not overwrite the status the abort sets.
+		abortOnce := sync.Once{}
+		abortResult := make(chan bool, 1)
+		var abort func()
+		mux.HandleFunc("/repos/o/r/statuses/", func(w http.ResponseWriter, r *http.Request) {
+			status := github.RepoStatus{}
+			json.NewDecoder(r.Body).Decode(&status)
+			if status.Description != nil && *status.Description == ghE2EWaitingStart {
+				abortOnce.Do(func() {
+					go abort()
+					time.Sleep(50 * time.Millisecond)
+				})
+			}
+			w.Write([]byte("{}"))
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		config.BaseWaitTime = time.Millisecond
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+		fake := &fake_e2e.FakeE2ETester{}
+		sq.e2e = fake
+
+		issue := LGTMApprovedIssue()
+		if test.state != "" {
+			issue.State = stringPtr(test.state)
+		}
+		pr := ValidPR()
+		pr.Head.SHA = stringPtr(test.sha)
+		latest := github_util.TestObject(config, issue, pr, Commits(), NewLGTMEvents())
+		abort = func() { abortResult <- sq.abortSupersededE2E(latest) }
+
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		sq.githubE2EQueue["1"] = obj
+		sq.githubE2ERunning = obj
+		finished := make(chan bool, 1)
+		go func() { finished <- sq.retestPR(obj) }()
+
+		if aborted := <-abortResult; aborted != test.aborted {
+			t.Errorf("%s: expected aborted=%v but got %v", test.name, test.aborted, aborted)
+		}
+		if !test.aborted {
+			sq.Lock()
+			close(sq.abortE2E)
+			sq.Unlock()
+			<-finished
+			server.Close()
+			continue
+		}
+
+		select {
+		case failed := <-finished:
+			if !failed {
+				t.Errorf("%s: expected the retest to report a status change", test.name)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("%s: retest kept waiting after being aborted", test.name)
+		}
+		if fake.Aborted[1] != "mysha" {
+			t.Errorf("%s: expected the e2e tester to abort mysha but got %v", test.name, fake.Aborted)
+		}
+		if sq.onQueue(obj) {
+			t.Errorf("%s: aborted PR is still queued", test.name)
+		}
+		if r := sq.prStatus["1"].Reason; r != ghE2EAborted {
+			t.Errorf("%s: expected reason %q but got %q", test.name, ghE2EAborted, r)
+		}
+		server.Close()
+	}
+}
+
+func TestCustomE2ETrigger(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	ciStatus := SuccessStatus()
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), ciStatus, nil, nil)
+	defer server.Close()
+	posted := []string{}
+	mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			c := new(github.IssueComment)
+			json.NewDecoder(r.Body).Decode(c)
+			posted = append(posted, *c.Body)
+			if strings.HasPrefix(*c.Body, "@ci-robot retest please ") {
+				go fakeRunGithubE2ESuccess(ciStatus, true, true)
+			}
+		}
+		w.Write([]byte("{}"))
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.BaseWaitTime = time.Millisecond
+	config.SetClient(client)
+	sq := getTestSQ(false, config, server)
+	sq.E2EBotName = "ci-robot"
+	sq.E2ETriggerPhrase = "retest please"
+
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	if changed := sq.retestPR(obj); changed {
+		t.Errorf("expected the custom trigger to run the tests, got reason %q", sq.prStatus["1"].Reason)
+	}
+	if len(posted) != 1 || strings.Contains(posted[0], jenkinsBotName) {
+		t.Errorf("expected one trigger for the custom bot but got %q", posted)
+	}
+}
+
+func TestMergeRateHistory(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	clock := sq.clock.(*utilclock.FakeClock)
+	sq.MergeRateRetention = 2 * time.Hour
+
+	sq.mergeRate = 24
+	sq.lastMergeTime = clock.Now()
+	for i := 0; i < 3; i++ {
+		sq.recordMergeRate()
+		clock.Step(time.Hour)
+	}
+	history := sq.getMergeRateHistory()
+	if len(history) != 3 {
+		t.Fatalf("expected 3 samples but got %v", history)
+	}
+	if history[0].Rate != 24 || !history[1].Time.Equal(history[0].Time.Add(time.Hour)) {
+		t.Errorf("unexpected samples: %v", history)
+	}
+
+	// The first sample is now 3 hours old
+	sq.recordMergeRate()
+	history = sq.getMergeRateHistory()
+	if len(history) != 3 || !history[2].Time.Equal(clock.Now()) {
+		t.Errorf("expected the oldest sample to be dropped: %v", history)
+	}
+	if history[2].Rate >= 24 {
+		t.Errorf("expected the rate to decay with no merges, got %v", history[2].Rate)
+	}
+}
+
+func TestRequeueAll(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), SuccessStatus(), nil, nil)
+	defer server.Close()
+	mux.HandleFunc("/repos/o/r/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	mux.HandleFunc("/repos/o/r/statuses/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	config := &github_util.Config{}
+	config.Org = "o"
+	config.Project = "r"
+	config.SetClient(client)
+	sq := getTestSQ(false, config, server)
+
+	obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+	running := LGTMApprovedIssue()
+	running.Number = intPtr(2)
+	runningObj := github_util.TestObject(config, running, ValidPR(), Commits(), NewLGTMEvents())
+	sq.githubE2EQueue["1"] = obj
+	sq.githubE2EQueue["2"] = runningObj
+	sq.githubE2ERunning = runningObj
+	sq.prStatus["1"] = submitStatus{Reason: fmt.Sprintf(noLGTMFmt, lgtmLabel)}
+	sq.lastPRStatus["1"] = submitStatus{Reason: fmt.Sprintf(noLGTMFmt, lgtmLabel)}
+	sq.prStatus["2"] = submitStatus{Reason: ghE2ERunning}
+
+	res := httptest.NewRecorder()
+	sq.RequeueAllHTTP(res, httptest.NewRequest("POST", "/api/requeue-all", nil))
+	result := struct{ Requeued int }{}
+	if err := json.Unmarshal(res.Body.Bytes(), &result); err != nil || result.Requeued != 1 {
+		t.Errorf("expected 1 PR to be requeued but got %q: %v", res.Body.String(), err)
+	}
+	if _, ok := sq.githubE2EQueue["1"]; ok {
+		t.Errorf("expected the PR to be taken off the queue")
+	}
+	if _, ok := sq.lastPRStatus["1"]; ok {
+		t.Errorf("expected the status of the PR to be forgotten")
+	}
+	if _, ok := sq.githubE2EQueue["2"]; !ok || sq.prStatus["2"].Reason != ghE2ERunning {
+		t.Errorf("expected the PR running e2e to be left alone but got %v", sq.prStatus["2"])
+	}
+
+	sq.Munge(obj)
+	if reason := sq.prStatus["1"].Reason; reason != ghE2EQueued {
+		t.Errorf("expected the status to be recomputed as %q but got %q", ghE2EQueued, reason)
+	}
+}
+
+func TestGithubE2EPollJitter(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	sq.githubE2EPollTime = 30 * time.Second
+	if d := sq.githubE2EPollInterval(); d != sq.githubE2EPollTime {
+		t.Errorf("expected no jitter by default but got %v", d)
+	}
+
+	sq.GithubE2EPollJitter = 0.2
+	min := 24 * time.Second
+	max := 36 * time.Second
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		d := sq.githubE2EPollInterval()
+		if d < min || d > max {
+			t.Fatalf("expected a poll interval between %v and %v but got %v", min, max, d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("expected the poll intervals to vary but got %v", seen)
+	}
+}
+
+func TestRequeueFailed(t *testing.T) {
+	sq := getTestSQ(false, nil, nil)
+	clock := sq.clock.(*utilclock.FakeClock)
+	start := clock.Now()
+	clock.Step(3 * time.Hour)
+	now := clock.Now()
+
+	sq.prStatus["1"] = submitStatus{Reason: fmt.Sprintf(ciFailureFmt, requiredReTestContext1), Since: now.Add(-10 * time.Minute)}
+	sq.prStatus["2"] = submitStatus{Reason: fmt.Sprintf(ciFailureFmt, requiredReTestContext1), Since: start}
+	sq.prStatus["3"] = submitStatus{Reason: ghE2EFailed, Since: now}
+	sq.prStatus["4"] = submitStatus{Reason: fmt.Sprintf(noLGTMFmt, lgtmLabel), Since: now}
+	// Failed last loop, not looked at yet this loop
+	sq.lastPRStatus["5"] = submitStatus{Reason: ghE2EFailed, Since: now}
+	// Failed last loop, but is fine now
+	sq.lastPRStatus["6"] = submitStatus{Reason: ghE2EFailed, Since: now}
+	sq.prStatus["6"] = submitStatus{Reason: ghE2EQueued, Since: now}
+
+	res := httptest.NewRecorder()
+	sq.RequeueFailedHTTP(res, httptest.NewRequest("POST", "/api/requeue-failed?window=1h", nil))
+	result := struct{ Requeued []string }{}
+	if err := json.Unmarshal(res.Body.Bytes(), &result); err != nil {
+		t.Fatalf("unexpected response %q: %v", res.Body.String(), err)
+	}
+	if expected := []string{"1", "3", "5"}; !reflect.DeepEqual(result.Requeued, expected) {
+		t.Errorf("expected %v to be requeued but got %v", expected, result.Requeued)
+	}
+	for _, key := range []string{"1", "3", "5"} {
+		if _, ok := sq.prStatus[key]; ok {
+			t.Errorf("expected the status of #%s to be forgotten", key)
+		}
+		if _, ok := sq.lastPRStatus[key]; ok {
+			t.Errorf("expected the last status of #%s to be forgotten", key)
+		}
+	}
+	for _, key := range []string{"2", "4", "6"} {
+		if _, ok := sq.prStatus[key]; !ok {
+			t.Errorf("expected the status of #%s to be kept", key)
+		}
+	}
+
+	res = httptest.NewRecorder()
+	sq.RequeueFailedHTTP(res, httptest.NewRequest("POST", "/api/requeue-failed?window=4h&reasons=noLGTM", nil))
+	if err := json.Unmarshal(res.Body.Bytes(), &result); err != nil || !reflect.DeepEqual(result.Requeued, []string{"4"}) {
+		t.Errorf("expected only #4 to be requeued for noLGTM but got %q: %v", res.Body.String(), err)
+	}
+
+	res = httptest.NewRecorder()
+	sq.RequeueFailedHTTP(res, httptest.NewRequest("POST", "/api/requeue-failed?reasons=bogus", nil))
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown reason to be rejected, got %d", res.Code)
+	}
+}
+
+func TestIgnoredStatusContextsDontBlockMerge(t *testing.T) {
+	github_util.SetCombinedStatusLifetime(1)
+
+	for _, ignore := range []bool{false, true} {
+		client, server, mux := github_test.InitServer(t, LGTMApprovedIssue(), ValidPR(), NewLGTMEvents(), Commits(), NoRetestFailStatus(), nil, nil)
+		mux.HandleFunc("/repos/o/r/statuses/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		})
+		config := &github_util.Config{}
+		config.Org = "o"
+		config.Project = "r"
+		if ignore {
+			config.IgnoredStatusContexts = []string{notRequiredReTestContext2}
+		}
+		config.SetClient(client)
+		sq := getTestSQ(false, config, server)
+
+		obj := github_util.TestObject(config, LGTMApprovedIssue(), ValidPR(), Commits(), NewLGTMEvents())
+		if valid := sq.validForMerge(obj); valid != ignore {
+			t.Errorf("ignore=%v: expected valid=%v but got %v (%q)", ignore, ignore, valid, sq.prStatus["1"].Reason)
+		}
+		server.Close()
+	}
+}
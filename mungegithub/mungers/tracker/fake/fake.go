@@ -0,0 +1,35 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"fmt"
+)
+
+// FakeTracker reports ticket states from a static map.
+type FakeTracker struct {
+	States map[string]string
+}
+
+// TicketState returns the state from the map, or an error if the ticket is unknown.
+func (f *FakeTracker) TicketState(id string) (string, error) {
+	state, ok := f.States[id]
+	if !ok {
+		return "", fmt.Errorf("unknown ticket %q", id)
+	}
+	return state, nil
+}
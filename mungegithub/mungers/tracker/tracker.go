@@ -0,0 +1,83 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Tracker can be queried for the state of a ticket in an external issue
+// tracker.
+type Tracker interface {
+	TicketState(id string) (string, error)
+}
+
+// ticketRE matches Jira style ticket keys, like ABC-123.
+var ticketRE = regexp.MustCompile(`\b([A-Z][A-Z0-9]+-[0-9]+)\b`)
+
+// ParseTicketID returns the first ticket ID found in the given strings, or ""
+// if there is none. Callers usually pass the PR title followed by its body.
+func ParseTicketID(texts ...string) string {
+	for _, text := range texts {
+		if match := ticketRE.FindStringSubmatch(text); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// JiraTracker queries the Jira REST API for ticket states.
+type JiraTracker struct {
+	// URL is the base URL of the Jira instance, e.g. https://jira.example.com
+	URL    string
+	Client *http.Client
+}
+
+type jiraIssue struct {
+	Fields struct {
+		Status struct {
+			Name string `json:"name"`
+		} `json:"status"`
+	} `json:"fields"`
+}
+
+// TicketState returns the name of the status the ticket is in.
+func (j *JiraTracker) TicketState(id string) (string, error) {
+	client := j.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u := fmt.Sprintf("%s/rest/api/2/issue/%s?fields=status", strings.TrimRight(j.URL, "/"), url.QueryEscape(id))
+	resp, err := client.Get(u)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("got status code %d fetching %s", resp.StatusCode, u)
+	}
+	issue := jiraIssue{}
+	if err := json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return "", err
+	}
+	return issue.Fields.Status.Name, nil
+}
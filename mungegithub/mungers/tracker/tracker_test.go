@@ -0,0 +1,57 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTicketID(t *testing.T) {
+	tests := []struct {
+		texts    []string
+		expected string
+	}{
+		{[]string{"ABC-12: do stuff"}, "ABC-12"},
+		{[]string{"do stuff", "Fixes PROJ2-7 and ABC-1"}, "PROJ2-7"},
+		{[]string{"do stuff", "nothing here"}, ""},
+		{[]string{"abc-12 is lower case"}, ""},
+	}
+	for i, test := range tests {
+		if id := ParseTicketID(test.texts...); id != test.expected {
+			t.Errorf("%d: expected %q but got %q", i, test.expected, id)
+		}
+	}
+}
+
+func TestJiraTicketState(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/rest/api/2/issue/ABC-1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"key": "ABC-1", "fields": {"status": {"name": "Ready for Merge"}}}`))
+	})
+
+	j := &JiraTracker{URL: server.URL}
+	if state, err := j.TicketState("ABC-1"); err != nil || state != "Ready for Merge" {
+		t.Errorf("unexpected state %q, err: %v", state, err)
+	}
+	if _, err := j.TicketState("ABC-2"); err == nil {
+		t.Errorf("expected an error for a missing ticket")
+	}
+}
@@ -185,6 +185,10 @@ function SQCntl(dataService, $interval, $location) {
       self.health = response.data;
       if (self.health.TotalLoops !== 0) {
         var percentStable = self.health.NumStable * 100.0 / self.health.TotalLoops;
+        if (self.health.JobWeights) {
+          // Some jobs count for more than others
+          percentStable = self.health.WeightedStable * 100.0;
+        }
         self.OverallHealth = Math.round(percentStable) + "%";
       }
       updateBuildStability(self.testResults.blockingBuilds, self.testResults.nonBlockingBuilds, self.health);
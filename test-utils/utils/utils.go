@@ -47,6 +47,9 @@ const (
 	lookUpDirectory = "directory"
 
 	successString = "SUCCESS"
+
+	// maxFinishedCacheSize bounds how many finished.json files are kept.
+	maxFinishedCacheSize = 1000
 )
 
 // Utils is a struct handling all communication with a given bucket
@@ -58,14 +61,21 @@ type Utils struct {
 
 	derefCache     map[string]string
 	derefCacheLock sync.Mutex
+
+	// A finished build's finished.json never changes, so it is only read
+	// once. finishedOrder is oldest first, for eviction.
+	finishedCache     map[string]*FinishedFile
+	finishedOrder     []string
+	finishedCacheLock sync.Mutex
 }
 
 // NewUtils returnes new Utils struct for a given bucket name and subdirectory
 func NewUtils(bucket, directory string) *Utils {
 	return &Utils{
-		bucket:     NewBucket(bucket),
-		directory:  directory,
-		derefCache: map[string]string{},
+		bucket:        NewBucket(bucket),
+		directory:     directory,
+		derefCache:    map[string]string{},
+		finishedCache: map[string]*FinishedFile{},
 	}
 }
 
@@ -79,15 +89,17 @@ func NewWithPresubmitDetection(bucket, directory, presubmitKey, presubmitDirecto
 		pullKey:       presubmitKey,
 		pullDirectory: presubmitDirectory,
 		derefCache:    map[string]string{},
+		finishedCache: map[string]*FinishedFile{},
 	}
 }
 
 // NewTestUtils returnes new Utils struct for a given url pointing to a file server.
 func NewTestUtils(bucket, directory, url string) *Utils {
 	return &Utils{
-		bucket:     NewTestBucket(bucket, url),
-		directory:  directory,
-		derefCache: map[string]string{},
+		bucket:        NewTestBucket(bucket, url),
+		directory:     directory,
+		derefCache:    map[string]string{},
+		finishedCache: map[string]*FinishedFile{},
 	}
 }
 
@@ -230,37 +242,110 @@ func (u *Utils) CheckStartedStatus(job string, buildNumber int) (*StartedFile, e
 }
 
 // FinishedFile is a type in which we store test result in GCS as finished.json
+// Builds from newer tooling also record what was tested and whether it
+// passed. Those fields are empty when an older build didn't write them.
 type FinishedFile struct {
 	Result    string `json:"result"`
 	Timestamp uint64 `json:"timestamp"`
+
+	JobVersion string                 `json:"job-version,omitempty"`
+	Revision   string                 `json:"revision,omitempty"`
+	Passed     *bool                  `json:"passed,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
 }
 
-// CheckFinishedStatus reads the finished.json file for a given job and build number.
-// It returns true if the result stored there is success, and false otherwise.
-func (u *Utils) CheckFinishedStatus(job string, buildNumber int) (bool, error) {
+// Success returns true if the build passed. "passed" is used if it was
+// written, otherwise the result must be SUCCESS.
+func (f *FinishedFile) Success() bool {
+	if f.Passed != nil {
+		return *f.Passed
+	}
+	return f.Result == successString
+}
+
+// Version returns the version of the code the build tested, which some
+// builds only record in the metadata. It is "" if the build didn't say.
+func (f *FinishedFile) Version() string {
+	if f.JobVersion != "" {
+		return f.JobVersion
+	}
+	if version, ok := f.Metadata["job-version"].(string); ok {
+		return version
+	}
+	return ""
+}
+
+// cachedFinishedFile returns a copy of the cached finished.json for the
+// build, if there is one.
+func (u *Utils) cachedFinishedFile(job string, buildNumber int) (*FinishedFile, bool) {
+	u.finishedCacheLock.Lock()
+	defer u.finishedCacheLock.Unlock()
+	cached, ok := u.finishedCache[fmt.Sprintf("%v/%v", job, buildNumber)]
+	if !ok {
+		return nil, false
+	}
+	result := *cached
+	return &result, true
+}
+
+// cacheFinishedFile remembers the build's finished.json, forgetting the
+// oldest one once there are maxFinishedCacheSize.
+func (u *Utils) cacheFinishedFile(job string, buildNumber int, finished *FinishedFile) {
+	u.finishedCacheLock.Lock()
+	defer u.finishedCacheLock.Unlock()
+	cacheKey := fmt.Sprintf("%v/%v", job, buildNumber)
+	if _, ok := u.finishedCache[cacheKey]; ok {
+		return
+	}
+	if len(u.finishedOrder) >= maxFinishedCacheSize {
+		delete(u.finishedCache, u.finishedOrder[0])
+		u.finishedOrder = u.finishedOrder[1:]
+	}
+	result := *finished
+	u.finishedCache[cacheKey] = &result
+	u.finishedOrder = append(u.finishedOrder, cacheKey)
+}
+
+// GetFinishedFile reads the finished.json file for a given job and build number.
+// Results are cached, since a build's finished.json doesn't change once written.
+func (u *Utils) GetFinishedFile(job string, buildNumber int) (*FinishedFile, error) {
+	if cached, ok := u.cachedFinishedFile(job, buildNumber); ok {
+		return cached, nil
+	}
 	response, err := u.GetFileFromJenkinsGoogleBucket(job, buildNumber, "finished.json")
 	if err != nil {
 		glog.Errorf("Error while getting data for %v/%v/%v: %v", job, buildNumber, "finished.json", err)
-		return false, err
+		return nil, err
 	}
 
 	defer response.Body.Close()
 	if response.StatusCode != http.StatusOK {
 		glog.Errorf("Got a non-success response %v while reading data for %v/%v/%v", response.StatusCode, job, buildNumber, "finished.json")
-		return false, fmt.Errorf("got status code %v", response.StatusCode)
+		return nil, fmt.Errorf("got status code %v", response.StatusCode)
 	}
-	result := FinishedFile{}
+	result := &FinishedFile{}
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		glog.Errorf("Failed to read the response for %v/%v/%v: %v", job, buildNumber, "finished.json", err)
-		return false, err
+		return nil, err
 	}
-	err = json.Unmarshal(body, &result)
+	err = json.Unmarshal(body, result)
 	if err != nil {
 		glog.Errorf("Failed to unmarshal %v: %v", string(body), err)
+		return nil, err
+	}
+	u.cacheFinishedFile(job, buildNumber, result)
+	return result, nil
+}
+
+// CheckFinishedStatus reads the finished.json file for a given job and build number.
+// It returns true if the result stored there is success, and false otherwise.
+func (u *Utils) CheckFinishedStatus(job string, buildNumber int) (bool, error) {
+	result, err := u.GetFinishedFile(job, buildNumber)
+	if err != nil {
 		return false, err
 	}
-	return result.Result == successString, nil
+	return result.Success(), nil
 }
 
 // ListFilesInBuild takes build info and list all file names with matching prefix
@@ -74,3 +74,114 @@ func TestGetPathToJenkinsGoogleBucket(t *testing.T) {
 		}
 	}
 }
+
+func TestGetFinishedFile(t *testing.T) {
+	table := []struct {
+		name     string
+		body     string
+		success  bool
+		version  string
+		revision string
+	}{
+		{
+			name:    "old success",
+			body:    `{"result": "SUCCESS", "timestamp": 1234}`,
+			success: true,
+		},
+		{
+			name: "old failure",
+			body: `{"result": "FAILURE", "timestamp": 1234}`,
+		},
+		{
+			name:     "new",
+			body:     `{"result": "SUCCESS", "timestamp": 1234, "passed": true, "job-version": "v1.5.0-alpha.1.23+abc", "revision": "abc"}`,
+			success:  true,
+			version:  "v1.5.0-alpha.1.23+abc",
+			revision: "abc",
+		},
+		{
+			name:    "passed wins over result",
+			body:    `{"result": "SUCCESS", "timestamp": 1234, "passed": false}`,
+			success: false,
+		},
+		{
+			name:    "version only in metadata",
+			body:    `{"result": "SUCCESS", "timestamp": 1234, "metadata": {"job-version": "v1.4.6"}}`,
+			success: true,
+			version: "v1.4.6",
+		},
+	}
+	for _, tt := range table {
+		m := http.NewServeMux()
+		m.HandleFunc("/bucket/logs/job/10/finished.json", func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, tt.body)
+		})
+		server := httptest.NewServer(m)
+
+		u := NewTestUtils("bucket", "logs", server.URL)
+		finished, err := u.GetFinishedFile("job", 10)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if finished.Timestamp != 1234 {
+			t.Errorf("%s: expected timestamp 1234 but got %v", tt.name, finished.Timestamp)
+		}
+		if finished.Success() != tt.success || finished.Version() != tt.version || finished.Revision != tt.revision {
+			t.Errorf("%s: expected success=%v version=%q revision=%q but got %v %q %q", tt.name, tt.success, tt.version, tt.revision, finished.Success(), finished.Version(), finished.Revision)
+		}
+		if status, err := u.CheckFinishedStatus("job", 10); err != nil || status != tt.success {
+			t.Errorf("%s: expected CheckFinishedStatus to return %v but got %v, %v", tt.name, tt.success, status, err)
+		}
+		server.Close()
+	}
+}
+
+func TestGetFinishedFileCached(t *testing.T) {
+	requests := 0
+	m := http.NewServeMux()
+	m.HandleFunc("/bucket/logs/job/10/finished.json", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"result": "SUCCESS", "timestamp": 1234}`)
+	})
+	m.HandleFunc("/bucket/logs/job/11/finished.json", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		http.NotFound(w, req)
+	})
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	u := NewTestUtils("bucket", "logs", server.URL)
+	for i := 0; i < 2; i++ {
+		finished, err := u.GetFinishedFile("job", 10)
+		if err != nil || !finished.Success() {
+			t.Fatalf("expected a successful build but got %v, %v", finished, err)
+		}
+		// Callers can't change what is cached
+		finished.Result = "FAILURE"
+	}
+	if requests != 1 {
+		t.Errorf("expected the second read to be cached but got %d requests", requests)
+	}
+
+	// A build which hasn't finished yet must be read again.
+	if _, err := u.GetFinishedFile("job", 11); err == nil {
+		t.Errorf("expected an error for a build which hasn't finished")
+	}
+	requests = 0
+	if _, err := u.GetFinishedFile("job", 11); err == nil {
+		t.Errorf("expected an error for a build which hasn't finished")
+	}
+	if requests == 0 {
+		t.Errorf("expected missing files not to be cached")
+	}
+
+	for i := 0; i < maxFinishedCacheSize; i++ {
+		u.cacheFinishedFile("other", i, &FinishedFile{})
+	}
+	if _, ok := u.cachedFinishedFile("job", 10); ok {
+		t.Errorf("expected the oldest build to be evicted")
+	}
+	if len(u.finishedCache) != maxFinishedCacheSize {
+		t.Errorf("expected %d cached builds but got %d", maxFinishedCacheSize, len(u.finishedCache))
+	}
+}